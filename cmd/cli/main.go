@@ -2,18 +2,27 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/beacon"
 	"github.com/koushamad/election-system/pkg/blockchain"
 	"github.com/koushamad/election-system/pkg/crypto"
 	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/keystore"
 	"github.com/koushamad/election-system/pkg/network"
+	"github.com/koushamad/election-system/pkg/registrar"
+	"github.com/koushamad/election-system/pkg/signer"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"golang.org/x/term"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"net/http"
 	"os"
 	"strings"
@@ -25,6 +34,15 @@ func main() {
 	nodeCmd := flag.NewFlagSet("node", flag.ExitOnError)
 	nodePort := nodeCmd.Int("port", 5000, "Port number for the node")
 	nodeValidator := nodeCmd.Bool("validator", false, "Run as a validator node")
+	nodeChainID := nodeCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier transactions must be bound to")
+	defaultParams := blockchain.DefaultConsensusParams()
+	nodeMaxBlockBytes := nodeCmd.Int("max-block-bytes", defaultParams.MaxBlockBytes, "Maximum serialized size of a block's transactions")
+	nodeKeystore := nodeCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	nodeKey := nodeCmd.String("key", "node", "Name of the node's identity key in the keystore, created if missing")
+	nodeCommitKey := nodeCmd.String("commit-key", "", "Name of the validator's BLS key signing finality commits in the keystore, created if missing")
+	nodeSigner := nodeCmd.String("signer", "", "Address of a signing daemon holding the validator key instead, e.g. unix:signer.sock")
+	nodeValidators := nodeCmd.String("validators", "", "Path of a JSON list of the validator set's keys and proofs of possession")
+	nodeMaxBlockTxs := nodeCmd.Int("max-block-txs", defaultParams.MaxBlockTxs, "Maximum number of transactions per block")
 
 	createElectionCmd := flag.NewFlagSet("create-election", flag.ExitOnError)
 	electionName := createElectionCmd.String("name", "", "Election name")
@@ -32,50 +50,362 @@ func main() {
 	startTime := createElectionCmd.String("start", "", "Start time (YYYY-MM-DD HH:MM)")
 	endTime := createElectionCmd.String("end", "", "End time (YYYY-MM-DD HH:MM)")
 	nodeAddr := createElectionCmd.String("node", "localhost:5000", "Node address to submit transaction")
+	electionKeystore := createElectionCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore to save the election key to")
+	electionKeyName := createElectionCmd.String("key", "", "Name to save the election key under, default the election ID")
+	electionSender := createElectionCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing")
+	electionRules := createElectionCmd.String("rules", smartcontracts.DefaultRuleModule, "Rule module applied to ballots and tallies")
+	electionRuleParams := createElectionCmd.String("rule-params", "", "JSON parameters of the rule module")
+	electionTallyScript := createElectionCmd.String("tally-script", "", "Path of a tally script deciding the outcome")
+	electionChainID := createElectionCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	electionBallotType := createElectionCmd.String("ballot-type", string(election.BallotPlurality), "Ballot type: plurality, ranked, approval or score")
+	electionMaxScore := createElectionCmd.Int("max-score", 0, "Highest score per candidate of score elections")
+	electionMinSelections := createElectionCmd.Int("min-selections", 0, "Lowest number of approvals or total score per ballot")
+	electionMaxSelections := createElectionCmd.Int("max-selections", 0, "Highest number of approvals or total score per ballot, 0 for no limit")
+	electionContests := createElectionCmd.String("contests", "", "Path of a JSON file with the contests, styles and voter_styles of a multi-contest election")
+	electionVoterWeights := createElectionCmd.String("voter-weights", "", "Path of a JSON file mapping voter IDs to their voting weight")
+	electionVoterCredentials := createElectionCmd.String("voter-credentials", "", "Path of a JSON file mapping voter IDs to their base64 credential keys")
+	electionRegistrarKey := createElectionCmd.String("registrar-key", "", "Base64 BLS key of the registrar issuing anonymous voting tokens")
+	electionVoterRing := createElectionCmd.String("voter-ring", "", "Path of a JSON list of the base64 keys voters sign anonymous ballots with")
+	electionWriteIn := createElectionCmd.Bool("write-in", false, "Add a write-in option; requires approval or score ballots")
+	electionMixServers := createElectionCmd.String("mix-servers", "", "Comma-separated mix server IDs shuffling ranked or write-in ballots before decryption")
+	electionOfficials := createElectionCmd.String("officials", "", "Path of a JSON list of the officials certifying results, with their BLS keys and proofs of possession")
+	electionQuorum := createElectionCmd.Int("quorum", 0, "Number of officials whose signatures certify the results, default all")
+	electionTrustedTally := createElectionCmd.Bool("trusted-tally", false, "Accept results signed by the officials without decryption proofs; required by unmixed ranked and sealed write-in elections")
+	electionBeaconKey := createElectionCmd.String("beacon-key", "", "Base64 BLS key of the randomness beacon to time-lock the election key to until voting closes")
+	electionSeats := createElectionCmd.Int("seats", 1, "Number of candidates to elect; more than one requires ranked ballots")
+	electionTieBreak := createElectionCmd.String("tie-break", string(election.DefaultTieBreak), "Elimination tie-break of ranked elections: previous-rounds, lot or candidate-order")
 
 	voteCmd := flag.NewFlagSet("vote", flag.ExitOnError)
 	voteElectionID := voteCmd.String("election", "", "Election ID")
-	voteCandidate := voteCmd.String("candidate", "", "Candidate name")
+	voteCandidate := voteCmd.String("candidate", "", "Candidate name; comma-separated ranking, approvals or name=score pairs for other ballot types")
+	voteWriteIn := voteCmd.String("write-in", "", "Name to write in; select the Write-in option with --candidate for it to count")
 	voteNodeAddr := voteCmd.String("node", "localhost:5000", "Node address to submit vote")
+	voteChainID := voteCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	voteCredential := voteCmd.String("credential", "", "Path of a voting token from get-credential to sign the ballot with")
+	voteRingKey := voteCmd.String("ring-key", "", "Path of the hex secret of your voter ring key to sign the ballot with")
+	voteVoterID := voteCmd.String("voter-id", "", "Your voter ID in the election's credential registry")
+	voteCredentialKey := voteCmd.String("credential-key", "", "Name of the elgamal key in the keystore registered as the credential of --voter-id")
+	voteAudit := voteCmd.Bool("audit", false, "Show the ballot tracker and ask whether to cast the ballot or challenge it")
+	voteKeystore := voteCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	voteSender := voteCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing; anonymous ballots are signed with a one-time key")
+
+	registrarCmd := flag.NewFlagSet("registrar", flag.ExitOnError)
+	registrarPort := registrarCmd.Int("port", 6000, "Port number for the registrar")
+	registrarKeystore := registrarCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	registrarKeyName := registrarCmd.String("key", "registrar", "Name of the registrar signing key in the keystore, created if missing")
+	registrarAccessCodes := registrarCmd.String("access-codes", "", "Path of a JSON file mapping voter IDs to their access codes")
+
+	credentialCmd := flag.NewFlagSet("get-credential", flag.ExitOnError)
+	credentialElectionID := credentialCmd.String("election", "", "Election ID")
+	credentialVoterID := credentialCmd.String("voter-id", "", "Voter ID registered with the registrar")
+	credentialAccessCode := credentialCmd.String("access-code", "", "Access code authenticating the voter")
+	credentialRegistrar := credentialCmd.String("registrar", "localhost:6000", "Registrar address")
+	credentialNodeAddr := credentialCmd.String("node", "localhost:5000", "Node address to fetch the election from")
+	credentialOut := credentialCmd.String("out", "credential.json", "Path to save the voting token to")
+
+	auditCmd := flag.NewFlagSet("audit", flag.ExitOnError)
+	auditElectionID := auditCmd.String("election", "", "Election ID")
+	auditTracker := auditCmd.String("tracker", "", "Ballot tracker to look up")
+	auditNodeAddr := auditCmd.String("node", "localhost:5000", "Node address to fetch the audit from")
+
+	beaconCmd := flag.NewFlagSet("beacon", flag.ExitOnError)
+	beaconPort := beaconCmd.Int("port", 7000, "Port number for the beacon")
+	beaconKeystore := beaconCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	beaconKeyName := beaconCmd.String("key", "beacon", "Name of the beacon signing key in the keystore, created if missing")
+
+	releaseKeyCmd := flag.NewFlagSet("release-key", flag.ExitOnError)
+	releaseElectionID := releaseKeyCmd.String("election", "", "Election ID")
+	releaseBeacon := releaseKeyCmd.String("beacon", "localhost:7000", "Beacon address")
+	releaseNodeAddr := releaseKeyCmd.String("node", "localhost:5000", "Node address to submit the release to")
+	releaseChainID := releaseKeyCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	releaseKeystore := releaseKeyCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	releaseSender := releaseKeyCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing")
+
+	proposeCmd := flag.NewFlagSet("propose-results", flag.ExitOnError)
+	proposeElectionID := proposeCmd.String("election", "", "Election ID")
+	proposeKey := proposeCmd.String("key", "", "Name of the election key in the keystore, default the election ID; unused once a time-locked key is released")
+	proposeNodeAddr := proposeCmd.String("node", "localhost:5000", "Node address to fetch the election from and submit the results to")
+	proposeChainID := proposeCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	proposeKeystore := proposeCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	proposeSender := proposeCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing")
+
+	certifyCmd := flag.NewFlagSet("certify", flag.ExitOnError)
+	certifyElectionID := certifyCmd.String("election", "", "Election ID")
+	certifyOfficial := certifyCmd.String("official", "", "Your official ID in the election")
+	certifyKey := certifyCmd.String("key", "", "Name of your BLS official key in the keystore, default the official ID")
+	certifyNodeAddr := certifyCmd.String("node", "localhost:5000", "Node address to fetch the results from and submit the signature to")
+	certifyChainID := certifyCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	certifyKeystore := certifyCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	certifySender := certifyCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing")
+
+	tallyCmd := flag.NewFlagSet("tally", flag.ExitOnError)
+	tallyElectionID := tallyCmd.String("election", "", "Election ID")
+	tallyFile := tallyCmd.String("tally", "", "Path of the JSON tally with the results, rankings, contests or write_ins; your signature is added to it")
+	tallyOfficial := tallyCmd.String("official", "", "Your official ID in the election")
+	tallyKey := tallyCmd.String("key", "", "Name of your BLS official key in the keystore, default the official ID")
+	tallyNodeAddr := tallyCmd.String("node", "localhost:5000", "Node address to fetch the election from and submit the tally to")
+	tallyChainID := tallyCmd.String("chain-id", blockchain.DefaultChainID, "Network identifier to bind the transaction to")
+	tallyKeystore := tallyCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	tallySender := tallyCmd.String("sender", defaultSender, "Name of the BLS key in the keystore signing the transaction, created if missing")
+
+	signerCmd := flag.NewFlagSet("signer", flag.ExitOnError)
+	signerListen := signerCmd.String("listen", "unix:signer.sock", "Unix socket, prefixed with unix:, to serve nodes on")
+	signerKeystore := signerCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	signerKeyName := signerCmd.String("key", "validator", "Name of the validator key in the keystore, created if missing")
+	signerState := signerCmd.String("state", "signer-state.json", "Path of the record of signed heights guarding against double signs")
+
+	keysCmd := flag.NewFlagSet("keys", flag.ExitOnError)
+	keysKeystore := keysCmd.String("keystore", defaultKeystore, "Directory of the encrypted keystore")
+	keysName := keysCmd.String("name", "", "Key name")
+	keysKind := keysCmd.String("type", string(keystore.KindElGamal), "Key type: elgamal (node and election keys) or bls (validator, registrar, beacon and official keys)")
+	keysFile := keysCmd.String("file", "", "Path of the hex private key to import, or to export to")
 
 	// Parse command
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'node', 'create-election', or 'vote' subcommands")
+		fmt.Println("Expected 'node', 'signer', 'keys', 'registrar', 'beacon', 'create-election', 'get-credential', 'vote', 'audit', 'release-key', 'propose-results', 'tally' or 'certify' subcommands")
 		os.Exit(1)
 	}
 
 	switch os.Args[1] {
 	case "node":
 		nodeCmd.Parse(os.Args[2:])
-		startNode(*nodePort, *nodeValidator)
+		params := blockchain.ConsensusParams{
+			MaxBlockBytes: *nodeMaxBlockBytes,
+			MaxBlockTxs:   *nodeMaxBlockTxs,
+		}
+		if *nodeValidators != "" {
+			src, err := os.ReadFile(*nodeValidators)
+			if err != nil {
+				fmt.Printf("Failed to read validator set: %v\n", err)
+				os.Exit(1)
+			}
+			if err := json.Unmarshal(src, &params.Validators); err != nil {
+				fmt.Printf("Invalid validator set file: %v\n", err)
+				os.Exit(1)
+			}
+			if _, err := params.ValidatorKeys(); err != nil {
+				fmt.Printf("Invalid validator set: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *nodeCommitKey != "" && *nodeSigner != "" {
+			fmt.Println("Use either --commit-key or --signer")
+			os.Exit(1)
+		}
+		startNode(*nodePort, *nodeValidator, *nodeChainID, keystore.NewKeystore(*nodeKeystore), *nodeKey, *nodeCommitKey, *nodeSigner, params)
+	case "signer":
+		signerCmd.Parse(os.Args[2:])
+		startSigner(*signerListen, keystore.NewKeystore(*signerKeystore), *signerKeyName, *signerState)
+	case "keys":
+		if len(os.Args) < 3 {
+			fmt.Println("Expected 'new', 'list', 'import' or 'export' after 'keys'")
+			os.Exit(1)
+		}
+		keysCmd.Parse(os.Args[3:])
+		manageKeys(os.Args[2], keystore.NewKeystore(*keysKeystore), *keysName, keystore.KeyKind(*keysKind), *keysFile)
+	case "registrar":
+		registrarCmd.Parse(os.Args[2:])
+		if *registrarAccessCodes == "" {
+			fmt.Println("The --access-codes flag is required")
+			os.Exit(1)
+		}
+		startRegistrar(*registrarPort, keystore.NewKeystore(*registrarKeystore), *registrarKeyName, *registrarAccessCodes)
+	case "beacon":
+		beaconCmd.Parse(os.Args[2:])
+		startBeacon(*beaconPort, keystore.NewKeystore(*beaconKeystore), *beaconKeyName)
 	case "create-election":
 		createElectionCmd.Parse(os.Args[2:])
-		if *electionName == "" || *candidatesStr == "" || *startTime == "" || *endTime == "" {
-			fmt.Println("All flags are required: --name, --candidates, --start, --end")
+		if *electionName == "" || (*candidatesStr == "") == (*electionContests == "") || *startTime == "" || *endTime == "" {
+			fmt.Println("All flags are required: --name, --candidates or --contests, --start, --end")
 			os.Exit(1)
 		}
-		createElection(*electionName, *candidatesStr, *startTime, *endTime, *nodeAddr)
+		rules := election.RuleConfig{Module: *electionRules, Params: json.RawMessage(*electionRuleParams)}
+		if *electionTallyScript != "" {
+			src, err := os.ReadFile(*electionTallyScript)
+			if err != nil {
+				fmt.Printf("Failed to read tally script: %v\n", err)
+				os.Exit(1)
+			}
+			rules.Script = string(src)
+		}
+		options := election.Election{
+			BallotType:    election.BallotType(*electionBallotType),
+			TieBreak:      election.TieBreak(*electionTieBreak),
+			Seats:         *electionSeats,
+			MaxScore:      *electionMaxScore,
+			MinSelections: *electionMinSelections,
+			MaxSelections: *electionMaxSelections,
+			WriteIn:       *electionWriteIn,
+		}
+		if *electionContests != "" {
+			src, err := os.ReadFile(*electionContests)
+			if err != nil {
+				fmt.Printf("Failed to read contests: %v\n", err)
+				os.Exit(1)
+			}
+			options = election.Election{BallotType: election.BallotMultiContest}
+			if err := json.Unmarshal(src, &options); err != nil {
+				fmt.Printf("Invalid contests file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		options.TrustedTally = *electionTrustedTally
+		if *electionVoterWeights != "" {
+			src, err := os.ReadFile(*electionVoterWeights)
+			if err != nil {
+				fmt.Printf("Failed to read voter weights: %v\n", err)
+				os.Exit(1)
+			}
+			if err := json.Unmarshal(src, &options.VoterWeights); err != nil {
+				fmt.Printf("Invalid voter weights file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *electionVoterCredentials != "" {
+			src, err := os.ReadFile(*electionVoterCredentials)
+			if err != nil {
+				fmt.Printf("Failed to read voter credentials: %v\n", err)
+				os.Exit(1)
+			}
+			if err := json.Unmarshal(src, &options.VoterCredentials); err != nil {
+				fmt.Printf("Invalid voter credentials file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *electionVoterRing != "" {
+			src, err := os.ReadFile(*electionVoterRing)
+			if err != nil {
+				fmt.Printf("Failed to read voter ring: %v\n", err)
+				os.Exit(1)
+			}
+			if err := json.Unmarshal(src, &options.VoterRing); err != nil {
+				fmt.Printf("Invalid voter ring file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *electionRegistrarKey != "" {
+			key, err := base64.StdEncoding.DecodeString(*electionRegistrarKey)
+			if err != nil {
+				fmt.Printf("Invalid registrar key: %v\n", err)
+				os.Exit(1)
+			}
+			options.RegistrarKey = key
+		}
+		if *electionMixServers != "" {
+			options.Mix = &election.MixConfig{Servers: strings.Split(*electionMixServers, ",")}
+		}
+		if *electionOfficials != "" {
+			src, err := os.ReadFile(*electionOfficials)
+			if err != nil {
+				fmt.Printf("Failed to read officials: %v\n", err)
+				os.Exit(1)
+			}
+			options.Certification = &election.CertificationConfig{Quorum: *electionQuorum}
+			if err := json.Unmarshal(src, &options.Certification.Officials); err != nil {
+				fmt.Printf("Invalid officials file: %v\n", err)
+				os.Exit(1)
+			}
+			if options.Certification.Quorum == 0 {
+				options.Certification.Quorum = len(options.Certification.Officials)
+			}
+		}
+		var beaconKey *bn256.G2
+		if *electionBeaconKey != "" {
+			data, err := base64.StdEncoding.DecodeString(*electionBeaconKey)
+			if err == nil {
+				beaconKey, err = crypto.UnmarshalG2(data)
+			}
+			if err != nil {
+				fmt.Printf("Invalid beacon key: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		createElection(*electionName, *candidatesStr, *startTime, *endTime, *nodeAddr, *electionChainID, rules, options, beaconKey,
+			keystore.NewKeystore(*electionKeystore), *electionKeyName, *electionSender)
+	case "get-credential":
+		credentialCmd.Parse(os.Args[2:])
+		if *credentialElectionID == "" || *credentialVoterID == "" || *credentialAccessCode == "" {
+			fmt.Println("All flags are required: --election, --voter-id, --access-code")
+			os.Exit(1)
+		}
+		getCredential(*credentialElectionID, *credentialVoterID, *credentialAccessCode, *credentialRegistrar, *credentialNodeAddr, *credentialOut)
 	case "vote":
 		voteCmd.Parse(os.Args[2:])
 		if *voteElectionID == "" || *voteCandidate == "" {
 			fmt.Println("All flags are required: --election, --candidate")
 			os.Exit(1)
 		}
-		castVote(*voteElectionID, *voteCandidate, *voteNodeAddr)
+		castVote(*voteElectionID, *voteCandidate, *voteWriteIn, *voteCredential, *voteRingKey, *voteVoterID, *voteCredentialKey, *voteNodeAddr, *voteChainID, *voteAudit,
+			keystore.NewKeystore(*voteKeystore), *voteSender)
+	case "audit":
+		auditCmd.Parse(os.Args[2:])
+		if *auditElectionID == "" {
+			fmt.Println("The --election flag is required")
+			os.Exit(1)
+		}
+		auditElection(*auditElectionID, *auditTracker, *auditNodeAddr)
+	case "release-key":
+		releaseKeyCmd.Parse(os.Args[2:])
+		if *releaseElectionID == "" {
+			fmt.Println("The --election flag is required")
+			os.Exit(1)
+		}
+		releaseKey(*releaseElectionID, *releaseBeacon, *releaseNodeAddr, *releaseChainID, keystore.NewKeystore(*releaseKeystore), *releaseSender)
+	case "propose-results":
+		proposeCmd.Parse(os.Args[2:])
+		if *proposeElectionID == "" {
+			fmt.Println("The --election flag is required")
+			os.Exit(1)
+		}
+		proposeResults(*proposeElectionID, *proposeNodeAddr, *proposeChainID, keystore.NewKeystore(*proposeKeystore), *proposeKey, *proposeSender)
+	case "tally":
+		tallyCmd.Parse(os.Args[2:])
+		if *tallyElectionID == "" || *tallyFile == "" || *tallyOfficial == "" {
+			fmt.Println("All flags are required: --election, --tally, --official")
+			os.Exit(1)
+		}
+		signTally(*tallyElectionID, *tallyFile, *tallyOfficial, *tallyNodeAddr, *tallyChainID, keystore.NewKeystore(*tallyKeystore), *tallyKey, *tallySender)
+	case "certify":
+		certifyCmd.Parse(os.Args[2:])
+		if *certifyElectionID == "" || *certifyOfficial == "" {
+			fmt.Println("All flags are required: --election, --official")
+			os.Exit(1)
+		}
+		certifyResults(*certifyElectionID, *certifyOfficial, *certifyNodeAddr, *certifyChainID, keystore.NewKeystore(*certifyKeystore), *certifyKey, *certifySender)
 	default:
-		fmt.Println("Expected 'node', 'create-election', or 'vote' subcommands")
+		fmt.Println("Expected 'node', 'signer', 'keys', 'registrar', 'beacon', 'create-election', 'get-credential', 'vote', 'audit', 'release-key', 'propose-results', 'tally' or 'certify' subcommands")
 		os.Exit(1)
 	}
 }
 
-func startNode(port int, isValidator bool) {
-	// Generate node keys
-	keyPair := crypto.GenerateKeys()
+func startNode(port int, isValidator bool, chainID string, ks *keystore.Keystore, keyName, commitKeyName, signerAddr string, params blockchain.ConsensusParams) {
+	// Load the node identity, kept across restarts
+	keyPair := loadElGamalKey(ks, keyName)
 
 	// Initialize node
 	node := blockchain.NewNode()
 	node.IsValidator = isValidator
+	node.ChainID = chainID
+	node.Params = params
+	node.Executor = smartcontracts.NewRuntime()
 	node.Address = fmt.Sprintf("%x", keyPair.PublicKey.Marshal()[:8]) // Use first 8 bytes of public key as address
+	switch {
+	case commitKeyName != "":
+		node.Signer = crypto.NewLocalSigner(loadBLSKey(ks, commitKeyName))
+	case signerAddr != "":
+		remote, err := signer.Dial(signerAddr)
+		if err != nil {
+			log.Fatalf("Failed to connect to signer: %v", err)
+		}
+		node.Signer = remote
+	}
+	if node.Signer != nil {
+		entry, _ := json.Marshal(blockchain.ValidatorKey{
+			PublicKey:  crypto.MarshalG2(node.Signer.PublicKey()),
+			Possession: node.Signer.Possession().Marshal(),
+		})
+		fmt.Printf("Validator set entry of this node: %s\n", entry)
+	}
 
 	// Start server
 	server := network.NewServer(node, port)
@@ -84,11 +414,18 @@ func startNode(port int, isValidator bool) {
 	log.Fatal(server.Start())
 }
 
-func createElection(name, candidatesStr, startTimeStr, endTimeStr, nodeAddr string) {
-	// Parse candidates
-	candidates := strings.Split(candidatesStr, ",")
-	if len(candidates) < 2 {
-		fmt.Println("At least two candidates are required")
+func createElection(name, candidatesStr, startTimeStr, endTimeStr, nodeAddr, chainID string, rules election.RuleConfig, options election.Election, beaconKey *bn256.G2, ks *keystore.Keystore, keyName, senderName string) {
+	// Parse candidates; multi-contest elections list them per contest
+	var candidates []string
+	if !options.IsMultiContest() {
+		candidates = strings.Split(candidatesStr, ",")
+		if len(candidates) < 2 {
+			fmt.Println("At least two candidates are required")
+			os.Exit(1)
+		}
+	}
+	if err := options.ValidateTally(); err != nil {
+		fmt.Printf("Invalid election: %v\n", err)
 		os.Exit(1)
 	}
 
@@ -106,7 +443,11 @@ func createElection(name, candidatesStr, startTimeStr, endTimeStr, nodeAddr stri
 	}
 
 	// Generate election keys
-	electionKeys := crypto.GenerateKeys()
+	electionKeys, err := crypto.GenerateKeys()
+	if err != nil {
+		fmt.Printf("Failed to generate election keys: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Create election object
 	electionID := fmt.Sprintf("election-%x", time.Now().Unix())
@@ -118,50 +459,724 @@ func createElection(name, candidatesStr, startTimeStr, endTimeStr, nodeAddr stri
 		}
 	}
 
-	newElection := election.Election{
-		ID:         electionID,
-		Name:       name,
-		Candidates: electionCandidates,
-		StartTime:  startTime,
-		EndTime:    endTime,
-		PublicKey:  electionKeys.PublicKey,
+	// options carries the ballot type and its settings
+	newElection := options
+	newElection.ID = electionID
+	newElection.Name = name
+	newElection.Candidates = electionCandidates
+	if newElection.WriteIn {
+		newElection.Candidates = append(newElection.Candidates, election.WriteInCandidate())
+	}
+	newElection.StartTime = startTime
+	newElection.EndTime = endTime
+	newElection.PublicKey = electionKeys.PublicKey
+	newElection.Rules = rules
+	newElection.RulesHash = rules.Hash()
+	if newElection.BallotType != election.BallotRanked {
+		newElection.TieBreak = ""
+	}
+	if beaconKey != nil {
+		if err := newElection.LockKey(electionKeys.PrivateKey, beaconKey); err != nil {
+			fmt.Printf("Failed to time-lock election key: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		// Save the key before the election exists, so it cannot be lost
+		if keyName == "" {
+			keyName = electionID
+		}
+		storeKey(ks, keyName, keystore.KindElGamal, electionKeys.PrivateKey)
+	}
+
+	// Submit the transaction signed by the creator
+	sender := loadBLSKey(ks, senderName)
+	if err := submitTransaction(blockchain.TxCreateElection, newElection, nodeAddr, chainID, sender); err != nil {
+		fmt.Printf("Failed to create election: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Election created successfully with ID: %s\n", electionID)
+	if newElection.TimeLock != nil {
+		fmt.Printf("The election key is time-locked to beacon round %d; release it with release-key once voting closes\n", newElection.TimeLock.Round)
+		return
 	}
+	fmt.Printf("Election key saved to keystore %s as %s\n", ks.Dir, keyName)
+}
 
-	// Create transaction
-	tx, err := blockchain.NewTransaction(blockchain.TxCreateElection, newElection)
+func castVote(electionID, choice, writeIn, credentialPath, ringKeyPath, registeredID, credentialKey, nodeAddr, chainID string, audit bool, ks *keystore.Keystore, senderName string) {
+	// Generate voter keys
+	voterKeys, err := crypto.GenerateKeys()
 	if err != nil {
-		fmt.Printf("Failed to create transaction: %v\n", err)
+		fmt.Printf("Failed to generate voter keys: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Sign transaction (in a real implementation)
-	// tx.Sign(electionKeys.PrivateKey)
+	// Get election details from the blockchain
+	electionData := fetchElection(electionID, nodeAddr)
+	voterID := fmt.Sprintf("%x", voterKeys.PublicKey.Marshal()[:8]) // Use first 8 bytes of public key as voter ID
 
-	// Submit to node
-	txJSON, _ := json.Marshal(tx)
-	resp, err := http.Post(fmt.Sprintf("http://%s/transactions", nodeAddr),
-		"application/json", bytes.NewBuffer(txJSON))
+	// Voters with a token vote under its pseudonymous voter ID
+	var token *election.VotingToken
+	if credentialPath != "" {
+		src, err := os.ReadFile(credentialPath)
+		if err == nil {
+			err = json.Unmarshal(src, &token)
+		}
+		if err != nil {
+			fmt.Printf("Failed to read voting token: %v\n", err)
+			os.Exit(1)
+		}
+		voterID = token.VoterID()
+	} else if electionData.HasRegistrar() {
+		fmt.Println("This election requires a voting token, see get-credential")
+		os.Exit(1)
+	}
+
+	// Ring members vote under the pseudonym of their key image
+	var ringSecret *big.Int
+	if ringKeyPath != "" {
+		src, err := os.ReadFile(ringKeyPath)
+		if err != nil {
+			fmt.Printf("Failed to read ring key: %v\n", err)
+			os.Exit(1)
+		}
+		var ok bool
+		if ringSecret, ok = new(big.Int).SetString(strings.TrimSpace(string(src)), 16); !ok {
+			fmt.Println("Invalid ring key file")
+			os.Exit(1)
+		}
+		voterID = electionData.RingVoterID(ringSecret)
+	} else if electionData.HasRing() {
+		fmt.Println("This election requires a voter ring key, see --ring-key")
+		os.Exit(1)
+	}
+
+	// Registered voters vote under their voter ID, signing with the key
+	// registered for it. Every ballot of a credential carries the same
+	// nullifier, so anyone can see that the voter revoted.
+	var credentialSecret *big.Int
+	if credentialKey != "" {
+		if registeredID == "" {
+			fmt.Println("The --voter-id flag is required with --credential-key")
+			os.Exit(1)
+		}
+		registered, ok := electionData.CredentialKey(registeredID)
+		if !ok {
+			fmt.Printf("Voter %s has no registered credential in this election\n", registeredID)
+			os.Exit(1)
+		}
+		key := loadElGamalKey(ks, credentialKey)
+		if key.PublicKey.String() != registered.String() {
+			fmt.Printf("Key %s is not the credential registered for voter %s\n", credentialKey, registeredID)
+			os.Exit(1)
+		}
+		credentialSecret, voterID = key.PrivateKey, registeredID
+	} else if len(electionData.VoterCredentials) > 0 {
+		fmt.Println("This election requires a registered voter credential, see --voter-id and --credential-key")
+		os.Exit(1)
+	}
+	if electionData.BallotType == election.BallotMultiContest {
+		fmt.Println("Multi-contest ballots must be cast by a client holding a registered voter ID")
+		os.Exit(1)
+	}
+
+	// A keystore key would link anonymous ballots to the voter's other
+	// transactions, so those are signed with a key used only once
+	var sender *crypto.BLSKeyPair
+	if token != nil || ringSecret != nil {
+		if sender, err = crypto.GenerateBLSKeys(); err != nil {
+			fmt.Printf("Failed to generate sender key: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		sender = loadBLSKey(ks, senderName)
+	}
+
+	// With --audit the voter sees the tracker of each encrypted ballot before
+	// deciding to cast it or to challenge it, which reveals its randomness
+	// and spoils it; a fresh ballot is then encrypted.
+	for {
+		ballot, opening := encryptBallot(electionData, voterID, choice, writeIn)
+		var err error
+		switch {
+		case token != nil:
+			err = electionData.SignBallotWithToken(ballot, token)
+		case ringSecret != nil:
+			err = electionData.SignBallotWithRing(ballot, ringSecret)
+		case credentialSecret != nil:
+			err = electionData.SignBallot(ballot, credentialSecret)
+		}
+		if err != nil {
+			fmt.Printf("Failed to sign ballot: %v\n", err)
+			os.Exit(1)
+		}
+		tracker, err := election.BallotTracker(ballot)
+		if err != nil {
+			fmt.Printf("Failed to compute ballot tracker: %v\n", err)
+			os.Exit(1)
+		}
+		if !audit || promptCast(stdin, tracker) {
+			submitVote(electionID, ballot, nodeAddr, chainID, sender)
+			fmt.Printf("Your ballot tracker: %s\n", tracker)
+			return
+		}
+
+		challenge := smartcontracts.ChallengePayload{ElectionID: electionID, Ballot: ballot, Opening: opening}
+		if err := submitTransaction(blockchain.TxChallengeBallot, challenge, nodeAddr, chainID, sender); err != nil {
+			fmt.Printf("Failed to challenge ballot: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Ballot challenged. Check it from another device with: audit --election %s --tracker %s\n", electionID, tracker)
+	}
+}
+
+// startRegistrar serves the registrar API, issuing tokens to the voters of
+// the access codes file.
+func startRegistrar(port int, ks *keystore.Keystore, keyName, accessCodesPath string) {
+	src, err := os.ReadFile(accessCodesPath)
+	if err != nil {
+		log.Fatalf("Failed to read access codes: %v", err)
+	}
+	var codes registrar.AccessCodes
+	if err := json.Unmarshal(src, &codes); err != nil {
+		log.Fatalf("Invalid access codes file: %v", err)
+	}
+
+	key := loadBLSKey(ks, keyName)
+	r := registrar.NewRegistrar(key, codes)
+	fmt.Printf("Registrar running on port %d (Key: %s)\n", port, base64.StdEncoding.EncodeToString(r.PublicKey()))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), r.Handler()))
+}
+
+// startSigner serves a signing daemon holding a validator key for a node
+// started with --signer.
+func startSigner(addr string, ks *keystore.Keystore, keyName, statePath string) {
+	d, err := signer.NewDaemon(loadBLSKey(ks, keyName), statePath)
+	if err != nil {
+		log.Fatalf("Failed to start signer: %v", err)
+	}
+	fmt.Printf("Signer listening on %s\n", addr)
+	log.Fatal(d.Serve(addr))
+}
+
+// startBeacon serves a stand-in randomness beacon releasing the rounds
+// time-locked election keys are unlocked with.
+func startBeacon(port int, ks *keystore.Keystore, keyName string) {
+	b := beacon.NewBeacon(loadBLSKey(ks, keyName))
+	fmt.Printf("Beacon running on port %d (Key: %s)\n", port, base64.StdEncoding.EncodeToString(b.PublicKey()))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), b.Handler()))
+}
+
+// releaseKey fetches the beacon round a time-locked election key is locked
+// to and submits it for the node to unlock the key.
+func releaseKey(electionID, beaconAddr, nodeAddr, chainID string, ks *keystore.Keystore, senderName string) {
+	electionData := fetchElection(electionID, nodeAddr)
+	if electionData.TimeLock == nil {
+		fmt.Println("This election key is not time-locked")
+		os.Exit(1)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/rounds/%d", beaconAddr, electionData.TimeLock.Round))
 	if err != nil {
-		fmt.Printf("Failed to submit transaction: %v\n", err)
+		fmt.Printf("Failed to contact beacon: %v\n", err)
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		fmt.Printf("Beacon refused round %d: %s\n", electionData.TimeLock.Round, msg)
+		os.Exit(1)
+	}
+	var round beacon.Round
+	if err := json.NewDecoder(resp.Body).Decode(&round); err != nil {
+		fmt.Printf("Invalid beacon response: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Unlock locally first, so a bad round is never submitted
+	sig, err := crypto.UnmarshalPoint(round.Signature)
+	var privKey *big.Int
+	if err == nil {
+		privKey, err = electionData.UnlockKey(sig)
+	}
+	if err != nil {
+		fmt.Printf("Failed to unlock election key: %v\n", err)
+		os.Exit(1)
+	}
+	payload := smartcontracts.KeyReleasePayload{ElectionID: electionID, Signature: round.Signature}
+	if err := submitTransaction(blockchain.TxReleaseKey, payload, nodeAddr, chainID, loadBLSKey(ks, senderName)); err != nil {
+		fmt.Printf("Failed to release key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Election key released: %x\n", privKey)
+}
+
+// proposeResults decrypts the tally of an election with its key, proving
+// every decryption, and submits the results for the officials to certify.
+func proposeResults(electionID, nodeAddr, chainID string, ks *keystore.Keystore, keyName, senderName string) {
+	es := fetchElectionState(electionID, nodeAddr)
+	privKey := es.ReleasedKey
+	if privKey == nil {
+		if keyName == "" {
+			keyName = electionID
+		}
+		privKey = loadElGamalKey(ks, keyName).PrivateKey
+	}
+
+	payload, err := smartcontracts.NewProposalPayload(es, privKey)
+	if err != nil {
+		fmt.Printf("Failed to decrypt tally: %v\n", err)
+		os.Exit(1)
+	}
+	if err := submitTransaction(blockchain.TxProposeResults, payload, nodeAddr, chainID, loadBLSKey(ks, senderName)); err != nil {
+		fmt.Printf("Failed to propose results: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Results of election %s proposed\n", electionID)
+}
+
+// signTally adds the official's signature to the trusted tally in path and
+// submits it once a quorum of officials has signed; until then the file is
+// passed on to the next official.
+func signTally(electionID, path, official, nodeAddr, chainID string, ks *keystore.Keystore, keyName, senderName string) {
+	es := fetchElectionState(electionID, nodeAddr)
+	config := es.Election.Certification
+	if !es.Election.TrustedTally || config == nil {
+		fmt.Println("This election does not take a trusted tally, see propose-results")
+		os.Exit(1)
+	}
+	index := config.OfficialIndex(official)
+	if index < 0 {
+		fmt.Printf("%s is not an official of this election\n", official)
+		os.Exit(1)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Failed to read tally: %v\n", err)
+		os.Exit(1)
+	}
+	var payload smartcontracts.TallyPayload
+	if err := json.Unmarshal(src, &payload); err != nil {
+		fmt.Printf("Invalid tally file: %v\n", err)
+		os.Exit(1)
+	}
+	if payload.ElectionID != "" && payload.ElectionID != electionID {
+		fmt.Printf("The tally is for election %s\n", payload.ElectionID)
+		os.Exit(1)
+	}
+	payload.ElectionID = electionID
+	if payload.Certificate == nil {
+		payload.Certificate = crypto.NewMultiSignature(len(config.Officials))
+	}
+
+	if keyName == "" {
+		keyName = official
+	}
+	key := loadBLSKey(ks, keyName)
+	keys, err := config.Keys()
+	if err != nil || keys[index].String() != key.PublicKey.String() {
+		fmt.Printf("Key %s is not the key of official %s\n", keyName, official)
+		os.Exit(1)
+	}
+	sig, err := smartcontracts.SignTally(es, &payload, key.PrivateKey)
+	if err == nil {
+		err = payload.Certificate.Add(index, sig)
+	}
+	if err != nil {
+		fmt.Printf("Failed to sign tally: %v\n", err)
+		os.Exit(1)
+	}
+	data, _ := json.MarshalIndent(payload, "", "  ")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Printf("Failed to save tally: %v\n", err)
+		os.Exit(1)
+	}
+
+	if signed := payload.Certificate.Count(); signed < config.Quorum {
+		fmt.Printf("Tally signed by %d of the %d officials needed; pass %s on to the next official\n", signed, config.Quorum, path)
+		return
+	}
+	if err := submitTransaction(blockchain.TxTallyVotes, payload, nodeAddr, chainID, loadBLSKey(ks, senderName)); err != nil {
+		fmt.Printf("Failed to submit tally: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Tally of election %s submitted with the signatures of a quorum of officials\n", electionID)
+}
+
+// certifyResults signs the proposed results of an election as one of its
+// officials.
+func certifyResults(electionID, official, nodeAddr, chainID string, ks *keystore.Keystore, keyName, senderName string) {
+	es := fetchElectionState(electionID, nodeAddr)
+	if es.Election.Certification == nil || es.Election.Certification.OfficialIndex(official) < 0 {
+		fmt.Printf("%s is not an official of this election\n", official)
+		os.Exit(1)
+	}
+	if es.Status == smartcontracts.ElectionCreated {
+		fmt.Println("No results have been proposed yet")
+		os.Exit(1)
+	}
+	results, _ := json.Marshal(struct {
+		Results        map[string]int            `json:"results"`
+		ContestResults map[string]map[string]int `json:"contest_results,omitempty"`
+	}{es.Results, es.ContestResults})
+	fmt.Printf("Signing results: %s\n", results)
+
+	if keyName == "" {
+		keyName = official
+	}
+	payload, err := smartcontracts.NewCertificationPayload(es, official, loadBLSKey(ks, keyName).PrivateKey)
+	if err != nil {
+		fmt.Printf("Failed to sign results: %v\n", err)
+		os.Exit(1)
+	}
+	if err := submitTransaction(blockchain.TxCertifyResults, payload, nodeAddr, chainID, loadBLSKey(ks, senderName)); err != nil {
+		fmt.Printf("Failed to certify results: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Results of election %s signed by %s\n", electionID, official)
+}
+
+// defaultKeystore is the keystore directory used unless --keystore is set.
+const defaultKeystore = "keystore"
+
+// defaultSender is the keystore key signing transactions unless --sender is
+// set.
+const defaultSender = "sender"
 
+// passphraseEnv names the environment variable read for keystore
+// passphrases instead of prompting, e.g. for nodes run as services.
+const passphraseEnv = "ELECTION_KEYSTORE_PASSPHRASE"
+
+// stdin buffers the terminal input shared by all prompts.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase returns the keystore passphrase from the environment, or
+// prompts for it without echoing it when stdin is a terminal.
+func readPassphrase(prompt string) []byte {
+	if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+		return []byte(passphrase)
+	}
+	fmt.Printf("%s: ", prompt)
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			log.Fatalf("Failed to read passphrase: %v", err)
+		}
+		return passphrase
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read passphrase: %v", err)
+	}
+	return []byte(strings.TrimRight(line, "\r\n"))
+}
+
+// loadKey decrypts the key name of kind, generating and storing it on first
+// use.
+func loadKey(ks *keystore.Keystore, name string, kind keystore.KeyKind) *big.Int {
+	stored, err := ks.Load(name)
+	if errors.Is(err, keystore.ErrKeyNotFound) {
+		priv, err := generateKey(kind)
+		if err != nil {
+			log.Fatalf("Failed to generate key: %v", err)
+		}
+		storeKey(ks, name, kind, priv)
+		fmt.Printf("Created %s key %s in keystore %s\n", kind, name, ks.Dir)
+		return priv
+	}
+	if err != nil {
+		log.Fatalf("Failed to load key %s: %v", name, err)
+	}
+	if stored.Kind != kind {
+		log.Fatalf("Key %s is a %s key, expected %s", name, stored.Kind, kind)
+	}
+	priv, err := stored.Decrypt(readPassphrase(fmt.Sprintf("Passphrase of key %s", name)))
+	if err != nil {
+		log.Fatalf("Failed to decrypt key %s: %v", name, err)
+	}
+	return priv
+}
+
+// loadElGamalKey loads a node or election key, see loadKey.
+func loadElGamalKey(ks *keystore.Keystore, name string) *crypto.KeyPair {
+	priv := loadKey(ks, name, keystore.KindElGamal)
+	return &crypto.KeyPair{PrivateKey: priv, PublicKey: new(bn256.G1).ScalarBaseMult(priv)}
+}
+
+// loadBLSKey loads a BLS signing key, see loadKey.
+func loadBLSKey(ks *keystore.Keystore, name string) *crypto.BLSKeyPair {
+	priv := loadKey(ks, name, keystore.KindBLS)
+	return &crypto.BLSKeyPair{PrivateKey: priv, PublicKey: new(bn256.G2).ScalarBaseMult(priv)}
+}
+
+// storeKey encrypts priv with a passphrase and saves it as name.
+func storeKey(ks *keystore.Keystore, name string, kind keystore.KeyKind, priv *big.Int) *keystore.EncryptedKey {
+	key, err := keystore.EncryptKey(kind, priv, readPassphrase(fmt.Sprintf("New passphrase of key %s", name)), keystore.StandardScrypt)
+	if err == nil {
+		err = ks.Store(name, key)
+	}
+	if err != nil {
+		log.Fatalf("Failed to store key %s: %v", name, err)
+	}
+	return key
+}
+
+func generateKey(kind keystore.KeyKind) (*big.Int, error) {
+	if kind == keystore.KindBLS {
+		key, err := crypto.GenerateBLSKeys()
+		if err != nil {
+			return nil, err
+		}
+		return key.PrivateKey, nil
+	}
+	key, err := crypto.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	return key.PrivateKey, nil
+}
+
+// manageKeys runs a keys subcommand: new, list, import or export.
+func manageKeys(action string, ks *keystore.Keystore, name string, kind keystore.KeyKind, file string) {
+	if action != "list" && name == "" {
+		fmt.Println("The --name flag is required")
+		os.Exit(1)
+	}
+
+	switch action {
+	case "new":
+		priv, err := generateKey(kind)
+		if err != nil {
+			fmt.Printf("Failed to generate key: %v\n", err)
+			os.Exit(1)
+		}
+		key := storeKey(ks, name, kind, priv)
+		fmt.Printf("Created %s key %s (Public key: %s)\n", kind, name, base64.StdEncoding.EncodeToString(key.PublicKey))
+	case "list":
+		names, err := ks.List()
+		if err != nil {
+			fmt.Printf("Failed to list keys: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range names {
+			key, err := ks.Load(name)
+			if err != nil {
+				fmt.Printf("%s: %v\n", name, err)
+				continue
+			}
+			fmt.Printf("%s\t%s\t%s\n", name, key.Kind, base64.StdEncoding.EncodeToString(key.PublicKey))
+		}
+	case "import":
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Failed to read key file: %v\n", err)
+			os.Exit(1)
+		}
+		priv, ok := new(big.Int).SetString(strings.TrimSpace(string(src)), 16)
+		if !ok {
+			fmt.Println("The key file must hold a hex private key")
+			os.Exit(1)
+		}
+		key := storeKey(ks, name, kind, priv)
+		fmt.Printf("Imported %s key %s (Public key: %s)\n", kind, name, base64.StdEncoding.EncodeToString(key.PublicKey))
+	case "export":
+		if file == "" {
+			fmt.Println("The --file flag is required")
+			os.Exit(1)
+		}
+		stored, err := ks.Load(name)
+		if err != nil {
+			fmt.Printf("Failed to load key %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		priv, err := stored.Decrypt(readPassphrase(fmt.Sprintf("Passphrase of key %s", name)))
+		if err != nil {
+			fmt.Printf("Failed to decrypt key %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%x\n", priv)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Printf("Failed to export key: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %s key %s to %s unencrypted; delete it once imported\n", stored.Kind, name, file)
+	default:
+		fmt.Println("Expected 'new', 'list', 'import' or 'export' after 'keys'")
+		os.Exit(1)
+	}
+}
+
+// getCredential obtains a voting token for an election from its registrar
+// and saves it for vote --credential.
+func getCredential(electionID, voterID, accessCode, registrarAddr, nodeAddr, outPath string) {
+	electionData := fetchElection(electionID, nodeAddr)
+	if !electionData.HasRegistrar() {
+		fmt.Println("This election does not issue voting tokens")
+		os.Exit(1)
+	}
+
+	request, err := electionData.NewTokenRequest()
+	if err != nil {
+		fmt.Printf("Failed to create token request: %v\n", err)
+		os.Exit(1)
+	}
+	body, _ := json.Marshal(registrar.IssueRequest{
+		ElectionID: electionID,
+		VoterID:    voterID,
+		Secret:     accessCode,
+		Blinded:    request.Blinded.Marshal(),
+	})
+	resp, err := http.Post(fmt.Sprintf("http://%s/tokens", registrarAddr), "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		fmt.Printf("Failed to contact registrar: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusCreated {
-		body, _ := ioutil.ReadAll(resp.Body)
-		fmt.Printf("Failed to create election: %s\n", body)
+		msg, _ := ioutil.ReadAll(resp.Body)
+		fmt.Printf("Registrar refused the token: %s\n", msg)
 		os.Exit(1)
 	}
 
-	fmt.Printf("Election created successfully with ID: %s\n", electionID)
-	fmt.Printf("Save your private key for tallying: %x\n", electionKeys.PrivateKey)
+	var issued registrar.IssueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+		fmt.Printf("Invalid registrar response: %v\n", err)
+		os.Exit(1)
+	}
+	blindSig, err := crypto.UnmarshalPoint(issued.Signature)
+	if err == nil {
+		var token *election.VotingToken
+		if token, err = request.Finish(electionData, blindSig); err == nil {
+			data, _ := json.MarshalIndent(token, "", "  ")
+			err = os.WriteFile(outPath, data, 0600)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Failed to obtain voting token: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Voting token saved to %s; vote with --credential %s\n", outPath, outPath)
 }
 
-func castVote(electionID, candidateName, nodeAddr string) {
-	// Generate voter keys
-	voterKeys := crypto.GenerateKeys()
+// promptCast shows the tracker of an encrypted ballot and asks the voter
+// whether to cast it, returning false if they challenge it instead.
+func promptCast(stdin *bufio.Reader, tracker string) bool {
+	fmt.Printf("Ballot tracker: %s\n", tracker)
+	for {
+		fmt.Print("Cast or challenge this ballot? [cast/challenge]: ")
+		answer, err := stdin.ReadString('\n')
+		switch strings.TrimSpace(strings.ToLower(answer)) {
+		case "cast":
+			return true
+		case "challenge":
+			return false
+		}
+		if err != nil {
+			fmt.Println("No answer given, the ballot was not cast")
+			os.Exit(1)
+		}
+	}
+}
 
-	// Get election details from the blockchain
+// encryptBallot encrypts the voter's choice for the election's ballot type,
+// returning the opening needed to challenge it.
+func encryptBallot(electionData *election.Election, voterID, choice, writeIn string) (*election.Ballot, *election.BallotOpening) {
+	switch electionData.BallotType {
+	case election.BallotRanked:
+		ranked, opening := rankedBallot(electionData, voterID, choice)
+		return &election.Ballot{VoterID: voterID, Type: election.BallotRanked, Ranked: ranked}, opening
+	case election.BallotApproval, election.BallotScore:
+		scores, opening := scoreBallot(electionData, voterID, choice)
+		ballot := &election.Ballot{VoterID: voterID, Type: electionData.BallotType, Scores: scores}
+		if electionData.WriteIn && electionData.Mix != nil {
+			text, err := electionData.EncryptWriteInText(voterID, writeIn)
+			if err != nil {
+				fmt.Printf("Invalid write-in: %v\n", err)
+				os.Exit(1)
+			}
+			ballot.WriteInText = text
+		} else if electionData.WriteIn {
+			sealed, err := electionData.SealWriteIn(voterID, writeIn)
+			if err != nil {
+				fmt.Printf("Invalid write-in: %v\n", err)
+				os.Exit(1)
+			}
+			ballot.WriteIn = sealed
+		}
+		return ballot, opening
+	}
+
+	// Find candidate index
+	candidateIndex := -1
+	for i, candidate := range electionData.Candidates {
+		if candidate.Name == choice {
+			candidateIndex = i
+			break
+		}
+	}
+	if candidateIndex < 0 {
+		fmt.Printf("Candidate '%s' not found in election\n", choice)
+		os.Exit(1)
+	}
+
+	// Create encrypted vote with its proof
+	ballot, opening, err := election.NewPluralityBallot(electionData, voterID, candidateIndex)
+	if err != nil {
+		fmt.Printf("Failed to encrypt vote: %v\n", err)
+		os.Exit(1)
+	}
+	return ballot, opening
+}
+
+// auditElection fetches the audit of an election from a node and checks
+// every challenged ballot locally rather than trusting the node. With a
+// tracker, it also reports whether that ballot was cast or challenged.
+func auditElection(electionID, tracker, nodeAddr string) {
+	electionData := fetchElection(electionID, nodeAddr)
+	resp, err := http.Get(fmt.Sprintf("http://%s/elections/%s/audit", nodeAddr, electionID))
+	if err != nil {
+		fmt.Printf("Failed to get election audit: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	var audit network.ElectionAudit
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&audit) != nil {
+		fmt.Println("Election audit not available")
+		os.Exit(1)
+	}
+
+	found := false
+	for _, challenged := range audit.Challenged {
+		checked, err := electionData.AuditBallot(challenged.Ballot, challenged.Opening)
+		if err != nil || checked.Tracker != challenged.Tracker {
+			fmt.Printf("Challenged ballot %s FAILED verification: %v\n", challenged.Tracker, err)
+			continue
+		}
+		fmt.Printf("Challenged ballot %s encrypts: %s\n", checked.Tracker, strings.Join(checked.Selections, ","))
+		found = found || checked.Tracker == tracker
+	}
+	for _, cast := range audit.Cast {
+		if cast == tracker {
+			fmt.Printf("Ballot %s has been cast and will be counted\n", tracker)
+			found = true
+		}
+	}
+	if tracker != "" && !found {
+		fmt.Printf("Ballot %s was not found\n", tracker)
+		os.Exit(1)
+	}
+}
+
+// fetchElection gets the details of an election from a node.
+func fetchElection(electionID, nodeAddr string) *election.Election {
 	resp, err := http.Get(fmt.Sprintf("http://%s/elections/%s", nodeAddr, electionID))
 	if err != nil {
 		fmt.Printf("Failed to get election details: %v\n", err)
@@ -176,81 +1191,142 @@ func castVote(electionID, candidateName, nodeAddr string) {
 
 	var electionData election.Election
 	json.NewDecoder(resp.Body).Decode(&electionData)
+	return &electionData
+}
 
-	// Find candidate ID
-	var candidateID string
-	for _, candidate := range electionData.Candidates {
-		if candidate.Name == candidateName {
-			candidateID = candidate.ID
-			break
-		}
+// fetchElectionState gets the contract state of an election from a node,
+// rebuilt with the built-in rule modules.
+func fetchElectionState(electionID, nodeAddr string) *smartcontracts.ElectionState {
+	resp, err := http.Get(fmt.Sprintf("http://%s/elections/%s/state", nodeAddr, electionID))
+	if err != nil {
+		fmt.Printf("Failed to get election state: %v\n", err)
+		os.Exit(1)
 	}
+	defer resp.Body.Close()
 
-	if candidateID == "" {
-		fmt.Printf("Candidate '%s' not found in election\n", candidateName)
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("Election not found")
 		os.Exit(1)
 	}
 
-	// Create encrypted vote
-	candidateIndex := 0
-	for i, candidate := range electionData.Candidates {
-		if candidate.ID == candidateID {
-			candidateIndex = i + 1 // 1-based index for voting
-			break
-		}
+	data, err := ioutil.ReadAll(resp.Body)
+	var es *smartcontracts.ElectionState
+	if err == nil {
+		es, err = smartcontracts.NewRuntime().RestoreElection(data)
 	}
+	if err != nil {
+		fmt.Printf("Invalid election state: %v\n", err)
+		os.Exit(1)
+	}
+	return es
+}
 
-	ciphertext, err := crypto.EncryptVote(electionData.PublicKey, candidateIndex)
+// rankedBallot encrypts a comma-separated ranking of candidate names.
+func rankedBallot(electionData *election.Election, voterID, rankingStr string) (*election.RankedBallot, *election.BallotOpening) {
+	rankings, err := election.ParseRankings(electionData.Candidates, [][]string{strings.Split(rankingStr, ",")})
 	if err != nil {
-		fmt.Printf("Failed to encrypt vote: %v\n", err)
+		fmt.Printf("Invalid ranking: %v\n", err)
 		os.Exit(1)
 	}
+	ranked, opening, err := election.NewRankedBallotWithOpening(electionData.PublicKey, electionData.ID, voterID,
+		len(electionData.Candidates), rankings[0])
+	if err != nil {
+		fmt.Printf("Failed to encrypt ranking: %v\n", err)
+		os.Exit(1)
+	}
+	return ranked, opening
+}
 
-	// Generate zero-knowledge proof
-	r, _ := rand.Int(rand.Reader, bn256.Order) // Random value used in encryption
-	proof := crypto.GenerateVoteProof(ciphertext, r, candidateIndex)
+// scoreBallot encrypts a comma-separated list of approved candidates, or of
+// name=score pairs for score elections. Candidates not listed get 0.
+func scoreBallot(electionData *election.Election, voterID, choices string) (*election.ScoreBallot, *election.BallotOpening) {
+	index := make(map[string]int, len(electionData.Candidates))
+	for i, candidate := range electionData.Candidates {
+		index[candidate.Name] = i
+	}
+
+	values := make([]int, len(electionData.Candidates))
+	for _, choice := range strings.Split(choices, ",") {
+		name, value := choice, 1
+		if electionData.BallotType == election.BallotScore {
+			var scoreStr string
+			var found bool
+			name, scoreStr, found = strings.Cut(choice, "=")
+			if _, err := fmt.Sscanf(scoreStr, "%d", &value); !found || err != nil {
+				fmt.Printf("Invalid score %q, expected name=score\n", choice)
+				os.Exit(1)
+			}
+		}
+		i, ok := index[name]
+		if !ok {
+			fmt.Printf("Candidate '%s' not found in election\n", name)
+			os.Exit(1)
+		}
+		values[i] = value
+	}
 
-	// Create ballot
-	ballot := election.Ballot{
-		Ciphertext: ciphertext,
-		ZKProof:    proof,
-		VoterID:    fmt.Sprintf("%x", voterKeys.PublicKey.Marshal()[:8]), // Use first 8 bytes of public key as voter ID
+	scores, opening, err := election.NewScoreBallotWithOpening(electionData, voterID, values)
+	if err != nil {
+		fmt.Printf("Failed to encrypt ballot: %v\n", err)
+		os.Exit(1)
 	}
+	return scores, opening
+}
 
-	// Create vote transaction
-	voteData := struct {
-		ElectionID string          `json:"election_id"`
-		Ballot     election.Ballot `json:"ballot"`
-	}{
+// submitVote wraps ballot in a cast_vote transaction and submits it to the node.
+func submitVote(electionID string, ballot *election.Ballot, nodeAddr, chainID string, sender *crypto.BLSKeyPair) {
+	voteData := smartcontracts.VotePayload{
 		ElectionID: electionID,
 		Ballot:     ballot,
 	}
-
-	tx, err := blockchain.NewTransaction(blockchain.TxCastVote, voteData)
-	if err != nil {
-		fmt.Printf("Failed to create transaction: %v\n", err)
+	if err := submitTransaction(blockchain.TxCastVote, voteData, nodeAddr, chainID, sender); err != nil {
+		fmt.Printf("Failed to cast vote: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Sign transaction (in a real implementation)
-	// tx.Sign(voterKeys.PrivateKey)
+	fmt.Println("Vote cast successfully!")
+}
+
+// submitTransaction wraps payload in a transaction of txType, signs it with
+// sender's next nonce and submits it to the node.
+func submitTransaction(txType blockchain.TransactionType, payload interface{}, nodeAddr, chainID string, sender *crypto.BLSKeyPair) error {
+	nonce, err := fetchNonce(sender, nodeAddr)
+	if err != nil {
+		return fmt.Errorf("get nonce: %v", err)
+	}
+	tx, err := blockchain.NewChainTransaction(chainID, nonce, txType, payload)
+	if err != nil {
+		return fmt.Errorf("create transaction: %v", err)
+	}
+	tx.Sign(sender)
 
 	// Submit to node
 	txJSON, _ := json.Marshal(tx)
-	resp, err = http.Post(fmt.Sprintf("http://%s/transactions", nodeAddr),
+	resp, err := http.Post(fmt.Sprintf("http://%s/transactions", nodeAddr),
 		"application/json", bytes.NewBuffer(txJSON))
 	if err != nil {
-		fmt.Printf("Failed to submit vote: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := ioutil.ReadAll(resp.Body)
-		fmt.Printf("Failed to cast vote: %s\n", body)
-		os.Exit(1)
+		return fmt.Errorf("%s", body)
 	}
+	return nil
+}
 
-	fmt.Println("Vote cast successfully!")
-	fmt.Printf("Your vote receipt: %x\n", ballot.ZKProof[:8]) // First 8 bytes as receipt
+// fetchNonce asks the node for the nonce of sender's next transaction.
+func fetchNonce(sender *crypto.BLSKeyPair, nodeAddr string) (uint64, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s/accounts/%x", nodeAddr, crypto.MarshalG2(sender.PublicKey)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var account network.Account
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&account) != nil {
+		return 0, errors.New("account not available")
+	}
+	return account.NextNonce, nil
 }
@@ -5,10 +5,11 @@ go 1.23.7
 require (
 	github.com/cloudflare/bn256 v0.0.0-20241212004005-a4a408366973
 	github.com/gtank/merlin v0.1.1
+	golang.org/x/crypto v0.31.0
+	golang.org/x/term v0.27.0
 )
 
 require (
 	github.com/mimoo/StrobeGo v0.0.0-20181016162300-f8f6d4d2b643 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,91 @@
+// pkg/beacon/beacon.go
+package beacon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// ErrNotReleased is returned for rounds still in the future.
+var ErrNotReleased = errors.New("beacon round has not been released yet")
+
+// Beacon is a local stand-in for a public randomness beacon such as drand.
+// It releases a BLS signature on every round once its time has come; the
+// signatures are deterministic, verifiable with the beacon's key and
+// unpredictable without it, so they unlock keys time-locked to their round.
+type Beacon struct {
+	key *crypto.BLSKeyPair
+}
+
+func NewBeacon(key *crypto.BLSKeyPair) *Beacon {
+	return &Beacon{key: key}
+}
+
+// PublicKey returns the key elections are time-locked with.
+func (b *Beacon) PublicKey() []byte {
+	return crypto.MarshalG2(b.key.PublicKey)
+}
+
+// Round is the response of GET /rounds/{round}.
+type Round struct {
+	Round     uint64 `json:"round"`
+	Signature []byte `json:"signature"`
+}
+
+// Release returns the signature on round, once the round's time has come.
+func (b *Beacon) Release(round uint64) (*Round, error) {
+	if uint64(time.Now().Unix()) < round {
+		return nil, ErrNotReleased
+	}
+	sig := crypto.BLSSign(b.key.PrivateKey, crypto.BeaconMessage(round))
+	return &Round{Round: round, Signature: sig.Marshal()}, nil
+}
+
+// Handler returns the HTTP handler serving the beacon API.
+func (b *Beacon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/key", b.handleKey)
+	mux.HandleFunc("/rounds/", b.handleRound)
+	return mux
+}
+
+func (b *Beacon) handleKey(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		PublicKey []byte `json:"public_key"`
+	}{
+		PublicKey: b.PublicKey(),
+	})
+}
+
+func (b *Beacon) handleRound(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	round, err := strconv.ParseUint(strings.TrimPrefix(req.URL.Path, "/rounds/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid round", http.StatusBadRequest)
+		return
+	}
+	resp, err := b.Release(round)
+	if errors.Is(err, ErrNotReleased) {
+		http.Error(w, err.Error(), http.StatusTooEarly)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"time"
+
+	"github.com/koushamad/election-system/pkg/crypto"
 )
 
 type Block struct {
@@ -14,7 +16,12 @@ type Block struct {
 	PrevHash     []byte         `json:"prev_hash"`
 	Hash         []byte         `json:"hash"`
 	Nonce        int            `json:"nonce"`
-	Validator    string         `json:"validator"` // Address of the validator who created this block
+	Validator    string         `json:"validator"`     // Address of the validator who created this block
+	ReceiptsRoot []byte         `json:"receipts_root"` // Merkle root of the receipts of Transactions
+
+	// Finality certificate: the validators' aggregate signature on the block,
+	// collected after it was produced and so not part of its hash
+	Certificate *crypto.MultiSignature `json:"certificate,omitempty"`
 }
 
 func NewBlock(index int, transactions []*Transaction, prevHash []byte, validator string) *Block {
@@ -30,6 +37,16 @@ func NewBlock(index int, transactions []*Transaction, prevHash []byte, validator
 	return block
 }
 
+// TransactionsSize returns the total serialized size of the block's
+// transactions, which is what ConsensusParams.MaxBlockBytes bounds.
+func (b *Block) TransactionsSize() int {
+	size := 0
+	for _, tx := range b.Transactions {
+		size += tx.Size()
+	}
+	return size
+}
+
 func (b *Block) CalculateHash() []byte {
 	blockData := struct {
 		Index        int
@@ -38,12 +55,14 @@ func (b *Block) CalculateHash() []byte {
 		PrevHash     []byte
 		Validator    string
 		Nonce        int
+		ReceiptsRoot []byte
 	}{
-		Index:     b.Index,
-		Timestamp: b.Timestamp,
-		PrevHash:  b.PrevHash,
-		Validator: b.Validator,
-		Nonce:     b.Nonce,
+		Index:        b.Index,
+		Timestamp:    b.Timestamp,
+		PrevHash:     b.PrevHash,
+		Validator:    b.Validator,
+		Nonce:        b.Nonce,
+		ReceiptsRoot: b.ReceiptsRoot,
 	}
 
 	// Extract transaction hashes
@@ -10,6 +10,9 @@ import (
 type Chain struct {
 	Blocks              []*Block
 	PendingTransactions []*Transaction
+
+	included *replayGuard // Transactions of Blocks[:indexed], see ContainsTransaction
+	indexed  int
 }
 
 func NewChain() *Chain {
@@ -42,12 +45,30 @@ func (c *Chain) AddTransaction(tx *Transaction) error {
 
 	// Check for duplicates
 	for _, t := range c.PendingTransactions {
-		if bytes.Equal(t.Hash, tx.Hash) {
+		if bytes.Equal(t.Hash, tx.Hash) || t.ID == tx.ID {
 			return errors.New("transaction already exists")
 		}
 	}
 
+	// Reject replays of transactions that were already included in a block
+	if c.ContainsTransaction(tx) {
+		return errors.New("transaction already included in chain")
+	}
+
 	// Add to pending transactions
 	c.PendingTransactions = append(c.PendingTransactions, tx)
 	return nil
 }
+
+// ContainsTransaction reports whether a transaction with the same ID or hash
+// has already been included in any block of the chain. Blocks are indexed
+// once, as they are first seen, rather than scanned on every lookup.
+func (c *Chain) ContainsTransaction(tx *Transaction) bool {
+	if c.included == nil || c.indexed > len(c.Blocks) {
+		c.included, c.indexed = newReplayGuard(), 0
+	}
+	for ; c.indexed < len(c.Blocks); c.indexed++ {
+		c.included.addBlock(c.Blocks[c.indexed])
+	}
+	return c.included.contains(tx)
+}
@@ -0,0 +1,43 @@
+// pkg/blockchain/executor.go
+package blockchain
+
+import "encoding/json"
+
+// Executor applies the transactions of a block to application state. It must
+// be deterministic: every node executing the same chain has to produce the
+// same receipts, since their root is committed to in the block.
+type Executor interface {
+	// Execute applies tx as part of block and returns its receipt.
+	Execute(tx *Transaction, block *Block) *Receipt
+	// Reset discards all state so the chain can be replayed from genesis.
+	Reset()
+}
+
+// BasicExecutor only checks that transactions are of a known type with a
+// well-formed payload. Nodes use it until a contract runtime is configured.
+type BasicExecutor struct{}
+
+func NewBasicExecutor() *BasicExecutor {
+	return &BasicExecutor{}
+}
+
+func (e *BasicExecutor) Execute(tx *Transaction, block *Block) *Receipt {
+	var payload struct {
+		ID         string `json:"id"`
+		ElectionID string `json:"election_id"`
+	}
+	if err := json.Unmarshal(tx.Payload, &payload); err != nil {
+		return NewReceipt(tx, block, "", 0, NewExecutionError(ErrCodeInvalidPayload, "decode payload: %v", err))
+	}
+
+	switch tx.Type {
+	case TxCreateElection:
+		return NewReceipt(tx, block, payload.ID, 0, nil)
+	case TxCastVote, TxTallyVotes, TxDelegateVote, TxMixBallots, TxChallengeBallot, TxProposeResults, TxCertifyResults, TxReleaseKey:
+		return NewReceipt(tx, block, payload.ElectionID, 0, nil)
+	default:
+		return NewReceipt(tx, block, "", 0, NewExecutionError(ErrCodeUnknownType, "unknown transaction type %q", tx.Type))
+	}
+}
+
+func (e *BasicExecutor) Reset() {}
@@ -0,0 +1,232 @@
+// pkg/blockchain/finality.go
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// ValidatorKey is a validator's BLS key with its proof of possession, which
+// keeps a validator from registering a key that cancels out the others'
+// in aggregate signatures.
+type ValidatorKey struct {
+	PublicKey  []byte `json:"public_key"`
+	Possession []byte `json:"possession"`
+}
+
+// NewValidatorKey returns the validator set entry of key.
+func NewValidatorKey(key *crypto.BLSKeyPair) ValidatorKey {
+	return ValidatorKey{
+		PublicKey:  crypto.MarshalG2(key.PublicKey),
+		Possession: crypto.ProvePossession(key.PrivateKey).Marshal(),
+	}
+}
+
+// ValidatorKeys decodes the validator set, checking every proof of
+// possession.
+func (p ConsensusParams) ValidatorKeys() ([]*bn256.G2, error) {
+	keys := make([]*bn256.G2, len(p.Validators))
+	for i, v := range p.Validators {
+		key, err := crypto.UnmarshalG2(v.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("validator %d: %v", i, err)
+		}
+		proof, err := crypto.UnmarshalPoint(v.Possession)
+		if err != nil || !crypto.VerifyPossession(key, proof) {
+			return nil, fmt.Errorf("validator %d: invalid proof of possession", i)
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// validatorSet caches the decoded keys of a validator set, see
+// Node.validatorKeys.
+type validatorSet struct {
+	mu      sync.Mutex
+	entries []ValidatorKey // Copy of the entries keys were decoded from
+	keys    []*bn256.G2
+	err     error
+}
+
+// validatorKeys returns Params.ValidatorKeys, decoding the keys and checking
+// their proofs of possession only when the validator set has changed since
+// the last call. The caller must hold n.mu, for reading at least.
+func (n *Node) validatorKeys() ([]*bn256.G2, error) {
+	n.validators.mu.Lock()
+	defer n.validators.mu.Unlock()
+
+	if !sameValidators(n.validators.entries, n.Params.Validators) {
+		n.validators.keys, n.validators.err = n.Params.ValidatorKeys()
+		n.validators.entries = make([]ValidatorKey, len(n.Params.Validators))
+		for i, v := range n.Params.Validators {
+			n.validators.entries[i] = ValidatorKey{
+				PublicKey:  append([]byte(nil), v.PublicKey...),
+				Possession: append([]byte(nil), v.Possession...),
+			}
+		}
+	}
+	return n.validators.keys, n.validators.err
+}
+
+func sameValidators(a, b []ValidatorKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].PublicKey, b[i].PublicKey) || !bytes.Equal(a[i].Possession, b[i].Possession) {
+			return false
+		}
+	}
+	return true
+}
+
+// Quorum returns the number of validators whose commits make a block final:
+// more than two thirds of the set.
+func (p ConsensusParams) Quorum() int {
+	return 2*len(p.Validators)/3 + 1
+}
+
+// Commit is a validator's signature on a block, aggregated into the block's
+// finality certificate.
+type Commit struct {
+	BlockIndex int    `json:"block_index"`
+	BlockHash  []byte `json:"block_hash"`
+	Validator  int    `json:"validator"` // Index in ConsensusParams.Validators
+	Signature  []byte `json:"signature"`
+}
+
+// SignCommit signs the block at index with the node's signer and adds the
+// commit to the block's certificate.
+func (n *Node) SignCommit(index int) (*Commit, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.signCommit(index)
+}
+
+// AddCommit checks a validator's commit and aggregates it into the finality
+// certificate of its block.
+func (n *Node) AddCommit(commit *Commit) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.addCommit(commit)
+}
+
+// IsFinal reports whether the block at index has a certificate signed by a
+// quorum of validators.
+func (n *Node) IsFinal(index int) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.isFinal(index)
+}
+
+// lastFinal returns the index of the highest final block, or -1 if there is
+// none. The caller must hold n.mu.
+func (n *Node) lastFinal() int {
+	for index := len(n.Chain.Blocks) - 1; index > 0; index-- {
+		if n.isFinal(index) {
+			return index
+		}
+	}
+	return -1
+}
+
+// isFinal implements IsFinal. The caller must hold n.mu.
+func (n *Node) isFinal(index int) bool {
+	if index < 0 || index >= len(n.Chain.Blocks) || len(n.Params.Validators) == 0 {
+		return false
+	}
+	block := n.Chain.Blocks[index]
+	return block.Certificate != nil && block.Certificate.Count() >= n.Params.Quorum() && n.verifyCertificate(block) == nil
+}
+
+// signCommit implements SignCommit. The caller must hold n.mu.
+func (n *Node) signCommit(index int) (*Commit, error) {
+	if n.Signer == nil {
+		return nil, errors.New("node has no commit signer")
+	}
+	if index < 0 || index >= len(n.Chain.Blocks) {
+		return nil, fmt.Errorf("block %d not found", index)
+	}
+	self := crypto.MarshalG2(n.Signer.PublicKey())
+	validator := -1
+	for i, v := range n.Params.Validators {
+		if bytes.Equal(v.PublicKey, self) {
+			validator = i
+			break
+		}
+	}
+	if validator < 0 {
+		return nil, errors.New("commit key is not in the validator set")
+	}
+
+	block := n.Chain.Blocks[index]
+	sig, err := n.Signer.SignCommit(n.ChainID, block.Index, block.Hash)
+	if err != nil {
+		return nil, err
+	}
+	commit := &Commit{BlockIndex: block.Index, BlockHash: block.Hash, Validator: validator, Signature: sig.Marshal()}
+	if block.Certificate == nil || !block.Certificate.HasSigned(validator) {
+		if err := n.addCommit(commit); err != nil {
+			return nil, err
+		}
+		if n.OnCommit != nil {
+			n.OnCommit(commit)
+		}
+	}
+	return commit, nil
+}
+
+// addCommit implements AddCommit. The caller must hold n.mu.
+func (n *Node) addCommit(commit *Commit) error {
+	if commit.BlockIndex < 0 || commit.BlockIndex >= len(n.Chain.Blocks) {
+		return fmt.Errorf("block %d not found", commit.BlockIndex)
+	}
+	block := n.Chain.Blocks[commit.BlockIndex]
+	if !bytes.Equal(block.Hash, commit.BlockHash) {
+		return errors.New("commit is for another block")
+	}
+	keys, err := n.validatorKeys()
+	if err != nil {
+		return err
+	}
+	if commit.Validator < 0 || commit.Validator >= len(keys) {
+		return fmt.Errorf("validator %d is not in the validator set", commit.Validator)
+	}
+	sig, err := crypto.UnmarshalPoint(commit.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed commit signature: %v", err)
+	}
+	if !crypto.BLSVerify(keys[commit.Validator], crypto.CommitMessage(n.ChainID, block.Index, block.Hash), sig) {
+		return errors.New("commit signature does not verify")
+	}
+
+	if block.Certificate == nil {
+		block.Certificate = crypto.NewMultiSignature(len(keys))
+	}
+	return block.Certificate.Add(commit.Validator, sig)
+}
+
+// verifyCertificate checks that the certificate of block, if any, is signed
+// by the validators it lists. It need not reach a quorum.
+func (n *Node) verifyCertificate(block *Block) error {
+	if block.Certificate == nil {
+		return nil
+	}
+	keys, err := n.validatorKeys()
+	if err != nil {
+		return err
+	}
+	if !block.Certificate.Verify(keys, crypto.CommitMessage(n.ChainID, block.Index, block.Hash)) {
+		return errors.New("finality certificate does not verify")
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// pkg/blockchain/merkle.go
+package blockchain
+
+import "crypto/sha256"
+
+// Domain separation prefixes, so that an inner node can never be passed off
+// as a leaf (as in RFC 6962).
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleRoot computes the binary SHA-256 Merkle root of leaves. An odd node
+// at any level is promoted to the next level unchanged rather than paired
+// with itself, so no two leaf lists share a root; the root of no leaves is
+// nil.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = merkleHash(merkleLeafPrefix, leaf)
+	}
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleHash(merkleNodePrefix, level[i], level[i+1]))
+		}
+		level = next
+	}
+
+	return level[0]
+}
+
+func merkleHash(prefix byte, parts ...[]byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
@@ -4,7 +4,11 @@ package blockchain
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"log"
 	"sync"
+
+	"github.com/koushamad/election-system/pkg/crypto"
 )
 
 type Node struct {
@@ -14,6 +18,18 @@ type Node struct {
 	TransactionPool []*Transaction
 	Address         string // Node's blockchain address for validation
 	IsValidator     bool   // Whether this node is a validator
+	ChainID         string // Network identifier every transaction must carry
+	Params          ConsensusParams
+	Executor        Executor      // Applies included transactions to application state
+	Signer          crypto.Signer // Validator key signing finality commits, see SignCommit
+
+	// Called with every commit the node's signer adds, e.g. to share it
+	// with peers. It runs under the node's lock and must not call back in.
+	OnCommit func(*Commit)
+
+	replay     *replayGuard        // Index of transactions already included in Chain
+	receipts   map[string]*Receipt // Receipts of included transactions by ID
+	validators validatorSet        // Decoded Params.Validators
 }
 
 func NewNode() *Node {
@@ -21,16 +37,16 @@ func NewNode() *Node {
 		Chain:           NewChain(),
 		Peers:           make([]string, 0),
 		TransactionPool: make([]*Transaction, 0),
+		ChainID:         DefaultChainID,
+		Params:          DefaultConsensusParams(),
+		Executor:        NewBasicExecutor(),
+		replay:          newReplayGuard(),
+		receipts:        make(map[string]*Receipt),
 	}
 }
 
 // pkg/blockchain/node.go
 func (n *Node) AddBlock(block *Block) error {
-	// Verify block
-	if !n.VerifyBlock(block) {
-		return errors.New("invalid block")
-	}
-
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
@@ -41,24 +57,33 @@ func (n *Node) AddBlock(block *Block) error {
 		}
 	}
 
+	// Verify block
+	if err := n.verifyBlock(block); err != nil {
+		return fmt.Errorf("invalid block: %w", err)
+	}
+
+	// Apply the block and check that we reach the same receipts as its
+	// producer, otherwise roll the state back to our current tip
+	receipts := n.applyBlock(block)
+	if !bytes.Equal(ReceiptsRoot(receipts), block.ReceiptsRoot) {
+		n.rebuildState(n.Chain)
+		return errors.New("invalid block: receipts root mismatch")
+	}
+
 	// Add block to chain
 	n.Chain.Blocks = append(n.Chain.Blocks, block)
+	n.replay.addBlock(block)
+	n.storeReceipts(receipts)
 
 	// Remove transactions that are now in the block
-	var newPool []*Transaction
-	for _, tx := range n.TransactionPool {
-		found := false
-		for _, btx := range block.Transactions {
-			if bytes.Equal(tx.Hash, btx.Hash) {
-				found = true
-				break
-			}
-		}
-		if !found {
-			newPool = append(newPool, tx)
+	n.pruneTransactionPool()
+
+	// Validators commit to every block they accept
+	if n.Signer != nil {
+		if _, err := n.signCommit(block.Index); err != nil {
+			log.Printf("Failed to commit to block %d: %v", block.Index, err)
 		}
 	}
-	n.TransactionPool = newPool
 
 	return nil
 }
@@ -73,35 +98,34 @@ func (n *Node) ReplaceChain(chain *Chain) {
 		return
 	}
 
+	// Final blocks are never reverted: the new chain must contain our
+	// highest final block, and so everything before it
+	if final := n.lastFinal(); final > 0 && !bytes.Equal(chain.Blocks[final].Hash, n.Chain.Blocks[final].Hash) {
+		return
+	}
+
 	// Verify the new chain
 	if !n.VerifyChain(chain) {
 		return
 	}
 
-	// Replace the chain
+	// Re-execute the new chain from genesis; if any block's receipts do not
+	// match, keep our own chain
+	if err := n.rebuildState(chain); err != nil {
+		n.rebuildState(n.Chain)
+		return
+	}
+
+	// Replace the chain and rebuild the replay index from it
 	n.Chain = chain
+	n.replay = newReplayGuard()
+	for _, block := range chain.Blocks {
+		n.replay.addBlock(block)
+	}
 
 	// Rebuild transaction pool
 	// Remove transactions that are now in the blockchain
-	var newPool []*Transaction
-	for _, tx := range n.TransactionPool {
-		found := false
-		for _, block := range chain.Blocks {
-			for _, btx := range block.Transactions {
-				if bytes.Equal(tx.Hash, btx.Hash) {
-					found = true
-					break
-				}
-			}
-			if found {
-				break
-			}
-		}
-		if !found {
-			newPool = append(newPool, tx)
-		}
-	}
-	n.TransactionPool = newPool
+	n.pruneTransactionPool()
 }
 
 // pkg/blockchain/node.go
@@ -110,6 +134,7 @@ func (n *Node) VerifyChain(chain *Chain) bool {
 		return true // Only genesis block
 	}
 
+	replay := newReplayGuard()
 	for i := 1; i < len(chain.Blocks); i++ {
 		block := chain.Blocks[i]
 		prevBlock := chain.Blocks[i-1]
@@ -124,11 +149,23 @@ func (n *Node) VerifyChain(chain *Chain) bool {
 			return false
 		}
 
-		// Verify all transactions
+		if n.checkBlockLimits(block) != nil {
+			return false
+		}
+
+		if n.verifyCertificate(block) != nil {
+			return false
+		}
+
+		// Verify all transactions, including that none is replayed
 		for _, tx := range block.Transactions {
 			if !tx.Validate() {
 				return false
 			}
+			if err := replay.check(tx, n.ChainID); err != nil {
+				return false
+			}
+			replay.add(tx)
 		}
 	}
 
@@ -137,27 +174,53 @@ func (n *Node) VerifyChain(chain *Chain) bool {
 
 // pkg/blockchain/node.go
 func (n *Node) VerifyBlock(block *Block) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return n.verifyBlock(block) == nil
+}
+
+// verifyBlock checks block against the current chain tip. The caller must
+// hold n.mu.
+func (n *Node) verifyBlock(block *Block) error {
 	// Verify block hash
 	if !bytes.Equal(block.CalculateHash(), block.Hash) {
-		return false
+		return errors.New("hash mismatch")
 	}
 
 	// Verify block index and previous hash
 	if len(n.Chain.Blocks) > 0 {
 		prevBlock := n.Chain.Blocks[len(n.Chain.Blocks)-1]
 		if block.Index != prevBlock.Index+1 || !bytes.Equal(block.PrevHash, prevBlock.Hash) {
-			return false
+			return errors.New("block does not extend the chain tip")
 		}
 	}
 
-	// Verify all transactions in the block
+	if err := n.checkBlockLimits(block); err != nil {
+		return err
+	}
+
+	if err := n.verifyCertificate(block); err != nil {
+		return err
+	}
+
+	// Verify all transactions in the block, rejecting replays of transactions
+	// already on chain as well as duplicates within the block itself
+	inBlock := newReplayGuard()
 	for _, tx := range block.Transactions {
 		if !tx.Validate() {
-			return false
+			return fmt.Errorf("invalid transaction %s", tx.ID)
 		}
+		if err := n.replay.check(tx, n.ChainID); err != nil {
+			return err
+		}
+		if err := inBlock.check(tx, n.ChainID); err != nil {
+			return err
+		}
+		inBlock.add(tx)
 	}
 
-	return true
+	return nil
 }
 
 func (n *Node) AddTransaction(tx *Transaction) error {
@@ -169,9 +232,19 @@ func (n *Node) AddTransaction(tx *Transaction) error {
 		return errors.New("invalid transaction")
 	}
 
+	// Reject transactions for another network or already included on chain
+	if err := n.replay.check(tx, n.ChainID); err != nil {
+		return err
+	}
+
+	// A transaction that cannot fit into any block would never leave the pool
+	if tx.Size() > n.Params.MaxBlockBytes {
+		return fmt.Errorf("transaction size %d exceeds maximum block size %d", tx.Size(), n.Params.MaxBlockBytes)
+	}
+
 	// Check for duplicates
 	for _, t := range n.TransactionPool {
-		if bytes.Equal(t.Hash, tx.Hash) {
+		if bytes.Equal(t.Hash, tx.Hash) || t.ID == tx.ID {
 			return errors.New("transaction already exists in pool")
 		}
 	}
@@ -190,20 +263,178 @@ func (n *Node) CreateBlock() *Block {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	if len(n.TransactionPool) == 0 {
+	// Select pending transactions in canonical order until the block is full;
+	// whatever does not fit stays in the pool for the next block
+	prevBlock := n.Chain.Blocks[len(n.Chain.Blocks)-1]
+	pending := make([]*Transaction, len(n.TransactionPool))
+	copy(pending, n.TransactionPool)
+	SortTransactions(pending, prevBlock.Hash)
+
+	inBlock := newReplayGuard()
+	transactions := make([]*Transaction, 0, len(pending))
+	var remaining []*Transaction
+	size := 0
+	for i, tx := range pending {
+		// Drop pending transactions that can no longer be included, e.g. a
+		// second transaction reusing a nonce that was taken in the meantime
+		if n.replay.check(tx, n.ChainID) != nil || inBlock.check(tx, n.ChainID) != nil {
+			continue
+		}
+		txSize := tx.Size()
+		if txSize > n.Params.MaxBlockBytes {
+			continue
+		}
+		if len(transactions) >= n.Params.MaxBlockTxs || size+txSize > n.Params.MaxBlockBytes {
+			remaining = append(remaining, pending[i:]...)
+			break
+		}
+		inBlock.add(tx)
+		transactions = append(transactions, tx)
+		size += txSize
+	}
+
+	if len(transactions) == 0 {
 		return nil
 	}
 
-	prevBlock := n.Chain.Blocks[len(n.Chain.Blocks)-1]
 	newBlock := NewBlock(
 		prevBlock.Index+1,
-		n.TransactionPool,
+		transactions,
 		prevBlock.Hash,
 		n.Address,
 	)
 
+	// Execute the block and commit to the resulting receipts
+	receipts := n.applyBlock(newBlock)
+	newBlock.ReceiptsRoot = ReceiptsRoot(receipts)
+
 	n.Chain.AddBlock(newBlock)
-	n.TransactionPool = []*Transaction{}
+	n.replay.addBlock(newBlock)
+	n.storeReceipts(receipts)
+	n.TransactionPool = remaining
+	if n.TransactionPool == nil {
+		n.TransactionPool = []*Transaction{}
+	}
+	if n.Signer != nil {
+		if _, err := n.signCommit(newBlock.Index); err != nil {
+			log.Printf("Failed to commit to block %d: %v", newBlock.Index, err)
+		}
+	}
 
 	return newBlock
 }
+
+// checkBlockLimits enforces the consensus limits on block size and
+// transaction count and that transactions appear in canonical order.
+func (n *Node) checkBlockLimits(block *Block) error {
+	if len(block.Transactions) > n.Params.MaxBlockTxs {
+		return fmt.Errorf("block has %d transactions, maximum is %d", len(block.Transactions), n.Params.MaxBlockTxs)
+	}
+
+	if size := block.TransactionsSize(); size > n.Params.MaxBlockBytes {
+		return fmt.Errorf("block transactions take %d bytes, maximum is %d", size, n.Params.MaxBlockBytes)
+	}
+
+	for i := 1; i < len(block.Transactions); i++ {
+		a, b := block.Transactions[i], block.Transactions[i-1]
+		if transactionLess(a, b, senderRank(a, block.PrevHash), senderRank(b, block.PrevHash)) {
+			return errors.New("block transactions are not in canonical order")
+		}
+	}
+
+	return nil
+}
+
+// pruneTransactionPool removes pending transactions that have been included
+// in the chain. The caller must hold n.mu.
+func (n *Node) pruneTransactionPool() {
+	var newPool []*Transaction
+	for _, tx := range n.TransactionPool {
+		if n.replay.check(tx, n.ChainID) == nil {
+			newPool = append(newPool, tx)
+		}
+	}
+	n.TransactionPool = newPool
+}
+
+// NextNonce returns the nonce the next transaction of sender, a hex-encoded
+// public key as returned by Transaction.Sender, must carry to follow both
+// its included and its pending transactions.
+func (n *Node) NextNonce(sender string) uint64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	nonce := n.replay.nextNonce(sender)
+	for _, tx := range n.TransactionPool {
+		if tx.Sender() == sender && tx.Nonce >= nonce {
+			nonce = tx.Nonce + 1
+		}
+	}
+	return nonce
+}
+
+// Receipt returns the receipt of the included transaction with the given ID.
+func (n *Node) Receipt(txID string) (*Receipt, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	receipt, ok := n.receipts[txID]
+	return receipt, ok
+}
+
+// TransactionByID looks up a transaction by ID, first on chain and then in
+// the pool. The receipt is nil for transactions that are still pending.
+func (n *Node) TransactionByID(txID string) (*Transaction, *Receipt, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if receipt, ok := n.receipts[txID]; ok && receipt.BlockIndex < len(n.Chain.Blocks) {
+		for _, tx := range n.Chain.Blocks[receipt.BlockIndex].Transactions {
+			if tx.ID == txID {
+				return tx, receipt, true
+			}
+		}
+	}
+
+	for _, tx := range n.TransactionPool {
+		if tx.ID == txID {
+			return tx, nil, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// applyBlock executes the transactions of block in order and returns their
+// receipts. The caller must hold n.mu.
+func (n *Node) applyBlock(block *Block) []*Receipt {
+	receipts := make([]*Receipt, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		receipts[i] = n.Executor.Execute(tx, block)
+	}
+	return receipts
+}
+
+// rebuildState resets the executor and replays chain from genesis, checking
+// each block's receipts root. The caller must hold n.mu.
+func (n *Node) rebuildState(chain *Chain) error {
+	n.Executor.Reset()
+	n.receipts = make(map[string]*Receipt)
+
+	for _, block := range chain.Blocks[1:] {
+		receipts := n.applyBlock(block)
+		if !bytes.Equal(ReceiptsRoot(receipts), block.ReceiptsRoot) {
+			return fmt.Errorf("receipts root mismatch at block %d", block.Index)
+		}
+		n.storeReceipts(receipts)
+	}
+
+	return nil
+}
+
+// storeReceipts indexes receipts by transaction ID. The caller must hold n.mu.
+func (n *Node) storeReceipts(receipts []*Receipt) {
+	for _, receipt := range receipts {
+		n.receipts[receipt.TxID] = receipt
+	}
+}
@@ -0,0 +1,69 @@
+// pkg/blockchain/params.go
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+)
+
+// ConsensusParams are the limits every node must agree on when producing and
+// verifying blocks.
+type ConsensusParams struct {
+	MaxBlockBytes int `json:"max_block_bytes"` // Maximum serialized size of a block's transactions
+	MaxBlockTxs   int `json:"max_block_txs"`   // Maximum number of transactions per block
+
+	// Validator set signing finality certificates, see Node.AddCommit. Blocks
+	// are never final without one.
+	Validators []ValidatorKey `json:"validators,omitempty"`
+}
+
+func DefaultConsensusParams() ConsensusParams {
+	return ConsensusParams{
+		MaxBlockBytes: 1 << 20, // 1 MiB
+		MaxBlockTxs:   1000,
+	}
+}
+
+// SortTransactions orders txs canonically so that every validator selects
+// and orders pending transactions the same way. Transactions are grouped by
+// sender in nonce order, so a sender's transactions are never selected out
+// of sequence. Senders are ranked by a hash of their key and prevHash rather
+// than by timestamp, so no sender can buy priority by backdating or by
+// grinding a key in advance.
+func SortTransactions(txs []*Transaction, prevHash []byte) {
+	ranks := make(map[*Transaction][]byte, len(txs))
+	for _, tx := range txs {
+		ranks[tx] = senderRank(tx, prevHash)
+	}
+	sort.SliceStable(txs, func(i, j int) bool {
+		return transactionLess(txs[i], txs[j], ranks[txs[i]], ranks[txs[j]])
+	})
+}
+
+// senderRank orders the senders of a block, see SortTransactions.
+func senderRank(tx *Transaction, prevHash []byte) []byte {
+	h := sha256.New()
+	h.Write(prevHash)
+	h.Write(tx.PublicKey)
+	return h.Sum(nil)
+}
+
+func transactionLess(a, b *Transaction, rankA, rankB []byte) bool {
+	if c := bytes.Compare(rankA, rankB); c != 0 {
+		return c < 0
+	}
+	if c := bytes.Compare(a.PublicKey, b.PublicKey); c != 0 {
+		return c < 0
+	}
+	if a.Nonce != b.Nonce {
+		return a.Nonce < b.Nonce
+	}
+	// Unsigned transactions share the empty sender and carry no nonce; among
+	// themselves they keep their timestamp order, e.g. an election before the
+	// votes on it
+	if a.Timestamp != b.Timestamp {
+		return a.Timestamp < b.Timestamp
+	}
+	return bytes.Compare(a.Hash, b.Hash) < 0
+}
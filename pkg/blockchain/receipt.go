@@ -0,0 +1,101 @@
+// pkg/blockchain/receipt.go
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type ReceiptStatus string
+
+const (
+	ReceiptApplied  ReceiptStatus = "applied"
+	ReceiptRejected ReceiptStatus = "rejected"
+)
+
+// Error codes reported in receipts of rejected transactions
+const (
+	ErrCodeInvalidPayload  = "invalid_payload"
+	ErrCodeInvalidEncoding = "invalid_encoding" // Malformed point or scalar, see crypto.DecodeError
+	ErrCodeUnknownType     = "unknown_type"
+	ErrCodeExecutionFailed = "execution_failed"
+)
+
+// Receipt records the outcome of applying a transaction included in a block.
+type Receipt struct {
+	TxID       string        `json:"tx_id"`
+	TxHash     []byte        `json:"tx_hash"`
+	BlockIndex int           `json:"block_index"`
+	Status     ReceiptStatus `json:"status"`
+	ErrorCode  string        `json:"error_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	ElectionID string        `json:"election_id,omitempty"` // Election affected by the transaction
+	Cost       uint64        `json:"cost"`                  // Execution cost charged, if metered
+}
+
+// Hash returns the digest of the receipt committed to by the block's
+// receipts root.
+func (r *Receipt) Hash() []byte {
+	data, _ := json.Marshal(r)
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// ReceiptsRoot returns the Merkle root over the hashes of receipts, in block
+// order.
+func ReceiptsRoot(receipts []*Receipt) []byte {
+	leaves := make([][]byte, len(receipts))
+	for i, receipt := range receipts {
+		leaves[i] = receipt.Hash()
+	}
+	return MerkleRoot(leaves)
+}
+
+// ExecutionError is returned by executors to reject a transaction with a
+// machine readable error code.
+type ExecutionError struct {
+	Code string
+	Err  error
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Code, e.Err)
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// NewExecutionError returns an ExecutionError with the given code and message.
+func NewExecutionError(code, format string, args ...interface{}) *ExecutionError {
+	return &ExecutionError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// NewReceipt builds the receipt for tx in block from the result of executing
+// it. A nil err marks the transaction as applied.
+func NewReceipt(tx *Transaction, block *Block, electionID string, cost uint64, err error) *Receipt {
+	receipt := &Receipt{
+		TxID:       tx.ID,
+		TxHash:     tx.Hash,
+		BlockIndex: block.Index,
+		Status:     ReceiptApplied,
+		ElectionID: electionID,
+		Cost:       cost,
+	}
+
+	if err != nil {
+		receipt.Status = ReceiptRejected
+		receipt.ErrorCode = ErrCodeExecutionFailed
+		receipt.Error = err.Error()
+
+		var execErr *ExecutionError
+		if errors.As(err, &execErr) {
+			receipt.ErrorCode = execErr.Code
+			receipt.Error = execErr.Err.Error()
+		}
+	}
+
+	return receipt
+}
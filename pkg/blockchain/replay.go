@@ -0,0 +1,82 @@
+// pkg/blockchain/replay.go
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// replayGuard tracks the transactions included on chain so that a
+// transaction cannot be replayed into a later block or onto another network.
+type replayGuard struct {
+	ids    map[string]bool   // Transaction IDs already included
+	hashes map[string]bool   // Hex-encoded transaction hashes already included
+	nonces map[string]uint64 // Highest included nonce per sender
+}
+
+func newReplayGuard() *replayGuard {
+	return &replayGuard{
+		ids:    make(map[string]bool),
+		hashes: make(map[string]bool),
+		nonces: make(map[string]uint64),
+	}
+}
+
+// check returns an error if tx was already included, belongs to another
+// chain, or reuses a nonce of its sender. A sender's nonce is only trusted
+// once its signature verifies, so nobody can burn another sender's nonces.
+func (g *replayGuard) check(tx *Transaction, chainID string) error {
+	if tx.ChainID != chainID {
+		return fmt.Errorf("transaction %s is bound to chain %q, expected %q", tx.ID, tx.ChainID, chainID)
+	}
+
+	if g.contains(tx) {
+		return fmt.Errorf("transaction %s already included in chain", tx.ID)
+	}
+
+	if err := tx.VerifySignature(); err != nil {
+		return fmt.Errorf("transaction %s: %v", tx.ID, err)
+	}
+
+	// Anonymous transactions are protected by their unique ID only
+	if sender := tx.Sender(); sender != "" {
+		if last, ok := g.nonces[sender]; ok && tx.Nonce <= last {
+			return fmt.Errorf("transaction %s reuses nonce %d (last included %d)", tx.ID, tx.Nonce, last)
+		}
+	}
+
+	return nil
+}
+
+// contains reports whether a transaction with the ID or hash of tx was
+// included.
+func (g *replayGuard) contains(tx *Transaction) bool {
+	return g.ids[tx.ID] || g.hashes[hex.EncodeToString(tx.Hash)]
+}
+
+// nextNonce returns the lowest nonce sender can still use.
+func (g *replayGuard) nextNonce(sender string) uint64 {
+	if last, ok := g.nonces[sender]; ok {
+		return last + 1
+	}
+	return 0
+}
+
+// add records tx as included.
+func (g *replayGuard) add(tx *Transaction) {
+	g.ids[tx.ID] = true
+	g.hashes[hex.EncodeToString(tx.Hash)] = true
+
+	if sender := tx.Sender(); sender != "" {
+		if last, ok := g.nonces[sender]; !ok || tx.Nonce > last {
+			g.nonces[sender] = tx.Nonce
+		}
+	}
+}
+
+// addBlock records every transaction of block as included.
+func (g *replayGuard) addBlock(block *Block) {
+	for _, tx := range block.Transactions {
+		g.add(tx)
+	}
+}
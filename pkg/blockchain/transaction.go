@@ -3,31 +3,54 @@ package blockchain
 
 import (
 	"bytes"
+	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/koushamad/election-system/pkg/crypto"
 )
 
 type TransactionType string
 
 const (
-	TxCreateElection TransactionType = "create_election"
-	TxCastVote       TransactionType = "cast_vote"
-	TxTallyVotes     TransactionType = "tally_votes"
+	TxCreateElection  TransactionType = "create_election"
+	TxCastVote        TransactionType = "cast_vote"
+	TxTallyVotes      TransactionType = "tally_votes"
+	TxDelegateVote    TransactionType = "delegate_vote"
+	TxMixBallots      TransactionType = "mix_ballots"
+	TxChallengeBallot TransactionType = "challenge_ballot"
+	TxProposeResults  TransactionType = "propose_results"
+	TxCertifyResults  TransactionType = "certify_results"
+	TxReleaseKey      TransactionType = "release_key"
 )
 
+// DefaultChainID is the network identifier transactions are bound to when
+// no other chain ID is configured.
+const DefaultChainID = "election-mainnet"
+
 type Transaction struct {
 	ID        string          `json:"id"`
+	ChainID   string          `json:"chain_id"` // Network the transaction is valid on
+	Nonce     uint64          `json:"nonce"`    // Per-sender sequence number
 	Type      TransactionType `json:"type"`
 	Payload   json.RawMessage `json:"payload"`
 	Hash      []byte          `json:"hash"`
 	Timestamp int64           `json:"timestamp"`
-	Signature []byte          `json:"signature"`
-	PublicKey []byte          `json:"public_key"` // Sender's public key
+	Signature []byte          `json:"signature"`  // Sender's BLS signature on Hash, see Sign
+	PublicKey []byte          `json:"public_key"` // Sender's BLS public key
 }
 
 func NewTransaction(txType TransactionType, payload interface{}) (*Transaction, error) {
+	return NewChainTransaction(DefaultChainID, 0, txType, payload)
+}
+
+// NewChainTransaction creates a transaction bound to chainID. The nonce must
+// be greater than any nonce already included on chain for the same sender.
+func NewChainTransaction(chainID string, nonce uint64, txType TransactionType, payload interface{}) (*Transaction, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
@@ -35,6 +58,8 @@ func NewTransaction(txType TransactionType, payload interface{}) (*Transaction,
 
 	tx := &Transaction{
 		ID:        GenerateUUID(),
+		ChainID:   chainID,
+		Nonce:     nonce,
 		Type:      txType,
 		Payload:   payloadBytes,
 		Timestamp: time.Now().Unix(),
@@ -47,12 +72,16 @@ func NewTransaction(txType TransactionType, payload interface{}) (*Transaction,
 func (t *Transaction) CalculateHash() []byte {
 	txData := struct {
 		ID        string
+		ChainID   string
+		Nonce     uint64
 		Type      TransactionType
 		Payload   json.RawMessage
 		Timestamp int64
 		PublicKey []byte
 	}{
 		ID:        t.ID,
+		ChainID:   t.ChainID,
+		Nonce:     t.Nonce,
 		Type:      t.Type,
 		Payload:   t.Payload,
 		Timestamp: t.Timestamp,
@@ -66,7 +95,7 @@ func (t *Transaction) CalculateHash() []byte {
 
 func (t *Transaction) Validate() bool {
 	// Basic validation
-	if t.ID == "" || len(t.Hash) == 0 {
+	if t.ID == "" || t.ChainID == "" || len(t.Hash) == 0 {
 		return false
 	}
 
@@ -80,9 +109,61 @@ func (t *Transaction) Validate() bool {
 	return bytes.Equal(calculatedHash, t.Hash)
 }
 
+// Sign makes the transaction a sender's: it sets the public key of key,
+// recomputes the hash, which covers the chain ID and nonce, and signs it.
+// The transaction must not be modified afterwards.
+func (t *Transaction) Sign(key *crypto.BLSKeyPair) {
+	t.PublicKey = crypto.MarshalG2(key.PublicKey)
+	t.Hash = t.CalculateHash()
+	t.Signature = crypto.BLSSign(key.PrivateKey, transactionMessage(t.Hash)).Marshal()
+}
+
+// VerifySignature checks that a transaction with a sender was signed by
+// its public key. Anonymous transactions must carry no signature.
+func (t *Transaction) VerifySignature() error {
+	if len(t.PublicKey) == 0 {
+		if len(t.Signature) != 0 {
+			return errors.New("signature without a sender public key")
+		}
+		return nil
+	}
+	key, err := crypto.UnmarshalG2(t.PublicKey)
+	if err != nil {
+		return fmt.Errorf("sender public key: %v", err)
+	}
+	sig, err := crypto.UnmarshalPoint(t.Signature)
+	if err != nil || !crypto.BLSVerify(key, transactionMessage(t.CalculateHash()), sig) {
+		return errors.New("invalid sender signature")
+	}
+	return nil
+}
+
+// transactionMessage is the message senders sign for the transaction of
+// hash, separated from the messages of validator commits.
+func transactionMessage(hash []byte) []byte {
+	return append([]byte("transaction/"), hash...)
+}
+
+// Size returns the number of bytes the transaction occupies in a serialized
+// block.
+func (t *Transaction) Size() int {
+	data, _ := json.Marshal(t)
+	return len(data)
+}
+
+// Sender returns the hex-encoded public key of the transaction sender, or an
+// empty string for anonymous transactions.
+func (t *Transaction) Sender() string {
+	return hex.EncodeToString(t.PublicKey)
+}
+
 // Helper function to generate a UUID
 func GenerateUUID() string {
-	// Simple implementation for demonstration purposes
-	hash := sha256.Sum256([]byte(time.Now().String()))
-	return fmt.Sprintf("%x", hash[:8])
+	// Random rather than time-derived so that transactions created in the
+	// same instant never share an ID
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id[:])
 }
@@ -0,0 +1,165 @@
+// pkg/crypto/bls.go
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+)
+
+// blsDomain separates the message hashes of BLS signatures from other uses
+// of HashToPoint.
+const blsDomain = "election-system/bls"
+
+// blsPossessionDomain separates proofs of possession from signatures.
+const blsPossessionDomain = "election-system/bls-possession"
+
+// BLSKeyPair is a BLS signing key over bn256. Signatures are G1 points and
+// public keys G2 points, so a signature is checked with a pairing.
+type BLSKeyPair struct {
+	PrivateKey *big.Int
+	PublicKey  *bn256.G2
+}
+
+// GenerateBLSKeys creates a BLS signing key.
+func GenerateBLSKeys() (*BLSKeyPair, error) {
+	priv, pub, err := bn256.RandomG2(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &BLSKeyPair{PrivateKey: priv, PublicKey: pub}, nil
+}
+
+// BLSSign signs message with priv.
+func BLSSign(priv *big.Int, message []byte) *bn256.G1 {
+	return new(bn256.G1).ScalarMult(HashToPoint(blsDomain, message), priv)
+}
+
+// BLSVerify checks that sig is a signature on message by the holder of pub:
+// e(sig, g2) = e(H(message), pub).
+func BLSVerify(pub *bn256.G2, message []byte, sig *bn256.G1) bool {
+	if pub == nil || sig == nil {
+		return false
+	}
+	return pairsEqual(sig, HashToPoint(blsDomain, message), pub)
+}
+
+// AggregateSignatures combines signatures into one, which verifies against
+// the aggregate of the signers' keys if they all signed the same message.
+func AggregateSignatures(sigs ...*bn256.G1) *bn256.G1 {
+	sum := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for _, sig := range sigs {
+		sum.Add(sum, sig)
+	}
+	return sum
+}
+
+// AggregatePublicKeys combines public keys for checking an aggregate
+// signature. Every key must come with a proof of possession, see
+// ProvePossession, or one signer could cancel the others' keys out.
+func AggregatePublicKeys(keys ...*bn256.G2) *bn256.G2 {
+	sum := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	for _, key := range keys {
+		sum.Add(sum, key)
+	}
+	return sum
+}
+
+// BLSVerifyAggregate checks that sig aggregates signatures on message by the
+// holders of all keys.
+func BLSVerifyAggregate(keys []*bn256.G2, message []byte, sig *bn256.G1) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	for _, key := range keys {
+		if key == nil {
+			return false
+		}
+	}
+	return BLSVerify(AggregatePublicKeys(keys...), message, sig)
+}
+
+// ProvePossession signs the public key of priv, proving the signer knows the
+// secret of the key it registers.
+func ProvePossession(priv *big.Int) *bn256.G1 {
+	pub := new(bn256.G2).ScalarBaseMult(priv)
+	return new(bn256.G1).ScalarMult(HashToPoint(blsPossessionDomain, pub.Marshal()), priv)
+}
+
+// VerifyPossession checks a proof of possession of pub.
+func VerifyPossession(pub *bn256.G2, proof *bn256.G1) bool {
+	if pub == nil || proof == nil {
+		return false
+	}
+	return pairsEqual(proof, HashToPoint(blsPossessionDomain, pub.Marshal()), pub)
+}
+
+// pairsEqual checks e(sig, g2) = e(hash, pub).
+func pairsEqual(sig, hash *bn256.G1, pub *bn256.G2) bool {
+	left := bn256.Pair(sig, new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
+	right := bn256.Pair(hash, pub)
+	return bytes.Equal(left.Marshal(), right.Marshal())
+}
+
+// BlindMessage hides message from a BLS signer by multiplying its hash with
+// a random factor, which Unblind removes from the signature.
+func BlindMessage(message []byte) (*bn256.G1, *big.Int, error) {
+	r, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.Sign() == 0 {
+		return nil, nil, errors.New("zero blinding factor")
+	}
+	return new(bn256.G1).ScalarMult(HashToPoint(blsDomain, message), r), r, nil
+}
+
+// BlindSign signs a blinded message without learning the message.
+func BlindSign(priv *big.Int, blinded *bn256.G1) *bn256.G1 {
+	return new(bn256.G1).ScalarMult(blinded, priv)
+}
+
+// Unblind turns the signature on a blinded message into a signature on the
+// message itself, which the signer cannot link to the blinded one.
+func Unblind(blindSig *bn256.G1, r *big.Int) *bn256.G1 {
+	return new(bn256.G1).ScalarMult(blindSig, new(big.Int).ModInverse(r, bn256.Order))
+}
+
+// MarshalG2 encodes a G2 point, e.g. a BLS public key. A nil point encodes
+// to nil.
+func MarshalG2(p *bn256.G2) []byte {
+	if p == nil {
+		return nil
+	}
+	return p.Marshal()
+}
+
+// UnmarshalG2 decodes a G2 point produced by MarshalG2. Like UnmarshalPoint
+// it rejects the identity and non-canonical encodings, and since the twist
+// has a cofactor it also checks the point is in the prime-order subgroup.
+func UnmarshalG2(data []byte) (*bn256.G2, error) {
+	if len(data) == 1 && data[0] == 0 {
+		return nil, &DecodeError{"G2 point", ErrIdentityPoint}
+	}
+	if len(data) != g2Size {
+		return nil, &DecodeError{"G2 point", ErrEncodingLength}
+	}
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, &DecodeError{"G2 point", ErrNotOnCurve}
+	}
+	encoded := p.Marshal()
+	if len(encoded) != g2Size {
+		return nil, &DecodeError{"G2 point", ErrIdentityPoint}
+	}
+	if !bytes.Equal(encoded, data) {
+		return nil, &DecodeError{"G2 point", ErrNonCanonical}
+	}
+	if len(new(bn256.G2).ScalarMult(p, bn256.Order).Marshal()) != 1 {
+		return nil, &DecodeError{"G2 point", ErrNotInSubgroup}
+	}
+	return p, nil
+}
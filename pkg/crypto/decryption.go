@@ -0,0 +1,103 @@
+// pkg/crypto/decryption.go
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// DecryptionProof is a Chaum-Pedersen proof that a ciphertext decrypts to a
+// claimed message M, the point m*G of a value m or an embedded text chunk,
+// under the private key of a public key Y = x*G: the shared secret
+// D = C2 - M satisfies D = x*C1. It reveals nothing about x, so
+// anyone can check a tally without the key.
+type DecryptionProof struct {
+	C *big.Int // Challenge
+	Z *big.Int // Response
+}
+
+// ProveDecryption proves that ct decrypts to m under privKey, for context.
+func ProveDecryption(privKey *big.Int, ct *Ciphertext, m int64, context []byte) (*DecryptionProof, error) {
+	return ProvePointDecryption(privKey, ct, new(bn256.G1).ScalarBaseMult(big.NewInt(m)), context)
+}
+
+// VerifyDecryption checks a proof that ct decrypts to m under the private
+// key of pubKey, for context.
+func VerifyDecryption(pubKey *bn256.G1, ct *Ciphertext, m int64, proof *DecryptionProof, context []byte) bool {
+	return VerifyPointDecryption(pubKey, ct, new(bn256.G1).ScalarBaseMult(big.NewInt(m)), proof, context)
+}
+
+// ProvePointDecryption proves that ct decrypts to the point m under privKey,
+// for context. Texts decrypt to points rather than small values, see
+// EncryptText.
+func ProvePointDecryption(privKey *big.Int, ct *Ciphertext, m *bn256.G1, context []byte) (*DecryptionProof, error) {
+	w, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	pubKey := new(bn256.G1).ScalarBaseMult(privKey)
+	a := new(bn256.G1).ScalarBaseMult(w)
+	b := new(bn256.G1).ScalarMult(ct.C1, w)
+
+	c := decryptionChallenge(pubKey, ct, m, a, b, context)
+	z := new(big.Int).Mul(c, privKey)
+	z.Add(z, w).Mod(z, bn256.Order)
+	return &DecryptionProof{C: c, Z: z}, nil
+}
+
+// VerifyPointDecryption checks a proof that ct decrypts to the point m under
+// the private key of pubKey, for context.
+func VerifyPointDecryption(pubKey *bn256.G1, ct *Ciphertext, m *bn256.G1, proof *DecryptionProof, context []byte) bool {
+	if proof == nil || proof.C == nil || proof.Z == nil || pubKey == nil || ct == nil || ct.C1 == nil || ct.C2 == nil || m == nil {
+		return false
+	}
+	shared := new(bn256.G1).Add(ct.C2, new(bn256.G1).Neg(m))
+
+	// A = z*G - c*Y and B = z*C1 - c*D
+	a := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.Z), new(bn256.G1).Neg(new(bn256.G1).ScalarMult(pubKey, proof.C)))
+	b := new(bn256.G1).Add(new(bn256.G1).ScalarMult(ct.C1, proof.Z), new(bn256.G1).Neg(new(bn256.G1).ScalarMult(shared, proof.C)))
+	return decryptionChallenge(pubKey, ct, m, a, b, context).Cmp(proof.C) == 0
+}
+
+func decryptionChallenge(pubKey *bn256.G1, ct *Ciphertext, m, a, b *bn256.G1, context []byte) *big.Int {
+	transcript := merlin.NewTranscript("decryption_proof")
+	transcript.AppendMessage([]byte("context"), context)
+	transcript.AppendMessage([]byte("pubkey"), pubKey.Marshal())
+	transcript.AppendMessage([]byte("ciphertext"), ct.Marshal())
+	transcript.AppendMessage([]byte("message"), m.Marshal())
+	transcript.AppendMessage([]byte("a"), a.Marshal())
+	transcript.AppendMessage([]byte("b"), b.Marshal())
+
+	challenge := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return challenge.Mod(challenge, bn256.Order)
+}
+
+// Marshal encodes the proof as C || Z.
+func (p *DecryptionProof) Marshal() []byte {
+	return append(marshalScalar(p.C), marshalScalar(p.Z)...)
+}
+
+func (p DecryptionProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Marshal())
+}
+
+func (p *DecryptionProof) UnmarshalJSON(data []byte) error {
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2*scalarSize {
+		return errors.New("malformed decryption proof")
+	}
+	scalars, err := unmarshalScalars([][]byte{raw[:scalarSize], raw[scalarSize:]})
+	if err != nil {
+		return err
+	}
+	p.C, p.Z = scalars[0], scalars[1]
+	return nil
+}
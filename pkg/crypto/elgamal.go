@@ -0,0 +1,158 @@
+// pkg/crypto/elgamal.go
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+)
+
+// Ciphertext is an exponential ElGamal ciphertext (g^r, pk^r * g^m) over G1.
+// Ciphertexts under the same key can be added to add their plaintexts.
+type Ciphertext struct {
+	C1 *bn256.G1
+	C2 *bn256.G1
+}
+
+// EncryptValue encrypts m under pubKey with the given randomness. It is used
+// directly when the randomness must be revealed later, e.g. for ballot audits.
+func EncryptValue(pubKey *bn256.G1, m int64, r *big.Int) *Ciphertext {
+	c1 := new(bn256.G1).ScalarBaseMult(r)
+	c2 := new(bn256.G1).Add(
+		new(bn256.G1).ScalarMult(pubKey, r),
+		new(bn256.G1).ScalarBaseMult(big.NewInt(m)),
+	)
+	return &Ciphertext{C1: c1, C2: c2}
+}
+
+// EncryptValueRandom encrypts m under pubKey with fresh randomness, which is
+// returned so the caller can prove statements about the ciphertext.
+func EncryptValueRandom(pubKey *bn256.G1, m int64) (*Ciphertext, *big.Int, error) {
+	r, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, nil, err
+	}
+	return EncryptValue(pubKey, m, r), r, nil
+}
+
+// ZeroCiphertext returns the trivial encryption of zero, the identity for Add.
+func ZeroCiphertext() *Ciphertext {
+	return &Ciphertext{
+		C1: new(bn256.G1).ScalarBaseMult(big.NewInt(0)),
+		C2: new(bn256.G1).ScalarBaseMult(big.NewInt(0)),
+	}
+}
+
+// Add returns the encryption of the sum of the plaintexts of c and other.
+func (c *Ciphertext) Add(other *Ciphertext) *Ciphertext {
+	return &Ciphertext{
+		C1: new(bn256.G1).Add(c.C1, other.C1),
+		C2: new(bn256.G1).Add(c.C2, other.C2),
+	}
+}
+
+// Sub returns the encryption of the difference of the plaintexts of c and
+// other.
+func (c *Ciphertext) Sub(other *Ciphertext) *Ciphertext {
+	return &Ciphertext{
+		C1: new(bn256.G1).Add(c.C1, new(bn256.G1).Neg(other.C1)),
+		C2: new(bn256.G1).Add(c.C2, new(bn256.G1).Neg(other.C2)),
+	}
+}
+
+// ScalarMult returns the encryption of the plaintext of c multiplied by k
+// modulo the group order.
+func (c *Ciphertext) ScalarMult(k *big.Int) *Ciphertext {
+	return &Ciphertext{
+		C1: new(bn256.G1).ScalarMult(c.C1, k),
+		C2: new(bn256.G1).ScalarMult(c.C2, k),
+	}
+}
+
+// Equal reports whether both ciphertexts consist of the same points.
+func (c *Ciphertext) Equal(other *Ciphertext) bool {
+	return bytes.Equal(c.C1.Marshal(), other.C1.Marshal()) &&
+		bytes.Equal(c.C2.Marshal(), other.C2.Marshal())
+}
+
+// Points returns the ciphertext in the two point form used by Ballot.
+func (c *Ciphertext) Points() []*bn256.G1 {
+	return []*bn256.G1{c.C1, c.C2}
+}
+
+// CiphertextFromPoints converts the two point form back into a Ciphertext.
+func CiphertextFromPoints(points []*bn256.G1) (*Ciphertext, error) {
+	if len(points) != 2 || points[0] == nil || points[1] == nil {
+		return nil, errors.New("ciphertext must consist of two points")
+	}
+	return &Ciphertext{C1: points[0], C2: points[1]}, nil
+}
+
+// Marshal encodes the ciphertext as the concatenation of its two points.
+func (c *Ciphertext) Marshal() []byte {
+	return append(c.C1.Marshal(), c.C2.Marshal()...)
+}
+
+// Unmarshal decodes a ciphertext produced by Marshal.
+func (c *Ciphertext) Unmarshal(data []byte) error {
+	if len(data) != 2*pointSize {
+		return &DecodeError{"ciphertext", ErrEncodingLength}
+	}
+	c1, err := UnmarshalPoint(data[:pointSize])
+	if err != nil {
+		return err
+	}
+	c2, err := UnmarshalPoint(data[pointSize:])
+	if err != nil {
+		return err
+	}
+	c.C1, c.C2 = c1, c2
+	return nil
+}
+
+func (c Ciphertext) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Marshal())
+}
+
+func (c *Ciphertext) UnmarshalJSON(data []byte) error {
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return c.Unmarshal(raw)
+}
+
+// DecryptValue decrypts c with privKey and recovers the plaintext by
+// baby-step giant-step search, which takes about 2*sqrt(max) group
+// operations: plaintexts outside [0, max] are reported as errors.
+func DecryptValue(privKey *big.Int, c *Ciphertext, max int64) (int64, error) {
+	if max < 0 {
+		return 0, errors.New("plaintext out of range")
+	}
+	// g^m = c2 - c1^priv
+	shared := new(bn256.G1).ScalarMult(c.C1, privKey)
+	gm := new(bn256.G1).Add(c.C2, new(bn256.G1).Neg(shared))
+
+	// Baby steps g^j for j < step, then giant steps g^m / g^(i*step)
+	step := int64(math.Sqrt(float64(max))) + 1
+	baby := make(map[string]int64, step)
+	point := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	g := new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	for j := int64(0); j < step; j++ {
+		baby[string(point.Marshal())] = j
+		point.Add(point, g)
+	}
+	giant := new(bn256.G1).Neg(point)
+	for i := int64(0); i*step <= max; i++ {
+		if j, ok := baby[string(gm.Marshal())]; ok && i*step+j <= max {
+			return i*step + j, nil
+		}
+		gm.Add(gm, giant)
+	}
+	return 0, errors.New("plaintext out of range")
+}
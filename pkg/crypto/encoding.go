@@ -0,0 +1,147 @@
+// pkg/crypto/encoding.go
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+)
+
+// pointSize is the length of a marshaled G1 point.
+const pointSize = 64
+
+// g2Size is the length of a marshaled G2 point other than the identity.
+const g2Size = 1 + 4*32
+
+// scalarSize is the length of a marshaled scalar modulo bn256.Order.
+const scalarSize = 32
+
+// Reasons a point or scalar is rejected by the decoders, wrapped in a
+// DecodeError.
+var (
+	ErrEncodingLength = errors.New("wrong encoding length")
+	ErrNotOnCurve     = errors.New("not on the curve")
+	ErrNonCanonical   = errors.New("non-canonical encoding")
+	ErrIdentityPoint  = errors.New("point at infinity")
+	ErrNotInSubgroup  = errors.New("not in the prime-order subgroup")
+	ErrScalarRange    = errors.New("scalar not reduced modulo the group order")
+)
+
+// DecodeError reports a point or scalar rejected while decoding, typically
+// from a transaction payload. Err is one of the errors above.
+type DecodeError struct {
+	What string // "G1 point", "G2 point", "ciphertext" or "scalar"
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.What, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalPoint encodes a G1 point for storage in JSON payloads. A nil point
+// encodes to nil.
+func MarshalPoint(p *bn256.G1) []byte {
+	if p == nil {
+		return nil
+	}
+	return p.Marshal()
+}
+
+// UnmarshalPoint decodes a G1 point produced by MarshalPoint. It accepts
+// only the exact, canonical encoding of a point other than the identity, so
+// every point has a single encoding and none can cancel out the others in
+// the arithmetic. G1 has prime order, so any curve point is in the group.
+func UnmarshalPoint(data []byte) (*bn256.G1, error) {
+	if len(data) != pointSize {
+		return nil, &DecodeError{"G1 point", ErrEncodingLength}
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, &DecodeError{"G1 point", ErrNotOnCurve}
+	}
+	encoded := p.Marshal()
+	if bytes.Equal(encoded, make([]byte, pointSize)) {
+		return nil, &DecodeError{"G1 point", ErrIdentityPoint}
+	}
+	if !bytes.Equal(encoded, data) {
+		return nil, &DecodeError{"G1 point", ErrNonCanonical}
+	}
+	return p, nil
+}
+
+// MarshalPoints encodes a list of G1 points, e.g. an ElGamal ciphertext.
+func MarshalPoints(points []*bn256.G1) [][]byte {
+	if points == nil {
+		return nil
+	}
+	encoded := make([][]byte, len(points))
+	for i, p := range points {
+		encoded[i] = MarshalPoint(p)
+	}
+	return encoded
+}
+
+// UnmarshalPoints decodes a list of G1 points produced by MarshalPoints.
+func UnmarshalPoints(data [][]byte) ([]*bn256.G1, error) {
+	if data == nil {
+		return nil, nil
+	}
+	points := make([]*bn256.G1, len(data))
+	for i, d := range data {
+		p, err := UnmarshalPoint(d)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	return points, nil
+}
+
+// marshalScalar encodes a scalar as a fixed size big-endian integer.
+func marshalScalar(s *big.Int) []byte {
+	out := make([]byte, scalarSize)
+	s.FillBytes(out)
+	return out
+}
+
+// marshalScalars encodes a list of scalars with marshalScalar.
+func marshalScalars(scalars []*big.Int) [][]byte {
+	encoded := make([][]byte, len(scalars))
+	for i, s := range scalars {
+		encoded[i] = marshalScalar(s)
+	}
+	return encoded
+}
+
+// unmarshalScalar decodes a scalar produced by marshalScalar, rejecting
+// values not reduced modulo bn256.Order.
+func unmarshalScalar(data []byte) (*big.Int, error) {
+	if len(data) != scalarSize {
+		return nil, &DecodeError{"scalar", ErrEncodingLength}
+	}
+	s := new(big.Int).SetBytes(data)
+	if s.Cmp(bn256.Order) >= 0 {
+		return nil, &DecodeError{"scalar", ErrScalarRange}
+	}
+	return s, nil
+}
+
+// unmarshalScalars decodes a list of scalars produced by marshalScalars.
+func unmarshalScalars(data [][]byte) ([]*big.Int, error) {
+	scalars := make([]*big.Int, len(data))
+	for i, d := range data {
+		s, err := unmarshalScalar(d)
+		if err != nil {
+			return nil, err
+		}
+		scalars[i] = s
+	}
+	return scalars, nil
+}
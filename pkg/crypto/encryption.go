@@ -8,7 +8,10 @@ import (
 )
 
 func EncryptVote(pubKey *bn256.G1, vote int) ([]*bn256.G1, error) {
-	r, _ := rand.Int(rand.Reader, bn256.Order)
+	r, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create the first part of the ciphertext: g^r
 	c1 := new(bn256.G1).ScalarBaseMult(r)
@@ -0,0 +1,102 @@
+// pkg/crypto/hybrid.go
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+)
+
+// SealedText is a message hybrid-encrypted to an ElGamal public key: an
+// ephemeral Diffie-Hellman share and the AES-GCM encryption of the message
+// under a key derived from the shared point.
+type SealedText struct {
+	Ephemeral  *bn256.G1 `json:"ephemeral"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// SealText encrypts plaintext to pubKey. The additional data is
+// authenticated but not encrypted; Open must be given the same.
+func SealText(pubKey *bn256.G1, plaintext, additionalData []byte) (*SealedText, error) {
+	k, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral := new(bn256.G1).ScalarBaseMult(k)
+	aead, err := sealingCipher(ephemeral, new(bn256.G1).ScalarMult(pubKey, k))
+	if err != nil {
+		return nil, err
+	}
+
+	// Every key encrypts a single message, so a fixed nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	return &SealedText{
+		Ephemeral:  ephemeral,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, additionalData),
+	}, nil
+}
+
+// Open decrypts the message with the private key it was sealed to.
+func (s *SealedText) Open(privKey *big.Int, additionalData []byte) ([]byte, error) {
+	if s.Ephemeral == nil {
+		return nil, errors.New("sealed text has no ephemeral key")
+	}
+	aead, err := sealingCipher(s.Ephemeral, new(bn256.G1).ScalarMult(s.Ephemeral, privKey))
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), s.Ciphertext, additionalData)
+}
+
+// sealingCipher derives the AES-GCM cipher of a message from its ephemeral
+// share and the shared point.
+func sealingCipher(ephemeral, shared *bn256.G1) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte("sealed_text"))
+	h.Write(ephemeral.Marshal())
+	h.Write(shared.Marshal())
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MarshalJSON encodes the ephemeral key in its binary form, since bn256
+// points have no JSON representation of their own.
+func (s SealedText) MarshalJSON() ([]byte, error) {
+	type alias SealedText
+	return json.Marshal(struct {
+		alias
+		Ephemeral []byte `json:"ephemeral"`
+	}{
+		alias:     alias(s),
+		Ephemeral: MarshalPoint(s.Ephemeral),
+	})
+}
+
+func (s *SealedText) UnmarshalJSON(data []byte) error {
+	type alias SealedText
+	aux := struct {
+		*alias
+		Ephemeral []byte `json:"ephemeral"`
+	}{
+		alias: (*alias)(s),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	ephemeral, err := UnmarshalPoint(aux.Ephemeral)
+	if err != nil {
+		return err
+	}
+	s.Ephemeral = ephemeral
+	return nil
+}
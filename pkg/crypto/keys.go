@@ -12,8 +12,11 @@ type KeyPair struct {
 	PublicKey  *bn256.G1
 }
 
-func GenerateKeys() *KeyPair {
-	priv, _ := rand.Int(rand.Reader, bn256.Order)
+func GenerateKeys() (*KeyPair, error) {
+	priv, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
 	pub := new(bn256.G1).ScalarBaseMult(priv)
-	return &KeyPair{priv, pub}
+	return &KeyPair{priv, pub}, nil
 }
@@ -0,0 +1,108 @@
+// pkg/crypto/multisig.go
+package crypto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/bn256"
+)
+
+// MultiSignature is an aggregate BLS signature on one message by members of
+// a fixed signer set, e.g. validators or trustees. The bitmap records which
+// members signed, by their index in the set, so the certificate stays the
+// size of a single signature however many sign.
+type MultiSignature struct {
+	Signers   []byte    // Bit i is set if member i signed
+	Signature *bn256.G1 // Aggregate of the members' signatures
+}
+
+// NewMultiSignature returns an empty multi-signature for a set of n members.
+func NewMultiSignature(n int) *MultiSignature {
+	return &MultiSignature{Signers: make([]byte, (n+7)/8)}
+}
+
+// Add aggregates the signature of member index.
+func (m *MultiSignature) Add(index int, sig *bn256.G1) error {
+	if index < 0 || index >= 8*len(m.Signers) {
+		return fmt.Errorf("signer %d is not in the set", index)
+	}
+	if m.HasSigned(index) {
+		return fmt.Errorf("signer %d has already signed", index)
+	}
+	if m.Signature == nil {
+		m.Signature = new(bn256.G1).Set(sig)
+	} else {
+		m.Signature = AggregateSignatures(m.Signature, sig)
+	}
+	m.Signers[index/8] |= 1 << (index % 8)
+	return nil
+}
+
+// HasSigned reports whether member index signed.
+func (m *MultiSignature) HasSigned(index int) bool {
+	return index >= 0 && index < 8*len(m.Signers) && m.Signers[index/8]&(1<<(index%8)) != 0
+}
+
+// Count returns the number of members who signed.
+func (m *MultiSignature) Count() int {
+	count := 0
+	for i := 0; i < 8*len(m.Signers); i++ {
+		if m.HasSigned(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// Verify checks that the signers marked in the bitmap, as members of keys,
+// signed message. Keys must have been registered with proofs of possession.
+func (m *MultiSignature) Verify(keys []*bn256.G2, message []byte) bool {
+	if m == nil || m.Signature == nil || len(m.Signers) != (len(keys)+7)/8 {
+		return false
+	}
+	var signers []*bn256.G2
+	for i := 0; i < 8*len(m.Signers); i++ {
+		if !m.HasSigned(i) {
+			continue
+		}
+		if i >= len(keys) {
+			return false
+		}
+		signers = append(signers, keys[i])
+	}
+	return BLSVerifyAggregate(signers, message, m.Signature)
+}
+
+type multiSignatureJSON struct {
+	Signers   []byte `json:"signers"`
+	Signature []byte `json:"signature"`
+}
+
+func (m MultiSignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(multiSignatureJSON{Signers: m.Signers, Signature: MarshalPoint(m.Signature)})
+}
+
+func (m *MultiSignature) UnmarshalJSON(data []byte) error {
+	var aux multiSignatureJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Signature) == 0 {
+		// Nobody has signed yet
+		for _, b := range aux.Signers {
+			if b != 0 {
+				return errors.New("multi-signature without a signature")
+			}
+		}
+		m.Signers, m.Signature = aux.Signers, nil
+		return nil
+	}
+	sig, err := UnmarshalPoint(aux.Signature)
+	if err != nil {
+		return err
+	}
+	m.Signers, m.Signature = aux.Signers, sig
+	return nil
+}
@@ -0,0 +1,129 @@
+// pkg/crypto/nullifier.go
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// fieldPrime is the prime of the field G1 is defined over, on the curve
+// y^2 = x^3 + 3.
+var fieldPrime, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+// HashToPoint maps data to a G1 point whose discrete logarithm nobody knows,
+// by hashing it to x coordinates until one lies on the curve. The domain
+// separates the uses of the function.
+func HashToPoint(domain string, data []byte) *bn256.G1 {
+	for counter := uint32(0); ; counter++ {
+		h := sha256.New()
+		h.Write([]byte(domain))
+		binary.Write(h, binary.BigEndian, counter)
+		h.Write(data)
+		x := new(big.Int).SetBytes(h.Sum(nil))
+		x.Mod(x, fieldPrime)
+
+		rhs := new(big.Int).Exp(x, big.NewInt(3), fieldPrime)
+		rhs.Add(rhs, big.NewInt(3)).Mod(rhs, fieldPrime)
+		y := new(big.Int).ModSqrt(rhs, fieldPrime)
+		if y == nil {
+			continue
+		}
+
+		encoded := make([]byte, pointSize)
+		x.FillBytes(encoded[:scalarSize])
+		y.FillBytes(encoded[scalarSize:])
+		if p, err := UnmarshalPoint(encoded); err == nil {
+			return p
+		}
+	}
+}
+
+// NullifierProof is a Chaum-Pedersen proof that a nullifier N = x*H and a
+// credential key X = x*G share the secret x. The proof is bound to a message,
+// which it thereby signs on behalf of the credential.
+type NullifierProof struct {
+	C *big.Int // Challenge
+	Z *big.Int // Response
+}
+
+// Nullifier returns the nullifier of secret for base, which is the same for
+// every use of the credential with that base and unlinkable across bases.
+func Nullifier(secret *big.Int, base *bn256.G1) *bn256.G1 {
+	return new(bn256.G1).ScalarMult(base, secret)
+}
+
+// ProveNullifier proves that Nullifier(secret, base) belongs to the
+// credential key of secret, signing message.
+func ProveNullifier(secret *big.Int, base *bn256.G1, message []byte) (*NullifierProof, error) {
+	w, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	key := new(bn256.G1).ScalarBaseMult(secret)
+	nullifier := Nullifier(secret, base)
+	a := new(bn256.G1).ScalarBaseMult(w)
+	b := new(bn256.G1).ScalarMult(base, w)
+
+	c := nullifierChallenge(key, base, nullifier, a, b, message)
+	z := new(big.Int).Mul(c, secret)
+	z.Add(z, w).Mod(z, bn256.Order)
+	return &NullifierProof{C: c, Z: z}, nil
+}
+
+// VerifyNullifier checks a proof that nullifier was derived from base with
+// the secret of the credential key, for message.
+func VerifyNullifier(key, base, nullifier *bn256.G1, proof *NullifierProof, message []byte) bool {
+	if proof == nil || proof.C == nil || proof.Z == nil || key == nil || nullifier == nil {
+		return false
+	}
+
+	// A = z*G - c*X and B = z*H - c*N
+	a := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.Z), new(bn256.G1).Neg(new(bn256.G1).ScalarMult(key, proof.C)))
+	b := new(bn256.G1).Add(new(bn256.G1).ScalarMult(base, proof.Z), new(bn256.G1).Neg(new(bn256.G1).ScalarMult(nullifier, proof.C)))
+	return nullifierChallenge(key, base, nullifier, a, b, message).Cmp(proof.C) == 0
+}
+
+func nullifierChallenge(key, base, nullifier, a, b *bn256.G1, message []byte) *big.Int {
+	transcript := merlin.NewTranscript("nullifier_proof")
+	transcript.AppendMessage([]byte("message"), message)
+	transcript.AppendMessage([]byte("key"), key.Marshal())
+	transcript.AppendMessage([]byte("base"), base.Marshal())
+	transcript.AppendMessage([]byte("nullifier"), nullifier.Marshal())
+	transcript.AppendMessage([]byte("a"), a.Marshal())
+	transcript.AppendMessage([]byte("b"), b.Marshal())
+
+	challenge := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return challenge.Mod(challenge, bn256.Order)
+}
+
+// Marshal encodes the proof as C || Z.
+func (p *NullifierProof) Marshal() []byte {
+	return append(marshalScalar(p.C), marshalScalar(p.Z)...)
+}
+
+func (p NullifierProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Marshal())
+}
+
+func (p *NullifierProof) UnmarshalJSON(data []byte) error {
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2*scalarSize {
+		return errors.New("malformed nullifier proof")
+	}
+	scalars, err := unmarshalScalars([][]byte{raw[:scalarSize], raw[scalarSize:]})
+	if err != nil {
+		return err
+	}
+	p.C, p.Z = scalars[0], scalars[1]
+	return nil
+}
@@ -0,0 +1,203 @@
+// pkg/crypto/proofs.go
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// MembershipProof is a non-interactive disjunctive Chaum-Pedersen proof that
+// a ciphertext encrypts one of a public set of values, without revealing
+// which. It holds one branch per allowed value.
+type MembershipProof struct {
+	A []*bn256.G1 // Commitments g^w
+	B []*bn256.G1 // Commitments pk^w
+	C []*big.Int  // Per-branch challenges, summing to the transcript challenge
+	Z []*big.Int  // Responses
+}
+
+// membershipBranchSize is the marshaled size of one branch: A, B, C and Z.
+const membershipBranchSize = 2*pointSize + 2*scalarSize
+
+// ProveMembership proves that ct = EncryptValue(pubKey, values[index], r).
+// The context binds the proof to its use, e.g. an election and voter, so it
+// cannot be replayed for another ballot.
+func ProveMembership(pubKey *bn256.G1, ct *Ciphertext, r *big.Int, values []int64, index int, context []byte) (*MembershipProof, error) {
+	if index < 0 || index >= len(values) {
+		return nil, errors.New("value index out of range")
+	}
+
+	n := len(values)
+	proof := &MembershipProof{
+		A: make([]*bn256.G1, n),
+		B: make([]*bn256.G1, n),
+		C: make([]*big.Int, n),
+		Z: make([]*big.Int, n),
+	}
+
+	// Simulate every branch except the true one
+	sum := new(big.Int)
+	for j := range values {
+		if j == index {
+			continue
+		}
+		c, err := rand.Int(rand.Reader, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		z, err := rand.Int(rand.Reader, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		proof.C[j], proof.Z[j] = c, z
+		proof.A[j], proof.B[j] = membershipCommitments(pubKey, ct, values[j], c, z)
+		sum.Add(sum, c)
+	}
+
+	// Commit honestly for the true branch
+	w, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	proof.A[index] = new(bn256.G1).ScalarBaseMult(w)
+	proof.B[index] = new(bn256.G1).ScalarMult(pubKey, w)
+
+	challenge := membershipChallenge(pubKey, ct, values, proof, context)
+	proof.C[index] = new(big.Int).Sub(challenge, sum)
+	proof.C[index].Mod(proof.C[index], bn256.Order)
+	proof.Z[index] = new(big.Int).Mul(proof.C[index], r)
+	proof.Z[index].Add(proof.Z[index], w)
+	proof.Z[index].Mod(proof.Z[index], bn256.Order)
+
+	return proof, nil
+}
+
+// VerifyMembership checks a proof that ct encrypts one of values under
+// pubKey, for the same context it was created with.
+func VerifyMembership(pubKey *bn256.G1, ct *Ciphertext, values []int64, proof *MembershipProof, context []byte) bool {
+	n := len(values)
+	if proof == nil || len(proof.A) != n || len(proof.B) != n || len(proof.C) != n || len(proof.Z) != n {
+		return false
+	}
+
+	sum := new(big.Int)
+	for j, m := range values {
+		a, b := membershipCommitments(pubKey, ct, m, proof.C[j], proof.Z[j])
+		if !bytes.Equal(a.Marshal(), proof.A[j].Marshal()) || !bytes.Equal(b.Marshal(), proof.B[j].Marshal()) {
+			return false
+		}
+		sum.Add(sum, proof.C[j])
+	}
+	sum.Mod(sum, bn256.Order)
+
+	challenge := membershipChallenge(pubKey, ct, values, proof, context)
+	return sum.Cmp(challenge) == 0
+}
+
+// membershipCommitments recomputes the commitments of a branch for value m
+// from its challenge c and response z:
+//
+//	A = g^z / c1^c
+//	B = pk^z / (c2 / g^m)^c
+func membershipCommitments(pubKey *bn256.G1, ct *Ciphertext, m int64, c, z *big.Int) (*bn256.G1, *bn256.G1) {
+	a := new(bn256.G1).Add(
+		new(bn256.G1).ScalarBaseMult(z),
+		new(bn256.G1).Neg(new(bn256.G1).ScalarMult(ct.C1, c)),
+	)
+
+	shifted := new(bn256.G1).Add(ct.C2, new(bn256.G1).Neg(new(bn256.G1).ScalarBaseMult(big.NewInt(m))))
+	b := new(bn256.G1).Add(
+		new(bn256.G1).ScalarMult(pubKey, z),
+		new(bn256.G1).Neg(new(bn256.G1).ScalarMult(shifted, c)),
+	)
+	return a, b
+}
+
+// membershipChallenge derives the Fiat-Shamir challenge from the statement
+// and all branch commitments.
+func membershipChallenge(pubKey *bn256.G1, ct *Ciphertext, values []int64, proof *MembershipProof, context []byte) *big.Int {
+	transcript := merlin.NewTranscript("membership_proof")
+	transcript.AppendMessage([]byte("context"), context)
+	transcript.AppendMessage([]byte("public_key"), pubKey.Marshal())
+	transcript.AppendMessage([]byte("c1"), ct.C1.Marshal())
+	transcript.AppendMessage([]byte("c2"), ct.C2.Marshal())
+	for j, m := range values {
+		transcript.AppendMessage([]byte("value"), big.NewInt(m).Bytes())
+		transcript.AppendMessage([]byte("a"), proof.A[j].Marshal())
+		transcript.AppendMessage([]byte("b"), proof.B[j].Marshal())
+	}
+
+	challenge := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return challenge.Mod(challenge, bn256.Order)
+}
+
+// Marshal encodes the proof as its branches, each A || B || C || Z.
+func (p *MembershipProof) Marshal() []byte {
+	out := make([]byte, 0, len(p.A)*membershipBranchSize)
+	for j := range p.A {
+		out = append(out, p.A[j].Marshal()...)
+		out = append(out, p.B[j].Marshal()...)
+		out = append(out, marshalScalar(p.C[j])...)
+		out = append(out, marshalScalar(p.Z[j])...)
+	}
+	return out
+}
+
+// UnmarshalMembershipProof decodes a proof produced by Marshal.
+func UnmarshalMembershipProof(data []byte) (*MembershipProof, error) {
+	if len(data) == 0 || len(data)%membershipBranchSize != 0 {
+		return nil, errors.New("malformed membership proof")
+	}
+
+	n := len(data) / membershipBranchSize
+	proof := &MembershipProof{
+		A: make([]*bn256.G1, n),
+		B: make([]*bn256.G1, n),
+		C: make([]*big.Int, n),
+		Z: make([]*big.Int, n),
+	}
+	for j := 0; j < n; j++ {
+		branch := data[j*membershipBranchSize : (j+1)*membershipBranchSize]
+		a, err := UnmarshalPoint(branch[:pointSize])
+		if err != nil {
+			return nil, err
+		}
+		b, err := UnmarshalPoint(branch[pointSize : 2*pointSize])
+		if err != nil {
+			return nil, err
+		}
+		c, err := unmarshalScalar(branch[2*pointSize : 2*pointSize+scalarSize])
+		if err != nil {
+			return nil, err
+		}
+		z, err := unmarshalScalar(branch[2*pointSize+scalarSize:])
+		if err != nil {
+			return nil, err
+		}
+		proof.A[j], proof.B[j], proof.C[j], proof.Z[j] = a, b, c, z
+	}
+	return proof, nil
+}
+
+func (p MembershipProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Marshal())
+}
+
+func (p *MembershipProof) UnmarshalJSON(data []byte) error {
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	decoded, err := UnmarshalMembershipProof(raw)
+	if err != nil {
+		return err
+	}
+	*p = *decoded
+	return nil
+}
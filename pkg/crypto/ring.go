@@ -0,0 +1,128 @@
+// pkg/crypto/ring.go
+package crypto
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// RingSignature is a linkable ring signature (LSAG): it shows that the signer
+// holds the secret of one key of a ring without revealing which, and carries
+// the key image I = x*H of that secret for a base H. Two signatures by the
+// same key for the same base share the key image, which is the signer's
+// Nullifier, so they can be linked.
+type RingSignature struct {
+	C *big.Int   // Challenge of the first ring member
+	S []*big.Int // Response per ring member
+}
+
+// RingSign signs message with the secret of ring[index], over the whole ring
+// and with the key image Nullifier(secret, base).
+func RingSign(ring []*bn256.G1, index int, secret *big.Int, base *bn256.G1, message []byte) (*RingSignature, error) {
+	n := len(ring)
+	if index < 0 || index >= n {
+		return nil, errors.New("signer is not in the ring")
+	}
+	if new(bn256.G1).ScalarBaseMult(secret).String() != ring[index].String() {
+		return nil, errors.New("secret does not belong to the signer's ring key")
+	}
+	image := Nullifier(secret, base)
+
+	w, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	s, err := randomScalars(n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the ring from the signer, then close it with the signer's response
+	c := make([]*big.Int, n)
+	l := new(bn256.G1).ScalarBaseMult(w)
+	r := new(bn256.G1).ScalarMult(base, w)
+	for k := 1; k <= n; k++ {
+		i := (index + k) % n
+		c[i] = ringChallenge(ring, base, image, message, l, r)
+		if i == index {
+			break
+		}
+		l, r = ringCommitments(ring[i], base, image, s[i], c[i])
+	}
+	s[index] = new(big.Int).Mul(c[index], secret)
+	s[index].Sub(w, s[index]).Mod(s[index], bn256.Order)
+	return &RingSignature{C: c[0], S: s}, nil
+}
+
+// VerifyRing checks that sig signs message by a key of ring, with the key
+// image image for base.
+func VerifyRing(ring []*bn256.G1, base, image *bn256.G1, sig *RingSignature, message []byte) bool {
+	if sig == nil || sig.C == nil || len(ring) == 0 || len(sig.S) != len(ring) || image == nil {
+		return false
+	}
+	c := sig.C
+	for i, key := range ring {
+		if key == nil || sig.S[i] == nil {
+			return false
+		}
+		l, r := ringCommitments(key, base, image, sig.S[i], c)
+		c = ringChallenge(ring, base, image, message, l, r)
+	}
+	return c.Cmp(sig.C) == 0
+}
+
+// ringCommitments recomputes L = s*G + c*P and R = s*H + c*I for a ring
+// member with key P.
+func ringCommitments(key, base, image *bn256.G1, s, c *big.Int) (*bn256.G1, *bn256.G1) {
+	l := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(s), new(bn256.G1).ScalarMult(key, c))
+	r := new(bn256.G1).Add(new(bn256.G1).ScalarMult(base, s), new(bn256.G1).ScalarMult(image, c))
+	return l, r
+}
+
+func ringChallenge(ring []*bn256.G1, base, image *bn256.G1, message []byte, l, r *bn256.G1) *big.Int {
+	transcript := merlin.NewTranscript("ring_signature")
+	transcript.AppendMessage([]byte("message"), message)
+	for _, key := range ring {
+		transcript.AppendMessage([]byte("key"), key.Marshal())
+	}
+	transcript.AppendMessage([]byte("base"), base.Marshal())
+	transcript.AppendMessage([]byte("image"), image.Marshal())
+	transcript.AppendMessage([]byte("l"), l.Marshal())
+	transcript.AppendMessage([]byte("r"), r.Marshal())
+
+	challenge := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return challenge.Mod(challenge, bn256.Order)
+}
+
+type ringSignatureJSON struct {
+	C []byte   `json:"c"`
+	S [][]byte `json:"s"`
+}
+
+func (s RingSignature) MarshalJSON() ([]byte, error) {
+	if s.C == nil {
+		return nil, errors.New("incomplete ring signature")
+	}
+	return json.Marshal(ringSignatureJSON{C: marshalScalar(s.C), S: marshalScalars(s.S)})
+}
+
+func (s *RingSignature) UnmarshalJSON(data []byte) error {
+	var aux ringSignatureJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	c, err := unmarshalScalar(aux.C)
+	if err != nil {
+		return err
+	}
+	scalars, err := unmarshalScalars(aux.S)
+	if err != nil {
+		return err
+	}
+	s.C, s.S = c, scalars
+	return nil
+}
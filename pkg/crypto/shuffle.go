@@ -0,0 +1,430 @@
+// pkg/crypto/shuffle.go
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// ShuffleProof is a Terelius-Wikström proof that a list of ciphertext rows is
+// a re-encryption and permutation of another, as presented by Haenni et al.
+// in "Pseudo-Code Algorithms for Verifiable Re-Encryption Mix-Nets". Rows are
+// shuffled as a whole, so a row may hold all ciphertexts of one ballot.
+type ShuffleProof struct {
+	Commitments []*bn256.G1   // Commitment to the permutation, one per row
+	Chain       []*bn256.G1   // Commitment chain to the permuted challenges
+	T1, T2, T3  *bn256.G1     // Commitments of the proof of knowledge
+	T4          []*Ciphertext // One per row component
+	THat        []*bn256.G1   // One per row
+	S1, S2, S3  *big.Int      // Responses
+	S4          []*big.Int    // One per row component
+	SHat        []*big.Int    // One per row
+	SPrime      []*big.Int    // One per row
+}
+
+// ReEncrypt returns a fresh encryption of the plaintext of c, using the
+// randomness r.
+func (c *Ciphertext) ReEncrypt(pubKey *bn256.G1, r *big.Int) *Ciphertext {
+	return c.Add(EncryptValue(pubKey, 0, r))
+}
+
+// Shuffle re-encrypts every row of ciphertexts under pubKey and returns them
+// in a secret random order, with a proof that the output holds the same
+// plaintexts as rows. The context binds the proof to its use, e.g. an
+// election and mixing step.
+func Shuffle(pubKey *bn256.G1, rows [][]*Ciphertext, context []byte) ([][]*Ciphertext, *ShuffleProof, error) {
+	width, err := rowWidth(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := len(rows)
+	permutation, err := randomPermutation(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	randomness := make([][]*big.Int, n)
+	out := make([][]*Ciphertext, n)
+	for i := range out {
+		randomness[i] = make([]*big.Int, width)
+		out[i] = make([]*Ciphertext, width)
+		for k := 0; k < width; k++ {
+			if randomness[i][k], err = randomScalar(); err != nil {
+				return nil, nil, err
+			}
+			out[i][k] = rows[permutation[i]][k].ReEncrypt(pubKey, randomness[i][k])
+		}
+	}
+
+	proof, err := proveShuffle(pubKey, rows, out, permutation, randomness, context)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, proof, nil
+}
+
+// proveShuffle proves that out[i] re-encrypts in[permutation[i]] with
+// randomness[i].
+func proveShuffle(pubKey *bn256.G1, in, out [][]*Ciphertext, permutation []int, randomness [][]*big.Int, context []byte) (*ShuffleProof, error) {
+	n, width := len(in), len(in[0])
+	h, hs := shuffleGenerators(n)
+	proof := &ShuffleProof{Commitments: make([]*bn256.G1, n)}
+
+	// Commit to the permutation: c[permutation[i]] = g^r * h_i
+	r := make([]*big.Int, n)
+	for i, j := range permutation {
+		var err error
+		if r[j], err = randomScalar(); err != nil {
+			return nil, err
+		}
+		proof.Commitments[j] = new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(r[j]), hs[i])
+	}
+
+	transcript := shuffleTranscript(pubKey, in, out, proof.Commitments, context)
+	u := shuffleChallenges(transcript, n)
+	uPrime := make([]*big.Int, n)
+	for i, j := range permutation {
+		uPrime[i] = u[j]
+	}
+
+	// Commit to the permuted challenges in a chain starting at h
+	proof.Chain = make([]*bn256.G1, n)
+	rHat := make([]*big.Int, n)
+	previous := h
+	for i := range proof.Chain {
+		var err error
+		if rHat[i], err = randomScalar(); err != nil {
+			return nil, err
+		}
+		proof.Chain[i] = new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(rHat[i]), new(bn256.G1).ScalarMult(previous, uPrime[i]))
+		previous = proof.Chain[i]
+	}
+
+	scalars, err := randomScalars(3 + width + 2*n)
+	if err != nil {
+		return nil, err
+	}
+	w1, w2, w3 := scalars[0], scalars[1], scalars[2]
+	w4, wHat, wPrime := scalars[3:3+width], scalars[3+width:3+width+n], scalars[3+width+n:]
+
+	proof.T1 = new(bn256.G1).ScalarBaseMult(w1)
+	proof.T2 = new(bn256.G1).ScalarBaseMult(w2)
+	proof.T3 = new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(w3), multiScalarMult(hs, wPrime))
+	proof.T4 = make([]*Ciphertext, width)
+	for k := range proof.T4 {
+		proof.T4[k] = column(out, k, wPrime).Sub(EncryptValue(pubKey, 0, w4[k]))
+	}
+	proof.THat = make([]*bn256.G1, n)
+	previous = h
+	for i := range proof.THat {
+		proof.THat[i] = new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(wHat[i]), new(bn256.G1).ScalarMult(previous, wPrime[i]))
+		previous = proof.Chain[i]
+	}
+	c := shuffleChallenge(transcript, proof)
+
+	// Responses s = w + c * secret
+	respond := func(w, secret *big.Int) *big.Int {
+		s := new(big.Int).Mul(c, secret)
+		return s.Add(s, w).Mod(s, bn256.Order)
+	}
+	rBar, rTilde := new(big.Int), new(big.Int)
+	for j := range r {
+		rBar.Add(rBar, r[j])
+		rTilde.Add(rTilde, new(big.Int).Mul(r[j], u[j]))
+	}
+	rHatSum, v := new(big.Int), big.NewInt(1) // v = product of uPrime after i
+	for i := n - 1; i >= 0; i-- {
+		rHatSum.Add(rHatSum, new(big.Int).Mul(rHat[i], v))
+		v = new(big.Int).Mul(v, uPrime[i])
+		v.Mod(v, bn256.Order)
+	}
+	proof.S1 = respond(w1, rBar.Mod(rBar, bn256.Order))
+	proof.S2 = respond(w2, rHatSum.Mod(rHatSum, bn256.Order))
+	proof.S3 = respond(w3, rTilde.Mod(rTilde, bn256.Order))
+	proof.S4 = make([]*big.Int, width)
+	for k := range proof.S4 {
+		rPrime := new(big.Int)
+		for i := range randomness {
+			rPrime.Add(rPrime, new(big.Int).Mul(randomness[i][k], uPrime[i]))
+		}
+		proof.S4[k] = respond(w4[k], rPrime.Mod(rPrime, bn256.Order))
+	}
+	proof.SHat = make([]*big.Int, n)
+	proof.SPrime = make([]*big.Int, n)
+	for i := 0; i < n; i++ {
+		proof.SHat[i] = respond(wHat[i], rHat[i])
+		proof.SPrime[i] = respond(wPrime[i], uPrime[i])
+	}
+	return proof, nil
+}
+
+// VerifyShuffle checks a proof that out is a re-encryption and permutation of
+// in under pubKey, for the same context it was created with.
+func VerifyShuffle(pubKey *bn256.G1, in, out [][]*Ciphertext, proof *ShuffleProof, context []byte) bool {
+	width, err := rowWidth(in)
+	if err != nil || proof == nil {
+		return false
+	}
+	if outWidth, err := rowWidth(out); err != nil || outWidth != width || len(out) != len(in) {
+		return false
+	}
+	n := len(in)
+	if len(proof.Commitments) != n || len(proof.Chain) != n || len(proof.THat) != n || len(proof.SHat) != n ||
+		len(proof.SPrime) != n || len(proof.T4) != width || len(proof.S4) != width ||
+		proof.T1 == nil || proof.T2 == nil || proof.T3 == nil || proof.S1 == nil || proof.S2 == nil || proof.S3 == nil {
+		return false
+	}
+
+	h, hs := shuffleGenerators(n)
+	transcript := shuffleTranscript(pubKey, in, out, proof.Commitments, context)
+	u := shuffleChallenges(transcript, n)
+	c := shuffleChallenge(transcript, proof)
+	negC := new(big.Int).Sub(bn256.Order, c)
+
+	// t1: the commitments hide the permutation of the generators h_i
+	cBar := new(bn256.G1).Add(sumPoints(proof.Commitments), new(bn256.G1).Neg(sumPoints(hs)))
+	t1 := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.S1), new(bn256.G1).ScalarMult(cBar, negC))
+
+	// t2: the chain ends at h raised to the product of the challenges
+	uProduct := big.NewInt(1)
+	for _, ui := range u {
+		uProduct.Mul(uProduct, ui).Mod(uProduct, bn256.Order)
+	}
+	cHat := new(bn256.G1).Add(proof.Chain[n-1], new(bn256.G1).Neg(new(bn256.G1).ScalarMult(h, uProduct)))
+	t2 := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.S2), new(bn256.G1).ScalarMult(cHat, negC))
+
+	// t3: the committed permutation applies to the challenges
+	cTilde := multiScalarMult(proof.Commitments, u)
+	t3 := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.S3), multiScalarMult(hs, proof.SPrime))
+	t3.Add(t3, new(bn256.G1).ScalarMult(cTilde, negC))
+
+	// t4: the output re-encrypts the permuted input
+	for k := 0; k < width; k++ {
+		t4 := column(out, k, proof.SPrime).Sub(EncryptValue(pubKey, 0, proof.S4[k])).Add(column(in, k, u).ScalarMult(negC))
+		if proof.T4[k] == nil || !t4.Equal(proof.T4[k]) {
+			return false
+		}
+	}
+
+	previous := h
+	for i := 0; i < n; i++ {
+		if proof.SHat[i] == nil || proof.SPrime[i] == nil || proof.THat[i] == nil {
+			return false
+		}
+		tHat := new(bn256.G1).Add(new(bn256.G1).ScalarBaseMult(proof.SHat[i]), new(bn256.G1).ScalarMult(previous, proof.SPrime[i]))
+		tHat.Add(tHat, new(bn256.G1).ScalarMult(proof.Chain[i], negC))
+		if !bytes.Equal(tHat.Marshal(), proof.THat[i].Marshal()) {
+			return false
+		}
+		previous = proof.Chain[i]
+	}
+
+	return bytes.Equal(t1.Marshal(), proof.T1.Marshal()) &&
+		bytes.Equal(t2.Marshal(), proof.T2.Marshal()) &&
+		bytes.Equal(t3.Marshal(), proof.T3.Marshal())
+}
+
+// rowWidth checks that rows is a non-empty list of rows of equal, non-zero
+// width without nil ciphertexts, and returns the width.
+func rowWidth(rows [][]*Ciphertext) (int, error) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return 0, errors.New("nothing to shuffle")
+	}
+	width := len(rows[0])
+	for _, row := range rows {
+		if len(row) != width {
+			return 0, errors.New("rows must have the same width")
+		}
+		for _, ct := range row {
+			if ct == nil || ct.C1 == nil || ct.C2 == nil {
+				return 0, errors.New("malformed ciphertext")
+			}
+		}
+	}
+	return width, nil
+}
+
+// shuffleGenerators returns independent generators h and h_1..h_n, whose
+// discrete logarithms nobody knows.
+func shuffleGenerators(n int) (*bn256.G1, []*bn256.G1) {
+	hs := make([]*bn256.G1, n)
+	for i := range hs {
+		var index [8]byte
+		binary.BigEndian.PutUint64(index[:], uint64(i))
+		hs[i] = HashToPoint("election-system/shuffle/h_i", index[:])
+	}
+	return HashToPoint("election-system/shuffle/h", nil), hs
+}
+
+func shuffleTranscript(pubKey *bn256.G1, in, out [][]*Ciphertext, commitments []*bn256.G1, context []byte) *merlin.Transcript {
+	transcript := merlin.NewTranscript("shuffle_proof")
+	transcript.AppendMessage([]byte("context"), context)
+	transcript.AppendMessage([]byte("public_key"), pubKey.Marshal())
+	for _, rows := range [][][]*Ciphertext{in, out} {
+		for _, row := range rows {
+			for _, ct := range row {
+				transcript.AppendMessage([]byte("ciphertext"), ct.Marshal())
+			}
+		}
+	}
+	for _, c := range commitments {
+		transcript.AppendMessage([]byte("commitment"), MarshalPoint(c))
+	}
+	return transcript
+}
+
+// shuffleChallenges derives the per-row challenges u.
+func shuffleChallenges(transcript *merlin.Transcript, n int) []*big.Int {
+	u := make([]*big.Int, n)
+	for i := range u {
+		u[i] = new(big.Int).SetBytes(transcript.ExtractBytes([]byte("u"), 64))
+		u[i].Mod(u[i], bn256.Order)
+	}
+	return u
+}
+
+// shuffleChallenge derives the challenge of the proof of knowledge.
+func shuffleChallenge(transcript *merlin.Transcript, proof *ShuffleProof) *big.Int {
+	for _, p := range proof.Chain {
+		transcript.AppendMessage([]byte("chain"), MarshalPoint(p))
+	}
+	for _, p := range []*bn256.G1{proof.T1, proof.T2, proof.T3} {
+		transcript.AppendMessage([]byte("t"), MarshalPoint(p))
+	}
+	for _, t4 := range proof.T4 {
+		if t4 != nil {
+			transcript.AppendMessage([]byte("t4"), t4.Marshal())
+		}
+	}
+	for _, p := range proof.THat {
+		transcript.AppendMessage([]byte("t_hat"), MarshalPoint(p))
+	}
+	c := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return c.Mod(c, bn256.Order)
+}
+
+// column returns the sum of rows[i][k] * scalars[i] over all rows.
+func column(rows [][]*Ciphertext, k int, scalars []*big.Int) *Ciphertext {
+	sum := ZeroCiphertext()
+	for i, row := range rows {
+		sum = sum.Add(row[k].ScalarMult(scalars[i]))
+	}
+	return sum
+}
+
+// multiScalarMult returns the sum of points[i] * scalars[i].
+func multiScalarMult(points []*bn256.G1, scalars []*big.Int) *bn256.G1 {
+	sum := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for i, p := range points {
+		sum.Add(sum, new(bn256.G1).ScalarMult(p, scalars[i]))
+	}
+	return sum
+}
+
+func sumPoints(points []*bn256.G1) *bn256.G1 {
+	sum := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for _, p := range points {
+		sum.Add(sum, p)
+	}
+	return sum
+}
+
+// randomPermutation returns a uniformly random permutation of 0..n-1.
+func randomPermutation(n int) ([]int, error) {
+	permutation := make([]int, n)
+	for i := range permutation {
+		permutation[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return nil, err
+		}
+		permutation[i], permutation[j.Int64()] = permutation[j.Int64()], permutation[i]
+	}
+	return permutation, nil
+}
+
+func randomScalar() (*big.Int, error) {
+	return rand.Int(rand.Reader, bn256.Order)
+}
+
+func randomScalars(n int) ([]*big.Int, error) {
+	scalars := make([]*big.Int, n)
+	for i := range scalars {
+		var err error
+		if scalars[i], err = randomScalar(); err != nil {
+			return nil, err
+		}
+	}
+	return scalars, nil
+}
+
+// shuffleProofJSON is the wire form of a ShuffleProof, with points and
+// scalars in their binary forms.
+type shuffleProofJSON struct {
+	Commitments [][]byte      `json:"commitments"`
+	Chain       [][]byte      `json:"chain"`
+	T           [][]byte      `json:"t"` // T1, T2, T3
+	T4          []*Ciphertext `json:"t4"`
+	THat        [][]byte      `json:"t_hat"`
+	S           [][]byte      `json:"s"` // S1, S2, S3
+	S4          [][]byte      `json:"s4"`
+	SHat        [][]byte      `json:"s_hat"`
+	SPrime      [][]byte      `json:"s_prime"`
+}
+
+func (p ShuffleProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(shuffleProofJSON{
+		Commitments: MarshalPoints(p.Commitments),
+		Chain:       MarshalPoints(p.Chain),
+		T:           MarshalPoints([]*bn256.G1{p.T1, p.T2, p.T3}),
+		T4:          p.T4,
+		THat:        MarshalPoints(p.THat),
+		S:           marshalScalars([]*big.Int{p.S1, p.S2, p.S3}),
+		S4:          marshalScalars(p.S4),
+		SHat:        marshalScalars(p.SHat),
+		SPrime:      marshalScalars(p.SPrime),
+	})
+}
+
+func (p *ShuffleProof) UnmarshalJSON(data []byte) error {
+	var aux shuffleProofJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.T) != 3 || len(aux.S) != 3 {
+		return errors.New("malformed shuffle proof")
+	}
+
+	var err error
+	var t []*bn256.G1
+	for _, field := range []struct {
+		points *[]*bn256.G1
+		data   [][]byte
+	}{{&p.Commitments, aux.Commitments}, {&p.Chain, aux.Chain}, {&t, aux.T}, {&p.THat, aux.THat}} {
+		if *field.points, err = UnmarshalPoints(field.data); err != nil {
+			return err
+		}
+	}
+	var s []*big.Int
+	for _, field := range []struct {
+		scalars *[]*big.Int
+		data    [][]byte
+	}{{&s, aux.S}, {&p.S4, aux.S4}, {&p.SHat, aux.SHat}, {&p.SPrime, aux.SPrime}} {
+		if *field.scalars, err = unmarshalScalars(field.data); err != nil {
+			return err
+		}
+	}
+	p.T1, p.T2, p.T3 = t[0], t[1], t[2]
+	p.S1, p.S2, p.S3 = s[0], s[1], s[2]
+	p.T4 = aux.T4
+	return nil
+}
@@ -0,0 +1,83 @@
+// pkg/crypto/signer.go
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cloudflare/bn256"
+)
+
+// ErrDoubleSign is returned when a signer is asked to commit to a second
+// block at a height it has already committed to.
+var ErrDoubleSign = errors.New("refusing to sign a second block at the same height")
+
+// Signer holds a validator's BLS key, possibly outside the node process,
+// and signs its finality commits. Signers build the commit message
+// themselves, so they can refuse to commit to two blocks at one height.
+type Signer interface {
+	PublicKey() *bn256.G2
+	Possession() *bn256.G1 // Proof of possession of PublicKey, see ProvePossession
+	SignCommit(chainID string, height int, hash []byte) (*bn256.G1, error)
+}
+
+// CommitMessage is the message validators sign to commit to the block of
+// hash at height on the network chainID.
+func CommitMessage(chainID string, height int, hash []byte) []byte {
+	return append([]byte(fmt.Sprintf("commit/%s/%d/", chainID, height)), hash...)
+}
+
+// SignedCommits records the block hash a signer committed to at each height
+// of each chain.
+type SignedCommits map[string][]byte
+
+// Check returns ErrDoubleSign if another block than hash has been signed
+// at height. Signing the same block again is allowed.
+func (s SignedCommits) Check(chainID string, height int, hash []byte) error {
+	if signed, ok := s[commitSlot(chainID, height)]; ok && !bytes.Equal(signed, hash) {
+		return ErrDoubleSign
+	}
+	return nil
+}
+
+// Record marks hash as signed at height.
+func (s SignedCommits) Record(chainID string, height int, hash []byte) {
+	s[commitSlot(chainID, height)] = append([]byte(nil), hash...)
+}
+
+func commitSlot(chainID string, height int) string {
+	return fmt.Sprintf("%s/%d", chainID, height)
+}
+
+// LocalSigner signs with a key held in memory, refusing double signs for
+// as long as it lives.
+type LocalSigner struct {
+	mu     sync.Mutex
+	key    *BLSKeyPair
+	signed SignedCommits
+}
+
+func NewLocalSigner(key *BLSKeyPair) *LocalSigner {
+	return &LocalSigner{key: key, signed: make(SignedCommits)}
+}
+
+func (s *LocalSigner) PublicKey() *bn256.G2 {
+	return s.key.PublicKey
+}
+
+func (s *LocalSigner) Possession() *bn256.G1 {
+	return ProvePossession(s.key.PrivateKey)
+}
+
+func (s *LocalSigner) SignCommit(chainID string, height int, hash []byte) (*bn256.G1, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.signed.Check(chainID, height, hash); err != nil {
+		return nil, err
+	}
+	s.signed.Record(chainID, height, hash)
+	return BLSSign(s.key.PrivateKey, CommitMessage(chainID, height, hash)), nil
+}
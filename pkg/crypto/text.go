@@ -0,0 +1,184 @@
+// pkg/crypto/text.go
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+// textChunkSize is the number of message bytes embedded in one point. The x
+// coordinate of the point is a zero byte, the chunk and a counter byte.
+const textChunkSize = 30
+
+// TextCiphertext is a short text ElGamal-encrypted as curve points, one
+// ciphertext per chunk. Unlike a SealedText its chunks can be re-encrypted,
+// so they can pass through a mix net. The proof shows knowledge of the
+// encryption randomness, so the ciphertext cannot be copied into another
+// context.
+type TextCiphertext struct {
+	Chunks []*Ciphertext    `json:"chunks"`
+	Proof  *RandomnessProof `json:"proof"`
+}
+
+// RandomnessProof is a Schnorr proof of knowledge of the sum of the
+// randomness of a list of ciphertexts, bound to a context.
+type RandomnessProof struct {
+	C *big.Int // Challenge
+	Z *big.Int // Response
+}
+
+// EncryptText encrypts text to pubKey in the given number of chunks, which
+// must fit a length byte and the text. Every text of an application should
+// use the same number of chunks, so the ciphertext does not reveal its length.
+func EncryptText(pubKey *bn256.G1, text []byte, chunks int, context []byte) (*TextCiphertext, error) {
+	if len(text) > 255 || 1+len(text) > chunks*textChunkSize {
+		return nil, fmt.Errorf("text of %d bytes does not fit %d chunks", len(text), chunks)
+	}
+	padded := make([]byte, chunks*textChunkSize)
+	padded[0] = byte(len(text))
+	copy(padded[1:], text)
+
+	ct := &TextCiphertext{Chunks: make([]*Ciphertext, chunks)}
+	total := new(big.Int)
+	for i := range ct.Chunks {
+		m, err := embedChunk(padded[i*textChunkSize : (i+1)*textChunkSize])
+		if err != nil {
+			return nil, err
+		}
+		r, err := rand.Int(rand.Reader, bn256.Order)
+		if err != nil {
+			return nil, err
+		}
+		ct.Chunks[i] = &Ciphertext{
+			C1: new(bn256.G1).ScalarBaseMult(r),
+			C2: new(bn256.G1).Add(m, new(bn256.G1).ScalarMult(pubKey, r)),
+		}
+		total.Add(total, r)
+	}
+
+	w, err := rand.Int(rand.Reader, bn256.Order)
+	if err != nil {
+		return nil, err
+	}
+	c := randomnessChallenge(sumC1(ct.Chunks), new(bn256.G1).ScalarBaseMult(w), context)
+	z := new(big.Int).Mul(c, total)
+	z.Add(z, w).Mod(z, bn256.Order)
+	ct.Proof = &RandomnessProof{C: c, Z: z}
+	return ct, nil
+}
+
+// Verify checks the shape of the ciphertext and its proof for context.
+func (t *TextCiphertext) Verify(context []byte) bool {
+	if t == nil || len(t.Chunks) == 0 || t.Proof == nil || t.Proof.C == nil || t.Proof.Z == nil {
+		return false
+	}
+	for _, chunk := range t.Chunks {
+		if chunk == nil || chunk.C1 == nil || chunk.C2 == nil {
+			return false
+		}
+	}
+
+	// a = z*G - c*sum(C1)
+	sum := sumC1(t.Chunks)
+	a := new(bn256.G1).ScalarBaseMult(t.Proof.Z)
+	a.Add(a, new(bn256.G1).Neg(new(bn256.G1).ScalarMult(sum, t.Proof.C)))
+	return randomnessChallenge(sum, a, context).Cmp(t.Proof.C) == 0
+}
+
+// DecryptText decrypts the chunks of a text, which may have been
+// re-encrypted since EncryptText.
+func DecryptText(privKey *big.Int, chunks []*Ciphertext) ([]byte, error) {
+	points := make([]*bn256.G1, len(chunks))
+	for i, chunk := range chunks {
+		if chunk == nil || chunk.C1 == nil || chunk.C2 == nil {
+			return nil, errors.New("malformed text chunk")
+		}
+		points[i] = new(bn256.G1).Add(chunk.C2, new(bn256.G1).Neg(new(bn256.G1).ScalarMult(chunk.C1, privKey)))
+	}
+	return DecodeText(points)
+}
+
+// DecodeText reads a text from the points its chunks decrypt to, e.g. points
+// whose decryption was proven with ProvePointDecryption.
+func DecodeText(points []*bn256.G1) ([]byte, error) {
+	padded := make([]byte, 0, len(points)*textChunkSize)
+	for _, m := range points {
+		if m == nil {
+			return nil, errors.New("malformed text chunk")
+		}
+		encoded := m.Marshal()
+		if encoded[0] != 0 {
+			return nil, errors.New("text chunk does not decrypt to an embedded chunk")
+		}
+		padded = append(padded, encoded[1:1+textChunkSize]...)
+	}
+	if len(padded) == 0 || int(padded[0]) > len(padded)-1 {
+		return nil, errors.New("malformed text")
+	}
+	return padded[1 : 1+int(padded[0])], nil
+}
+
+// embedChunk maps a chunk to a point, trying counter values until the x
+// coordinate lies on the curve.
+func embedChunk(chunk []byte) (*bn256.G1, error) {
+	encoded := make([]byte, pointSize)
+	copy(encoded[1:], chunk)
+	x := new(big.Int)
+	for counter := 0; counter < 256; counter++ {
+		encoded[scalarSize-1] = byte(counter)
+		x.SetBytes(encoded[:scalarSize])
+
+		rhs := new(big.Int).Exp(x, big.NewInt(3), fieldPrime)
+		rhs.Add(rhs, big.NewInt(3)).Mod(rhs, fieldPrime)
+		y := new(big.Int).ModSqrt(rhs, fieldPrime)
+		if y == nil {
+			continue
+		}
+		y.FillBytes(encoded[scalarSize:])
+		return UnmarshalPoint(encoded)
+	}
+	return nil, errors.New("chunk cannot be embedded")
+}
+
+func sumC1(chunks []*Ciphertext) *bn256.G1 {
+	sum := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for _, chunk := range chunks {
+		sum.Add(sum, chunk.C1)
+	}
+	return sum
+}
+
+func randomnessChallenge(sum, a *bn256.G1, context []byte) *big.Int {
+	transcript := merlin.NewTranscript("randomness_proof")
+	transcript.AppendMessage([]byte("context"), context)
+	transcript.AppendMessage([]byte("sum"), sum.Marshal())
+	transcript.AppendMessage([]byte("a"), a.Marshal())
+	c := new(big.Int).SetBytes(transcript.ExtractBytes([]byte("challenge"), 64))
+	return c.Mod(c, bn256.Order)
+}
+
+func (p RandomnessProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(marshalScalars([]*big.Int{p.C, p.Z}))
+}
+
+func (p *RandomnessProof) UnmarshalJSON(data []byte) error {
+	var aux [][]byte
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux) != 2 {
+		return errors.New("malformed randomness proof")
+	}
+	scalars, err := unmarshalScalars(aux)
+	if err != nil {
+		return err
+	}
+	p.C, p.Z = scalars[0], scalars[1]
+	return nil
+}
@@ -0,0 +1,269 @@
+// pkg/crypto/timelock.go
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/cloudflare/bn256"
+	"github.com/gtank/merlin"
+)
+
+const (
+	// timeLockDomain separates the masks of time-locked secrets from other
+	// hashes.
+	timeLockDomain = "election-system/timelock"
+
+	// keyLockRounds is the number of cut-and-choose rounds of a LockedKey: a
+	// lock of the wrong key passes verification with probability
+	// 2^-keyLockRounds.
+	keyLockRounds = 128
+)
+
+// BeaconMessage is the message a randomness beacon signs for round. Beacon
+// rounds are Unix seconds: round r is released at time r.
+func BeaconMessage(round uint64) []byte {
+	return []byte(fmt.Sprintf("beacon/%d", round))
+}
+
+// BeaconRound returns the first beacon round released at or after t.
+func BeaconRound(t time.Time) uint64 {
+	round := t.Unix()
+	if t.After(time.Unix(round, 0)) {
+		round++
+	}
+	return uint64(round)
+}
+
+// TimeLockCiphertext is a scalar encrypted to a future round of a BLS
+// randomness beacon, with the beacon's signature on the round as the
+// decryption key (Boneh-Franklin identity-based encryption). Nobody can
+// decrypt it before the beacon releases the round, including whoever
+// encrypted it once they discard the scalar.
+type TimeLockCiphertext struct {
+	U *bn256.G2 // k*g2 for a random k
+	V []byte    // Secret XOR H(e(H(round), beaconKey)^k)
+}
+
+// TimeLockEncrypt encrypts secret to round of the beacon holding beaconKey.
+func TimeLockEncrypt(beaconKey *bn256.G2, round uint64, secret *big.Int) (*TimeLockCiphertext, error) {
+	k, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	return timeLockEncrypt(roundKey(beaconKey, round), secret, k), nil
+}
+
+// roundKey returns e(H(round), beaconKey), which time-locks to round are
+// encrypted under.
+func roundKey(beaconKey *bn256.G2, round uint64) *bn256.GT {
+	return bn256.Pair(HashToPoint(blsDomain, BeaconMessage(round)), beaconKey)
+}
+
+// timeLockEncrypt encrypts secret under the round key with randomness k.
+func timeLockEncrypt(key *bn256.GT, secret, k *big.Int) *TimeLockCiphertext {
+	shared := new(bn256.GT).ScalarMult(key, k)
+	return &TimeLockCiphertext{U: new(bn256.G2).ScalarBaseMult(k), V: xorMask(marshalScalar(secret), shared)}
+}
+
+// TimeLockDecrypt decrypts ct with the beacon's signature on its round,
+// since e(sig, k*g2) = e(H(round), beaconKey)^k. A wrong signature yields a
+// wrong scalar rather than an error, so callers check the result, e.g.
+// against a public key.
+func TimeLockDecrypt(ct *TimeLockCiphertext, beaconSig *bn256.G1) (*big.Int, error) {
+	if ct == nil || ct.U == nil || len(ct.V) != scalarSize {
+		return nil, errors.New("malformed time-locked ciphertext")
+	}
+	if beaconSig == nil {
+		return nil, errors.New("missing beacon signature")
+	}
+	secret := new(big.Int).SetBytes(xorMask(ct.V, bn256.Pair(beaconSig, ct.U)))
+	return secret.Mod(secret, bn256.Order), nil
+}
+
+// LockedKey is a private key time-locked to a beacon round with a proof
+// that it is the discrete log x of a public key X (Stadler's verifiable
+// encryption by cut and choose). Every round i commits to a random scalar
+// t_i as T_i = t_i*G and time-locks t_i with randomness derived from it. A
+// challenge bit per round, drawn from all of them, then either opens t_i, so
+// anyone can redo the commitment and the lock, or reveals t_i - x, so
+// whoever unlocks t_i learns x. Locking a wrong key means guessing every
+// challenge bit.
+type LockedKey struct {
+	Commitments []*bn256.G1
+	Locks       []*TimeLockCiphertext
+	Responses   []*big.Int // t_i if the round is opened, t_i - x otherwise
+}
+
+// LockKey time-locks privKey, the discrete log of pubKey, to round of the
+// beacon holding beaconKey.
+func LockKey(beaconKey *bn256.G2, round uint64, privKey *big.Int, pubKey *bn256.G1) (*LockedKey, error) {
+	secrets, err := randomScalars(keyLockRounds)
+	if err != nil {
+		return nil, err
+	}
+	key := roundKey(beaconKey, round)
+	l := &LockedKey{
+		Commitments: make([]*bn256.G1, keyLockRounds),
+		Locks:       make([]*TimeLockCiphertext, keyLockRounds),
+		Responses:   make([]*big.Int, keyLockRounds),
+	}
+	for i, t := range secrets {
+		l.Commitments[i] = new(bn256.G1).ScalarBaseMult(t)
+		l.Locks[i] = timeLockEncrypt(key, t, lockRandomness(t))
+	}
+	for i, opened := range l.challenges(beaconKey, round, pubKey) {
+		if opened {
+			l.Responses[i] = secrets[i]
+		} else {
+			l.Responses[i] = new(big.Int).Sub(secrets[i], privKey)
+			l.Responses[i].Mod(l.Responses[i], bn256.Order)
+		}
+	}
+	return l, nil
+}
+
+// Verify checks that l time-locks the discrete log of pubKey to round of the
+// beacon holding beaconKey.
+func (l *LockedKey) Verify(beaconKey *bn256.G2, round uint64, pubKey *bn256.G1) bool {
+	if l == nil || pubKey == nil || len(l.Commitments) != keyLockRounds || len(l.Locks) != keyLockRounds || len(l.Responses) != keyLockRounds {
+		return false
+	}
+	for i := range l.Commitments {
+		if l.Commitments[i] == nil || l.Locks[i] == nil || l.Locks[i].U == nil || l.Responses[i] == nil {
+			return false
+		}
+	}
+	key := roundKey(beaconKey, round)
+	unlockable := 0
+	for i, opened := range l.challenges(beaconKey, round, pubKey) {
+		commitment, lock, response := l.Commitments[i], l.Locks[i], l.Responses[i]
+		if !opened {
+			// (t_i - x)*G = T_i - X
+			expected := new(bn256.G1).Add(commitment, new(bn256.G1).Neg(pubKey))
+			if !bytes.Equal(new(bn256.G1).ScalarBaseMult(response).Marshal(), expected.Marshal()) {
+				return false
+			}
+			unlockable++
+			continue
+		}
+		redone := timeLockEncrypt(key, response, lockRandomness(response))
+		if !bytes.Equal(new(bn256.G1).ScalarBaseMult(response).Marshal(), commitment.Marshal()) ||
+			!bytes.Equal(redone.U.Marshal(), lock.U.Marshal()) || !bytes.Equal(redone.V, lock.V) {
+			return false
+		}
+	}
+	return unlockable > 0
+}
+
+// Unlock recovers the locked key with the beacon's signature on its round,
+// checking it against pubKey.
+func (l *LockedKey) Unlock(beaconSig, pubKey *bn256.G1) (*big.Int, error) {
+	if l == nil || len(l.Locks) != len(l.Responses) {
+		return nil, errors.New("malformed locked key")
+	}
+	expected := pubKey.Marshal()
+	for i, lock := range l.Locks {
+		t, err := TimeLockDecrypt(lock, beaconSig)
+		if err != nil {
+			return nil, err
+		}
+		privKey := new(big.Int).Sub(t, l.Responses[i])
+		privKey.Mod(privKey, bn256.Order)
+		if bytes.Equal(new(bn256.G1).ScalarBaseMult(privKey).Marshal(), expected) {
+			return privKey, nil
+		}
+	}
+	return nil, errors.New("time-locked key does not match the public key")
+}
+
+// challenges draws the challenge bit of every round: true opens the round.
+func (l *LockedKey) challenges(beaconKey *bn256.G2, round uint64, pubKey *bn256.G1) []bool {
+	transcript := merlin.NewTranscript("locked_key")
+	transcript.AppendMessage([]byte("beacon"), MarshalG2(beaconKey))
+	transcript.AppendMessage([]byte("round"), binary.BigEndian.AppendUint64(nil, round))
+	transcript.AppendMessage([]byte("pubkey"), pubKey.Marshal())
+	for i := range l.Commitments {
+		transcript.AppendMessage([]byte("commitment"), l.Commitments[i].Marshal())
+		transcript.AppendMessage([]byte("lock_u"), l.Locks[i].U.Marshal())
+		transcript.AppendMessage([]byte("lock_v"), l.Locks[i].V)
+	}
+	bits := transcript.ExtractBytes([]byte("challenges"), keyLockRounds/8)
+	challenges := make([]bool, keyLockRounds)
+	for i := range challenges {
+		challenges[i] = bits[i/8]&(1<<(i%8)) == 0
+	}
+	return challenges
+}
+
+// lockRandomness derives the randomness t is locked with, so an opened round
+// can be locked again to check it.
+func lockRandomness(t *big.Int) *big.Int {
+	hash := sha256.Sum256(append([]byte(timeLockDomain+"/randomness"), marshalScalar(t)...))
+	k := new(big.Int).SetBytes(hash[:])
+	return k.Mod(k, bn256.Order)
+}
+
+func xorMask(data []byte, shared *bn256.GT) []byte {
+	mask := sha256.Sum256(append([]byte(timeLockDomain), shared.Marshal()...))
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ mask[i%len(mask)]
+	}
+	return out
+}
+
+type timeLockJSON struct {
+	U []byte `json:"u"`
+	V []byte `json:"v"`
+}
+
+func (c TimeLockCiphertext) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeLockJSON{U: MarshalG2(c.U), V: c.V})
+}
+
+func (c *TimeLockCiphertext) UnmarshalJSON(data []byte) error {
+	var raw timeLockJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	u, err := UnmarshalG2(raw.U)
+	if err != nil {
+		return fmt.Errorf("malformed time-locked ciphertext: %v", err)
+	}
+	c.U, c.V = u, raw.V
+	return nil
+}
+
+type lockedKeyJSON struct {
+	Commitments [][]byte              `json:"commitments"`
+	Locks       []*TimeLockCiphertext `json:"locks"`
+	Responses   [][]byte              `json:"responses"`
+}
+
+func (l LockedKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(lockedKeyJSON{Commitments: MarshalPoints(l.Commitments), Locks: l.Locks, Responses: marshalScalars(l.Responses)})
+}
+
+func (l *LockedKey) UnmarshalJSON(data []byte) error {
+	var raw lockedKeyJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	commitments, err := UnmarshalPoints(raw.Commitments)
+	if err != nil {
+		return fmt.Errorf("malformed locked key: %w", err)
+	}
+	responses, err := unmarshalScalars(raw.Responses)
+	if err != nil {
+		return fmt.Errorf("malformed locked key: %w", err)
+	}
+	l.Commitments, l.Locks, l.Responses = commitments, raw.Locks, responses
+	return nil
+}
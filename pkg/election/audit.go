@@ -0,0 +1,178 @@
+// pkg/election/audit.go
+package election
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// BallotOpening reveals the plaintext and encryption randomness of every
+// ciphertext of a ballot, in the order of Ciphertexts. A voter who doubts
+// the device that encrypted a ballot challenges it by publishing its opening
+// instead of casting it; anyone can then check the ballot encrypted the
+// intended choice. Challenged ballots are never counted.
+type BallotOpening struct {
+	Values     []int64    `json:"values"`
+	Randomness []*big.Int `json:"randomness"`
+}
+
+// BallotAudit is a challenged ballot with its opening and the choice it was
+// found to encrypt.
+type BallotAudit struct {
+	Tracker    string         `json:"tracker"`
+	Ballot     *Ballot        `json:"ballot"`
+	Opening    *BallotOpening `json:"opening"`
+	Selections []string       `json:"selections"` // In the form voters enter them, see Selections
+}
+
+// BallotTracker returns the tracker shown to the voter before a ballot is
+// cast or challenged: a hash of the encrypted ballot, under which it can be
+// found on the chain.
+func BallotTracker(ballot *Ballot) (string, error) {
+	data, err := json.Marshal(ballot)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// NewPluralityBallot encrypts a vote for the candidate at index as one cell
+// per candidate with its proofs, returning the opening for the voter to
+// challenge the ballot.
+func NewPluralityBallot(e *Election, voterID string, index int) (*Ballot, *BallotOpening, error) {
+	if index < 0 || index >= len(e.Candidates) {
+		return nil, nil, fmt.Errorf("candidate index %d out of range", index)
+	}
+	values := make([]int, len(e.Candidates))
+	values[index] = 1
+	scores, opening, err := NewScoreBallotWithOpening(e, voterID, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Ballot{VoterID: voterID, Type: BallotPlurality, Scores: scores}, opening, nil
+}
+
+// Ciphertexts returns the ciphertexts carrying the voter's choice, in the
+// order a BallotOpening lists them: the ranked cells row by row, or the cells
+// of plurality, approval and score ballots. Write-ins and multi-contest sections are not
+// covered.
+func (b *Ballot) Ciphertexts() ([]*crypto.Ciphertext, error) {
+	switch {
+	case b.Ranked != nil:
+		var cts []*crypto.Ciphertext
+		for _, row := range b.Ranked.Cells {
+			cts = append(cts, row...)
+		}
+		return cts, nil
+	case b.Scores != nil:
+		return b.Scores.Cells, nil
+	}
+	return nil, errors.New("ballot type cannot be audited")
+}
+
+// AuditBallot checks that opening opens ballot under the election key and
+// decodes the choice it reveals.
+func (e *Election) AuditBallot(ballot *Ballot, opening *BallotOpening) (*BallotAudit, error) {
+	ballotType := ballot.Type
+	if ballotType == "" {
+		ballotType = BallotPlurality
+	}
+	if ballotType != e.ballotType() {
+		return nil, fmt.Errorf("%s ballot challenged in a %s election", ballotType, e.ballotType())
+	}
+	cts, err := ballot.Ciphertexts()
+	if err != nil {
+		return nil, err
+	}
+	if opening == nil || len(opening.Values) != len(cts) || len(opening.Randomness) != len(cts) {
+		return nil, fmt.Errorf("opening must cover all %d ciphertexts of the ballot", len(cts))
+	}
+	for i, ct := range cts {
+		r := opening.Randomness[i]
+		if ct == nil || ct.C1 == nil || ct.C2 == nil || r == nil || r.Sign() < 0 {
+			return nil, errors.New("malformed ballot or opening")
+		}
+		if !crypto.EncryptValue(e.PublicKey, opening.Values[i], r).Equal(ct) {
+			return nil, fmt.Errorf("ciphertext %d does not encrypt the revealed value", i)
+		}
+	}
+
+	selections, err := e.Selections(ballot.VoterID, opening.Values)
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := BallotTracker(ballot)
+	if err != nil {
+		return nil, err
+	}
+	return &BallotAudit{Tracker: tracker, Ballot: ballot, Opening: opening, Selections: selections}, nil
+}
+
+// Selections decodes the revealed values of a ballot of voterID into the
+// choice as the CLI takes it: the candidate voted for, the ranking, the
+// approved candidates or name=score pairs.
+func (e *Election) Selections(voterID string, values []int64) ([]string, error) {
+	n := len(e.Candidates)
+	selections := []string{}
+	switch e.ballotType() {
+	case BallotRanked:
+		if len(values) != n*n {
+			return nil, fmt.Errorf("ranked ballots have %d cells", n*n)
+		}
+		for j := 0; j < n; j++ {
+			holder := -1
+			for i := 0; i < n; i++ {
+				switch values[i*n+j] {
+				case 0:
+				case 1:
+					if holder >= 0 {
+						return nil, fmt.Errorf("rank %d holds several candidates", j+1)
+					}
+					holder = i
+				default:
+					return nil, errors.New("ranked cells must encrypt 0 or 1")
+				}
+			}
+			if holder < 0 {
+				break
+			}
+			selections = append(selections, e.Candidates[holder].Name)
+		}
+		return selections, nil
+
+	case BallotPlurality, BallotApproval, BallotScore:
+		if len(values) != n {
+			return nil, fmt.Errorf("%s ballots have %d cells", e.ballotType(), n)
+		}
+		weight := int64(e.WeightOf(voterID))
+		for i, v := range values {
+			if weight < 1 || v < 0 || v%weight != 0 || v/weight > int64(e.MaxCellValue()) {
+				return nil, fmt.Errorf("cell %d encrypts an invalid value", i)
+			}
+			switch {
+			case v == 0:
+			case e.BallotType != BallotScore:
+				selections = append(selections, e.Candidates[i].Name)
+			default:
+				selections = append(selections, fmt.Sprintf("%s=%d", e.Candidates[i].Name, v/weight))
+			}
+		}
+		return selections, nil
+	}
+	return nil, fmt.Errorf("%s ballots cannot be audited", e.BallotType)
+}
+
+// ballotType returns the ballot type of the election, plurality when unset.
+func (e *Election) ballotType() BallotType {
+	if e.BallotType == "" {
+		return BallotPlurality
+	}
+	return e.BallotType
+}
@@ -1,27 +1,21 @@
 // pkg/election/ballot.go
 package election
 
-import (
-	"github.com/cloudflare/bn256"
-	"github.com/gtank/merlin"
-	"github.com/koushamad/election-system/pkg/crypto"
-)
+import "github.com/koushamad/election-system/pkg/crypto"
 
 type Ballot struct {
-	Ciphertext []*bn256.G1 `json:"ciphertext"`
-	ZKProof    []byte      `json:"zk_proof"`
-	VoterID    string      `json:"voter_id"`
+	VoterID     string                 `json:"voter_id"`
+	Type        BallotType             `json:"type,omitempty"`          // Ballot type of the election, plurality when unset
+	Ranked      *RankedBallot          `json:"ranked,omitempty"`        // Set for ranked elections
+	Scores      *ScoreBallot           `json:"scores,omitempty"`        // Set for plurality, approval and score elections
+	Contests    []ContestSection       `json:"contests,omitempty"`      // Set for multi-contest elections
+	WriteIn     *crypto.SealedText     `json:"write_in,omitempty"`      // Sealed write-in name of write-in elections
+	WriteInText *crypto.TextCiphertext `json:"write_in_text,omitempty"` // Write-in name of mixed write-in elections
+	Credential  *BallotCredential      `json:"credential,omitempty"`    // Signature of elections with voter credentials
 }
 
-func NewBallot(ciphertext []*bn256.G1, proof []byte, voterID string) *Ballot {
-	return &Ballot{
-		Ciphertext: ciphertext,
-		ZKProof:    proof,
-		VoterID:    voterID,
-	}
-}
-
-func (b *Ballot) Validate() bool {
-	transcript := merlin.NewTranscript("vote_proof")
-	return crypto.VerifyZKProof(transcript, b.Ciphertext, b.ZKProof)
+// Validate checks the proofs of a plurality ballot: that its cells select
+// exactly one of the candidates of e, for this voter.
+func (b *Ballot) Validate(e *Election) bool {
+	return b.Scores != nil && e.PublicKey != nil && b.Scores.Verify(e, b.VoterID)
 }
@@ -0,0 +1,149 @@
+// pkg/election/certification.go
+package election
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// Official is an election official who certifies results with a BLS key.
+type Official struct {
+	ID         string `json:"id"`
+	PublicKey  []byte `json:"public_key"`
+	Possession []byte `json:"possession"` // Proof of possession of PublicKey, see crypto.ProvePossession
+}
+
+// NewOfficial returns the entry of an official holding key.
+func NewOfficial(id string, key *crypto.BLSKeyPair) Official {
+	return Official{
+		ID:         id,
+		PublicKey:  crypto.MarshalG2(key.PublicKey),
+		Possession: crypto.ProvePossession(key.PrivateKey).Marshal(),
+	}
+}
+
+// CertificationConfig lists the officials of an election. Its results are
+// proposed with decryption proofs once voting closes, or tallied by the
+// officials themselves if the election has a trusted tally, and become
+// certified when Quorum officials have signed them.
+type CertificationConfig struct {
+	Officials []Official `json:"officials"`
+	Quorum    int        `json:"quorum"`
+}
+
+// ValidateCertification checks the officials of an election.
+func (e *Election) ValidateCertification() error {
+	c := e.Certification
+	if c == nil {
+		return nil
+	}
+	if len(c.Officials) == 0 {
+		return errors.New("certified elections require at least one official")
+	}
+	if c.Quorum < 1 || c.Quorum > len(c.Officials) {
+		return fmt.Errorf("certification quorum must be between 1 and %d", len(c.Officials))
+	}
+	ids := make(map[string]bool, len(c.Officials))
+	keys := make(map[string]bool, len(c.Officials))
+	for _, official := range c.Officials {
+		if official.ID == "" || ids[official.ID] {
+			return fmt.Errorf("official IDs must be unique and non-empty, got %q", official.ID)
+		}
+		ids[official.ID] = true
+		key, err := crypto.UnmarshalG2(official.PublicKey)
+		if err != nil {
+			return fmt.Errorf("key of official %s: %v", official.ID, err)
+		}
+		proof, err := crypto.UnmarshalPoint(official.Possession)
+		if err != nil || !crypto.VerifyPossession(key, proof) {
+			return fmt.Errorf("official %s has no valid proof of possession", official.ID)
+		}
+		if keys[string(official.PublicKey)] {
+			return fmt.Errorf("official %s shares a key with another official", official.ID)
+		}
+		keys[string(official.PublicKey)] = true
+	}
+	return nil
+}
+
+// TallyProvable reports whether the results of the election can be proven
+// from the ballots with decryption proofs: the per-candidate totals of
+// plurality, approval, score and question ballots, and the mixed rankings
+// and write-in texts of mixed elections. Unmixed rankings and sealed
+// write-ins could only be decrypted ballot by ballot, revealing every vote.
+func (e *Election) TallyProvable() bool {
+	if e.IsMultiContest() {
+		for _, c := range e.Contests {
+			if c.BallotType == BallotRanked {
+				return false
+			}
+		}
+		return true
+	}
+	switch {
+	case e.WriteIn:
+		return e.Mix != nil
+	case e.ballotType() == BallotPlurality, e.BallotType == BallotApproval, e.BallotType == BallotScore:
+		return true
+	case e.BallotType == BallotRanked:
+		return e.Mix != nil
+	}
+	return false
+}
+
+// ValidateTally checks how the results of the election are established:
+// with decryption proofs, or by a trusted tally its officials sign because
+// its results cannot be proven.
+func (e *Election) ValidateTally() error {
+	if e.TrustedTally {
+		if e.TallyProvable() {
+			return errors.New("results of the election can be proven from the ballots, so it cannot take a trusted tally")
+		}
+		if e.Certification == nil {
+			return errors.New("trusted tallies must be signed by the election's officials")
+		}
+		return nil
+	}
+	if !e.TallyProvable() {
+		return errors.New("unmixed rankings and sealed write-ins cannot be proven from the ballots, so the election requires a trusted tally")
+	}
+	return nil
+}
+
+// Keys decodes the officials' keys, in the order of Officials.
+func (c *CertificationConfig) Keys() ([]*bn256.G2, error) {
+	keys := make([]*bn256.G2, len(c.Officials))
+	for i, official := range c.Officials {
+		key, err := crypto.UnmarshalG2(official.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("key of official %s: %v", official.ID, err)
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+// OfficialIndex returns the index of official id, or -1.
+func (c *CertificationConfig) OfficialIndex(id string) int {
+	for i, official := range c.Officials {
+		if official.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// DecryptionContext returns the context the decryption proof of the index-th
+// tallied ciphertext of an election is bound to.
+func DecryptionContext(electionID string, index int) []byte {
+	return []byte(fmt.Sprintf("decryption/%s/%d", electionID, index))
+}
+
+// TextDecryptionContext returns the context the decryption proof of a chunk
+// of the write-in text of a mixed row is bound to.
+func TextDecryptionContext(electionID string, row, chunk int) []byte {
+	return []byte(fmt.Sprintf("decryption/%s/text/%d/%d", electionID, row, chunk))
+}
@@ -0,0 +1,281 @@
+// pkg/election/contest.go
+package election
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// BallotMultiContest is the ballot type of elections with several contests,
+// whose ballots carry one ContestSection per contest of the voter's style.
+const BallotMultiContest BallotType = "multi-contest"
+
+// Contest is one race or question of a multi-contest election. Its ballot
+// settings mean the same as those of a single-contest Election.
+type Contest struct {
+	ID            string      `json:"id"`
+	Name          string      `json:"name"`
+	Candidates    []Candidate `json:"candidates"`
+	BallotType    BallotType  `json:"ballot_type,omitempty"` // Plurality when unset
+	TieBreak      TieBreak    `json:"tie_break,omitempty"`
+	Seats         int         `json:"seats,omitempty"`
+	MaxScore      int         `json:"max_score,omitempty"`
+	MinSelections int         `json:"min_selections,omitempty"`
+	MaxSelections int         `json:"max_selections,omitempty"`
+
+	// Outcome rules of question contests, see EvaluateQuestion
+	Threshold     *Ratio `json:"threshold,omitempty"`
+	QuorumPercent int    `json:"quorum_percent,omitempty"`
+}
+
+// BallotStyle is the set of contests on the ballots of a group of voters,
+// e.g. the residents of one district.
+type BallotStyle struct {
+	ID       string   `json:"id"`
+	Contests []string `json:"contests"` // Contest IDs in ballot order
+}
+
+// ContestSection is the encrypted answer of a multi-contest ballot to one
+// contest. Ranked contests use Ranked, all others Scores; plurality contests
+// are encoded as approval ballots with at most one selection and question
+// contests as approval ballots with exactly one.
+type ContestSection struct {
+	ContestID string        `json:"contest_id"`
+	Ranked    *RankedBallot `json:"ranked,omitempty"`
+	Scores    *ScoreBallot  `json:"scores,omitempty"`
+}
+
+// IsMultiContest reports whether the election has several contests.
+func (e *Election) IsMultiContest() bool {
+	return len(e.Contests) > 0
+}
+
+// Contest returns the contest with the given ID.
+func (e *Election) Contest(id string) (*Contest, bool) {
+	for i := range e.Contests {
+		if e.Contests[i].ID == id {
+			return &e.Contests[i], true
+		}
+	}
+	return nil, false
+}
+
+// StyleFor returns the ballot style assigned to voterID.
+func (e *Election) StyleFor(voterID string) (*BallotStyle, bool) {
+	styleID, ok := e.VoterStyles[voterID]
+	if !ok {
+		return nil, false
+	}
+	for i := range e.Styles {
+		if e.Styles[i].ID == styleID {
+			return &e.Styles[i], true
+		}
+	}
+	return nil, false
+}
+
+// HasContest reports whether the ballot style of voterID includes contestID.
+func (e *Election) HasContest(voterID, contestID string) bool {
+	style, ok := e.StyleFor(voterID)
+	if !ok {
+		return false
+	}
+	for _, id := range style.Contests {
+		if id == contestID {
+			return true
+		}
+	}
+	return false
+}
+
+// ContestElection returns a single-contest view of contest c, so ballots and
+// tallies of the contest reuse the single-contest machinery. The view's ID
+// joins the election and contest IDs, which binds section proofs to both.
+func (e *Election) ContestElection(c *Contest) *Election {
+	view := &Election{
+		ID:            e.ID + "/" + c.ID,
+		Name:          c.Name,
+		Candidates:    c.Candidates,
+		StartTime:     e.StartTime,
+		EndTime:       e.EndTime,
+		PublicKey:     e.PublicKey,
+		BallotType:    c.BallotType,
+		TieBreak:      c.TieBreak,
+		Seats:         c.Seats,
+		MaxScore:      c.MaxScore,
+		MinSelections: c.MinSelections,
+		MaxSelections: c.MaxSelections,
+		VoterStyles:   e.VoterStyles,
+		VoterWeights:  e.VoterWeights,
+	}
+	switch view.BallotType {
+	case "", BallotPlurality:
+		view.BallotType = BallotApproval
+		view.MaxSelections = 1
+	case BallotQuestion:
+		view.BallotType = BallotApproval
+		view.MinSelections, view.MaxSelections = 1, 1
+	}
+	return view
+}
+
+// ValidateContests checks the contests and ballot styles of a multi-contest
+// election. Each contest's own ballot settings are checked by the caller on
+// its ContestElection view.
+func (e *Election) ValidateContests() error {
+	contests := make(map[string]bool, len(e.Contests))
+	for _, c := range e.Contests {
+		if c.ID == "" || contests[c.ID] {
+			return fmt.Errorf("contest IDs must be unique and non-empty")
+		}
+		contests[c.ID] = true
+		switch c.BallotType {
+		case "", BallotPlurality:
+			if c.MaxScore != 0 || c.MaxSelections > 1 || c.MinSelections > 1 {
+				return fmt.Errorf("plurality contest %s takes at most one selection", c.ID)
+			}
+		case BallotMultiContest:
+			return fmt.Errorf("contest %s cannot itself be multi-contest", c.ID)
+		}
+		if err := c.validateQuestion(); err != nil {
+			return err
+		}
+	}
+
+	if len(e.Styles) == 0 {
+		return errors.New("at least one ballot style is required")
+	}
+	styles := make(map[string]bool, len(e.Styles))
+	for _, style := range e.Styles {
+		if style.ID == "" || styles[style.ID] {
+			return fmt.Errorf("ballot style IDs must be unique and non-empty")
+		}
+		styles[style.ID] = true
+		if len(style.Contests) == 0 {
+			return fmt.Errorf("ballot style %s has no contests", style.ID)
+		}
+		seen := make(map[string]bool, len(style.Contests))
+		for _, id := range style.Contests {
+			if !contests[id] || seen[id] {
+				return fmt.Errorf("ballot style %s lists unknown or repeated contest %q", style.ID, id)
+			}
+			seen[id] = true
+		}
+	}
+	for voterID, styleID := range e.VoterStyles {
+		if !styles[styleID] {
+			return fmt.Errorf("voter %s is assigned unknown ballot style %q", voterID, styleID)
+		}
+	}
+	return nil
+}
+
+// NewContestSection encrypts a voter's choice in one contest. choice is read
+// according to the contest's ballot type: candidate indices in preference
+// order for ranked contests, one value per candidate for approval and score
+// contests, and at most one candidate or option index for plurality and
+// question contests.
+func (e *Election) NewContestSection(contestID, voterID string, choice []int) (*ContestSection, error) {
+	c, ok := e.Contest(contestID)
+	if !ok {
+		return nil, fmt.Errorf("unknown contest %q", contestID)
+	}
+	view := e.ContestElection(c)
+	section := &ContestSection{ContestID: contestID}
+
+	switch c.BallotType {
+	case BallotRanked:
+		ranked, err := NewRankedBallot(view.PublicKey, view.ID, voterID, len(view.Candidates), choice)
+		if err != nil {
+			return nil, err
+		}
+		section.Ranked = ranked
+		return section, nil
+	case "", BallotPlurality, BallotQuestion:
+		if len(choice) > 1 {
+			return nil, fmt.Errorf("contest %s takes at most one choice", contestID)
+		}
+		values := make([]int, len(view.Candidates))
+		for _, i := range choice {
+			if i < 0 || i >= len(values) {
+				return nil, fmt.Errorf("candidate index %d out of range", i)
+			}
+			values[i] = 1
+		}
+		choice = values
+	}
+
+	scores, err := NewScoreBallot(view, voterID, choice)
+	if err != nil {
+		return nil, err
+	}
+	section.Scores = scores
+	return section, nil
+}
+
+// VerifySections checks that sections answer exactly the contests of the
+// voter's ballot style, in ballot order, and that every section's proofs
+// hold.
+func (e *Election) VerifySections(voterID string, sections []ContestSection) error {
+	style, ok := e.StyleFor(voterID)
+	if !ok {
+		return fmt.Errorf("voter %s has no ballot style", voterID)
+	}
+	if len(sections) != len(style.Contests) {
+		return fmt.Errorf("ballot style %s has %d contests, ballot answers %d", style.ID, len(style.Contests), len(sections))
+	}
+
+	for i, section := range sections {
+		if section.ContestID != style.Contests[i] {
+			return fmt.Errorf("section %d answers %q instead of %q", i, section.ContestID, style.Contests[i])
+		}
+		c, _ := e.Contest(section.ContestID)
+		view := e.ContestElection(c)
+		var valid bool
+		if c.BallotType == BallotRanked {
+			valid = section.Ranked != nil && section.Scores == nil &&
+				section.Ranked.Verify(view.PublicKey, view.ID, voterID, len(view.Candidates))
+		} else {
+			valid = section.Scores != nil && section.Ranked == nil && section.Scores.Verify(view, voterID)
+		}
+		if !valid {
+			return fmt.Errorf("proofs of contest %s do not verify", c.ID)
+		}
+	}
+	return nil
+}
+
+// ContestSections returns the sections of ballots answering contestID, one
+// per ballot whose style includes the contest.
+func ContestSections(ballots []*Ballot, contestID string) []*ContestSection {
+	var sections []*ContestSection
+	for _, ballot := range ballots {
+		for i := range ballot.Contests {
+			if ballot.Contests[i].ContestID == contestID {
+				sections = append(sections, &ballot.Contests[i])
+				break
+			}
+		}
+	}
+	return sections
+}
+
+// TallyContest adds up the sections of any contest but a ranked one per
+// candidate, giving each candidate's encrypted total.
+func (e *Election) TallyContest(contestID string, ballots []*Ballot) ([]*crypto.Ciphertext, error) {
+	c, ok := e.Contest(contestID)
+	if !ok {
+		return nil, fmt.Errorf("unknown contest %q", contestID)
+	}
+	if c.BallotType == BallotRanked {
+		return nil, fmt.Errorf("ranked contest %s is tabulated from decrypted rankings", contestID)
+	}
+
+	var scores []*ScoreBallot
+	for _, section := range ContestSections(ballots, contestID) {
+		scores = append(scores, section.Scores)
+	}
+	return TallyScores(scores, len(c.Candidates)), nil
+}
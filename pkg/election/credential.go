@@ -0,0 +1,190 @@
+// pkg/election/credential.go
+package election
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// BallotCredential links a ballot to its voter's eligibility credential. The
+// nullifier is the same on every ballot the credential signs in an election,
+// so later ballots can supersede earlier ones, and the proof signs the ballot
+// so that only the credential holder can cast or supersede it. The shared
+// nullifier also shows everyone that the credential voted again, so a
+// coercer who can find the coerced ballot on chain, e.g. by its tracker, can
+// tell it was replaced: revoting only defeats coercers who cannot.
+type BallotCredential struct {
+	Nullifier []byte                 `json:"nullifier"`
+	Proof     *crypto.NullifierProof `json:"proof"`
+
+	// Token of elections with a registrar: the credential key and the
+	// registrar's signature on it, see VotingToken
+	Key       []byte `json:"key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+
+	// Ring signature of elections with a voter ring, whose key image is the
+	// nullifier, see SignBallotWithRing
+	Ring *crypto.RingSignature `json:"ring,omitempty"`
+}
+
+// HasCredentials reports whether the election has a voter credential
+// registry, a registrar or a voter ring, in which case every ballot must be
+// signed, see SignBallot.
+func (e *Election) HasCredentials() bool {
+	return len(e.VoterCredentials) > 0 || e.HasRegistrar() || e.HasRing()
+}
+
+// CredentialKey returns the registered credential key of voterID.
+func (e *Election) CredentialKey(voterID string) (*bn256.G1, bool) {
+	data, ok := e.VoterCredentials[voterID]
+	if !ok {
+		return nil, false
+	}
+	key, err := crypto.UnmarshalPoint(data)
+	if err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+// ValidateCredentials checks that every registered credential key is a
+// valid point, as are the registrar key and the keys of the voter ring.
+// Voters are admitted by only one of them, and ring members all weigh the
+// same.
+func (e *Election) ValidateCredentials() error {
+	admissions := 0
+	for _, set := range []bool{len(e.VoterCredentials) > 0, e.HasRegistrar(), e.HasRing()} {
+		if set {
+			admissions++
+		}
+	}
+	if admissions > 1 {
+		return errors.New("elections admit voters by one of a credential registry, a registrar or a voter ring")
+	}
+	if e.HasRegistrar() {
+		if _, err := crypto.UnmarshalG2(e.RegistrarKey); err != nil {
+			return fmt.Errorf("registrar key: %v", err)
+		}
+	}
+	if e.HasRing() {
+		// Ring ballots hide which member cast them, so no weight can be
+		// looked up for them
+		if e.IsWeighted() {
+			return errors.New("ring elections cannot be weighted")
+		}
+		ring, err := e.RingKeys()
+		if err != nil {
+			return fmt.Errorf("voter ring: %v", err)
+		}
+		seen := make(map[string]bool, len(ring))
+		for _, key := range ring {
+			if seen[key.String()] {
+				return errors.New("voter ring lists a key twice")
+			}
+			seen[key.String()] = true
+		}
+	}
+	for voterID, data := range e.VoterCredentials {
+		if _, err := crypto.UnmarshalPoint(data); err != nil {
+			return fmt.Errorf("credential key of voter %s: %v", voterID, err)
+		}
+	}
+	return nil
+}
+
+// SignBallot attaches the nullifier of the credential secret to ballot, with
+// a proof binding it to the ballot's contents.
+func (e *Election) SignBallot(ballot *Ballot, secret *big.Int) error {
+	digest, err := ballotDigest(e.ID, ballot)
+	if err != nil {
+		return err
+	}
+	credential, err := e.signDigest(secret, digest)
+	if err != nil {
+		return err
+	}
+	ballot.Credential = credential
+	return nil
+}
+
+// signDigest attaches the nullifier of the credential secret to digest, with
+// a proof binding them.
+func (e *Election) signDigest(secret *big.Int, digest []byte) (*BallotCredential, error) {
+	base := e.nullifierBase()
+	proof, err := crypto.ProveNullifier(secret, base, digest)
+	if err != nil {
+		return nil, err
+	}
+	return &BallotCredential{
+		Nullifier: crypto.Nullifier(secret, base).Marshal(),
+		Proof:     proof,
+	}, nil
+}
+
+// VerifyBallotCredential checks that ballot is signed by the registered
+// credential of its voter, by a token of the election's registrar, or by a
+// member of the voter ring.
+func (e *Election) VerifyBallotCredential(ballot *Ballot) error {
+	if ballot.Credential == nil {
+		return errors.New("ballot is not signed with a voter credential")
+	}
+	digest, err := ballotDigest(e.ID, ballot)
+	if err != nil {
+		return err
+	}
+	return e.verifyCredential(ballot.VoterID, ballot.Credential, digest)
+}
+
+// verifyCredential checks that credential signs digest on behalf of voterID,
+// see VerifyBallotCredential.
+func (e *Election) verifyCredential(voterID string, credential *BallotCredential, digest []byte) error {
+	if e.HasRing() {
+		return e.verifyRingCredential(voterID, credential, digest)
+	}
+	var key *bn256.G1
+	if e.HasRegistrar() {
+		var err error
+		if key, err = e.VerifyToken(voterID, credential.Key, credential.Signature); err != nil {
+			return err
+		}
+	} else {
+		var ok bool
+		if key, ok = e.CredentialKey(voterID); !ok {
+			return fmt.Errorf("voter %s has no registered credential", voterID)
+		}
+	}
+	nullifier, err := crypto.UnmarshalPoint(credential.Nullifier)
+	if err != nil {
+		return fmt.Errorf("malformed nullifier: %v", err)
+	}
+	if !crypto.VerifyNullifier(key, e.nullifierBase(), nullifier, credential.Proof, digest) {
+		return errors.New("credential proof does not verify")
+	}
+	return nil
+}
+
+// nullifierBase is the point nullifiers of the election are derived from.
+func (e *Election) nullifierBase() *bn256.G1 {
+	return crypto.HashToPoint("election-system/nullifier", []byte(e.ID))
+}
+
+// ballotDigest hashes the election ID and the contents of ballot other than
+// its credential.
+func ballotDigest(electionID string, ballot *Ballot) ([]byte, error) {
+	unsigned := *ballot
+	unsigned.Credential = nil
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write([]byte(electionID))
+	h.Write(data)
+	return h.Sum(nil), nil
+}
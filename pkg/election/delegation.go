@@ -0,0 +1,200 @@
+// pkg/election/delegation.go
+package election
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// DelegateWeight is the weight a voter's ballot carries once the delegations
+// ending at them are resolved.
+type DelegateWeight struct {
+	Own        int      `json:"own"`
+	Delegated  int      `json:"delegated"`
+	Effective  int      `json:"effective"`
+	Delegators []string `json:"delegators"` // Voter IDs whose chains end at the delegate, sorted
+}
+
+// DelegationReport is the result of resolving the delegations of an election
+// or contest against the ballots cast.
+type DelegationReport struct {
+	Delegates  map[string]*DelegateWeight `json:"delegates"`            // Per voter ID of delegates who voted
+	Delegated  int                        `json:"delegated"`            // Weight moved to delegates in total
+	Lost       int                        `json:"lost"`                 // Weight of chains that reach no ballot
+	Overridden []string                   `json:"overridden,omitempty"` // Delegators who voted directly, sorted
+}
+
+// IsRegistered reports whether voterID may vote in the election, and so
+// delegate or receive a delegation. Elections without a voter credential or
+// weight registry or ballot style assignments admit any voter.
+func (e *Election) IsRegistered(voterID string) bool {
+	if len(e.VoterCredentials) > 0 {
+		if _, ok := e.VoterCredentials[voterID]; !ok {
+			return false
+		}
+	}
+	if e.IsWeighted() {
+		return e.VoterWeights[voterID] > 0
+	}
+	if e.IsMultiContest() {
+		_, ok := e.StyleFor(voterID)
+		return ok
+	}
+	return true
+}
+
+// SignDelegation signs delegator's delegation of their vote on the topic,
+// the election or contestID of it, to delegate, or its revocation when
+// delegate is empty. Like ballots, delegations are signed with the
+// delegator's credential secret, or its ring key in ring elections.
+func (e *Election) SignDelegation(contestID, delegator, delegate string, secret *big.Int) (*BallotCredential, error) {
+	digest := delegationDigest(e.ID, contestID, delegator, delegate)
+	if e.HasRing() {
+		return e.signDigestWithRing(secret, delegator, digest)
+	}
+	return e.signDigest(secret, digest)
+}
+
+// SignDelegationWithToken signs a delegation of the token's voter like
+// SignDelegation, attaching the token like SignBallotWithToken.
+func (e *Election) SignDelegationWithToken(contestID, delegate string, token *VotingToken) (*BallotCredential, error) {
+	if token.ElectionID != e.ID {
+		return nil, fmt.Errorf("token was issued for election %s", token.ElectionID)
+	}
+	credential, err := e.signDigest(token.Secret, delegationDigest(e.ID, contestID, token.VoterID(), delegate))
+	if err != nil {
+		return nil, err
+	}
+	credential.Key = token.Key
+	credential.Signature = token.Signature
+	return credential, nil
+}
+
+// VerifyDelegation checks that credential signs the delegation on behalf of
+// delegator, as VerifyBallotCredential checks ballots.
+func (e *Election) VerifyDelegation(contestID, delegator, delegate string, credential *BallotCredential) error {
+	if credential == nil {
+		return errors.New("delegation is not signed with a voter credential")
+	}
+	return e.verifyCredential(delegator, credential, delegationDigest(e.ID, contestID, delegator, delegate))
+}
+
+// delegationDigest hashes a delegation, separated from ballot digests so
+// that neither signature can stand in for the other.
+func delegationDigest(electionID, contestID, delegator, delegate string) []byte {
+	data, _ := json.Marshal([]string{electionID, contestID, delegator, delegate})
+	hash := sha256.Sum256(append([]byte("election-system/delegation"), data...))
+	return hash[:]
+}
+
+// ResolveDelegations follows every delegator's chain in delegations, which
+// maps delegators to their delegates, to the first voter along it who voted.
+// That voter receives the delegator's weight. A direct vote overrides the
+// voter's own delegation, and chains ending at a voter who neither voted nor
+// delegated lose their weight.
+func ResolveDelegations(delegations map[string]string, voted func(string) bool, weight func(string) int) *DelegationReport {
+	report := &DelegationReport{Delegates: make(map[string]*DelegateWeight)}
+	for delegator := range delegations {
+		if voted(delegator) {
+			report.Overridden = append(report.Overridden, delegator)
+			continue
+		}
+
+		w := weight(delegator)
+		delegate, ok := resolveChain(delegations, delegator, voted)
+		if !ok {
+			report.Lost += w
+			continue
+		}
+		d := report.Delegates[delegate]
+		if d == nil {
+			d = &DelegateWeight{Own: weight(delegate)}
+			report.Delegates[delegate] = d
+		}
+		d.Delegated += w
+		d.Delegators = append(d.Delegators, delegator)
+		report.Delegated += w
+	}
+
+	for _, d := range report.Delegates {
+		d.Effective = d.Own + d.Delegated
+		sort.Strings(d.Delegators)
+	}
+	sort.Strings(report.Overridden)
+	return report
+}
+
+// EffectiveWeight returns the weight of voterID's ballot given its own weight.
+func (r *DelegationReport) EffectiveWeight(voterID string, own int) int {
+	if d, ok := r.Delegates[voterID]; ok {
+		return d.Effective
+	}
+	return own
+}
+
+// resolveChain returns the first voter after delegator in its chain who
+// voted.
+func resolveChain(delegations map[string]string, delegator string, voted func(string) bool) (string, bool) {
+	current := delegator
+	for steps := 0; steps < len(delegations); steps++ {
+		next, ok := delegations[current]
+		if !ok {
+			return "", false
+		}
+		if voted(next) {
+			return next, true
+		}
+		current = next
+	}
+	return "", false // Unreachable while delegations are acyclic
+}
+
+// DelegationCycle reports whether delegating from delegator to delegate would
+// close a cycle in delegations, which must be acyclic.
+func DelegationCycle(delegations map[string]string, delegator, delegate string) bool {
+	current := delegate
+	for steps := 0; steps <= len(delegations); steps++ {
+		if current == delegator {
+			return true
+		}
+		next, ok := delegations[current]
+		if !ok {
+			return false
+		}
+		current = next
+	}
+	return true
+}
+
+// TallyDelegatedScores is TallyScores with every ballot counted at its
+// voter's effective weight. Ballots maps voter IDs to their score ballots,
+// whose cells encrypt value*own weight; they are scaled by effective/own
+// modulo the group order, which yields exactly value*effective weight.
+func (e *Election) TallyDelegatedScores(ballots map[string]*ScoreBallot, report *DelegationReport) []*crypto.Ciphertext {
+	scaled := make([]*ScoreBallot, 0, len(ballots))
+	for voterID, ballot := range ballots {
+		own := e.WeightOf(voterID)
+		effective := report.EffectiveWeight(voterID, own)
+		if effective == own || own < 1 {
+			scaled = append(scaled, ballot)
+			continue
+		}
+
+		factor := new(big.Int).ModInverse(big.NewInt(int64(own)), bn256.Order)
+		factor.Mul(factor, big.NewInt(int64(effective)))
+		factor.Mod(factor, bn256.Order)
+		cells := make([]*crypto.Ciphertext, len(ballot.Cells))
+		for i, cell := range ballot.Cells {
+			cells[i] = cell.ScalarMult(factor)
+		}
+		scaled = append(scaled, &ScoreBallot{Cells: cells})
+	}
+	return TallyScores(scaled, len(e.Candidates))
+}
@@ -2,7 +2,9 @@
 package election
 
 import (
+	"encoding/json"
 	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
 	"time"
 )
 
@@ -13,9 +15,111 @@ type Election struct {
 	StartTime  time.Time   `json:"start_time"`
 	EndTime    time.Time   `json:"end_time"`
 	PublicKey  *bn256.G1   `json:"public_key"`
+	Rules      RuleConfig  `json:"rules"`      // Rule module applied to ballots and tallies
+	RulesHash  []byte      `json:"rules_hash"` // Commitment to Rules, see RuleConfig.Hash
+	BallotType BallotType  `json:"ballot_type,omitempty"`
+	TieBreak   TieBreak    `json:"tie_break,omitempty"` // Elimination tie-breaking for ranked tabulation
+	Seats      int         `json:"seats,omitempty"`     // Number of candidates to elect, 1 when unset
+
+	// Scoring of approval and score ballots, see ValidateScoring
+	MaxScore      int `json:"max_score,omitempty"`      // Highest score per candidate on score ballots
+	MinSelections int `json:"min_selections,omitempty"` // Lowest number of approvals or total score per ballot
+	MaxSelections int `json:"max_selections,omitempty"` // Highest number of approvals or total score per ballot, unbounded when 0
+
+	// Contests of multi-contest elections, which leave Candidates empty
+	Contests    []Contest         `json:"contests,omitempty"`
+	Styles      []BallotStyle     `json:"styles,omitempty"`
+	VoterStyles map[string]string `json:"voter_styles,omitempty"` // Ballot style ID per voter ID
+
+	// Voter registry of weighted elections, e.g. share counts, see WeightOf
+	VoterWeights map[string]int `json:"voter_weights,omitempty"`
+
+	// Write-ins: the WriteInCandidate option, selected on ballots that carry
+	// the written name sealed to the election key, see SealWriteIn
+	WriteIn bool `json:"write_in,omitempty"`
+
+	// Voter credential registry: credential key per voter ID, see SignBallot
+	VoterCredentials map[string][]byte `json:"voter_credentials,omitempty"`
+
+	// BLS public key of the registrar blindly signing anonymous voting
+	// tokens, instead of a credential registry, see VotingToken
+	RegistrarKey []byte `json:"registrar_key,omitempty"`
+
+	// Credential keys of the voters signing ballots with linkable ring
+	// signatures, instead of a registry or registrar, see SignBallotWithRing
+	VoterRing [][]byte `json:"voter_ring,omitempty"`
+
+	// Mix servers shuffling the ballots before they are decrypted one by one
+	Mix *MixConfig `json:"mix,omitempty"`
+
+	// Officials certifying the results
+	Certification *CertificationConfig `json:"certification,omitempty"`
+
+	// Lets the officials post results without decryption proofs, trusting
+	// a quorum of them. Required by elections whose results cannot be
+	// proven, see TallyProvable
+	TrustedTally bool `json:"trusted_tally,omitempty"`
+
+	// Beacon round the election's private key is time-locked to, released
+	// when voting closes
+	TimeLock *TimeLockConfig `json:"time_lock,omitempty"`
 }
 
+// SeatCount returns the number of candidates the election fills.
+func (e *Election) SeatCount() int {
+	if e.Seats < 1 {
+		return 1
+	}
+	return e.Seats
+}
+
+// BallotType determines how voters express their choice.
+type BallotType string
+
+const (
+	BallotPlurality BallotType = "plurality" // An encrypted 0 or 1 per candidate, exactly one 1, see ScoreBallot
+	BallotRanked    BallotType = "ranked"    // An encrypted preference order, see RankedBallot
+	BallotApproval  BallotType = "approval"  // An encrypted approval per candidate, see ScoreBallot
+	BallotScore     BallotType = "score"     // An encrypted score per candidate, see ScoreBallot
+)
+
 type Candidate struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
+
+// MarshalJSON encodes the public key in its binary form, since bn256 points
+// have no JSON representation of their own.
+func (e Election) MarshalJSON() ([]byte, error) {
+	type alias Election
+	return json.Marshal(struct {
+		alias
+		PublicKey []byte `json:"public_key"`
+	}{
+		alias:     alias(e),
+		PublicKey: crypto.MarshalPoint(e.PublicKey),
+	})
+}
+
+func (e *Election) UnmarshalJSON(data []byte) error {
+	type alias Election
+	aux := struct {
+		*alias
+		PublicKey []byte `json:"public_key"`
+	}{
+		alias: (*alias)(e),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	e.PublicKey = nil
+	if aux.PublicKey != nil {
+		publicKey, err := crypto.UnmarshalPoint(aux.PublicKey)
+		if err != nil {
+			return err
+		}
+		e.PublicKey = publicKey
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// pkg/election/irv.go
+package election
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// TieBreak selects which of several candidates tied for fewest votes is
+// eliminated in a tabulation round.
+type TieBreak string
+
+const (
+	// TieBreakPreviousRounds eliminates the tied candidate that had fewer
+	// votes in the most recent earlier round where they differed, falling
+	// back to TieBreakLot if they were tied in every round.
+	TieBreakPreviousRounds TieBreak = "previous-rounds"
+	// TieBreakLot eliminates the tied candidate drawn by a deterministic lot
+	// seeded with the election ID, so observers can reproduce the draw.
+	TieBreakLot TieBreak = "lot"
+	// TieBreakCandidateOrder eliminates the tied candidate listed last.
+	TieBreakCandidateOrder TieBreak = "candidate-order"
+
+	DefaultTieBreak = TieBreakPreviousRounds
+)
+
+// IRVRound records one round of instant runoff tabulation.
+type IRVRound struct {
+	Round      int            `json:"round"`
+	Votes      map[string]int `json:"votes"`     // Votes of each continuing candidate
+	Exhausted  int            `json:"exhausted"` // Ballots without a continuing candidate
+	Eliminated string         `json:"eliminated,omitempty"`
+	TiedWith   []string       `json:"tied_with,omitempty"` // Candidates tied with Eliminated
+	TieBreak   TieBreak       `json:"tie_break,omitempty"` // Rule that resolved the tie
+}
+
+// IRVReport is the full round-by-round result of an instant runoff count.
+type IRVReport struct {
+	Winner  string     `json:"winner,omitempty"`
+	Ballots int        `json:"ballots"`
+	Rounds  []IRVRound `json:"rounds"`
+}
+
+// ParseRankings converts rankings given as candidate names into candidate
+// indices, rejecting unknown and repeated candidates.
+func ParseRankings(candidates []Candidate, rankings [][]string) ([][]int, error) {
+	index := make(map[string]int, len(candidates))
+	for i, candidate := range candidates {
+		index[candidate.Name] = i
+	}
+
+	parsed := make([][]int, len(rankings))
+	for b, ranking := range rankings {
+		seen := make(map[int]bool, len(ranking))
+		parsed[b] = make([]int, len(ranking))
+		for r, name := range ranking {
+			i, ok := index[name]
+			if !ok {
+				return nil, fmt.Errorf("ballot %d ranks unknown candidate %q", b, name)
+			}
+			if seen[i] {
+				return nil, fmt.Errorf("ballot %d ranks %q twice", b, name)
+			}
+			seen[i] = true
+			parsed[b][r] = i
+		}
+	}
+	return parsed, nil
+}
+
+// TabulateIRV runs an instant runoff count over rankings, each a list of
+// candidate indices from most to least preferred. Each round counts every
+// ballot for its highest ranked continuing candidate; a candidate with more
+// than half of the non-exhausted ballots wins, otherwise the candidate with
+// the fewest votes is eliminated, ties being resolved by tieBreak. seed is
+// the input of TieBreakLot, normally the election ID.
+func TabulateIRV(candidates []Candidate, rankings [][]int, tieBreak TieBreak, seed string) (*IRVReport, error) {
+	if tieBreak == "" {
+		tieBreak = DefaultTieBreak
+	}
+	switch tieBreak {
+	case TieBreakPreviousRounds, TieBreakLot, TieBreakCandidateOrder:
+	default:
+		return nil, fmt.Errorf("unknown tie-break rule %q", tieBreak)
+	}
+
+	report := &IRVReport{Ballots: len(rankings)}
+	if len(candidates) == 0 || len(rankings) == 0 {
+		return report, nil
+	}
+
+	continuing := make([]bool, len(candidates))
+	for i := range continuing {
+		continuing[i] = true
+	}
+	remaining := len(candidates)
+	var history [][]int64 // Votes per candidate index, per round
+
+	for round := 1; ; round++ {
+		votes := make([]int, len(candidates))
+		exhausted := 0
+		for _, ranking := range rankings {
+			counted := false
+			for _, i := range ranking {
+				if continuing[i] {
+					votes[i]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				exhausted++
+			}
+		}
+		snapshot := make([]int64, len(votes))
+		for i, v := range votes {
+			snapshot[i] = int64(v)
+		}
+		history = append(history, snapshot)
+
+		result := IRVRound{Round: round, Votes: make(map[string]int), Exhausted: exhausted}
+		active := len(rankings) - exhausted
+		leader := -1
+		for i, candidate := range candidates {
+			if !continuing[i] {
+				continue
+			}
+			result.Votes[candidate.Name] = votes[i]
+			if leader < 0 || votes[i] > votes[leader] {
+				leader = i
+			}
+		}
+
+		if remaining == 1 || (active > 0 && 2*votes[leader] > active) {
+			report.Rounds = append(report.Rounds, result)
+			report.Winner = candidates[leader].Name
+			return report, nil
+		}
+
+		// Eliminate the continuing candidate with the fewest votes
+		var lowest []int
+		for i := range candidates {
+			if !continuing[i] {
+				continue
+			}
+			switch {
+			case len(lowest) == 0 || votes[i] < votes[lowest[0]]:
+				lowest = []int{i}
+			case votes[i] == votes[lowest[0]]:
+				lowest = append(lowest, i)
+			}
+		}
+
+		eliminated := lowest[0]
+		if len(lowest) > 1 {
+			var rule TieBreak
+			eliminated, rule = breakTie(candidates, lowest, history, tieBreak, seed, round)
+			result.TieBreak = rule
+			for _, i := range lowest {
+				if i != eliminated {
+					result.TiedWith = append(result.TiedWith, candidates[i].Name)
+				}
+			}
+		}
+		result.Eliminated = candidates[eliminated].Name
+		report.Rounds = append(report.Rounds, result)
+
+		continuing[eliminated] = false
+		remaining--
+	}
+}
+
+// breakTie picks the candidate to eliminate among tied and returns the rule
+// that decided it.
+func breakTie(candidates []Candidate, tied []int, history [][]int64, tieBreak TieBreak, seed string, round int) (int, TieBreak) {
+	switch tieBreak {
+	case TieBreakCandidateOrder:
+		return tied[len(tied)-1], TieBreakCandidateOrder
+	case TieBreakPreviousRounds:
+		// Walk back through earlier rounds until the tied candidates differ
+		for r := len(history) - 2; r >= 0; r-- {
+			var lowest []int
+			for _, i := range tied {
+				switch {
+				case len(lowest) == 0 || history[r][i] < history[r][lowest[0]]:
+					lowest = []int{i}
+				case history[r][i] == history[r][lowest[0]]:
+					lowest = append(lowest, i)
+				}
+			}
+			if len(lowest) == 1 {
+				return lowest[0], TieBreakPreviousRounds
+			}
+			tied = lowest
+		}
+	}
+
+	// Lot: the smallest hash of seed, round and candidate name is eliminated
+	loser := tied[0]
+	var loserDraw []byte
+	for _, i := range tied {
+		draw := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%s", seed, round, candidates[i].Name)))
+		if loserDraw == nil || bytes.Compare(draw[:], loserDraw) < 0 {
+			loser, loserDraw = i, draw[:]
+		}
+	}
+	return loser, TieBreakLot
+}
@@ -0,0 +1,167 @@
+// pkg/election/mix.go
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"unicode/utf8"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// writeInTextChunks is the number of chunks of every write-in text: a length
+// byte and the name padded to MaxWriteInLength.
+const writeInTextChunks = 3
+
+// MixConfig lists the mix servers that shuffle the ballots of an election
+// after voting closes, in the order they take turns. Once all have mixed,
+// ballots can be decrypted one by one without revealing who cast them, as
+// long as one server kept its permutation secret.
+type MixConfig struct {
+	Servers []string `json:"servers"`
+}
+
+// ValidateMix checks the mix servers of an election. Mixing serves ballots
+// that are decrypted individually, ranked ballots and write-ins; weighted
+// ballots are left out, since decrypting their cells would reveal the
+// voters' weights.
+func (e *Election) ValidateMix() error {
+	if e.Mix == nil {
+		return nil
+	}
+	if len(e.Mix.Servers) == 0 {
+		return errors.New("mixed elections require at least one mix server")
+	}
+	seen := make(map[string]bool, len(e.Mix.Servers))
+	for _, server := range e.Mix.Servers {
+		if server == "" || seen[server] {
+			return fmt.Errorf("mix server IDs must be unique and non-empty, got %q", server)
+		}
+		seen[server] = true
+	}
+	if e.BallotType != BallotRanked && !e.WriteIn {
+		return errors.New("only ranked and write-in elections are mixed")
+	}
+	if e.IsWeighted() {
+		return errors.New("weighted elections cannot be mixed")
+	}
+	return nil
+}
+
+// EncryptWriteInText encrypts the name voterID writes in to the election key
+// for a mixed election. Like sealed write-ins, every text has the same size.
+func (e *Election) EncryptWriteInText(voterID, name string) (*crypto.TextCiphertext, error) {
+	if len(name) > MaxWriteInLength {
+		return nil, fmt.Errorf("write-in names are limited to %d bytes", MaxWriteInLength)
+	}
+	if !utf8.ValidString(name) {
+		return nil, errors.New("write-in name is not valid UTF-8")
+	}
+	return crypto.EncryptText(e.PublicKey, []byte(name), writeInTextChunks, writeInContext(e.ID, voterID))
+}
+
+// CheckWriteInText checks the shape and proof of a write-in text, which binds
+// it to voterID's ballot.
+func (e *Election) CheckWriteInText(voterID string, text *crypto.TextCiphertext) error {
+	if text == nil {
+		return errors.New("mixed write-in elections require a write-in text on every ballot")
+	}
+	if len(text.Chunks) != writeInTextChunks {
+		return fmt.Errorf("write-in texts must have %d chunks", writeInTextChunks)
+	}
+	if !text.Verify(writeInContext(e.ID, voterID)) {
+		return errors.New("write-in text proof does not verify")
+	}
+	return nil
+}
+
+// MixRows returns the rows of ciphertexts the first mix server shuffles, one
+// per ballot: the cells of a ranked ballot row by row, or the write-in cell
+// of a write-in ballot followed by its text.
+func (e *Election) MixRows(ballots []*Ballot) ([][]*crypto.Ciphertext, error) {
+	rows := make([][]*crypto.Ciphertext, 0, len(ballots))
+	index := e.WriteInIndex()
+	for _, ballot := range ballots {
+		var row []*crypto.Ciphertext
+		switch {
+		case e.BallotType == BallotRanked:
+			if ballot.Ranked == nil {
+				return nil, fmt.Errorf("ballot of voter %s is not ranked", ballot.VoterID)
+			}
+			for _, cells := range ballot.Ranked.Cells {
+				row = append(row, cells...)
+			}
+		case e.WriteIn:
+			if ballot.Scores == nil || index < 0 || index >= len(ballot.Scores.Cells) || ballot.WriteInText == nil {
+				return nil, fmt.Errorf("ballot of voter %s has no write-in", ballot.VoterID)
+			}
+			row = append([]*crypto.Ciphertext{ballot.Scores.Cells[index]}, ballot.WriteInText.Chunks...)
+		default:
+			return nil, fmt.Errorf("%s ballots are not mixed", e.BallotType)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// DecryptMixedRankings decrypts the mixed rows of a ranked election into
+// rankings of candidate names, most preferred first.
+func (e *Election) DecryptMixedRankings(privKey *big.Int, rows [][]*crypto.Ciphertext) ([][]string, error) {
+	n := len(e.Candidates)
+	rankings := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != n*n {
+			return nil, fmt.Errorf("mixed ranked rows must have %d cells", n*n)
+		}
+		ballot := &RankedBallot{Cells: make([][]*crypto.Ciphertext, n)}
+		for i := range ballot.Cells {
+			ballot.Cells[i] = row[i*n : (i+1)*n]
+		}
+		ranking, err := ballot.Decrypt(privKey)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, len(ranking))
+		for rank, candidate := range ranking {
+			names[rank] = e.Candidates[candidate].Name
+		}
+		rankings = append(rankings, names)
+	}
+	return rankings, nil
+}
+
+// DecryptMixedWriteIns decrypts the write-ins of the mixed rows whose
+// write-in cell is selected, returning the weight written in per normalized
+// name like DecryptWriteIns.
+func (e *Election) DecryptMixedWriteIns(privKey *big.Int, rows [][]*crypto.Ciphertext) (map[string]int, error) {
+	names := make(map[string]int)
+	for _, row := range rows {
+		if len(row) != 1+writeInTextChunks {
+			return nil, fmt.Errorf("mixed write-in rows must have %d ciphertexts", 1+writeInTextChunks)
+		}
+		selected, err := crypto.DecryptValue(privKey, row[0], int64(e.MaxCellValue()))
+		if err != nil {
+			return nil, fmt.Errorf("write-in cell: %v", err)
+		}
+		if selected == 0 {
+			continue
+		}
+
+		name, err := crypto.DecryptText(privKey, row[1:])
+		if err != nil {
+			return nil, fmt.Errorf("write-in text: %v", err)
+		}
+		if len(name) > MaxWriteInLength {
+			return nil, errors.New("malformed write-in")
+		}
+		names[NormalizeWriteIn(string(name))] += int(selected)
+	}
+	return names, nil
+}
+
+// MixContext returns the context the shuffle proof of a mixing step is bound
+// to.
+func MixContext(electionID string, step int) []byte {
+	return []byte(fmt.Sprintf("mix/%s/%d", electionID, step))
+}
@@ -0,0 +1,129 @@
+// pkg/election/question.go
+package election
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BallotQuestion is the ballot type of question contests: referendums and
+// polls whose Candidates are the options, one of which each voter picks.
+const BallotQuestion BallotType = "question"
+
+// AbstainOptionID is the ID of a question option that counts towards
+// turnout and quorum but not towards the decisive votes thresholds apply to.
+const AbstainOptionID = "abstain"
+
+// Ratio is an exact fraction, used for vote thresholds.
+type Ratio struct {
+	Num int `json:"num"`
+	Den int `json:"den"`
+}
+
+// QuestionResult is the evaluation of a question contest's tally.
+type QuestionResult struct {
+	Turnout   int            `json:"turnout"`  // Weight of ballots answering the question, abstentions included
+	Eligible  int            `json:"eligible"` // Weight of voters whose ballot style includes the question
+	Decisive  int            `json:"decisive"` // Votes for options other than abstain
+	Votes     map[string]int `json:"votes"`
+	QuorumMet bool           `json:"quorum_met"`
+	Passed    string         `json:"passed,omitempty"` // Option that met the threshold, if any
+}
+
+// ReferendumOptions returns the options of a yes/no referendum, optionally
+// with an abstain option.
+func ReferendumOptions(abstain bool) []Candidate {
+	options := []Candidate{{ID: "yes", Name: "Yes"}, {ID: "no", Name: "No"}}
+	if abstain {
+		options = append(options, Candidate{ID: AbstainOptionID, Name: "Abstain"})
+	}
+	return options
+}
+
+// validateQuestion checks the threshold and quorum settings of a contest.
+// Only question contests may set them.
+func (c *Contest) validateQuestion() error {
+	if c.BallotType != BallotQuestion {
+		if c.Threshold != nil || c.QuorumPercent != 0 {
+			return fmt.Errorf("only question contests take a threshold or quorum")
+		}
+		return nil
+	}
+	if c.MaxScore != 0 || c.Seats > 1 || c.MinSelections != 0 || c.MaxSelections != 0 {
+		return fmt.Errorf("question contest %s takes exactly one option per ballot", c.ID)
+	}
+	if t := c.Threshold; t != nil {
+		// At least half, so that no two options can both pass
+		if t.Den <= 0 || 2*t.Num < t.Den || t.Num > t.Den {
+			return fmt.Errorf("threshold of question %s must be between 1/2 and 1", c.ID)
+		}
+	}
+	if c.QuorumPercent < 0 || c.QuorumPercent > 100 {
+		return fmt.Errorf("quorum of question %s must be a percentage", c.ID)
+	}
+	decisive := 0
+	for _, option := range c.Candidates {
+		if option.ID != AbstainOptionID {
+			decisive++
+		}
+	}
+	if decisive < 2 {
+		return errors.New("questions need at least two options besides abstain")
+	}
+	return nil
+}
+
+// EligibleWeight returns the total weight of the voters whose ballot style
+// includes contestID, which is their number in unweighted elections.
+func (e *Election) EligibleWeight(contestID string) int {
+	eligible := 0
+	for voterID := range e.VoterStyles {
+		if e.HasContest(voterID, contestID) {
+			eligible += e.WeightOf(voterID)
+		}
+	}
+	return eligible
+}
+
+// EvaluateQuestion decides a question contest from its results. turnout is
+// the total weight of the ballots that answered it. The quorum is met when turnout is
+// at least QuorumPercent of the eligible voters; an option passes when the
+// quorum is met and its share of the decisive votes reaches the threshold,
+// which is more than half when unset.
+func (e *Election) EvaluateQuestion(c *Contest, results map[string]int, turnout int) *QuestionResult {
+	result := &QuestionResult{
+		Turnout:  turnout,
+		Eligible: e.EligibleWeight(c.ID),
+		Votes:    make(map[string]int, len(c.Candidates)),
+	}
+	for _, option := range c.Candidates {
+		result.Votes[option.Name] = results[option.Name]
+		if option.ID != AbstainOptionID {
+			result.Decisive += results[option.Name]
+		}
+	}
+	result.QuorumMet = 100*turnout >= c.QuorumPercent*result.Eligible
+	if !result.QuorumMet || result.Decisive == 0 {
+		return result
+	}
+
+	var passed []string
+	for _, option := range c.Candidates {
+		if option.ID == AbstainOptionID {
+			continue
+		}
+		votes := results[option.Name]
+		if t := c.Threshold; t != nil {
+			if votes*t.Den >= t.Num*result.Decisive {
+				passed = append(passed, option.Name)
+			}
+		} else if 2*votes > result.Decisive {
+			passed = append(passed, option.Name)
+		}
+	}
+	// Two options can only both reach a threshold of exactly one half
+	if len(passed) == 1 {
+		result.Passed = passed[0]
+	}
+	return result
+}
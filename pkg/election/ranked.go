@@ -0,0 +1,246 @@
+// pkg/election/ranked.go
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// RankedBallot is an encrypted preference order over n candidates, encoded as
+// an n x n matrix: Cells[i][j] encrypts 1 if candidate i is ranked in
+// position j and 0 otherwise. Proofs show, in order, that
+//
+//   - every cell encrypts 0 or 1 (n*n proofs),
+//   - every candidate holds at most one rank (n row sum proofs),
+//   - every rank holds at most one candidate (n column sum proofs),
+//   - ranks are filled from the top without gaps (n-1 proofs that each
+//     column sum minus the next one is 0 or 1),
+//
+// so the ballot is a valid full or partial ranking without revealing it.
+type RankedBallot struct {
+	Cells  [][]*crypto.Ciphertext    `json:"cells"`
+	Proofs []*crypto.MembershipProof `json:"proofs"`
+}
+
+var binaryValues = []int64{0, 1}
+
+// NewRankedBallot encrypts ranking, a list of candidate indices from most to
+// least preferred, for an election with numCandidates candidates. The proofs
+// are bound to the election and voter IDs.
+func NewRankedBallot(pubKey *bn256.G1, electionID, voterID string, numCandidates int, ranking []int) (*RankedBallot, error) {
+	ballot, _, err := NewRankedBallotWithOpening(pubKey, electionID, voterID, numCandidates, ranking)
+	return ballot, err
+}
+
+// NewRankedBallotWithOpening is NewRankedBallot, also returning the opening
+// of the cells for the voter to challenge the ballot, see AuditBallot.
+func NewRankedBallotWithOpening(pubKey *bn256.G1, electionID, voterID string, numCandidates int, ranking []int) (*RankedBallot, *BallotOpening, error) {
+	if len(ranking) > numCandidates {
+		return nil, nil, errors.New("ranking lists more candidates than the election has")
+	}
+	rankOf := make([]int, numCandidates)
+	for i := range rankOf {
+		rankOf[i] = -1
+	}
+	for rank, candidate := range ranking {
+		if candidate < 0 || candidate >= numCandidates {
+			return nil, nil, fmt.Errorf("candidate index %d out of range", candidate)
+		}
+		if rankOf[candidate] >= 0 {
+			return nil, nil, fmt.Errorf("candidate index %d ranked twice", candidate)
+		}
+		rankOf[candidate] = rank
+	}
+
+	n := numCandidates
+	ballot := &RankedBallot{Cells: make([][]*crypto.Ciphertext, n)}
+	opening := &BallotOpening{}
+	randomness := make([][]*big.Int, n)
+	for i := 0; i < n; i++ {
+		ballot.Cells[i] = make([]*crypto.Ciphertext, n)
+		randomness[i] = make([]*big.Int, n)
+		for j := 0; j < n; j++ {
+			var bit int64
+			if rankOf[i] == j {
+				bit = 1
+			}
+			ct, r, err := crypto.EncryptValueRandom(pubKey, bit)
+			if err != nil {
+				return nil, nil, err
+			}
+			ballot.Cells[i][j], randomness[i][j] = ct, r
+			opening.Values = append(opening.Values, bit)
+			opening.Randomness = append(opening.Randomness, r)
+		}
+	}
+
+	prove := func(ct *crypto.Ciphertext, r *big.Int, bit int, label string) error {
+		proof, err := crypto.ProveMembership(pubKey, ct, r, binaryValues, bit, rankedProofContext(electionID, voterID, label))
+		if err != nil {
+			return err
+		}
+		ballot.Proofs = append(ballot.Proofs, proof)
+		return nil
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			bit := 0
+			if rankOf[i] == j {
+				bit = 1
+			}
+			if err := prove(ballot.Cells[i][j], randomness[i][j], bit, fmt.Sprintf("cell/%d/%d", i, j)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		ct, r := ballot.rowSum(i, randomness)
+		bit := 0
+		if rankOf[i] >= 0 {
+			bit = 1
+		}
+		if err := prove(ct, r, bit, fmt.Sprintf("row/%d", i)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for j := 0; j < n; j++ {
+		ct, r := ballot.columnSum(j, randomness)
+		bit := 0
+		if j < len(ranking) {
+			bit = 1
+		}
+		if err := prove(ct, r, bit, fmt.Sprintf("column/%d", j)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for j := 0; j+1 < n; j++ {
+		ct, r := ballot.columnStep(j, randomness)
+		bit := 0
+		if j == len(ranking)-1 {
+			bit = 1
+		}
+		if err := prove(ct, r, bit, fmt.Sprintf("step/%d", j)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ballot, opening, nil
+}
+
+// Verify checks the shape of the ballot and all of its proofs.
+func (rb *RankedBallot) Verify(pubKey *bn256.G1, electionID, voterID string, numCandidates int) bool {
+	n := numCandidates
+	if n < 1 || len(rb.Cells) != n || len(rb.Proofs) != n*n+3*n-1 {
+		return false
+	}
+	for _, row := range rb.Cells {
+		if len(row) != n {
+			return false
+		}
+		for _, cell := range row {
+			if cell == nil || cell.C1 == nil || cell.C2 == nil {
+				return false
+			}
+		}
+	}
+
+	proofs := rb.Proofs
+	verify := func(ct *crypto.Ciphertext, label string) bool {
+		proof := proofs[0]
+		proofs = proofs[1:]
+		return crypto.VerifyMembership(pubKey, ct, binaryValues, proof, rankedProofContext(electionID, voterID, label))
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if !verify(rb.Cells[i][j], fmt.Sprintf("cell/%d/%d", i, j)) {
+				return false
+			}
+		}
+	}
+	for i := 0; i < n; i++ {
+		if ct, _ := rb.rowSum(i, nil); !verify(ct, fmt.Sprintf("row/%d", i)) {
+			return false
+		}
+	}
+	for j := 0; j < n; j++ {
+		if ct, _ := rb.columnSum(j, nil); !verify(ct, fmt.Sprintf("column/%d", j)) {
+			return false
+		}
+	}
+	for j := 0; j+1 < n; j++ {
+		if ct, _ := rb.columnStep(j, nil); !verify(ct, fmt.Sprintf("step/%d", j)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decrypt recovers the ranking as candidate indices from most to least
+// preferred.
+func (rb *RankedBallot) Decrypt(privKey *big.Int) ([]int, error) {
+	n := len(rb.Cells)
+	ranking := make([]int, 0, n)
+	for j := 0; j < n; j++ {
+		holder := -1
+		for i := 0; i < n; i++ {
+			bit, err := crypto.DecryptValue(privKey, rb.Cells[i][j], 1)
+			if err != nil {
+				return nil, err
+			}
+			if bit == 1 {
+				holder = i
+			}
+		}
+		if holder < 0 {
+			break
+		}
+		ranking = append(ranking, holder)
+	}
+	return ranking, nil
+}
+
+// rowSum returns the homomorphic sum of row i and, when the cell randomness
+// is known, the randomness of the sum.
+func (rb *RankedBallot) rowSum(i int, randomness [][]*big.Int) (*crypto.Ciphertext, *big.Int) {
+	sum, r := crypto.ZeroCiphertext(), new(big.Int)
+	for j := range rb.Cells[i] {
+		sum = sum.Add(rb.Cells[i][j])
+		if randomness != nil {
+			r.Add(r, randomness[i][j])
+		}
+	}
+	return sum, r
+}
+
+// columnSum returns the homomorphic sum of column j and its randomness.
+func (rb *RankedBallot) columnSum(j int, randomness [][]*big.Int) (*crypto.Ciphertext, *big.Int) {
+	sum, r := crypto.ZeroCiphertext(), new(big.Int)
+	for i := range rb.Cells {
+		sum = sum.Add(rb.Cells[i][j])
+		if randomness != nil {
+			r.Add(r, randomness[i][j])
+		}
+	}
+	return sum, r
+}
+
+// columnStep returns column j minus column j+1 and its randomness.
+func (rb *RankedBallot) columnStep(j int, randomness [][]*big.Int) (*crypto.Ciphertext, *big.Int) {
+	upper, ru := rb.columnSum(j, randomness)
+	lower, rl := rb.columnSum(j+1, randomness)
+	r := new(big.Int).Sub(ru, rl)
+	return upper.Sub(lower), r.Mod(r, bn256.Order)
+}
+
+func rankedProofContext(electionID, voterID, label string) []byte {
+	return []byte(fmt.Sprintf("ranked/%s/%s/%s", electionID, voterID, label))
+}
@@ -0,0 +1,108 @@
+// pkg/election/ring.go
+package election
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// HasRing reports whether the election admits voters by linkable ring
+// signatures over its voter ring, see SignBallotWithRing.
+func (e *Election) HasRing() bool {
+	return len(e.VoterRing) > 0
+}
+
+// RingKeys decodes the voter ring.
+func (e *Election) RingKeys() ([]*bn256.G1, error) {
+	return crypto.UnmarshalPoints(e.VoterRing)
+}
+
+// RingVoterID returns the pseudonymous voter ID ballots signed with the ring
+// secret use. It is derived from the secret's key image, so a voter has the
+// same ID on every ballot of the election and can only vote as that voter.
+func (e *Election) RingVoterID(secret *big.Int) string {
+	return ringVoterID(crypto.Nullifier(secret, e.nullifierBase()).Marshal())
+}
+
+func ringVoterID(image []byte) string {
+	hash := sha256.Sum256(image)
+	return "ring-" + hex.EncodeToString(hash[:16])
+}
+
+// SignBallotWithRing signs ballot with a linkable ring signature over the
+// voter ring, revealing only that the signer holds one of its keys. The
+// ballot must be cast under RingVoterID(secret).
+func (e *Election) SignBallotWithRing(ballot *Ballot, secret *big.Int) error {
+	digest, err := ballotDigest(e.ID, ballot)
+	if err != nil {
+		return err
+	}
+	credential, err := e.signDigestWithRing(secret, ballot.VoterID, digest)
+	if err != nil {
+		return err
+	}
+	ballot.Credential = credential
+	return nil
+}
+
+// signDigestWithRing signs digest on behalf of voterID with a linkable ring
+// signature, see SignBallotWithRing.
+func (e *Election) signDigestWithRing(secret *big.Int, voterID string, digest []byte) (*BallotCredential, error) {
+	ring, err := e.RingKeys()
+	if err != nil {
+		return nil, err
+	}
+	key := new(bn256.G1).ScalarBaseMult(secret).String()
+	index := -1
+	for i, member := range ring {
+		if member.String() == key {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("key is not in the voter ring")
+	}
+	if voterID != e.RingVoterID(secret) {
+		return nil, fmt.Errorf("the key signs only as voter %s", e.RingVoterID(secret))
+	}
+
+	base := e.nullifierBase()
+	sig, err := crypto.RingSign(ring, index, secret, base, digest)
+	if err != nil {
+		return nil, err
+	}
+	return &BallotCredential{
+		Nullifier: crypto.Nullifier(secret, base).Marshal(),
+		Ring:      sig,
+	}, nil
+}
+
+// verifyRingCredential checks the ring signature of credential on digest
+// and that voterID is derived from the key image.
+func (e *Election) verifyRingCredential(voterID string, credential *BallotCredential, digest []byte) error {
+	if credential.Ring == nil {
+		return errors.New("not signed with a ring signature")
+	}
+	if voterID != ringVoterID(credential.Nullifier) {
+		return fmt.Errorf("voter %s does not match the key image", voterID)
+	}
+	ring, err := e.RingKeys()
+	if err != nil {
+		return fmt.Errorf("malformed voter ring: %v", err)
+	}
+	image, err := crypto.UnmarshalPoint(credential.Nullifier)
+	if err != nil {
+		return fmt.Errorf("malformed key image: %v", err)
+	}
+	if !crypto.VerifyRing(ring, e.nullifierBase(), image, credential.Ring, digest) {
+		return errors.New("ring signature does not verify")
+	}
+	return nil
+}
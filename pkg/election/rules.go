@@ -0,0 +1,42 @@
+// pkg/election/rules.go
+package election
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// RuleConfig selects the rule module that validates ballots and tallies of an
+// election, together with its module specific parameters and an optional
+// tally script deciding the outcome from the aggregate results.
+type RuleConfig struct {
+	Module string          `json:"module"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Script string          `json:"script,omitempty"`
+}
+
+// Hash commits to the module ID, its parameters and the tally script.
+// Parameters are compacted first so that formatting differences do not
+// change the commitment.
+func (r RuleConfig) Hash() []byte {
+	var params bytes.Buffer
+	if len(r.Params) > 0 {
+		if err := json.Compact(&params, r.Params); err != nil {
+			params.Reset()
+			params.Write(r.Params)
+		}
+	}
+
+	data, _ := json.Marshal(struct {
+		Module string
+		Params []byte
+		Script string
+	}{
+		Module: r.Module,
+		Params: params.Bytes(),
+		Script: r.Script,
+	})
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
@@ -0,0 +1,254 @@
+// pkg/election/score.go
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+const (
+	maxScoreLimit      = 100     // Highest MaxScore an election may set
+	maxSelectionValues = 1000    // Largest range a selection bound proof may cover
+	maxTotalWeight     = 1 << 20 // Keeps weighted tallies small enough to decrypt
+)
+
+// ScoreBallot holds one encrypted value per candidate: 0 or 1 on plurality
+// and approval ballots, 0 to MaxScore on score ballots, multiplied by the
+// voter's weight in weighted elections. Proofs show that every cell is in
+// range, and TotalProof that the ballot's total respects the election's
+// selection bounds, which on plurality ballots means that exactly one cell
+// is 1; both are checked against the registry weight, so a ballot
+// cannot claim more weight than its voter holds. Since cells are exponential
+// ElGamal ciphertexts, summing them per candidate across ballots yields the
+// encrypted weighted tally, see TallyScores.
+type ScoreBallot struct {
+	Cells      []*crypto.Ciphertext      `json:"cells"`
+	Proofs     []*crypto.MembershipProof `json:"proofs"`
+	TotalProof *crypto.MembershipProof   `json:"total_proof,omitempty"`
+}
+
+// MaxCellValue returns the highest value a ballot may give a single
+// candidate.
+func (e *Election) MaxCellValue() int {
+	if e.BallotType == BallotScore {
+		return e.MaxScore
+	}
+	return 1
+}
+
+// SelectionBounds returns the smallest and largest total a ballot may have:
+// the number of approved candidates on approval ballots, the sum of scores on
+// score ballots, exactly one vote on plurality ballots and at most one
+// otherwise.
+func (e *Election) SelectionBounds() (int, int) {
+	switch e.ballotType() {
+	case BallotApproval, BallotScore:
+		max := e.MaxSelections
+		if max == 0 {
+			max = len(e.Candidates) * e.MaxCellValue()
+		}
+		return e.MinSelections, max
+	case BallotPlurality:
+		return 1, 1
+	}
+	return 0, 1
+}
+
+// IsWeighted reports whether the election has a voter weight registry.
+func (e *Election) IsWeighted() bool {
+	return len(e.VoterWeights) > 0
+}
+
+// WeightOf returns the weight of voterID's ballot: 1 in unweighted elections,
+// the registry weight otherwise, and 0 for voters missing from the registry.
+func (e *Election) WeightOf(voterID string) int {
+	if !e.IsWeighted() {
+		return 1
+	}
+	return e.VoterWeights[voterID]
+}
+
+// ValidateWeights checks the voter weight registry. Weights scale the cells
+// of plurality, approval and score ballots, but ranked ballots are tabulated
+// one ranking each, so they cannot be weighted. The total weight is bounded
+// so that the weighted tallies can be decrypted.
+func (e *Election) ValidateWeights() error {
+	if !e.IsWeighted() {
+		return nil
+	}
+	if e.BallotType == BallotRanked {
+		return fmt.Errorf("%s ballots cannot be weighted", e.BallotType)
+	}
+	total := 0
+	for voterID, weight := range e.VoterWeights {
+		if weight < 1 || weight > maxTotalWeight {
+			return fmt.Errorf("weight of voter %s must be between 1 and %d", voterID, maxTotalWeight)
+		}
+		total += weight
+	}
+	if total > maxTotalWeight {
+		return fmt.Errorf("total voter weight %d exceeds %d", total, maxTotalWeight)
+	}
+	return nil
+}
+
+// hasSelectionBounds reports whether the election bounds the ballot total.
+func (e *Election) hasSelectionBounds() bool {
+	return e.MinSelections > 0 || e.MaxSelections > 0
+}
+
+// provesTotal reports whether ballots must prove their total: plurality
+// ballots always do, since they select exactly one candidate.
+func (e *Election) provesTotal() bool {
+	return e.ballotType() == BallotPlurality || e.hasSelectionBounds()
+}
+
+// ValidateScoring checks the score and selection settings of an election
+// against its ballot type.
+func (e *Election) ValidateScoring() error {
+	switch e.BallotType {
+	case BallotScore:
+		if e.MaxScore < 1 || e.MaxScore > maxScoreLimit {
+			return fmt.Errorf("max score must be between 1 and %d", maxScoreLimit)
+		}
+	case BallotApproval:
+		if e.MaxScore != 0 {
+			return errors.New("approval elections do not take a max score")
+		}
+	default:
+		if e.MaxScore != 0 || e.hasSelectionBounds() {
+			return fmt.Errorf("%s elections do not take scores or selection bounds", e.BallotType)
+		}
+		return nil
+	}
+
+	min, max := e.SelectionBounds()
+	if min < 0 || max < min || max > len(e.Candidates)*e.MaxCellValue() {
+		return fmt.Errorf("invalid selection bounds %d to %d", min, max)
+	}
+	if e.hasSelectionBounds() && max-min+1 > maxSelectionValues {
+		return fmt.Errorf("selection bounds may cover at most %d values", maxSelectionValues)
+	}
+	return nil
+}
+
+// NewScoreBallot encrypts one value per candidate for a plurality, approval
+// or score election, weighted by the voter's registry weight. The proofs are bound to
+// the election and voter IDs.
+func NewScoreBallot(e *Election, voterID string, values []int) (*ScoreBallot, error) {
+	ballot, _, err := NewScoreBallotWithOpening(e, voterID, values)
+	return ballot, err
+}
+
+// NewScoreBallotWithOpening is NewScoreBallot, also returning the opening of
+// the cells for the voter to challenge the ballot, see AuditBallot.
+func NewScoreBallotWithOpening(e *Election, voterID string, values []int) (*ScoreBallot, *BallotOpening, error) {
+	if len(values) != len(e.Candidates) {
+		return nil, nil, fmt.Errorf("expected %d values, got %d", len(e.Candidates), len(values))
+	}
+	weight := e.WeightOf(voterID)
+	if weight < 1 {
+		return nil, nil, fmt.Errorf("voter %s is not in the voter registry", voterID)
+	}
+	cellValues := rangeValues(0, e.MaxCellValue(), weight)
+
+	ballot, opening := &ScoreBallot{}, &BallotOpening{}
+	total, totalR, sum := crypto.ZeroCiphertext(), new(big.Int), 0
+	for i, v := range values {
+		if v < 0 || v > e.MaxCellValue() {
+			return nil, nil, fmt.Errorf("value %d for candidate %d out of range", v, i)
+		}
+		ct, r, err := crypto.EncryptValueRandom(e.PublicKey, int64(v*weight))
+		if err != nil {
+			return nil, nil, err
+		}
+		proof, err := crypto.ProveMembership(e.PublicKey, ct, r, cellValues, v, scoreProofContext(e.ID, voterID, fmt.Sprintf("cell/%d", i)))
+		if err != nil {
+			return nil, nil, err
+		}
+		ballot.Cells = append(ballot.Cells, ct)
+		ballot.Proofs = append(ballot.Proofs, proof)
+		opening.Values = append(opening.Values, int64(v*weight))
+		opening.Randomness = append(opening.Randomness, r)
+		total, sum = total.Add(ct), sum+v
+		totalR.Add(totalR, r)
+	}
+
+	min, max := e.SelectionBounds()
+	if sum < min || sum > max {
+		return nil, nil, fmt.Errorf("ballot total %d is outside the allowed range %d to %d", sum, min, max)
+	}
+	if e.provesTotal() {
+		proof, err := crypto.ProveMembership(e.PublicKey, total, totalR, rangeValues(min, max, weight), sum-min, scoreProofContext(e.ID, voterID, "total"))
+		if err != nil {
+			return nil, nil, err
+		}
+		ballot.TotalProof = proof
+	}
+	return ballot, opening, nil
+}
+
+// Verify checks the shape of the ballot and all of its proofs.
+func (sb *ScoreBallot) Verify(e *Election, voterID string) bool {
+	if len(sb.Cells) != len(e.Candidates) || len(sb.Proofs) != len(sb.Cells) {
+		return false
+	}
+	if (sb.TotalProof != nil) != e.provesTotal() {
+		return false
+	}
+	weight := e.WeightOf(voterID)
+	if weight < 1 {
+		return false
+	}
+
+	cellValues := rangeValues(0, e.MaxCellValue(), weight)
+	total := crypto.ZeroCiphertext()
+	for i, cell := range sb.Cells {
+		if cell == nil || cell.C1 == nil || cell.C2 == nil {
+			return false
+		}
+		if !crypto.VerifyMembership(e.PublicKey, cell, cellValues, sb.Proofs[i], scoreProofContext(e.ID, voterID, fmt.Sprintf("cell/%d", i))) {
+			return false
+		}
+		total = total.Add(cell)
+	}
+
+	if sb.TotalProof != nil {
+		min, max := e.SelectionBounds()
+		return crypto.VerifyMembership(e.PublicKey, total, rangeValues(min, max, weight), sb.TotalProof, scoreProofContext(e.ID, voterID, "total"))
+	}
+	return true
+}
+
+// TallyScores adds up the cells of ballots per candidate, giving each
+// candidate's encrypted total.
+func TallyScores(ballots []*ScoreBallot, numCandidates int) []*crypto.Ciphertext {
+	totals := make([]*crypto.Ciphertext, numCandidates)
+	for i := range totals {
+		totals[i] = crypto.ZeroCiphertext()
+	}
+	for _, ballot := range ballots {
+		for i, cell := range ballot.Cells {
+			if i < numCandidates {
+				totals[i] = totals[i].Add(cell)
+			}
+		}
+	}
+	return totals
+}
+
+// rangeValues returns min to max, each multiplied by weight.
+func rangeValues(min, max, weight int) []int64 {
+	values := make([]int64, 0, max-min+1)
+	for v := min; v <= max; v++ {
+		values = append(values, int64(v)*int64(weight))
+	}
+	return values
+}
+
+func scoreProofContext(electionID, voterID, label string) []byte {
+	return []byte(fmt.Sprintf("score/%s/%s/%s", electionID, voterID, label))
+}
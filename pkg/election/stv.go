@@ -0,0 +1,265 @@
+// pkg/election/stv.go
+package election
+
+import (
+	"fmt"
+	"sort"
+)
+
+// STVScale is the fixed-point precision of STV vote values: a ballot is worth
+// STVScale units and transfer values are truncated to whole units, so every
+// observer replaying a count gets identical arithmetic.
+const STVScale = 100000
+
+// STVAction is the kind of step recorded in an STV transfer log.
+type STVAction string
+
+const (
+	STVElect    STVAction = "elect"   // A candidate is elected
+	STVSurplus  STVAction = "surplus" // An elected candidate's surplus is transferred
+	STVExclude  STVAction = "exclude" // The weakest hopeful candidate is excluded
+	STVFillSeat STVAction = "fill"    // Remaining hopefuls are elected to the open seats
+)
+
+// STVStage is one step of an STV count. Votes and Exhausted are the totals
+// before the step, in STVScale units; Transfers, ToExhausted and Lost say
+// where the value moved by the step went, so Votes of the next stage can be
+// recomputed from this one.
+type STVStage struct {
+	Stage         int              `json:"stage"`
+	Action        STVAction        `json:"action"`
+	Candidate     string           `json:"candidate"`
+	Votes         map[string]int64 `json:"votes"` // Totals of elected and hopeful candidates
+	Exhausted     int64            `json:"exhausted"`
+	TransferValue int64            `json:"transfer_value,omitempty"` // Surplus value per ballot, in STVScale units
+	Transfers     map[string]int64 `json:"transfers,omitempty"`
+	ToExhausted   int64            `json:"to_exhausted,omitempty"`
+	Lost          int64            `json:"lost,omitempty"` // Value lost to truncation
+	TiedWith      []string         `json:"tied_with,omitempty"`
+	TieBreak      TieBreak         `json:"tie_break,omitempty"`
+}
+
+// STVReport is the result of an STV count and its full transfer log.
+type STVReport struct {
+	Seats   int        `json:"seats"`
+	Ballots int        `json:"ballots"`
+	Quota   int64      `json:"quota"` // Droop quota in STVScale units
+	Elected []string   `json:"elected"`
+	Stages  []STVStage `json:"stages"`
+}
+
+// stvParcel is a ballot in a candidate's pile at its current value.
+type stvParcel struct {
+	ballot int
+	value  int64
+}
+
+type stvStatus int
+
+const (
+	stvHopeful stvStatus = iota
+	stvElected
+	stvExcluded
+)
+
+// CountSTV fills seats by single transferable vote over rankings, each a list
+// of candidate indices from most to least preferred. It uses the Droop quota
+// and the weighted inclusive Gregory method: an elected candidate's surplus
+// is passed on by moving every ballot in their pile to its next hopeful
+// preference at a reduced value. Candidates are elected as soon as they reach
+// the quota; otherwise the hopeful candidate with the fewest votes is
+// excluded, ties being resolved by tieBreak with seed as the lot input.
+func CountSTV(candidates []Candidate, rankings [][]int, seats int, tieBreak TieBreak, seed string) (*STVReport, error) {
+	if tieBreak == "" {
+		tieBreak = DefaultTieBreak
+	}
+	switch tieBreak {
+	case TieBreakPreviousRounds, TieBreakLot, TieBreakCandidateOrder:
+	default:
+		return nil, fmt.Errorf("unknown tie-break rule %q", tieBreak)
+	}
+	if seats < 1 || seats > len(candidates) {
+		return nil, fmt.Errorf("cannot fill %d seats with %d candidates", seats, len(candidates))
+	}
+
+	c := &stvCount{
+		candidates: candidates,
+		rankings:   rankings,
+		status:     make([]stvStatus, len(candidates)),
+		totals:     make([]int64, len(candidates)),
+		piles:      make([][]stvParcel, len(candidates)),
+	}
+	report := &STVReport{Seats: seats, Ballots: len(rankings), Elected: []string{}}
+
+	valid := 0
+	for b := range rankings {
+		if c.place(stvParcel{ballot: b, value: STVScale}) >= 0 {
+			valid++
+		}
+	}
+	if valid == 0 {
+		return report, nil
+	}
+	report.Quota = int64(valid/(seats+1)+1) * STVScale
+
+	var surpluses []int // Elected candidates whose surplus is not yet transferred
+	var history [][]int64
+	for len(report.Elected) < seats {
+		history = append(history, append([]int64(nil), c.totals...))
+
+		// Elect every hopeful at or above the quota, highest total first
+		var reached []int
+		for i := range candidates {
+			if c.status[i] == stvHopeful && c.totals[i] >= report.Quota {
+				reached = append(reached, i)
+			}
+		}
+		sort.SliceStable(reached, func(a, b int) bool { return c.totals[reached[a]] > c.totals[reached[b]] })
+		for _, i := range reached {
+			if len(report.Elected) == seats {
+				break
+			}
+			report.Stages = append(report.Stages, c.stage(len(report.Stages)+1, STVElect, i))
+			c.status[i] = stvElected
+			report.Elected = append(report.Elected, candidates[i].Name)
+			if c.totals[i] > report.Quota {
+				surpluses = append(surpluses, i)
+			}
+		}
+		if len(report.Elected) == seats {
+			break
+		}
+
+		// Fill the open seats once there are no more hopefuls than seats
+		var hopeful []int
+		for i := range candidates {
+			if c.status[i] == stvHopeful {
+				hopeful = append(hopeful, i)
+			}
+		}
+		if len(hopeful) <= seats-len(report.Elected) {
+			sort.SliceStable(hopeful, func(a, b int) bool { return c.totals[hopeful[a]] > c.totals[hopeful[b]] })
+			for _, i := range hopeful {
+				report.Stages = append(report.Stages, c.stage(len(report.Stages)+1, STVFillSeat, i))
+				c.status[i] = stvElected
+				report.Elected = append(report.Elected, candidates[i].Name)
+			}
+			break
+		}
+
+		if len(surpluses) > 0 {
+			// Transfer the largest surplus, earliest elected first on ties
+			next := 0
+			for k, i := range surpluses {
+				if c.totals[i] > c.totals[surpluses[next]] {
+					next = k
+				}
+			}
+			i := surpluses[next]
+			surpluses = append(surpluses[:next], surpluses[next+1:]...)
+
+			stage := c.stage(len(report.Stages)+1, STVSurplus, i)
+			surplus := c.totals[i] - report.Quota
+			stage.TransferValue = surplus * STVScale / c.totals[i]
+			c.transfer(&stage, i, stage.TransferValue)
+			stage.Lost = surplus - stage.ToExhausted
+			for _, v := range stage.Transfers {
+				stage.Lost -= v
+			}
+			c.totals[i] = report.Quota
+			report.Stages = append(report.Stages, stage)
+			continue
+		}
+
+		// Exclude the hopeful with the fewest votes
+		var lowest []int
+		for _, i := range hopeful {
+			switch {
+			case len(lowest) == 0 || c.totals[i] < c.totals[lowest[0]]:
+				lowest = []int{i}
+			case c.totals[i] == c.totals[lowest[0]]:
+				lowest = append(lowest, i)
+			}
+		}
+		excluded := lowest[0]
+		var rule TieBreak
+		if len(lowest) > 1 {
+			excluded, rule = breakTie(candidates, lowest, history, tieBreak, seed, len(report.Stages)+1)
+		}
+		stage := c.stage(len(report.Stages)+1, STVExclude, excluded)
+		if rule != "" {
+			stage.TieBreak = rule
+			for _, i := range lowest {
+				if i != excluded {
+					stage.TiedWith = append(stage.TiedWith, candidates[i].Name)
+				}
+			}
+		}
+		c.status[excluded] = stvExcluded
+		c.transfer(&stage, excluded, STVScale)
+		c.totals[excluded] = 0
+		report.Stages = append(report.Stages, stage)
+	}
+	return report, nil
+}
+
+// stvCount is the working state of CountSTV.
+type stvCount struct {
+	candidates []Candidate
+	rankings   [][]int
+	status     []stvStatus
+	totals     []int64
+	piles      [][]stvParcel
+	exhausted  int64
+}
+
+// place adds p to the pile of the ballot's highest ranked hopeful candidate
+// and returns that candidate, or -1 if the ballot is exhausted.
+func (c *stvCount) place(p stvParcel) int {
+	for _, i := range c.rankings[p.ballot] {
+		if c.status[i] == stvHopeful {
+			c.piles[i] = append(c.piles[i], p)
+			c.totals[i] += p.value
+			return i
+		}
+	}
+	c.exhausted += p.value
+	return -1
+}
+
+// transfer moves every ballot in the pile of from to its next hopeful
+// preference, scaling its value by ratio/STVScale, and records where the
+// value went in stage.
+func (c *stvCount) transfer(stage *STVStage, from int, ratio int64) {
+	stage.Transfers = make(map[string]int64)
+	pile := c.piles[from]
+	c.piles[from] = nil
+	for _, p := range pile {
+		p.value = p.value * ratio / STVScale
+		if p.value == 0 {
+			continue
+		}
+		if i := c.place(p); i >= 0 {
+			stage.Transfers[c.candidates[i].Name] += p.value
+		} else {
+			stage.ToExhausted += p.value
+		}
+	}
+}
+
+// stage snapshots the current totals for a log entry about candidate i.
+func (c *stvCount) stage(number int, action STVAction, i int) STVStage {
+	stage := STVStage{
+		Stage:     number,
+		Action:    action,
+		Candidate: c.candidates[i].Name,
+		Votes:     make(map[string]int64),
+		Exhausted: c.exhausted,
+	}
+	for j, candidate := range c.candidates {
+		if c.status[j] != stvExcluded {
+			stage.Votes[candidate.Name] = c.totals[j]
+		}
+	}
+	return stage
+}
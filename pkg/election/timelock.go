@@ -0,0 +1,76 @@
+// pkg/election/timelock.go
+package election
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// TimeLockConfig holds an election's private key encrypted to the beacon
+// round at its EndTime, so that once its creator discards the key no one,
+// trustees included, can decrypt ballots or partial tallies while voting is
+// open. The lock proves that it opens to the election's key, so nodes reject
+// elections whose tally could never be decrypted.
+type TimeLockConfig struct {
+	BeaconKey []byte            `json:"beacon_key"` // BLS key of the randomness beacon
+	Round     uint64            `json:"round"`      // See crypto.BeaconRound
+	LockedKey *crypto.LockedKey `json:"locked_key"`
+}
+
+// LockKey time-locks privKey, the private key of the election, to the
+// beacon round at EndTime. The caller must discard privKey afterwards: the
+// lock cannot show that it did.
+func (e *Election) LockKey(privKey *big.Int, beaconKey *bn256.G2) error {
+	if e.PublicKey == nil || !bytes.Equal(new(bn256.G1).ScalarBaseMult(privKey).Marshal(), e.PublicKey.Marshal()) {
+		return errors.New("private key does not match the election public key")
+	}
+	round := crypto.BeaconRound(e.EndTime)
+	locked, err := crypto.LockKey(beaconKey, round, privKey, e.PublicKey)
+	if err != nil {
+		return err
+	}
+	e.TimeLock = &TimeLockConfig{BeaconKey: crypto.MarshalG2(beaconKey), Round: round, LockedKey: locked}
+	return nil
+}
+
+// ValidateTimeLock checks that a time-locked key opens no earlier than the
+// election closes, and that it is the election's private key.
+func (e *Election) ValidateTimeLock() error {
+	t := e.TimeLock
+	if t == nil {
+		return nil
+	}
+	beaconKey, err := crypto.UnmarshalG2(t.BeaconKey)
+	if err != nil {
+		return fmt.Errorf("beacon key: %v", err)
+	}
+	if t.Round != crypto.BeaconRound(e.EndTime) {
+		return fmt.Errorf("key must be locked to round %d, when voting closes", crypto.BeaconRound(e.EndTime))
+	}
+	if e.PublicKey == nil || !t.LockedKey.Verify(beaconKey, t.Round, e.PublicKey) {
+		return errors.New("time-locked key is not the election's private key")
+	}
+	return nil
+}
+
+// UnlockKey checks the beacon's signature on the election's round and
+// recovers the private key of the election with it.
+func (e *Election) UnlockKey(beaconSig *bn256.G1) (*big.Int, error) {
+	t := e.TimeLock
+	if t == nil {
+		return nil, errors.New("election key is not time-locked")
+	}
+	beaconKey, err := crypto.UnmarshalG2(t.BeaconKey)
+	if err != nil {
+		return nil, fmt.Errorf("beacon key: %v", err)
+	}
+	if !crypto.BLSVerify(beaconKey, crypto.BeaconMessage(t.Round), beaconSig) {
+		return nil, fmt.Errorf("invalid beacon signature for round %d", t.Round)
+	}
+	return t.LockedKey.Unlock(beaconSig, e.PublicKey)
+}
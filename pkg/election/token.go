@@ -0,0 +1,125 @@
+// pkg/election/token.go
+package election
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// VotingToken is an anonymous voter credential issued by the registrar of an
+// election: a credential key and the registrar's signature on it, obtained
+// blindly so the registrar cannot tell which voter holds which key. Ballots
+// cast with the token are signed with its secret, see SignBallotWithToken.
+type VotingToken struct {
+	ElectionID string   `json:"election_id"`
+	Secret     *big.Int `json:"secret"`
+	Key        []byte   `json:"key"`
+	Signature  []byte   `json:"signature"` // Registrar's BLS signature on TokenMessage
+}
+
+// TokenRequest is a voter's pending request for a token: the credential
+// secret and the blinding factor of the message sent to the registrar.
+type TokenRequest struct {
+	Secret  *big.Int
+	Key     *bn256.G1
+	Blinded *bn256.G1 // Sent to the registrar for its blind signature
+	factor  *big.Int
+}
+
+// HasRegistrar reports whether the election admits voters by tokens from a
+// registrar, see RegistrarKey.
+func (e *Election) HasRegistrar() bool {
+	return len(e.RegistrarKey) > 0
+}
+
+// NewTokenRequest creates a credential key and blinds it for the registrar.
+func (e *Election) NewTokenRequest() (*TokenRequest, error) {
+	credential, err := crypto.GenerateKeys()
+	if err != nil {
+		return nil, err
+	}
+	blinded, factor, err := crypto.BlindMessage(TokenMessage(e.ID, credential.PublicKey.Marshal()))
+	if err != nil {
+		return nil, err
+	}
+	return &TokenRequest{Secret: credential.PrivateKey, Key: credential.PublicKey, Blinded: blinded, factor: factor}, nil
+}
+
+// Finish unblinds the registrar's signature and checks it against the
+// election's registrar key.
+func (r *TokenRequest) Finish(e *Election, blindSig *bn256.G1) (*VotingToken, error) {
+	token := &VotingToken{
+		ElectionID: e.ID,
+		Secret:     r.Secret,
+		Key:        r.Key.Marshal(),
+		Signature:  crypto.Unblind(blindSig, r.factor).Marshal(),
+	}
+	if _, err := e.VerifyToken(token.VoterID(), token.Key, token.Signature); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// VoterID returns the pseudonymous voter ID ballots cast with the token use.
+func (t *VotingToken) VoterID() string {
+	return TokenVoterID(t.Key)
+}
+
+// TokenVoterID derives the voter ID of a token from its credential key.
+func TokenVoterID(key []byte) string {
+	hash := sha256.Sum256(key)
+	return "token-" + hex.EncodeToString(hash[:16])
+}
+
+// TokenMessage is the message the registrar signs for a credential key.
+func TokenMessage(electionID string, key []byte) []byte {
+	return append([]byte(fmt.Sprintf("voting-token/%s/", electionID)), key...)
+}
+
+// VerifyToken checks that key carries the registrar's signature for the
+// election and belongs to voterID, returning the credential key.
+func (e *Election) VerifyToken(voterID string, key, signature []byte) (*bn256.G1, error) {
+	registrar, err := crypto.UnmarshalG2(e.RegistrarKey)
+	if err != nil {
+		return nil, fmt.Errorf("malformed registrar key: %v", err)
+	}
+	if voterID != TokenVoterID(key) {
+		return nil, fmt.Errorf("voter %s does not hold the token's key", voterID)
+	}
+	point, err := crypto.UnmarshalPoint(key)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token key: %v", err)
+	}
+	sig, err := crypto.UnmarshalPoint(signature)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %v", err)
+	}
+	if !crypto.BLSVerify(registrar, TokenMessage(e.ID, key), sig) {
+		return nil, errors.New("token is not signed by the election's registrar")
+	}
+	return point, nil
+}
+
+// SignBallotWithToken signs ballot with the token's secret, like SignBallot,
+// and attaches the token so nodes can check it was issued by the registrar.
+// The ballot must be cast under the token's voter ID.
+func (e *Election) SignBallotWithToken(ballot *Ballot, token *VotingToken) error {
+	if token.ElectionID != e.ID {
+		return fmt.Errorf("token was issued for election %s", token.ElectionID)
+	}
+	if ballot.VoterID != token.VoterID() {
+		return fmt.Errorf("ballots cast with the token must use voter ID %s", token.VoterID())
+	}
+	if err := e.SignBallot(ballot, token.Secret); err != nil {
+		return err
+	}
+	ballot.Credential.Key = token.Key
+	ballot.Credential.Signature = token.Signature
+	return nil
+}
@@ -0,0 +1,226 @@
+// pkg/election/writein.go
+package election
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+const (
+	// WriteInCandidateID is the ID of the candidate voters select to write in
+	// a name, see Election.WriteIn.
+	WriteInCandidateID = "write-in"
+
+	// MaxWriteInLength is the longest write-in name, in bytes.
+	MaxWriteInLength = 64
+
+	// writeInSealedSize is the size of every sealed write-in: a length byte,
+	// the name padded to MaxWriteInLength and the AES-GCM tag.
+	writeInSealedSize = 1 + MaxWriteInLength + 16
+)
+
+// WriteInCandidate returns the write-in option to list last among the
+// candidates of a write-in election.
+func WriteInCandidate() Candidate {
+	return Candidate{ID: WriteInCandidateID, Name: "Write-in"}
+}
+
+// WriteInIndex returns the ballot position of the write-in option, or -1.
+func (e *Election) WriteInIndex() int {
+	for i, candidate := range e.Candidates {
+		if candidate.ID == WriteInCandidateID {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateWriteIn checks that write-in elections list the write-in option
+// exactly once, and that other elections do not list it. Write-ins need a
+// ballot cell of their own, so only approval and score ballots take them.
+func (e *Election) ValidateWriteIn() error {
+	count := 0
+	for _, candidate := range e.Candidates {
+		if candidate.ID == WriteInCandidateID {
+			count++
+		}
+	}
+	if !e.WriteIn {
+		if count > 0 {
+			return errors.New("only write-in elections may list the write-in option")
+		}
+		return nil
+	}
+	if e.BallotType != BallotApproval && e.BallotType != BallotScore {
+		return fmt.Errorf("%s ballots do not take write-ins", e.BallotType)
+	}
+	if count != 1 {
+		return errors.New("write-in elections must list the write-in option exactly once")
+	}
+	return nil
+}
+
+// SealWriteIn encrypts the name voterID writes in to the election key. Every
+// ballot of a write-in election carries a sealed write-in of the same size,
+// with an empty name when the voter wrote nothing, so neither its presence
+// nor its length reveals the voter's choice.
+func (e *Election) SealWriteIn(voterID, name string) (*crypto.SealedText, error) {
+	if len(name) > MaxWriteInLength {
+		return nil, fmt.Errorf("write-in names are limited to %d bytes", MaxWriteInLength)
+	}
+	if !utf8.ValidString(name) {
+		return nil, errors.New("write-in name is not valid UTF-8")
+	}
+	padded := make([]byte, 1+MaxWriteInLength)
+	padded[0] = byte(len(name))
+	copy(padded[1:], name)
+	return crypto.SealText(e.PublicKey, padded, writeInContext(e.ID, voterID))
+}
+
+// CheckSealedWriteIn checks the shape of a sealed write-in; its contents stay
+// hidden until the tally.
+func (e *Election) CheckSealedWriteIn(sealed *crypto.SealedText) error {
+	if sealed == nil || sealed.Ephemeral == nil {
+		return errors.New("write-in elections require a sealed write-in on every ballot")
+	}
+	if len(sealed.Ciphertext) != writeInSealedSize {
+		return fmt.Errorf("sealed write-ins must be %d bytes", writeInSealedSize)
+	}
+	return nil
+}
+
+// OpenWriteIn decrypts the write-in of voterID's ballot.
+func (e *Election) OpenWriteIn(privKey *big.Int, voterID string, sealed *crypto.SealedText) (string, error) {
+	padded, err := sealed.Open(privKey, writeInContext(e.ID, voterID))
+	if err != nil {
+		return "", err
+	}
+	if len(padded) != 1+MaxWriteInLength || int(padded[0]) > MaxWriteInLength {
+		return "", errors.New("malformed write-in")
+	}
+	return string(padded[1 : 1+int(padded[0])]), nil
+}
+
+// DecryptWriteIns opens the write-ins of the ballots that selected the
+// write-in option, leaving all others sealed. It returns the weight written
+// in per normalized name, see NormalizeWriteIn.
+func (e *Election) DecryptWriteIns(privKey *big.Int, ballots []*Ballot) (map[string]int, error) {
+	index := e.WriteInIndex()
+	if !e.WriteIn || index < 0 {
+		return nil, errors.New("election does not take write-ins")
+	}
+
+	names := make(map[string]int)
+	for _, ballot := range ballots {
+		if ballot.Scores == nil || index >= len(ballot.Scores.Cells) {
+			return nil, fmt.Errorf("ballot of voter %s has no write-in cell", ballot.VoterID)
+		}
+		weight := e.WeightOf(ballot.VoterID)
+		selected, err := crypto.DecryptValue(privKey, ballot.Scores.Cells[index], int64(weight*e.MaxCellValue()))
+		if err != nil {
+			return nil, fmt.Errorf("write-in cell of voter %s: %v", ballot.VoterID, err)
+		}
+		if selected == 0 {
+			continue
+		}
+
+		name, err := e.OpenWriteIn(privKey, ballot.VoterID, ballot.WriteIn)
+		if err != nil {
+			return nil, fmt.Errorf("write-in of voter %s: %v", ballot.VoterID, err)
+		}
+		names[NormalizeWriteIn(name)] += int(selected)
+	}
+	return names, nil
+}
+
+// WriteInGroup is a set of write-in names taken to mean the same person.
+type WriteInGroup struct {
+	Name      string   `json:"name"`                // Spelling with the most weight, alphabetically first on ties
+	Count     int      `json:"count"`               // Weight written in for the group
+	Variants  []string `json:"variants,omitempty"`  // Other spellings, sorted
+	Candidate string   `json:"candidate,omitempty"` // Listed candidate the group matches, if any
+}
+
+// NormalizeWriteIn trims a write-in name and collapses its inner whitespace.
+func NormalizeWriteIn(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// GroupWriteIns groups write-in names that differ only in case, punctuation
+// or spacing, and flags groups naming a listed candidate. Groups are sorted
+// by count, most first, then by name. Blank names form no group.
+func (e *Election) GroupWriteIns(names map[string]int) []WriteInGroup {
+	candidates := make(map[string]string, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		if candidate.ID != WriteInCandidateID {
+			candidates[writeInKey(candidate.Name)] = candidate.Name
+		}
+	}
+
+	spellings := make(map[string]map[string]int)
+	for name, count := range names {
+		key := writeInKey(name)
+		if key == "" {
+			continue
+		}
+		if spellings[key] == nil {
+			spellings[key] = make(map[string]int)
+		}
+		spellings[key][NormalizeWriteIn(name)] += count
+	}
+
+	groups := make([]WriteInGroup, 0, len(spellings))
+	for key, variants := range spellings {
+		group := WriteInGroup{Candidate: candidates[key]}
+		for spelling, count := range variants {
+			group.Count += count
+			group.Variants = append(group.Variants, spelling)
+		}
+		sort.Slice(group.Variants, func(i, j int) bool {
+			a, b := group.Variants[i], group.Variants[j]
+			if variants[a] != variants[b] {
+				return variants[a] > variants[b]
+			}
+			return a < b
+		})
+		group.Name, group.Variants = group.Variants[0], group.Variants[1:]
+		if len(group.Variants) == 0 {
+			group.Variants = nil
+		}
+		sort.Strings(group.Variants)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// writeInKey reduces a name to lower case letters and digits separated by
+// single spaces, the form write-ins are grouped by.
+func writeInKey(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-':
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func writeInContext(electionID, voterID string) []byte {
+	return []byte(fmt.Sprintf("write-in/%s/%s", electionID, voterID))
+}
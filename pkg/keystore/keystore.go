@@ -0,0 +1,264 @@
+// pkg/keystore/keystore.go
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyKind tells which group a stored key's public key lives in.
+type KeyKind string
+
+const (
+	KindElGamal KeyKind = "elgamal" // Node identities and election keys, see crypto.KeyPair
+	KindBLS     KeyKind = "bls"     // Validator, registrar, beacon and official keys, see crypto.BLSKeyPair
+)
+
+var (
+	// ErrWrongPassphrase is returned when a key cannot be decrypted with the
+	// given passphrase.
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key file")
+
+	// ErrKeyExists is returned when storing a key under a name in use.
+	ErrKeyExists = errors.New("a key with this name already exists")
+
+	// ErrKeyNotFound is returned when loading a key that is not stored.
+	ErrKeyNotFound = errors.New("key not found")
+)
+
+// ScryptParams are the cost parameters of the key derivation.
+type ScryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var (
+	// StandardScrypt takes about a second and 256 MB of memory per key
+	StandardScrypt = ScryptParams{N: 1 << 18, R: 8, P: 1}
+
+	// LightScrypt is cheap enough for tests and constrained devices
+	LightScrypt = ScryptParams{N: 1 << 12, R: 8, P: 1}
+)
+
+// EncryptedKey is the JSON format of a stored private key: the key
+// encrypted with AES-256-GCM under a key derived from a passphrase with
+// scrypt. The kind and public key are authenticated with it, so a key file
+// cannot be relabelled.
+type EncryptedKey struct {
+	Version    int          `json:"version"`
+	Kind       KeyKind      `json:"kind"`
+	PublicKey  []byte       `json:"public_key"`
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdf_params"`
+	Salt       []byte       `json:"salt"`
+	Cipher     string       `json:"cipher"`
+	Nonce      []byte       `json:"nonce"`
+	Ciphertext []byte       `json:"ciphertext"`
+}
+
+// EncryptKey encrypts the private key priv of kind with passphrase.
+func EncryptKey(kind KeyKind, priv *big.Int, passphrase []byte, params ScryptParams) (*EncryptedKey, error) {
+	pub, err := publicKey(kind, priv)
+	if err != nil {
+		return nil, err
+	}
+	key := &EncryptedKey{
+		Version:   1,
+		Kind:      kind,
+		PublicKey: pub,
+		KDF:       "scrypt",
+		KDFParams: params,
+		Salt:      make([]byte, 32),
+		Cipher:    "aes-256-gcm",
+	}
+	if _, err := rand.Read(key.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := key.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	key.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(key.Nonce); err != nil {
+		return nil, err
+	}
+	key.Ciphertext = aead.Seal(nil, key.Nonce, priv.FillBytes(make([]byte, 32)), key.additionalData())
+	return key, nil
+}
+
+// Decrypt recovers the private key with passphrase.
+func (k *EncryptedKey) Decrypt(passphrase []byte) (*big.Int, error) {
+	if k.Version != 1 || k.KDF != "scrypt" || k.Cipher != "aes-256-gcm" {
+		return nil, fmt.Errorf("unsupported key format: version %d, %s, %s", k.Version, k.KDF, k.Cipher)
+	}
+	aead, err := k.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(k.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plain, err := aead.Open(nil, k.Nonce, k.Ciphertext, k.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	priv := new(big.Int).SetBytes(plain)
+	if pub, err := publicKey(k.Kind, priv); err != nil || !bytes.Equal(pub, k.PublicKey) {
+		return nil, errors.New("decrypted key does not match its public key")
+	}
+	return priv, nil
+}
+
+// ElGamalKeys decrypts an elgamal key.
+func (k *EncryptedKey) ElGamalKeys(passphrase []byte) (*crypto.KeyPair, error) {
+	if k.Kind != KindElGamal {
+		return nil, fmt.Errorf("expected an %s key, got %s", KindElGamal, k.Kind)
+	}
+	priv, err := k.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.KeyPair{PrivateKey: priv, PublicKey: new(bn256.G1).ScalarBaseMult(priv)}, nil
+}
+
+// BLSKeys decrypts a bls key.
+func (k *EncryptedKey) BLSKeys(passphrase []byte) (*crypto.BLSKeyPair, error) {
+	if k.Kind != KindBLS {
+		return nil, fmt.Errorf("expected a %s key, got %s", KindBLS, k.Kind)
+	}
+	priv, err := k.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &crypto.BLSKeyPair{PrivateKey: priv, PublicKey: new(bn256.G2).ScalarBaseMult(priv)}, nil
+}
+
+func (k *EncryptedKey) aead(passphrase []byte) (cipher.AEAD, error) {
+	derived, err := scrypt.Key(passphrase, k.Salt, k.KDFParams.N, k.KDFParams.R, k.KDFParams.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %v", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *EncryptedKey) additionalData() []byte {
+	return append([]byte(k.Kind+"/"), k.PublicKey...)
+}
+
+func publicKey(kind KeyKind, priv *big.Int) ([]byte, error) {
+	if priv == nil || priv.Sign() <= 0 || priv.Cmp(bn256.Order) >= 0 {
+		return nil, errors.New("private key out of range")
+	}
+	switch kind {
+	case KindElGamal:
+		return new(bn256.G1).ScalarBaseMult(priv).Marshal(), nil
+	case KindBLS:
+		return crypto.MarshalG2(new(bn256.G2).ScalarBaseMult(priv)), nil
+	}
+	return nil, fmt.Errorf("unknown key kind %q", kind)
+}
+
+// validName keeps key names usable as file names.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Keystore is a directory of encrypted keys, one JSON file per key name.
+type Keystore struct {
+	Dir string
+}
+
+func NewKeystore(dir string) *Keystore {
+	return &Keystore{Dir: dir}
+}
+
+// Store saves key under name, refusing to overwrite another key.
+func (ks *Keystore) Store(name string, key *EncryptedKey) error {
+	path, err := ks.path(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ks.Dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return ErrKeyExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the key stored under name.
+func (ks *Keystore) Load(name string) (*EncryptedKey, error) {
+	path, err := ks.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var key EncryptedKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("malformed key file %s: %v", path, err)
+	}
+	return &key, nil
+}
+
+// List returns the names of the stored keys, sorted.
+func (ks *Keystore) List() ([]string, error) {
+	entries, err := os.ReadDir(ks.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (ks *Keystore) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", fmt.Errorf("invalid key name %q", name)
+	}
+	return filepath.Join(ks.Dir, name+".json"), nil
+}
@@ -63,6 +63,20 @@ func (p *P2PNetwork) BroadcastTransaction(tx *blockchain.Transaction) {
 	}
 }
 
+// BroadcastCommit sends a validator's commit to a block to all peers.
+func (p *P2PNetwork) BroadcastCommit(commit *blockchain.Commit) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for peer := range p.KnownPeers {
+		go func(peerAddr string) {
+			commitData, _ := json.Marshal(commit)
+			http.Post(fmt.Sprintf("http://%s/commits", peerAddr),
+				"application/json", bytes.NewBuffer(commitData))
+		}(peer)
+	}
+}
+
 func (p *P2PNetwork) SyncWithPeer(peerAddr string) {
 	// Get peer's blockchain
 	resp, err := http.Get(fmt.Sprintf("http://%s/chain", peerAddr))
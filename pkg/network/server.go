@@ -5,9 +5,38 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
 	"net/http"
+	"strings"
 )
 
+// ElectionAudit is the response of GET /elections/{id}/audit.
+type ElectionAudit struct {
+	ElectionID string                  `json:"election_id"`
+	Cast       []string                `json:"cast"`       // Trackers of the counted ballots
+	Challenged []*election.BallotAudit `json:"challenged"` // Ballots opened instead of cast
+}
+
+// ElectionResults is the response of GET /elections/{id}/results, served
+// once a quorum of officials has certified the results.
+type ElectionResults struct {
+	ElectionID  string                  `json:"election_id"`
+	Results     map[string]int          `json:"results"`
+	Outcome     *smartcontracts.Outcome `json:"outcome"`
+	Digest      []byte                  `json:"digest"`      // Signed by the officials, see ElectionState.ResultsDigest
+	Certificate *crypto.MultiSignature  `json:"certificate"` // Aggregate signature of the officials listed in Signers
+	Signers     []string                `json:"signers"`
+}
+
+// Account is the response of GET /accounts/{sender}, telling a client the
+// nonce to sign its next transaction with.
+type Account struct {
+	Sender    string `json:"sender"`     // Hex-encoded public key, see Transaction.Sender
+	NextNonce uint64 `json:"next_nonce"` // Follows the sender's included and pending transactions
+}
+
 type Server struct {
 	Node   *blockchain.Node
 	Port   int
@@ -23,19 +52,35 @@ func NewServer(node *blockchain.Node, port int) *Server {
 	nodeAddr := fmt.Sprintf("localhost:%d", port)
 	server.P2PNet = NewP2PNetwork(nodeAddr, node)
 
+	// Validators share their commits to the blocks they create or accept
+	node.OnCommit = server.P2PNet.BroadcastCommit
+
 	return server
 }
 
-func (s *Server) Start() error {
+// Handler returns the HTTP handler serving the node API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
 	// Chain endpoints
-	http.HandleFunc("/chain", s.handleGetChain)
-	http.HandleFunc("/blocks", s.handleBlocks)
-	http.HandleFunc("/transactions", s.handleTransactions)
+	mux.HandleFunc("/chain", s.handleGetChain)
+	mux.HandleFunc("/blocks", s.handleBlocks)
+	mux.HandleFunc("/commits", s.handleCommits)
+	mux.HandleFunc("/transactions", s.handleTransactions)
+	mux.HandleFunc("/tx/", s.handleGetTransaction)
+	mux.HandleFunc("/accounts/", s.handleGetAccount)
+
+	// Election endpoints
+	mux.HandleFunc("/elections/", s.handleGetElection)
 
 	// P2P endpoints
-	http.HandleFunc("/peers", s.handlePeers)
-	http.HandleFunc("/addPeer", s.handleAddPeer)
+	mux.HandleFunc("/peers", s.handlePeers)
+	mux.HandleFunc("/addPeer", s.handleAddPeer)
 
+	return mux
+}
+
+func (s *Server) Start() error {
 	// Start P2P sync
 	s.P2PNet.StartSyncLoop()
 
@@ -43,7 +88,7 @@ func (s *Server) Start() error {
 	s.loadInitialPeers()
 
 	fmt.Printf("Server running on port %d\n", s.Port)
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.Port), nil)
+	return http.ListenAndServe(fmt.Sprintf(":%d", s.Port), s.Handler())
 }
 
 func (s *Server) handleGetChain(w http.ResponseWriter, r *http.Request) {
@@ -59,7 +104,8 @@ func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Verify and add block
+		// Verify and add block; validators commit to it and share the
+		// commit through Node.OnCommit
 		if err := s.Node.AddBlock(&block); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -71,6 +117,25 @@ func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleCommits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var commit blockchain.Commit
+	if err := json.NewDecoder(r.Body).Decode(&commit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.Node.AddCommit(&commit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
 func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
 		var tx blockchain.Transaction
@@ -97,6 +162,123 @@ func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleGetTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	txID := strings.TrimPrefix(r.URL.Path, "/tx/")
+	tx, receipt, ok := s.Node.TransactionByID(txID)
+	if !ok {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	status := "pending"
+	if receipt != nil {
+		status = string(receipt.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Transaction *blockchain.Transaction `json:"transaction"`
+		Receipt     *blockchain.Receipt     `json:"receipt,omitempty"`
+		Status      string                  `json:"status"`
+	}{
+		Transaction: tx,
+		Receipt:     receipt,
+		Status:      status,
+	})
+}
+
+func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sender := strings.TrimPrefix(r.URL.Path, "/accounts/")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Account{Sender: sender, NextNonce: s.Node.NextNonce(sender)})
+}
+
+func (s *Server) handleGetElection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	runtime, ok := s.Node.Executor.(*smartcontracts.Runtime)
+	if !ok {
+		http.Error(w, "Contract runtime not enabled on this node", http.StatusNotFound)
+		return
+	}
+
+	electionID, view, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/elections/"), "/")
+	es, ok := runtime.Election(electionID)
+	if !ok {
+		http.Error(w, "Election not found", http.StatusNotFound)
+		return
+	}
+
+	switch view {
+	case "":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(es.Election)
+	case "state":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(es)
+	case "audit":
+		s.handleElectionAudit(w, es)
+	case "results":
+		s.handleElectionResults(w, es)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleElectionAudit serves the trackers of the cast ballots, for voters to
+// find theirs, and the challenged ballots with their openings, for anyone to
+// check that they encrypted what their voters intended.
+func (s *Server) handleElectionAudit(w http.ResponseWriter, es *smartcontracts.ElectionState) {
+	challenged := es.Challenged
+	if challenged == nil {
+		challenged = []*election.BallotAudit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ElectionAudit{
+		ElectionID: es.Election.ID,
+		Cast:       es.CastTrackers(),
+		Challenged: challenged,
+	})
+}
+
+// handleElectionResults serves certified results with the officials'
+// signatures, for anyone to check against the officials of the election.
+func (s *Server) handleElectionResults(w http.ResponseWriter, es *smartcontracts.ElectionState) {
+	if es.Status != smartcontracts.ElectionCertified {
+		http.Error(w, "Results not certified", http.StatusNotFound)
+		return
+	}
+	digest, err := es.ResultsDigest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ElectionResults{
+		ElectionID:  es.Election.ID,
+		Results:     es.Results,
+		Outcome:     es.Outcome,
+		Digest:      digest,
+		Certificate: es.Certificate,
+		Signers:     es.Certifiers(),
+	})
+}
+
 func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
 	s.P2PNet.mu.RLock()
 	defer s.P2PNet.mu.RUnlock()
@@ -0,0 +1,155 @@
+// pkg/registrar/registrar.go
+package registrar
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+var (
+	// ErrUnauthenticated is returned when a voter fails authentication.
+	ErrUnauthenticated = errors.New("voter could not be authenticated")
+
+	// ErrAlreadyIssued is returned when a voter asks for a second token for
+	// the same election.
+	ErrAlreadyIssued = errors.New("a token has already been issued to this voter for this election")
+)
+
+// Authenticator identifies voters before the registrar signs their tokens.
+type Authenticator interface {
+	Authenticate(voterID, secret string) bool
+}
+
+// AccessCodes authenticates voters by the access codes handed out to them,
+// e.g. by mail, mapping voter IDs to their codes.
+type AccessCodes map[string]string
+
+func (a AccessCodes) Authenticate(voterID, secret string) bool {
+	code, ok := a[voterID]
+	if !ok {
+		return false
+	}
+	expected, given := sha256.Sum256([]byte(code)), sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(expected[:], given[:]) == 1
+}
+
+// Registrar authenticates voters and blindly signs one voting token per
+// voter and election. It learns who asked for a token but never sees the
+// token itself, so ballots cast with it stay anonymous.
+type Registrar struct {
+	mu     sync.Mutex
+	key    *crypto.BLSKeyPair
+	auth   Authenticator
+	issued map[string]map[string]bool // Voter IDs issued a token, per election ID
+}
+
+func NewRegistrar(key *crypto.BLSKeyPair, auth Authenticator) *Registrar {
+	return &Registrar{
+		key:    key,
+		auth:   auth,
+		issued: make(map[string]map[string]bool),
+	}
+}
+
+// PublicKey returns the key elections list as their RegistrarKey.
+func (r *Registrar) PublicKey() []byte {
+	return crypto.MarshalG2(r.key.PublicKey)
+}
+
+// IssueRequest is the body of POST /tokens.
+type IssueRequest struct {
+	ElectionID string `json:"election_id"`
+	VoterID    string `json:"voter_id"`
+	Secret     string `json:"secret"`  // Authenticates the voter, e.g. an access code
+	Blinded    []byte `json:"blinded"` // Blinded token message, see election.TokenRequest
+}
+
+// IssueResponse is the response of POST /tokens.
+type IssueResponse struct {
+	Signature []byte `json:"signature"` // Blind signature, to be unblinded by the voter
+}
+
+// Issue authenticates the voter and signs the blinded token message, at most
+// once per voter and election.
+func (r *Registrar) Issue(req *IssueRequest) (*IssueResponse, error) {
+	if req.ElectionID == "" || req.VoterID == "" {
+		return nil, errors.New("election and voter IDs are required")
+	}
+	if !r.auth.Authenticate(req.VoterID, req.Secret) {
+		return nil, ErrUnauthenticated
+	}
+	blinded, err := crypto.UnmarshalPoint(req.Blinded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed blinded token: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.issued[req.ElectionID][req.VoterID] {
+		return nil, ErrAlreadyIssued
+	}
+	if r.issued[req.ElectionID] == nil {
+		r.issued[req.ElectionID] = make(map[string]bool)
+	}
+	r.issued[req.ElectionID][req.VoterID] = true
+	return &IssueResponse{Signature: crypto.BlindSign(r.key.PrivateKey, blinded).Marshal()}, nil
+}
+
+// Handler returns the HTTP handler serving the registrar API.
+func (r *Registrar) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/key", r.handleKey)
+	mux.HandleFunc("/tokens", r.handleTokens)
+	return mux
+}
+
+func (r *Registrar) handleKey(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		PublicKey []byte `json:"public_key"`
+	}{
+		PublicKey: r.PublicKey(),
+	})
+}
+
+func (r *Registrar) handleTokens(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var issue IssueRequest
+	if err := json.NewDecoder(req.Body).Decode(&issue); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := r.Issue(&issue)
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, ErrAlreadyIssued):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
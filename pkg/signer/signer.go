@@ -0,0 +1,309 @@
+// pkg/signer/signer.go
+package signer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// Daemon holds a validator key away from the node and signs its finality
+// commits over HTTP on a Unix socket. The API is unauthenticated, so only
+// the socket's file permissions keep other users from signing. It records
+// every height it signs in a state file before releasing the signature, so
+// it refuses double signs across restarts too.
+type Daemon struct {
+	mu        sync.Mutex
+	key       *crypto.BLSKeyPair
+	statePath string
+	signed    crypto.SignedCommits
+}
+
+// NewDaemon returns a daemon signing with key, keeping its record of signed
+// heights in statePath.
+func NewDaemon(key *crypto.BLSKeyPair, statePath string) (*Daemon, error) {
+	d := &Daemon{key: key, statePath: statePath, signed: make(crypto.SignedCommits)}
+	data, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &d.signed); err != nil {
+		return nil, fmt.Errorf("malformed signer state %s: %v", statePath, err)
+	}
+	return d, nil
+}
+
+// KeyResponse is the response of GET /key.
+type KeyResponse struct {
+	PublicKey  []byte `json:"public_key"`
+	Possession []byte `json:"possession"`
+}
+
+// SignRequest is the body of POST /sign.
+type SignRequest struct {
+	ChainID string `json:"chain_id"`
+	Height  int    `json:"height"`
+	Hash    []byte `json:"hash"`
+}
+
+// SignResponse is the response of POST /sign.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// Sign commits to the block of req, unless another block has been signed
+// at its height.
+func (d *Daemon) Sign(req *SignRequest) (*SignResponse, error) {
+	if req.ChainID == "" || req.Height < 0 || len(req.Hash) == 0 {
+		return nil, errors.New("chain ID, height and block hash are required")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.signed.Check(req.ChainID, req.Height, req.Hash); err != nil {
+		return nil, err
+	}
+	d.signed.Record(req.ChainID, req.Height, req.Hash)
+	if err := d.saveState(); err != nil {
+		return nil, err
+	}
+	sig := crypto.BLSSign(d.key.PrivateKey, crypto.CommitMessage(req.ChainID, req.Height, req.Hash))
+	return &SignResponse{Signature: sig.Marshal()}, nil
+}
+
+// saveState writes the signed heights, replacing the state file atomically
+// and durably, so a crash cannot lose a height that was signed. The caller
+// must hold d.mu.
+func (d *Daemon) saveState() error {
+	data, err := json.Marshal(d.signed)
+	if err != nil {
+		return err
+	}
+	tmp := d.statePath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, d.statePath); err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	dir, err := os.Open(filepath.Dir(d.statePath))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Handler returns the HTTP handler serving the signer API.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/key", d.handleKey)
+	mux.HandleFunc("/sign", d.handleSign)
+	return mux
+}
+
+// Serve serves the signer API on addr, a Unix socket path prefixed with
+// "unix:". The socket is only accessible to the daemon's user: it is created
+// in a private directory and moved to path once restricted, so nobody can
+// connect while it still has the permissions of the umask.
+func (d *Daemon) Serve(addr string) error {
+	path, err := socketPath(addr)
+	if err != nil {
+		return err
+	}
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return err
+	}
+	return http.Serve(listener, d.Handler())
+}
+
+// listenPrivate listens on a Unix socket at path accessible to the current
+// user only.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".signer-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "signer.sock")
+	listener, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(private, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	os.Remove(path)
+	if err := os.Rename(private, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func (d *Daemon) handleKey(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KeyResponse{
+		PublicKey:  crypto.MarshalG2(d.key.PublicKey),
+		Possession: crypto.ProvePossession(d.key.PrivateKey).Marshal(),
+	})
+}
+
+func (d *Daemon) handleSign(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var sign SignRequest
+	if err := json.NewDecoder(req.Body).Decode(&sign); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := d.Sign(&sign)
+	switch {
+	case errors.Is(err, crypto.ErrDoubleSign):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Remote is a crypto.Signer backed by a signing daemon.
+type Remote struct {
+	baseURL    string
+	client     *http.Client
+	publicKey  *bn256.G2
+	possession *bn256.G1
+}
+
+// Dial connects to the daemon at addr, a Unix socket path prefixed with
+// "unix:", and fetches its key.
+func Dial(addr string) (*Remote, error) {
+	path, err := socketPath(addr)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+	return NewRemote("http://signer", client)
+}
+
+// NewRemote returns a Remote talking to the daemon at baseURL through
+// client, and fetches its key.
+func NewRemote(baseURL string, client *http.Client) (*Remote, error) {
+	resp, err := client.Get(baseURL + "/key")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var key KeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return nil, fmt.Errorf("invalid signer response: %v", err)
+	}
+
+	r := &Remote{baseURL: baseURL, client: client}
+	if r.publicKey, err = crypto.UnmarshalG2(key.PublicKey); err != nil {
+		return nil, fmt.Errorf("signer key: %v", err)
+	}
+	if r.possession, err = crypto.UnmarshalPoint(key.Possession); err != nil || !crypto.VerifyPossession(r.publicKey, r.possession) {
+		return nil, errors.New("signer has no valid proof of possession of its key")
+	}
+	return r, nil
+}
+
+func (r *Remote) PublicKey() *bn256.G2 {
+	return r.publicKey
+}
+
+func (r *Remote) Possession() *bn256.G1 {
+	return r.possession
+}
+
+// SignCommit asks the daemon to sign, checking the signature it returns.
+func (r *Remote) SignCommit(chainID string, height int, hash []byte) (*bn256.G1, error) {
+	body, _ := json.Marshal(SignRequest{ChainID: chainID, Height: height, Hash: hash})
+	resp, err := r.client.Post(r.baseURL+"/sign", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return nil, crypto.ErrDoubleSign
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("signer refused: %s", strings.TrimSpace(string(msg)))
+	}
+
+	var signed SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("invalid signer response: %v", err)
+	}
+	sig, err := crypto.UnmarshalPoint(signed.Signature)
+	if err != nil || !crypto.BLSVerify(r.publicKey, crypto.CommitMessage(chainID, height, hash), sig) {
+		return nil, errors.New("signer returned an invalid signature")
+	}
+	return sig, nil
+}
+
+// socketPath returns the socket path of a "unix:" signer address. Other
+// networks are refused, since the signer does not authenticate callers.
+func socketPath(addr string) (string, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok || path == "" {
+		return "", fmt.Errorf("signer address %q must be a Unix socket path prefixed with unix:", addr)
+	}
+	return path, nil
+}
@@ -2,18 +2,251 @@
 package smartcontracts
 
 import (
+	"fmt"
+	"math/big"
+
+	"github.com/cloudflare/bn256"
 	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
 	"github.com/koushamad/election-system/pkg/election"
 )
 
+// VotePayload is the payload of a cast_vote transaction.
+type VotePayload struct {
+	ElectionID string           `json:"election_id"`
+	Ballot     *election.Ballot `json:"ballot"`
+}
+
+// TallyPayload is the payload of a tally_votes transaction, accepted only by
+// elections with a trusted tally and only with the signatures of a quorum of
+// their officials, see SignTally. Ranked elections provide the decrypted
+// rankings instead of Results, which are then derived by tabulation.
+type TallyPayload struct {
+	ElectionID  string                  `json:"election_id"`
+	Results     map[string]int          `json:"results"`
+	Rankings    [][]string              `json:"rankings,omitempty"`  // Candidate names, most preferred first
+	Contests    map[string]ContestTally `json:"contests,omitempty"`  // Per contest ID, for multi-contest elections
+	WriteIns    map[string]int          `json:"write_ins,omitempty"` // Weight per decrypted write-in name, for write-in elections
+	Certificate *crypto.MultiSignature  `json:"certificate"`         // Officials' signatures on the results the tally leads to
+	Timestamp   int64                   `json:"timestamp"`
+}
+
+// ContestTally is the tally of one contest of a multi-contest election.
+type ContestTally struct {
+	Results  map[string]int `json:"results,omitempty"`
+	Rankings [][]string     `json:"rankings,omitempty"`
+}
+
+// DelegationPayload is the payload of a delegate_vote transaction. An empty
+// Delegate revokes the delegator's delegation for the topic.
+type DelegationPayload struct {
+	ElectionID string                     `json:"election_id"`
+	ContestID  string                     `json:"contest_id,omitempty"` // Topic of the delegation, the whole election when empty
+	Delegator  string                     `json:"delegator"`
+	Delegate   string                     `json:"delegate,omitempty"`
+	Credential *election.BallotCredential `json:"credential"` // Delegator's signature, see election.SignDelegation
+}
+
+// ChallengePayload is the payload of a challenge_ballot transaction: a ballot
+// its voter chose to audit instead of cast, with its opening.
+type ChallengePayload struct {
+	ElectionID string                  `json:"election_id"`
+	Ballot     *election.Ballot        `json:"ballot"`
+	Opening    *election.BallotOpening `json:"opening"`
+}
+
+// MixPayload is the payload of a mix_ballots transaction: a mix server's
+// shuffle of the previous step's output, or of the counted ballots for the
+// first step, with its proof.
+type MixPayload struct {
+	ElectionID string                 `json:"election_id"`
+	Server     string                 `json:"server"`
+	Output     [][]*crypto.Ciphertext `json:"output"`
+	Proof      *crypto.ShuffleProof   `json:"proof"`
+}
+
+// NewMixPayload shuffles the ballots of es as the next mix server in turn,
+// which must be server.
+func NewMixPayload(es *ElectionState, server string) (*MixPayload, error) {
+	e := es.Election
+	if e.Mix == nil {
+		return nil, fmt.Errorf("election %s is not mixed", e.ID)
+	}
+	step := len(es.MixedBy)
+	if step >= len(e.Mix.Servers) || e.Mix.Servers[step] != server {
+		return nil, fmt.Errorf("it is not the turn of mix server %s", server)
+	}
+	input, err := es.MixInput()
+	if err != nil {
+		return nil, err
+	}
+	output, proof, err := crypto.Shuffle(e.PublicKey, input, election.MixContext(e.ID, step))
+	if err != nil {
+		return nil, err
+	}
+	return &MixPayload{ElectionID: e.ID, Server: server, Output: output, Proof: proof}, nil
+}
+
+// ProposalPayload is the payload of a propose_results transaction: the
+// decrypted values of the tallied ciphertexts of an election, see
+// ElectionState.TallyCiphertexts, with proofs of their decryption. The
+// results are derived from them on chain.
+type ProposalPayload struct {
+	ElectionID string                    `json:"election_id"`
+	Values     []int64                   `json:"values"`
+	Proofs     []*crypto.DecryptionProof `json:"proofs"`
+	WriteIns   []*TextDecryption         `json:"write_ins,omitempty"` // One per mixed write-in row whose write-in cell is selected, in row order
+}
+
+// TextDecryption is the proven decryption of a mixed write-in text: the
+// points its chunks decrypt to, see crypto.DecodeText.
+type TextDecryption struct {
+	Row    int                       `json:"row"`
+	Chunks [][]byte                  `json:"chunks"`
+	Proofs []*crypto.DecryptionProof `json:"proofs"`
+}
+
+// CertificationPayload is the payload of a certify_results transaction: an
+// official's BLS signature on the proposed results, see ResultsDigest.
+type CertificationPayload struct {
+	ElectionID string `json:"election_id"`
+	Official   string `json:"official"`
+	Signature  []byte `json:"signature"`
+}
+
+// NewProposalPayload decrypts the tallied ciphertexts of es with the
+// election's private key and proves each decryption, along with the texts
+// of the mixed write-ins that were selected.
+func NewProposalPayload(es *ElectionState, privKey *big.Int) (*ProposalPayload, error) {
+	cts, max, err := es.TallyCiphertexts()
+	if err != nil {
+		return nil, err
+	}
+	payload := &ProposalPayload{ElectionID: es.Election.ID, Values: make([]int64, len(cts)), Proofs: make([]*crypto.DecryptionProof, len(cts))}
+	for i, ct := range cts {
+		if payload.Values[i], err = crypto.DecryptValue(privKey, ct, max); err != nil {
+			return nil, fmt.Errorf("ciphertext %d: %v", i, err)
+		}
+		if payload.Proofs[i], err = crypto.ProveDecryption(privKey, ct, payload.Values[i], election.DecryptionContext(es.Election.ID, i)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !es.Election.WriteIn {
+		return payload, nil
+	}
+	cells := payload.Values[len(payload.Values)-len(es.Mixed):]
+	for row, selected := range cells {
+		if selected == 0 {
+			continue
+		}
+		text := &TextDecryption{Row: row}
+		for i, chunk := range es.Mixed[row][1:] {
+			m := new(bn256.G1).Add(chunk.C2, new(bn256.G1).Neg(new(bn256.G1).ScalarMult(chunk.C1, privKey)))
+			proof, err := crypto.ProvePointDecryption(privKey, chunk, m, election.TextDecryptionContext(es.Election.ID, row, i))
+			if err != nil {
+				return nil, err
+			}
+			text.Chunks = append(text.Chunks, crypto.MarshalPoint(m))
+			text.Proofs = append(text.Proofs, proof)
+		}
+		payload.WriteIns = append(payload.WriteIns, text)
+	}
+	return payload, nil
+}
+
+// NewCertificationPayload signs the proposed results of es as official.
+func NewCertificationPayload(es *ElectionState, official string, key *big.Int) (*CertificationPayload, error) {
+	digest, err := es.ResultsDigest()
+	if err != nil {
+		return nil, err
+	}
+	return &CertificationPayload{ElectionID: es.Election.ID, Official: official, Signature: crypto.BLSSign(key, digest).Marshal()}, nil
+}
+
+// SignTally signs, as an official of an election with a trusted tally, the
+// results that the tally in payload leads to, see ResultsDigest. A quorum of
+// these signatures, aggregated in payload.Certificate, lets the tally be
+// posted.
+func SignTally(es *ElectionState, payload *TallyPayload, key *big.Int) (*bn256.G1, error) {
+	ctx := &Context{State: NewState(), Block: &blockchain.Block{}}
+	tallied, err := applyTally(ctx, es, payload)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := tallied.ResultsDigest()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.BLSSign(key, digest), nil
+}
+
+// KeyReleasePayload is the payload of a release_key transaction: the
+// beacon's signature on the round the key of a time-locked election is
+// locked to.
+type KeyReleasePayload struct {
+	ElectionID string `json:"election_id"`
+	Signature  []byte `json:"signature"`
+}
+
+// ElectionContract submits election transactions to a chain's pending pool.
 type ElectionContract struct {
 	Chain *blockchain.Chain
 }
 
 func (ec *ElectionContract) CreateElection(e *election.Election) error {
-	tx := blockchain.Transaction{
-		Type: "create_election",
-		Data: e,
+	return ec.submit(blockchain.TxCreateElection, e)
+}
+
+func (ec *ElectionContract) CastVote(electionID string, ballot *election.Ballot) error {
+	return ec.submit(blockchain.TxCastVote, VotePayload{
+		ElectionID: electionID,
+		Ballot:     ballot,
+	})
+}
+
+func (ec *ElectionContract) TallyVotes(payload *TallyPayload) error {
+	return ec.submit(blockchain.TxTallyVotes, payload)
+}
+
+func (ec *ElectionContract) DelegateVote(electionID, contestID, delegator, delegate string, credential *election.BallotCredential) error {
+	return ec.submit(blockchain.TxDelegateVote, DelegationPayload{
+		ElectionID: electionID,
+		ContestID:  contestID,
+		Delegator:  delegator,
+		Delegate:   delegate,
+		Credential: credential,
+	})
+}
+
+func (ec *ElectionContract) ChallengeBallot(electionID string, ballot *election.Ballot, opening *election.BallotOpening) error {
+	return ec.submit(blockchain.TxChallengeBallot, ChallengePayload{
+		ElectionID: electionID,
+		Ballot:     ballot,
+		Opening:    opening,
+	})
+}
+
+func (ec *ElectionContract) MixBallots(payload *MixPayload) error {
+	return ec.submit(blockchain.TxMixBallots, payload)
+}
+
+func (ec *ElectionContract) ProposeResults(payload *ProposalPayload) error {
+	return ec.submit(blockchain.TxProposeResults, payload)
+}
+
+func (ec *ElectionContract) CertifyResults(payload *CertificationPayload) error {
+	return ec.submit(blockchain.TxCertifyResults, payload)
+}
+
+func (ec *ElectionContract) ReleaseKey(electionID string, beaconSig []byte) error {
+	return ec.submit(blockchain.TxReleaseKey, KeyReleasePayload{ElectionID: electionID, Signature: beaconSig})
+}
+
+func (ec *ElectionContract) submit(txType blockchain.TransactionType, payload interface{}) error {
+	tx, err := blockchain.NewTransaction(txType, payload)
+	if err != nil {
+		return err
 	}
 	return ec.Chain.AddTransaction(tx)
 }
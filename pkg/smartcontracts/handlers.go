@@ -0,0 +1,907 @@
+// pkg/smartcontracts/handlers.go
+package smartcontracts
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+)
+
+// Error codes reported in receipts of transactions rejected by the election
+// contract
+const (
+	ErrCodeInvalidElection  = "invalid_election"
+	ErrCodeElectionExists   = "election_exists"
+	ErrCodeElectionNotFound = "election_not_found"
+	ErrCodeVotingClosed     = "voting_closed"
+	ErrCodeInvalidBallot    = "invalid_ballot"
+	ErrCodeDuplicateVote    = "duplicate_vote"
+	ErrCodeTallyTooEarly    = "tally_too_early"
+	ErrCodeAlreadyTallied   = "already_tallied"
+	ErrCodeInvalidTally     = "invalid_tally"
+
+	ErrCodeInvalidDelegation = "invalid_delegation"
+	ErrCodeDelegationCycle   = "delegation_cycle"
+
+	ErrCodeInvalidChallenge = "invalid_challenge"
+
+	ErrCodeInvalidMix    = "invalid_mix"
+	ErrCodeMixIncomplete = "mix_incomplete"
+
+	ErrCodeInvalidCertification = "invalid_certification"
+
+	ErrCodeKeyLocked         = "key_locked"
+	ErrCodeInvalidKeyRelease = "invalid_key_release"
+)
+
+func handleCreateElection(ctx *Context, tx *blockchain.Transaction) error {
+	var e election.Election
+	if err := ctx.DecodePayload(tx, &e); err != nil {
+		return err
+	}
+	ctx.ElectionID = e.ID
+
+	if e.ID == "" || e.Name == "" {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "election ID and name are required")
+	}
+	if !e.EndTime.After(e.StartTime) {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "end time must be after start time")
+	}
+	if e.PublicKey == nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "election public key is required")
+	}
+	if e.IsMultiContest() {
+		if err := checkContests(&e); err != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidElection, "%v", err)
+		}
+	} else if err := checkBallotSettings(&e); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "%v", err)
+	}
+	if err := e.ValidateTally(); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "%v", err)
+	}
+	if err := e.ValidateCredentials(); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "%v", err)
+	}
+	if err := e.ValidateTimeLock(); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidElection, "%v", err)
+	}
+	if _, exists := ctx.State.Elections[e.ID]; exists {
+		return blockchain.NewExecutionError(ErrCodeElectionExists, "election %s already exists", e.ID)
+	}
+
+	rules, err := ctx.configureRules(&e)
+	if err != nil {
+		return err
+	}
+	tallyScript, err := parseTallyScript(e.Rules.Script)
+	if err != nil {
+		return err
+	}
+
+	ctx.State.Elections[e.ID] = &ElectionState{
+		Election:    &e,
+		Status:      ElectionCreated,
+		Voters:      make(map[string]int),
+		Rules:       rules,
+		TallyScript: tallyScript,
+	}
+	return nil
+}
+
+func handleCastVote(ctx *Context, tx *blockchain.Transaction) error {
+	var payload VotePayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+
+	// Votes count only while the election is open at the time of the block
+	now := ctx.BlockTime()
+	if es.Status != ElectionCreated || now.Before(es.Election.StartTime) || !now.Before(es.Election.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeVotingClosed, "election %s is not open for voting", es.Election.ID)
+	}
+
+	ballot := payload.Ballot
+	if ballot == nil || ballot.VoterID == "" {
+		return blockchain.NewExecutionError(ErrCodeInvalidBallot, "malformed ballot")
+	}
+	ctx.Charge(uint64(len(es.Election.VoterRing))) // Ring signatures verify per ring member
+	if err := checkBallotProofs(es.Election, ballot); err != nil {
+		return err
+	}
+	if len(es.Challenged) > 0 {
+		tracker, err := election.BallotTracker(ballot)
+		if err != nil || es.IsChallenged(tracker) {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "challenged ballots cannot be cast")
+		}
+	}
+	if err := es.Rules.CheckBallot(es, ballot); err != nil {
+		return err
+	}
+
+	es.RecordBallot(ballot)
+	return nil
+}
+
+func handleTallyVotes(ctx *Context, tx *blockchain.Transaction) error {
+	var payload TallyPayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	if es.Status != ElectionCreated {
+		return blockchain.NewExecutionError(ErrCodeAlreadyTallied, "election %s has already been tallied", es.Election.ID)
+	}
+	if !es.Election.TrustedTally {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "results of election %s must be proposed with decryption proofs", es.Election.ID)
+	}
+	if ctx.BlockTime().Before(es.Election.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeTallyTooEarly, "election %s is still open", es.Election.ID)
+	}
+	if es.MixPending() {
+		return blockchain.NewExecutionError(ErrCodeMixIncomplete, "election %s has been mixed by %d of %d servers", es.Election.ID, len(es.MixedBy), len(es.Election.Mix.Servers))
+	}
+	if es.KeyLocked() {
+		return blockchain.NewExecutionError(ErrCodeKeyLocked, "key of election %s has not been released", es.Election.ID)
+	}
+
+	tallied, err := applyTally(ctx, es, &payload)
+	if err != nil {
+		return err
+	}
+	// Nothing proves a trusted tally, so a quorum of officials must sign it
+	c := es.Election.Certification
+	keys, err := c.Keys()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+	digest, err := tallied.ResultsDigest()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+	ctx.Charge(uint64(len(keys)))
+	if payload.Certificate == nil || !payload.Certificate.Verify(keys, digest) {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "tally of election %s is not signed by its officials", es.Election.ID)
+	}
+	if count := payload.Certificate.Count(); count < c.Quorum {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "tally of election %s is signed by %d officials, %d required", es.Election.ID, count, c.Quorum)
+	}
+
+	es.Results = tallied.Results
+	es.ContestResults = tallied.ContestResults
+	es.Outcome = tallied.Outcome
+	es.Certificate = payload.Certificate
+	es.Status = ElectionCertified
+	return nil
+}
+
+// applyTally derives the results and outcome of es from a trusted tally,
+// returning a tallied copy of es and leaving es itself unchanged.
+func applyTally(ctx *Context, es *ElectionState, payload *TallyPayload) (*ElectionState, error) {
+	tallied := *es
+	if es.Election.IsMultiContest() {
+		if payload.WriteIns != nil {
+			return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "multi-contest elections do not take write-ins")
+		}
+		if err := tallyContests(ctx, &tallied, payload.Contests); err != nil {
+			return nil, err
+		}
+		return &tallied, nil
+	}
+
+	outcome, results, err := tallyContest(ctx, es, payload.Results, payload.Rankings)
+	if err != nil {
+		return nil, err
+	}
+	if payload.WriteIns != nil {
+		if err := checkWriteIns(es.Election, results, payload.WriteIns); err != nil {
+			return nil, err
+		}
+		outcome.WriteIns = es.Election.GroupWriteIns(payload.WriteIns)
+	}
+	tallied.Results = results
+	tallied.Outcome = outcome
+	tallied.Status = ElectionTallied
+	return &tallied, nil
+}
+
+// handleDelegateVote records or revokes a voter's delegation for an election
+// or one of its contests. Both are possible only while voting is open.
+func handleDelegateVote(ctx *Context, tx *blockchain.Transaction) error {
+	var payload DelegationPayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	now := ctx.BlockTime()
+	if es.Status != ElectionCreated || now.Before(es.Election.StartTime) || !now.Before(es.Election.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeVotingClosed, "election %s is not open for voting", es.Election.ID)
+	}
+	if es.Election.Mix != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "mixed ballots are counted without voter links, so weight cannot move")
+	}
+	if err := checkDelegationTopic(es.Election, payload.ContestID, payload.Delegator); err != nil {
+		return err
+	}
+	// Voter IDs alone are not authenticated, so delegators sign like voters
+	if !es.Election.HasCredentials() {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "election %s has no voter credentials to sign delegations with", es.Election.ID)
+	}
+	if err := es.Election.VerifyDelegation(payload.ContestID, payload.Delegator, payload.Delegate, payload.Credential); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "%v", err)
+	}
+
+	topic := es.Delegations[payload.ContestID]
+	if payload.Delegate == "" {
+		if _, ok := topic[payload.Delegator]; !ok {
+			return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "voter %s has no delegation to revoke", payload.Delegator)
+		}
+		delete(topic, payload.Delegator)
+		return nil
+	}
+
+	if payload.Delegate == payload.Delegator {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "voters cannot delegate to themselves")
+	}
+	if err := checkDelegationTopic(es.Election, payload.ContestID, payload.Delegate); err != nil {
+		return err
+	}
+	if es.delegationCycle(payload.ContestID, payload.Delegator, payload.Delegate) {
+		return blockchain.NewExecutionError(ErrCodeDelegationCycle, "delegating from %s to %s would create a cycle", payload.Delegator, payload.Delegate)
+	}
+
+	if topic == nil {
+		topic = make(map[string]string)
+		if es.Delegations == nil {
+			es.Delegations = make(map[string]map[string]string)
+		}
+		es.Delegations[payload.ContestID] = topic
+	}
+	topic[payload.Delegator] = payload.Delegate
+	return nil
+}
+
+// checkDelegationTopic checks that voterID is registered and may take part in
+// a delegation for the topic: the election, or contestID of it.
+func checkDelegationTopic(e *election.Election, contestID, voterID string) error {
+	if voterID == "" || !e.IsRegistered(voterID) {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "voter %q is not registered", voterID)
+	}
+	if contestID == "" {
+		// Ranked rankings are tallied without voter links, so weight cannot move
+		if e.BallotType == election.BallotRanked {
+			return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "ranked elections do not support delegation")
+		}
+		return nil
+	}
+
+	c, ok := e.Contest(contestID)
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "unknown contest %q", contestID)
+	}
+	if c.BallotType == election.BallotRanked {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "ranked contest %s does not support delegation", c.ID)
+	}
+	if !e.HasContest(voterID, contestID) {
+		return blockchain.NewExecutionError(ErrCodeInvalidDelegation, "contest %s is not on the ballot of voter %s", c.ID, voterID)
+	}
+	return nil
+}
+
+// handleChallengeBallot publishes a ballot its voter opened instead of
+// casting it, while voting is open. The opening must hold, and the ballot
+// must not have been cast, since challenged ballots are never counted.
+func handleChallengeBallot(ctx *Context, tx *blockchain.Transaction) error {
+	var payload ChallengePayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	now := ctx.BlockTime()
+	if es.Status != ElectionCreated || now.Before(es.Election.StartTime) || !now.Before(es.Election.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeVotingClosed, "election %s is not open for voting", es.Election.ID)
+	}
+	if payload.Ballot == nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidChallenge, "malformed challenge")
+	}
+
+	audit, err := es.Election.AuditBallot(payload.Ballot, payload.Opening)
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidChallenge, "%v", err)
+	}
+	if es.IsChallenged(audit.Tracker) {
+		return blockchain.NewExecutionError(ErrCodeInvalidChallenge, "ballot %s has already been challenged", audit.Tracker)
+	}
+	ctx.Charge(uint64(len(es.Ballots)))
+	for _, tracker := range es.CastTrackers() {
+		if tracker == audit.Tracker {
+			return blockchain.NewExecutionError(ErrCodeInvalidChallenge, "ballot %s has been cast", audit.Tracker)
+		}
+	}
+
+	es.Challenged = append(es.Challenged, audit)
+	return nil
+}
+
+// handleMixBallots records the shuffle of the mix server whose turn it is,
+// once voting has closed and before the tally.
+func handleMixBallots(ctx *Context, tx *blockchain.Transaction) error {
+	var payload MixPayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	e := es.Election
+	if e.Mix == nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "election %s is not mixed", e.ID)
+	}
+	if es.Status != ElectionCreated {
+		return blockchain.NewExecutionError(ErrCodeAlreadyTallied, "election %s has already been tallied", e.ID)
+	}
+	if ctx.BlockTime().Before(e.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "election %s is still open", e.ID)
+	}
+	step := len(es.MixedBy)
+	if step >= len(e.Mix.Servers) {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "election %s has been mixed by every server", e.ID)
+	}
+	if payload.Server != e.Mix.Servers[step] {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "mixing step %d belongs to server %s, not %s", step, e.Mix.Servers[step], payload.Server)
+	}
+
+	input, err := es.MixInput()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "%v", err)
+	}
+	if len(input) == 0 {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "election %s has no ballots to mix", e.ID)
+	}
+	ctx.Charge(uint64(len(input) * len(input[0])))
+	if !crypto.VerifyShuffle(e.PublicKey, input, payload.Output, payload.Proof, election.MixContext(e.ID, step)) {
+		return blockchain.NewExecutionError(ErrCodeInvalidMix, "shuffle proof of server %s does not verify", payload.Server)
+	}
+
+	es.Mixed = payload.Output
+	es.MixedBy = append(es.MixedBy, payload.Server)
+	return nil
+}
+
+// handleProposeResults tallies an election from the decryptions of its
+// tallied ciphertexts, which must come with valid proofs, so the results
+// follow from the ballots. Officials of a certified election then sign them.
+func handleProposeResults(ctx *Context, tx *blockchain.Transaction) error {
+	var payload ProposalPayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	e := es.Election
+	if es.Status != ElectionCreated {
+		return blockchain.NewExecutionError(ErrCodeAlreadyTallied, "election %s has already been tallied", e.ID)
+	}
+	if ctx.BlockTime().Before(e.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeTallyTooEarly, "election %s is still open", e.ID)
+	}
+	if es.MixPending() {
+		return blockchain.NewExecutionError(ErrCodeMixIncomplete, "election %s has been mixed by %d of %d servers", e.ID, len(es.MixedBy), len(e.Mix.Servers))
+	}
+	if es.KeyLocked() {
+		return blockchain.NewExecutionError(ErrCodeKeyLocked, "key of election %s has not been released", e.ID)
+	}
+
+	cts, max, err := es.TallyCiphertexts()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+	if len(payload.Values) != len(cts) || len(payload.Proofs) != len(cts) {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "proposal must decrypt all %d tallied ciphertexts", len(cts))
+	}
+	ctx.Charge(uint64(len(cts)))
+	for i, ct := range cts {
+		if payload.Values[i] < 0 || payload.Values[i] > max {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "value %d is out of range", i)
+		}
+		if !crypto.VerifyDecryption(e.PublicKey, ct, payload.Values[i], payload.Proofs[i], election.DecryptionContext(e.ID, i)) {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "decryption proof %d does not verify", i)
+		}
+	}
+
+	if e.IsMultiContest() {
+		if err := tallyContests(ctx, es, contestTallies(e, payload.Values)); err != nil {
+			return err
+		}
+	} else {
+		// Derive the results, or the rankings they are tabulated from
+		var results map[string]int
+		var rankings [][]string
+		if e.BallotType == election.BallotRanked {
+			n := len(e.Candidates)
+			for row := 0; row < len(cts); row += n * n {
+				ranking, err := e.Selections("", payload.Values[row:row+n*n])
+				if err != nil {
+					return blockchain.NewExecutionError(ErrCodeInvalidTally, "mixed ballot %d: %v", row/(n*n), err)
+				}
+				rankings = append(rankings, ranking)
+			}
+		} else {
+			results = make(map[string]int, len(e.Candidates))
+			for i, candidate := range e.Candidates {
+				results[candidate.Name] = int(payload.Values[i])
+			}
+		}
+
+		outcome, results, err := tallyContest(ctx, es, results, rankings)
+		if err != nil {
+			return err
+		}
+		if e.WriteIn {
+			writeIns, err := provenWriteIns(ctx, es, payload.Values[len(e.Candidates):], payload.WriteIns)
+			if err != nil {
+				return err
+			}
+			if err := checkWriteIns(e, results, writeIns); err != nil {
+				return err
+			}
+			outcome.WriteIns = e.GroupWriteIns(writeIns)
+		} else if payload.WriteIns != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "election %s does not take write-ins", e.ID)
+		}
+		es.Results = results
+		es.Outcome = outcome
+		es.Status = ElectionTallied
+	}
+	if e.Certification != nil {
+		es.Certificate = crypto.NewMultiSignature(len(e.Certification.Officials))
+	}
+	return nil
+}
+
+// contestTallies splits the decrypted totals of a multi-contest election
+// into the results of its contests, in the order of TallyCiphertexts.
+func contestTallies(e *election.Election, values []int64) map[string]ContestTally {
+	tallies := make(map[string]ContestTally, len(e.Contests))
+	for _, c := range e.Contests {
+		results := make(map[string]int, len(c.Candidates))
+		for i, candidate := range c.Candidates {
+			results[candidate.Name] = int(values[i])
+		}
+		values = values[len(c.Candidates):]
+		tallies[c.ID] = ContestTally{Results: results}
+	}
+	return tallies
+}
+
+// provenWriteIns checks the decrypted texts of the mixed write-in rows whose
+// write-in cell decrypted to a selection, given the cells' values in row
+// order, and returns the weight written in per normalized name.
+func provenWriteIns(ctx *Context, es *ElectionState, cells []int64, texts []*TextDecryption) (map[string]int, error) {
+	e := es.Election
+	writeIns := make(map[string]int)
+	next := 0
+	for row, selected := range cells {
+		if selected == 0 {
+			continue
+		}
+		if next >= len(texts) || texts[next] == nil || texts[next].Row != row {
+			return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "proposal is missing the write-in of mixed ballot %d", row)
+		}
+		text := texts[next]
+		next++
+
+		chunks := es.Mixed[row][1:]
+		if len(text.Chunks) != len(chunks) || len(text.Proofs) != len(chunks) {
+			return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "write-in of mixed ballot %d must decrypt all %d chunks", row, len(chunks))
+		}
+		ctx.Charge(uint64(len(chunks)))
+		points, err := crypto.UnmarshalPoints(text.Chunks)
+		if err != nil {
+			return nil, blockchain.NewExecutionError(blockchain.ErrCodeInvalidEncoding, "write-in of mixed ballot %d: %v", row, err)
+		}
+		for i, chunk := range chunks {
+			if !crypto.VerifyPointDecryption(e.PublicKey, chunk, points[i], text.Proofs[i], election.TextDecryptionContext(e.ID, row, i)) {
+				return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "decryption proof of write-in chunk %d of mixed ballot %d does not verify", i, row)
+			}
+		}
+		name, err := crypto.DecodeText(points)
+		if err != nil || len(name) > election.MaxWriteInLength {
+			return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "write-in of mixed ballot %d is malformed", row)
+		}
+		writeIns[election.NormalizeWriteIn(string(name))] += int(selected)
+	}
+	if next != len(texts) {
+		return nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "proposal decrypts write-ins of unselected mixed ballots")
+	}
+	return writeIns, nil
+}
+
+// handleCertifyResults adds an official's signature on the proposed results
+// to the election's certificate. The election is certified once a quorum of
+// officials has signed.
+func handleCertifyResults(ctx *Context, tx *blockchain.Transaction) error {
+	var payload CertificationPayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	c := es.Election.Certification
+	if c == nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "election %s has no officials", es.Election.ID)
+	}
+	if es.Status == ElectionCreated {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "results of election %s have not been proposed", es.Election.ID)
+	}
+	index := c.OfficialIndex(payload.Official)
+	if index < 0 {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "%q is not an official of election %s", payload.Official, es.Election.ID)
+	}
+	if es.Certificate.HasSigned(index) {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "official %s has already signed", payload.Official)
+	}
+	keys, err := c.Keys()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "%v", err)
+	}
+	sig, err := crypto.UnmarshalPoint(payload.Signature)
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "malformed signature: %v", err)
+	}
+	digest, err := es.ResultsDigest()
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "%v", err)
+	}
+	if !crypto.BLSVerify(keys[index], digest, sig) {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "signature of official %s does not verify", payload.Official)
+	}
+
+	if err := es.Certificate.Add(index, sig); err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidCertification, "%v", err)
+	}
+	if es.Certificate.Count() >= c.Quorum {
+		es.Status = ElectionCertified
+	}
+	return nil
+}
+
+// handleReleaseKey recovers the private key of a time-locked election from
+// the beacon's signature on its round, which the beacon releases when voting
+// closes, and records it for anyone to decrypt the tally with.
+func handleReleaseKey(ctx *Context, tx *blockchain.Transaction) error {
+	var payload KeyReleasePayload
+	if err := ctx.DecodePayload(tx, &payload); err != nil {
+		return err
+	}
+	ctx.ElectionID = payload.ElectionID
+
+	es, ok := ctx.State.Elections[payload.ElectionID]
+	if !ok {
+		return blockchain.NewExecutionError(ErrCodeElectionNotFound, "election %s not found", payload.ElectionID)
+	}
+	if es.Election.TimeLock == nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidKeyRelease, "key of election %s is not time-locked", es.Election.ID)
+	}
+	if es.ReleasedKey != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidKeyRelease, "key of election %s has already been released", es.Election.ID)
+	}
+	if ctx.BlockTime().Before(es.Election.EndTime) {
+		return blockchain.NewExecutionError(ErrCodeTallyTooEarly, "election %s is still open", es.Election.ID)
+	}
+	sig, err := crypto.UnmarshalPoint(payload.Signature)
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidKeyRelease, "malformed beacon signature: %v", err)
+	}
+	privKey, err := es.Election.UnlockKey(sig)
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidKeyRelease, "%v", err)
+	}
+
+	es.ReleasedKey = privKey
+	return nil
+}
+
+// checkWriteIns checks that the decrypted write-ins add up to the result of
+// the write-in option.
+func checkWriteIns(e *election.Election, results map[string]int, writeIns map[string]int) error {
+	index := e.WriteInIndex()
+	if !e.WriteIn || index < 0 {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "election %s does not take write-ins", e.ID)
+	}
+	total := 0
+	for name, count := range writeIns {
+		if count < 0 {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "invalid write-in count for %q", name)
+		}
+		total += count
+	}
+	if option := e.Candidates[index].Name; total != results[option] {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "write-ins add up to %d, but %s has %d", total, option, results[option])
+	}
+	return nil
+}
+
+// tallyContest checks the results of a single-contest election, or of one
+// contest's view, and decides its outcome. Ranked contests are tabulated
+// from the decrypted rankings instead.
+func tallyContest(ctx *Context, es *ElectionState, results map[string]int, rankings [][]string) (*Outcome, map[string]int, error) {
+	var ranked *Outcome
+	if es.Election.BallotType == election.BallotRanked {
+		var err error
+		ranked, results, err = tabulateRankings(es, rankings)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := es.Rules.CheckTally(es, results); err != nil {
+		return nil, nil, err
+	}
+	outcome, err := decideOutcome(ctx, es, results)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ranked != nil && es.TallyScript == nil {
+		outcome = ranked
+	}
+	if len(es.Delegations[""]) > 0 {
+		outcome.Delegation = es.ResolveDelegations(es.Election.WeightOf)
+	}
+	return outcome, results, nil
+}
+
+// tallyContests tallies a multi-contest election contest by contest. Every
+// contest must be present in tallies.
+func tallyContests(ctx *Context, es *ElectionState, tallies map[string]ContestTally) error {
+	for contestID := range tallies {
+		if _, ok := es.Election.Contest(contestID); !ok {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "tally names unknown contest %q", contestID)
+		}
+	}
+
+	outcome := &Outcome{Elected: []string{}, Contests: make(map[string]*Outcome, len(es.Election.Contests))}
+	results := make(map[string]map[string]int, len(es.Election.Contests))
+	for i := range es.Election.Contests {
+		c := &es.Election.Contests[i]
+		tally, ok := tallies[c.ID]
+		if !ok {
+			return blockchain.NewExecutionError(ErrCodeInvalidTally, "tally is missing contest %s", c.ID)
+		}
+		cs := es.contestState(c)
+		contestOutcome, contestResults, err := tallyContest(ctx, cs, tally.Results, tally.Rankings)
+		if err != nil {
+			var execErr *blockchain.ExecutionError
+			if errors.As(err, &execErr) {
+				return blockchain.NewExecutionError(execErr.Code, "contest %s: %v", c.ID, execErr.Err)
+			}
+			return err
+		}
+
+		// Questions pass by their threshold and quorum rather than by plurality
+		if c.BallotType == election.BallotQuestion {
+			question := es.Election.EvaluateQuestion(c, contestResults, cs.CastWeight())
+			contestOutcome = &Outcome{Elected: []string{}, Question: question, Delegation: contestOutcome.Delegation}
+			if question.Passed != "" {
+				contestOutcome.Elected = []string{question.Passed}
+			}
+		}
+		outcome.Contests[c.ID] = contestOutcome
+		results[c.ID] = contestResults
+	}
+
+	es.ContestResults = results
+	es.Outcome = outcome
+	es.Status = ElectionTallied
+	return nil
+}
+
+// checkBallotSettings validates the candidates and ballot settings of a
+// single-contest election, defaulting its ballot type to plurality.
+func checkBallotSettings(e *election.Election) error {
+	if len(e.Candidates) < 2 {
+		return errors.New("at least two candidates are required")
+	}
+	switch e.BallotType {
+	case "":
+		e.BallotType = election.BallotPlurality
+	case election.BallotPlurality, election.BallotRanked, election.BallotApproval, election.BallotScore:
+	default:
+		return fmt.Errorf("unknown ballot type %q", e.BallotType)
+	}
+	switch e.TieBreak {
+	case "", election.TieBreakPreviousRounds, election.TieBreakLot, election.TieBreakCandidateOrder:
+	default:
+		return fmt.Errorf("unknown tie-break rule %q", e.TieBreak)
+	}
+	if err := e.ValidateScoring(); err != nil {
+		return err
+	}
+	if err := e.ValidateWeights(); err != nil {
+		return err
+	}
+	if err := e.ValidateWriteIn(); err != nil {
+		return err
+	}
+	if err := e.ValidateMix(); err != nil {
+		return err
+	}
+	if err := e.ValidateCertification(); err != nil {
+		return err
+	}
+	if e.Seats < 0 || e.Seats > len(e.Candidates) {
+		return fmt.Errorf("cannot fill %d seats with %d candidates", e.Seats, len(e.Candidates))
+	}
+	if e.SeatCount() > 1 && e.BallotType != election.BallotRanked {
+		return errors.New("multi-seat elections require ranked ballots")
+	}
+	return nil
+}
+
+// checkContests validates a multi-contest election: its styles, and the
+// ballot settings of every contest.
+func checkContests(e *election.Election) error {
+	if e.BallotType != "" && e.BallotType != election.BallotMultiContest {
+		return errors.New("multi-contest elections take their ballot types from their contests")
+	}
+	e.BallotType = election.BallotMultiContest
+	if len(e.Candidates) > 0 || e.Seats != 0 || e.MaxScore != 0 || e.MinSelections != 0 || e.MaxSelections != 0 {
+		return errors.New("multi-contest elections set candidates and ballot settings per contest")
+	}
+	if e.Mix != nil {
+		return errors.New("multi-contest elections cannot be mixed")
+	}
+	if err := e.ValidateCertification(); err != nil {
+		return err
+	}
+	if err := e.ValidateContests(); err != nil {
+		return err
+	}
+	for i := range e.Contests {
+		if err := checkBallotSettings(e.ContestElection(&e.Contests[i])); err != nil {
+			return fmt.Errorf("contest %s: %v", e.Contests[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// checkBallotProofs verifies that ballot has the shape required by the
+// election's ballot type and that its validity proofs hold.
+func checkBallotProofs(e *election.Election, ballot *election.Ballot) error {
+	ballotType := ballot.Type
+	if ballotType == "" {
+		ballotType = election.BallotPlurality
+	}
+	if ballotType != e.BallotType {
+		return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%s ballot cast in a %s election", ballotType, e.BallotType)
+	}
+	if e.WeightOf(ballot.VoterID) < 1 {
+		return blockchain.NewExecutionError(ErrCodeInvalidBallot, "voter %s is not in the voter registry", ballot.VoterID)
+	}
+	if e.HasCredentials() {
+		if err := e.VerifyBallotCredential(ballot); err != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%v", err)
+		}
+	}
+
+	// Mixed elections take write-ins as texts the mix servers can shuffle
+	switch {
+	case e.WriteIn && e.Mix != nil:
+		if ballot.WriteIn != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "mixed elections take write-in texts instead of sealed write-ins")
+		}
+		if err := e.CheckWriteInText(ballot.VoterID, ballot.WriteInText); err != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%v", err)
+		}
+	case e.WriteIn:
+		if err := e.CheckSealedWriteIn(ballot.WriteIn); err != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%v", err)
+		}
+		if ballot.WriteInText != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "only mixed elections take write-in texts")
+		}
+	case ballot.WriteIn != nil || ballot.WriteInText != nil:
+		return blockchain.NewExecutionError(ErrCodeInvalidBallot, "election %s does not take write-ins", e.ID)
+	}
+
+	switch e.BallotType {
+	case election.BallotMultiContest:
+		if err := e.VerifySections(ballot.VoterID, ballot.Contests); err != nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%v", err)
+		}
+	case election.BallotPlurality, election.BallotApproval, election.BallotScore:
+		if ballot.Scores == nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%s election requires per-candidate scores", e.BallotType)
+		}
+		if !ballot.Scores.Verify(e, ballot.VoterID) {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "%s ballot proofs do not verify", e.BallotType)
+		}
+	case election.BallotRanked:
+		if ballot.Ranked == nil {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "ranked election requires a ranked ballot")
+		}
+		if !ballot.Ranked.Verify(e.PublicKey, e.ID, ballot.VoterID, len(e.Candidates)) {
+			return blockchain.NewExecutionError(ErrCodeInvalidBallot, "ranked ballot proofs do not verify")
+		}
+	}
+	return nil
+}
+
+// tabulateRankings counts a ranked election over the decrypted rankings of
+// its counted ballots: instant runoff for a single seat, STV for several. It
+// also returns the first preference counts, which serve as the results.
+func tabulateRankings(es *ElectionState, rankings [][]string) (*Outcome, map[string]int, error) {
+	if len(rankings) > len(es.Ballots) {
+		return nil, nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "tally has %d rankings but only %d ballots were cast", len(rankings), len(es.Ballots))
+	}
+	e := es.Election
+	parsed, err := election.ParseRankings(e.Candidates, rankings)
+	if err != nil {
+		return nil, nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+
+	firstPreferences := make(map[string]int, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		firstPreferences[candidate.Name] = 0
+	}
+	for _, ranking := range parsed {
+		if len(ranking) > 0 {
+			firstPreferences[e.Candidates[ranking[0]].Name]++
+		}
+	}
+
+	outcome := &Outcome{Elected: []string{}}
+	if e.SeatCount() > 1 {
+		report, err := election.CountSTV(e.Candidates, parsed, e.SeatCount(), e.TieBreak, e.ID)
+		if err != nil {
+			return nil, nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+		}
+		outcome.Elected, outcome.STV = report.Elected, report
+		return outcome, firstPreferences, nil
+	}
+
+	report, err := election.TabulateIRV(e.Candidates, parsed, e.TieBreak, e.ID)
+	if err != nil {
+		return nil, nil, blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+	if report.Winner != "" {
+		outcome.Elected = []string{report.Winner}
+	}
+	outcome.IRV = report
+	return outcome, firstPreferences, nil
+}
@@ -0,0 +1,122 @@
+// pkg/smartcontracts/outcome.go
+package smartcontracts
+
+import (
+	"fmt"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts/script"
+)
+
+// ErrCodeScriptFailed is reported when an election's tally script fails or
+// returns an invalid outcome.
+const ErrCodeScriptFailed = "script_failed"
+
+// Outcome is the final result of an election derived from its tally.
+type Outcome struct {
+	Elected  []string                 `json:"elected"`             // Names of the elected candidates
+	IRV      *election.IRVReport      `json:"irv,omitempty"`       // Round-by-round report of single-seat ranked elections
+	STV      *election.STVReport      `json:"stv,omitempty"`       // Transfer log of multi-seat ranked elections
+	Question *election.QuestionResult `json:"question,omitempty"`  // Quorum and threshold evaluation of question contests
+	Contests map[string]*Outcome      `json:"contests,omitempty"`  // Per contest ID, for multi-contest elections
+	WriteIns []election.WriteInGroup  `json:"write_ins,omitempty"` // Grouped write-in names of write-in elections
+
+	Delegation *election.DelegationReport `json:"delegation,omitempty"` // Effective weight per delegate, when voters delegated
+}
+
+// parseTallyScript parses the tally script of an election, if any.
+func parseTallyScript(src string) (*script.Program, error) {
+	if src == "" {
+		return nil, nil
+	}
+	program, err := script.Parse(src)
+	if err != nil {
+		return nil, blockchain.NewExecutionError(ErrCodeInvalidElection, "tally script: %v", err)
+	}
+	return program, nil
+}
+
+// decideOutcome derives the outcome of es from the tallied results, using the
+// election's tally script if it has one. Scripts are given:
+//
+//	votes       map of candidate name to count
+//	candidates  list of candidate names in ballot order
+//	ballots     number of counted ballots
+//	seats       number of seats to fill
+//
+// and must return the list of elected candidate names.
+func decideOutcome(ctx *Context, es *ElectionState, results map[string]int) (*Outcome, error) {
+	if es.TallyScript == nil {
+		return pluralityOutcome(es, results), nil
+	}
+
+	votes := make(script.Map, len(results))
+	for name, count := range results {
+		votes[name] = int64(count)
+	}
+	candidates := make(script.List, len(es.Election.Candidates))
+	for i, candidate := range es.Election.Candidates {
+		candidates[i] = candidate.Name
+	}
+
+	value, steps, err := es.TallyScript.Run(map[string]script.Value{
+		"votes":      votes,
+		"candidates": candidates,
+		"ballots":    int64(len(es.Ballots)),
+		"seats":      int64(es.Election.SeatCount()),
+	}, script.DefaultLimits())
+	ctx.Charge(steps)
+	if err != nil {
+		return nil, blockchain.NewExecutionError(ErrCodeScriptFailed, "%v", err)
+	}
+
+	elected, err := electedFromScript(es, value)
+	if err != nil {
+		return nil, blockchain.NewExecutionError(ErrCodeScriptFailed, "%v", err)
+	}
+	return &Outcome{Elected: elected}, nil
+}
+
+// electedFromScript checks that a script result is a list of distinct
+// candidate names.
+func electedFromScript(es *ElectionState, value script.Value) ([]string, error) {
+	list, ok := value.(script.List)
+	if !ok {
+		return nil, fmt.Errorf("script must return a list of candidate names")
+	}
+
+	known := make(map[string]bool, len(es.Election.Candidates))
+	for _, candidate := range es.Election.Candidates {
+		known[candidate.Name] = true
+	}
+
+	elected := make([]string, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		name, ok := v.(string)
+		if !ok || !known[name] {
+			return nil, fmt.Errorf("script returned %v, which is not a candidate", v)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("script elected %s twice", name)
+		}
+		seen[name] = true
+		elected = append(elected, name)
+	}
+	return elected, nil
+}
+
+// pluralityOutcome elects the candidate with the most votes. Ties go to the
+// candidate listed first on the ballot; nobody is elected without votes.
+func pluralityOutcome(es *ElectionState, results map[string]int) *Outcome {
+	outcome := &Outcome{Elected: []string{}}
+	best := 0
+	for _, candidate := range es.Election.Candidates {
+		if count := results[candidate.Name]; count > best {
+			best = count
+			outcome.Elected = []string{candidate.Name}
+		}
+	}
+	return outcome
+}
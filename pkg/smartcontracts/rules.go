@@ -0,0 +1,150 @@
+// pkg/smartcontracts/rules.go
+package smartcontracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/election"
+)
+
+// Rule module IDs. IDs are versioned: a module's behaviour never changes
+// under an existing ID, since elections commit to it on chain.
+const (
+	RuleOneVotePerVoter = "one-vote-per-voter/v1"
+	RuleLastBallotCount = "last-ballot-counts/v1"
+
+	DefaultRuleModule = RuleOneVotePerVoter
+)
+
+// ErrCodeUnknownRules is reported when an election selects a rule module that
+// is not registered or rejects its parameters.
+const ErrCodeUnknownRules = "unknown_rules"
+
+// RuleModule creates the rules applied to an election from the parameters
+// given at its creation. Modules must be deterministic.
+type RuleModule interface {
+	ID() string
+	Configure(params json.RawMessage) (ElectionRules, error)
+}
+
+// ElectionRules validate ballots and tallies of a single election.
+type ElectionRules interface {
+	// CheckBallot decides whether ballot may be recorded. A ballot by a voter
+	// that has already voted replaces the earlier one if it is accepted.
+	CheckBallot(es *ElectionState, ballot *election.Ballot) error
+	// CheckTally validates proposed results against the recorded ballots.
+	CheckTally(es *ElectionState, results map[string]int) error
+}
+
+// configureRules resolves the rule module selected by e, defaulting to
+// DefaultRuleModule, and checks the election's commitment to it.
+func (c *Context) configureRules(e *election.Election) (ElectionRules, error) {
+	if e.Rules.Module == "" {
+		e.Rules.Module = DefaultRuleModule
+	}
+
+	rulesHash := e.Rules.Hash()
+	if len(e.RulesHash) > 0 && string(e.RulesHash) != string(rulesHash) {
+		return nil, blockchain.NewExecutionError(ErrCodeInvalidElection, "rules hash does not match rule module and parameters")
+	}
+	e.RulesHash = rulesHash
+
+	if e.Rules.Module == RuleLastBallotCount && !e.HasCredentials() {
+		return nil, blockchain.NewExecutionError(ErrCodeInvalidElection, "the %s rule module requires voter credentials", RuleLastBallotCount)
+	}
+	module, ok := c.modules[e.Rules.Module]
+	if !ok {
+		return nil, blockchain.NewExecutionError(ErrCodeUnknownRules, "rule module %q is not registered", e.Rules.Module)
+	}
+	rules, err := module.Configure(e.Rules.Params)
+	if err != nil {
+		return nil, blockchain.NewExecutionError(ErrCodeUnknownRules, "rule module %s: %v", module.ID(), err)
+	}
+	return rules, nil
+}
+
+// checkCandidateResults verifies that results only name candidates of the
+// election, with counts that cast ballots (or their weight) can produce, and
+// returns their sum.
+func checkCandidateResults(es *ElectionState, results map[string]int, cast int) (int, error) {
+	candidates := make(map[string]bool, len(es.Election.Candidates))
+	for _, candidate := range es.Election.Candidates {
+		candidates[candidate.Name] = true
+	}
+
+	// Iterate in sorted order so the reported error is deterministic
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	total := 0
+	for _, name := range names {
+		if !candidates[name] || results[name] < 0 {
+			return 0, fmt.Errorf("invalid result for %q", name)
+		}
+		if results[name] > cast*es.Election.MaxCellValue() {
+			return 0, fmt.Errorf("result for %q exceeds what %d ballots can give a candidate", name, cast)
+		}
+		total += results[name]
+	}
+	return total, nil
+}
+
+// oneVotePerVoter accepts a single ballot per voter.
+type oneVotePerVoter struct{}
+
+func (oneVotePerVoter) ID() string { return RuleOneVotePerVoter }
+
+func (m oneVotePerVoter) Configure(params json.RawMessage) (ElectionRules, error) {
+	if len(params) > 0 && string(params) != "null" && string(params) != "{}" {
+		return nil, fmt.Errorf("takes no parameters")
+	}
+	return m, nil
+}
+
+func (oneVotePerVoter) CheckBallot(es *ElectionState, ballot *election.Ballot) error {
+	if es.HasVoted(ballot.VoterID) {
+		return blockchain.NewExecutionError(ErrCodeDuplicateVote, "voter %s has already voted", ballot.VoterID)
+	}
+	return nil
+}
+
+func (oneVotePerVoter) CheckTally(es *ElectionState, results map[string]int) error {
+	cast := es.CastWeight()
+	total, err := checkCandidateResults(es, results, cast)
+	if err != nil {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "%v", err)
+	}
+	if _, max := es.Election.SelectionBounds(); total > cast*max {
+		return blockchain.NewExecutionError(ErrCodeInvalidTally, "tally counts %d votes but only %d ballots were cast", total, cast)
+	}
+	return nil
+}
+
+// lastBallotCounts lets voters supersede their ballot until voting closes, so
+// a coerced voter can later cast the ballot that counts. Elections using it
+// must have voter credentials: every ballot then carries its credential's
+// nullifier and is signed by it, so only the credential holder can supersede
+// a ballot. Superseded ballots are dropped from the state, which only keeps
+// their number; their transactions remain on chain, so revoting is visible.
+type lastBallotCounts struct {
+	oneVotePerVoter
+}
+
+func (lastBallotCounts) ID() string { return RuleLastBallotCount }
+
+func (m lastBallotCounts) Configure(params json.RawMessage) (ElectionRules, error) {
+	if _, err := m.oneVotePerVoter.Configure(params); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (lastBallotCounts) CheckBallot(es *ElectionState, ballot *election.Ballot) error {
+	return nil
+}
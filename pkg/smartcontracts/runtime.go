@@ -0,0 +1,168 @@
+// pkg/smartcontracts/runtime.go
+package smartcontracts
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+)
+
+// Handler validates a transaction against the current state and applies it.
+// Handlers must be deterministic and must not mutate state before all of
+// their checks have passed, so that a rejected transaction leaves no trace.
+type Handler func(ctx *Context, tx *blockchain.Transaction) error
+
+// Context carries what a handler may use while executing a transaction.
+type Context struct {
+	State      *State
+	Block      *blockchain.Block
+	ElectionID string // Election affected by the transaction, reported in its receipt
+	cost       uint64
+	modules    map[string]RuleModule
+}
+
+// BlockTime returns the timestamp of the block being applied. Handlers use it
+// instead of the wall clock so every node reaches the same result.
+func (c *Context) BlockTime() time.Time {
+	return time.Unix(c.Block.Timestamp, 0)
+}
+
+// Charge adds units to the execution cost reported in the receipt.
+func (c *Context) Charge(units uint64) {
+	c.cost += units
+}
+
+// DecodePayload unmarshals the transaction payload into v, reporting decode
+// failures as invalid payloads, or invalid encodings if a point or scalar
+// in it is malformed.
+func (c *Context) DecodePayload(tx *blockchain.Transaction, v interface{}) error {
+	if err := json.Unmarshal(tx.Payload, v); err != nil {
+		var decodeErr *crypto.DecodeError
+		if errors.As(err, &decodeErr) {
+			return blockchain.NewExecutionError(blockchain.ErrCodeInvalidEncoding, "decode payload: %v", err)
+		}
+		return blockchain.NewExecutionError(blockchain.ErrCodeInvalidPayload, "decode payload: %v", err)
+	}
+	return nil
+}
+
+// Runtime routes each transaction to the handler registered for its type. It
+// implements blockchain.Executor so nodes invoke it when applying blocks.
+type Runtime struct {
+	mu       sync.RWMutex
+	handlers map[blockchain.TransactionType]Handler
+	modules  map[string]RuleModule
+	state    *State
+}
+
+// NewRuntime returns a runtime with the election contract handlers and the
+// built-in rule modules registered.
+func NewRuntime() *Runtime {
+	r := &Runtime{
+		handlers: make(map[blockchain.TransactionType]Handler),
+		modules:  make(map[string]RuleModule),
+		state:    NewState(),
+	}
+
+	r.Register(blockchain.TxCreateElection, handleCreateElection)
+	r.Register(blockchain.TxCastVote, handleCastVote)
+	r.Register(blockchain.TxTallyVotes, handleTallyVotes)
+	r.Register(blockchain.TxDelegateVote, handleDelegateVote)
+	r.Register(blockchain.TxMixBallots, handleMixBallots)
+	r.Register(blockchain.TxChallengeBallot, handleChallengeBallot)
+	r.Register(blockchain.TxProposeResults, handleProposeResults)
+	r.Register(blockchain.TxCertifyResults, handleCertifyResults)
+	r.Register(blockchain.TxReleaseKey, handleReleaseKey)
+
+	r.RegisterRuleModule(oneVotePerVoter{})
+	r.RegisterRuleModule(lastBallotCounts{})
+
+	return r
+}
+
+// Register sets the handler for txType, replacing any existing one.
+func (r *Runtime) Register(txType blockchain.TransactionType, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[txType] = handler
+}
+
+// RegisterRuleModule makes module selectable by elections under its ID. All
+// nodes of a network must register the same modules.
+func (r *Runtime) RegisterRuleModule(module RuleModule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.modules[module.ID()] = module
+}
+
+func (r *Runtime) Execute(tx *blockchain.Transaction, block *blockchain.Block) *blockchain.Receipt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	handler, ok := r.handlers[tx.Type]
+	if !ok {
+		return blockchain.NewReceipt(tx, block, "", 0,
+			blockchain.NewExecutionError(blockchain.ErrCodeUnknownType, "no handler for transaction type %q", tx.Type))
+	}
+
+	ctx := &Context{State: r.state, Block: block, modules: r.modules}
+	err := handler(ctx, tx)
+	return blockchain.NewReceipt(tx, block, ctx.ElectionID, ctx.cost, err)
+}
+
+func (r *Runtime) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = NewState()
+}
+
+// Election returns a snapshot of the state of the election with the given ID,
+// which callers may read without holding the runtime's lock.
+func (r *Runtime) Election(id string) (*ElectionState, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	es, ok := r.state.Elections[id]
+	if !ok {
+		return nil, false
+	}
+	return es.snapshot(), true
+}
+
+// RestoreElection decodes the state of an election served by a node and
+// rebuilds what is not serialized with it, using the rule modules of r, so
+// clients can propose, sign or certify its results.
+func (r *Runtime) RestoreElection(data []byte) (*ElectionState, error) {
+	var es ElectionState
+	if err := json.Unmarshal(data, &es); err != nil {
+		return nil, err
+	}
+	if es.Election == nil {
+		return nil, errors.New("election state has no election")
+	}
+
+	r.mu.RLock()
+	ctx := &Context{modules: r.modules}
+	r.mu.RUnlock()
+
+	rules, err := ctx.configureRules(es.Election)
+	if err != nil {
+		return nil, err
+	}
+	if es.TallyScript, err = parseTallyScript(es.Election.Rules.Script); err != nil {
+		return nil, err
+	}
+	es.Rules = rules
+	es.Voters = make(map[string]int, len(es.Ballots))
+	for i, ballot := range es.Ballots {
+		es.Voters[ballot.VoterID] = i
+	}
+	return &es, nil
+}
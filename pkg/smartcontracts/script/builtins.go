@@ -0,0 +1,706 @@
+// pkg/smartcontracts/script/builtins.go
+package script
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var builtins map[string]*builtin
+
+func init() {
+	fns := map[string]func(m *machine, args []Value) (Value, error){
+		// Arithmetic on int64, failing on overflow instead of wrapping
+		"+":   builtinAdd,
+		"-":   builtinSub,
+		"*":   builtinMul,
+		"/":   builtinDiv,
+		"mod": builtinMod,
+		"min": builtinMin,
+		"max": builtinMax,
+
+		// Comparison and logic
+		"=":   builtinEqual,
+		"!=":  builtinNotEqual,
+		"<":   compareInts(func(a, b int64) bool { return a < b }),
+		"<=":  compareInts(func(a, b int64) bool { return a <= b }),
+		">":   compareInts(func(a, b int64) bool { return a > b }),
+		">=":  compareInts(func(a, b int64) bool { return a >= b }),
+		"not": builtinNot,
+
+		// Lists
+		"list":     builtinList,
+		"len":      builtinLen,
+		"nth":      builtinNth,
+		"concat":   builtinConcat,
+		"range":    builtinRange,
+		"sum":      builtinSum,
+		"reverse":  builtinReverse,
+		"take":     builtinTake,
+		"drop":     builtinDrop,
+		"contains": builtinContains,
+		"map":      builtinMap,
+		"filter":   builtinFilter,
+		"reduce":   builtinReduce,
+		"sort-by":  builtinSortBy,
+
+		// Maps
+		"get":  builtinGet,
+		"has":  builtinHas,
+		"keys": builtinKeys,
+		"put":  builtinPut,
+
+		"error": builtinError,
+	}
+
+	builtins = make(map[string]*builtin, len(fns))
+	for name, fn := range fns {
+		builtins[name] = &builtin{name: name, fn: fn}
+	}
+}
+
+func arity(args []Value, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("takes %d arguments, got %d", n, len(args))
+	}
+	return nil
+}
+
+func toInt(v Value) (int64, error) {
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expected integer, got %s", typeName(v))
+	}
+	return i, nil
+}
+
+func toInts(args []Value) ([]int64, error) {
+	ints := make([]int64, len(args))
+	for i, arg := range args {
+		v, err := toInt(arg)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+	return ints, nil
+}
+
+func toList(v Value) (List, error) {
+	l, ok := v.(List)
+	if !ok {
+		return nil, fmt.Errorf("expected list, got %s", typeName(v))
+	}
+	return l, nil
+}
+
+func toMap(v Value) (Map, error) {
+	mp, ok := v.(Map)
+	if !ok {
+		return nil, fmt.Errorf("expected map, got %s", typeName(v))
+	}
+	return mp, nil
+}
+
+var errOverflow = fmt.Errorf("integer overflow")
+
+func addInt(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, errOverflow
+	}
+	return a + b, nil
+}
+
+func mulInt(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	c := a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, errOverflow
+	}
+	return c, nil
+}
+
+func builtinAdd(m *machine, args []Value) (Value, error) {
+	ints, err := toInts(args)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, i := range ints {
+		if total, err = addInt(total, i); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+func builtinSub(m *machine, args []Value) (Value, error) {
+	ints, err := toInts(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ints) == 0 {
+		return nil, fmt.Errorf("takes at least 1 argument")
+	}
+	if len(ints) == 1 {
+		return mulInt(ints[0], -1)
+	}
+	result := ints[0]
+	for _, i := range ints[1:] {
+		if i == math.MinInt64 {
+			return nil, errOverflow
+		}
+		if result, err = addInt(result, -i); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func builtinMul(m *machine, args []Value) (Value, error) {
+	ints, err := toInts(args)
+	if err != nil {
+		return nil, err
+	}
+	result := int64(1)
+	for _, i := range ints {
+		if result, err = mulInt(result, i); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func divArgs(args []Value) (int64, int64, error) {
+	if err := arity(args, 2); err != nil {
+		return 0, 0, err
+	}
+	ints, err := toInts(args)
+	if err != nil {
+		return 0, 0, err
+	}
+	if ints[1] == 0 {
+		return 0, 0, fmt.Errorf("division by zero")
+	}
+	if ints[0] == math.MinInt64 && ints[1] == -1 {
+		return 0, 0, errOverflow
+	}
+	return ints[0], ints[1], nil
+}
+
+// builtinDiv divides integers, truncating toward zero.
+func builtinDiv(m *machine, args []Value) (Value, error) {
+	a, b, err := divArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return a / b, nil
+}
+
+func builtinMod(m *machine, args []Value) (Value, error) {
+	a, b, err := divArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return a % b, nil
+}
+
+func builtinMin(m *machine, args []Value) (Value, error) {
+	return extremum(args, func(a, b int64) bool { return a < b })
+}
+
+func builtinMax(m *machine, args []Value) (Value, error) {
+	return extremum(args, func(a, b int64) bool { return a > b })
+}
+
+func extremum(args []Value, better func(a, b int64) bool) (Value, error) {
+	if len(args) == 1 {
+		// A single list argument is expanded
+		if l, ok := args[0].(List); ok {
+			args = l
+		}
+	}
+	ints, err := toInts(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ints) == 0 {
+		return nil, fmt.Errorf("takes at least 1 value")
+	}
+	result := ints[0]
+	for _, i := range ints[1:] {
+		if better(i, result) {
+			result = i
+		}
+	}
+	return result, nil
+}
+
+// valuesEqual compares a and b deeply, charging a step for every value it
+// visits.
+func (m *machine) valuesEqual(a, b Value) (bool, error) {
+	if err := m.charge(1); err != nil {
+		return false, err
+	}
+	switch x := a.(type) {
+	case List:
+		y, ok := b.(List)
+		if !ok || len(x) != len(y) {
+			return false, nil
+		}
+		for i := range x {
+			if eq, err := m.valuesEqual(x[i], y[i]); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case Map:
+		y, ok := b.(Map)
+		if !ok || len(x) != len(y) {
+			return false, nil
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok {
+				return false, nil
+			}
+			if eq, err := m.valuesEqual(v, w); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case int64, bool, string:
+		return a == b, nil
+	}
+	return false, nil
+}
+
+func builtinEqual(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	eq, err := m.valuesEqual(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return eq, nil
+}
+
+func builtinNotEqual(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	eq, err := m.valuesEqual(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return !eq, nil
+}
+
+func compareInts(cmp func(a, b int64) bool) func(m *machine, args []Value) (Value, error) {
+	return func(m *machine, args []Value) (Value, error) {
+		if err := arity(args, 2); err != nil {
+			return nil, err
+		}
+		ints, err := toInts(args)
+		if err != nil {
+			return nil, err
+		}
+		return cmp(ints[0], ints[1]), nil
+	}
+}
+
+func builtinNot(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	b, ok := args[0].(bool)
+	if !ok {
+		return nil, fmt.Errorf("expected boolean, got %s", typeName(args[0]))
+	}
+	return !b, nil
+}
+
+func builtinList(m *machine, args []Value) (Value, error) {
+	if err := m.charge(uint64(len(args))); err != nil {
+		return nil, err
+	}
+	return List(append([]Value{}, args...)), nil
+}
+
+func builtinLen(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case List:
+		return int64(len(v)), nil
+	case Map:
+		return int64(len(v)), nil
+	case string:
+		return int64(len(v)), nil
+	}
+	return nil, fmt.Errorf("expected list, map or string, got %s", typeName(args[0]))
+}
+
+func builtinNth(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	i, err := toInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= int64(len(l)) {
+		return nil, fmt.Errorf("index %d out of range for list of length %d", i, len(l))
+	}
+	return l[i], nil
+}
+
+func builtinConcat(m *machine, args []Value) (Value, error) {
+	result := List{}
+	for _, arg := range args {
+		l, err := toList(arg)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.charge(uint64(len(l))); err != nil {
+			return nil, err
+		}
+		result = append(result, l...)
+	}
+	return result, nil
+}
+
+// builtinRange returns the integers [0, n) or [a, b).
+func builtinRange(m *machine, args []Value) (Value, error) {
+	ints, err := toInts(args)
+	if err != nil {
+		return nil, err
+	}
+	var from, to int64
+	switch len(ints) {
+	case 1:
+		to = ints[0]
+	case 2:
+		from, to = ints[0], ints[1]
+	default:
+		return nil, fmt.Errorf("takes 1 or 2 arguments, got %d", len(ints))
+	}
+	if to <= from {
+		return List{}, nil
+	}
+	if err := m.charge(uint64(to - from)); err != nil {
+		return nil, err
+	}
+	result := make(List, 0, to-from)
+	for i := from; i < to; i++ {
+		result = append(result, i)
+	}
+	return result, nil
+}
+
+func builtinSum(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.charge(uint64(len(l))); err != nil {
+		return nil, err
+	}
+	return builtinAdd(m, l)
+}
+
+func builtinReverse(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.charge(uint64(len(l))); err != nil {
+		return nil, err
+	}
+	result := make(List, len(l))
+	for i, v := range l {
+		result[len(l)-1-i] = v
+	}
+	return result, nil
+}
+
+func sliceArgs(args []Value) (List, int64, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, 0, err
+	}
+	n, err := toInt(args[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > int64(len(l)) {
+		n = int64(len(l))
+	}
+	return l, n, nil
+}
+
+// builtinTake returns the first n elements: (take n list).
+func builtinTake(m *machine, args []Value) (Value, error) {
+	l, n, err := sliceArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.charge(uint64(n)); err != nil {
+		return nil, err
+	}
+	return append(List{}, l[:n]...), nil
+}
+
+// builtinDrop returns all but the first n elements: (drop n list).
+func builtinDrop(m *machine, args []Value) (Value, error) {
+	l, n, err := sliceArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.charge(uint64(int64(len(l)) - n)); err != nil {
+		return nil, err
+	}
+	return append(List{}, l[n:]...), nil
+}
+
+func builtinContains(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range l {
+		eq, err := m.valuesEqual(v, args[1])
+		if err != nil {
+			return nil, err
+		}
+		if eq {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// builtinMap applies f to every element: (map f list).
+func builtinMap(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, err
+	}
+	result := make(List, len(l))
+	for i, v := range l {
+		if result[i], err = m.call(args[0], []Value{v}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// builtinFilter keeps the elements for which f returns true: (filter f list).
+func builtinFilter(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, err
+	}
+	result := List{}
+	for _, v := range l {
+		keep, err := m.call(args[0], []Value{v})
+		if err != nil {
+			return nil, err
+		}
+		b, ok := keep.(bool)
+		if !ok {
+			return nil, fmt.Errorf("predicate must return a boolean, got %s", typeName(keep))
+		}
+		if b {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// builtinReduce folds the list from the left: (reduce f init list) calls
+// (f acc element) for each element.
+func builtinReduce(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 3); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[2])
+	if err != nil {
+		return nil, err
+	}
+	acc := args[1]
+	for _, v := range l {
+		if acc, err = m.call(args[0], []Value{acc, v}); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// builtinSortBy sorts the list stably in ascending order of the integer key
+// returned by f: (sort-by f list).
+func builtinSortBy(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	l, err := toList(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	type keyed struct {
+		key   int64
+		value Value
+	}
+	items := make([]keyed, len(l))
+	for i, v := range l {
+		k, err := m.call(args[0], []Value{v})
+		if err != nil {
+			return nil, err
+		}
+		key, err := toInt(k)
+		if err != nil {
+			return nil, fmt.Errorf("sort key: %w", err)
+		}
+		items[i] = keyed{key, v}
+	}
+	if err := m.charge(uint64(len(items))); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].key < items[j].key })
+
+	result := make(List, len(items))
+	for i, item := range items {
+		result[i] = item.value
+	}
+	return result, nil
+}
+
+// builtinGet looks up a map key: (get m key [default]). Without a default, a
+// missing key is an error.
+func builtinGet(m *machine, args []Value) (Value, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, fmt.Errorf("takes 2 or 3 arguments, got %d", len(args))
+	}
+	mp, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("map key must be a string, got %s", typeName(args[1]))
+	}
+	if v, ok := mp[key]; ok {
+		return v, nil
+	}
+	if len(args) == 3 {
+		return args[2], nil
+	}
+	return nil, fmt.Errorf("key %q not found", key)
+}
+
+func builtinHas(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 2); err != nil {
+		return nil, err
+	}
+	mp, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("map key must be a string, got %s", typeName(args[1]))
+	}
+	_, found := mp[key]
+	return found, nil
+}
+
+// builtinKeys returns the keys of a map in sorted order.
+func builtinKeys(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	mp, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := m.charge(uint64(len(mp))); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(mp))
+	for k := range mp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make(List, len(keys))
+	for i, k := range keys {
+		result[i] = k
+	}
+	return result, nil
+}
+
+// builtinPut returns a copy of the map with key set: (put m key value).
+func builtinPut(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 3); err != nil {
+		return nil, err
+	}
+	mp, err := toMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("map key must be a string, got %s", typeName(args[1]))
+	}
+	if err := m.charge(uint64(len(mp) + 1)); err != nil {
+		return nil, err
+	}
+	result := make(Map, len(mp)+1)
+	for k, v := range mp {
+		result[k] = v
+	}
+	result[key] = args[2]
+	return result, nil
+}
+
+// builtinError aborts the script with a message: (error "reason").
+func builtinError(m *machine, args []Value) (Value, error) {
+	if err := arity(args, 1); err != nil {
+		return nil, err
+	}
+	msg, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("message must be a string")
+	}
+	return nil, fmt.Errorf("%s", msg)
+}
@@ -0,0 +1,311 @@
+// pkg/smartcontracts/script/interp.go
+package script
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Value is a script value: int64, bool, string, List, Map or a function.
+type Value interface{}
+
+// List is an ordered list of values.
+type List []Value
+
+// Map is a string keyed map. Functions that enumerate it do so in sorted key
+// order, so results never depend on Go's map iteration order.
+type Map map[string]Value
+
+type lambda struct {
+	params []string
+	body   []*node
+	env    *scope
+}
+
+type builtin struct {
+	name string
+	fn   func(m *machine, args []Value) (Value, error)
+}
+
+// Limits bound the resources a single run may use.
+type Limits struct {
+	MaxSteps uint64 // Evaluation steps, including one per element allocated
+	MaxDepth int    // Nested function calls
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxSteps: 1_000_000,
+		MaxDepth: 128,
+	}
+}
+
+// ErrStepLimit is returned when a run exceeds Limits.MaxSteps.
+var ErrStepLimit = errors.New("script step limit exceeded")
+
+// Run evaluates the program with the given global bindings and returns the
+// value of its last expression together with the number of steps used.
+func (p *Program) Run(globals map[string]Value, limits Limits) (Value, uint64, error) {
+	m := &machine{limits: limits}
+	env := &scope{vars: make(map[string]Value)}
+	for name, v := range builtins {
+		env.vars[name] = v
+	}
+	for name, v := range globals {
+		env.vars[name] = v
+	}
+
+	var result Value
+	for _, form := range p.forms {
+		v, err := m.eval(form, env)
+		if err != nil {
+			return nil, m.steps, err
+		}
+		result = v
+	}
+	return result, m.steps, nil
+}
+
+type scope struct {
+	vars   map[string]Value
+	parent *scope
+}
+
+func (s *scope) lookup(name string) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+type machine struct {
+	limits Limits
+	steps  uint64
+	depth  int
+}
+
+// charge consumes n steps of the budget.
+func (m *machine) charge(n uint64) error {
+	if n > m.limits.MaxSteps-m.steps {
+		m.steps = m.limits.MaxSteps
+		return ErrStepLimit
+	}
+	m.steps += n
+	return nil
+}
+
+func (m *machine) eval(n *node, env *scope) (Value, error) {
+	if err := m.charge(1); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case n.isSym:
+		v, ok := env.lookup(n.sym)
+		if !ok {
+			return nil, fmt.Errorf("line %d: undefined symbol %s", n.line, n.sym)
+		}
+		return v, nil
+	case !n.isLst:
+		return n.atom, nil
+	case len(n.list) == 0:
+		return nil, fmt.Errorf("line %d: empty expression", n.line)
+	}
+
+	if head := n.list[0]; head.isSym {
+		if form, ok := specialForms[head.sym]; ok {
+			return form(m, n, env)
+		}
+	}
+
+	fn, err := m.eval(n.list[0], env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, len(n.list)-1)
+	for i, arg := range n.list[1:] {
+		if args[i], err = m.eval(arg, env); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := m.call(fn, args)
+	if err != nil && !errors.Is(err, ErrStepLimit) {
+		return nil, fmt.Errorf("line %d: %w", n.line, err)
+	}
+	return v, err
+}
+
+func (m *machine) call(fn Value, args []Value) (Value, error) {
+	switch f := fn.(type) {
+	case *builtin:
+		v, err := f.fn(m, args)
+		if err != nil && !errors.Is(err, ErrStepLimit) {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		return v, err
+	case *lambda:
+		if len(args) != len(f.params) {
+			return nil, fmt.Errorf("function takes %d arguments, got %d", len(f.params), len(args))
+		}
+		if m.depth >= m.limits.MaxDepth {
+			return nil, fmt.Errorf("call depth limit of %d exceeded", m.limits.MaxDepth)
+		}
+		m.depth++
+		defer func() { m.depth-- }()
+
+		env := &scope{vars: make(map[string]Value, len(args)), parent: f.env}
+		for i, param := range f.params {
+			env.vars[param] = args[i]
+		}
+		return m.evalBody(f.body, env)
+	default:
+		return nil, fmt.Errorf("%s is not a function", typeName(fn))
+	}
+}
+
+func (m *machine) evalBody(body []*node, env *scope) (Value, error) {
+	var result Value
+	for _, n := range body {
+		v, err := m.eval(n, env)
+		if err != nil {
+			return nil, err
+		}
+		result = v
+	}
+	return result, nil
+}
+
+type specialForm func(m *machine, n *node, env *scope) (Value, error)
+
+var specialForms map[string]specialForm
+
+func init() {
+	specialForms = map[string]specialForm{
+		"define": evalDefine,
+		"let":    evalLet,
+		"lambda": evalLambda,
+		"if":     evalIf,
+		"and":    evalAnd,
+		"or":     evalOr,
+		"do": func(m *machine, n *node, env *scope) (Value, error) {
+			return m.evalBody(n.list[1:], env)
+		},
+	}
+}
+
+// (define name expr) binds name in the current scope.
+func evalDefine(m *machine, n *node, env *scope) (Value, error) {
+	if len(n.list) != 3 || !n.list[1].isSym {
+		return nil, fmt.Errorf("line %d: expected (define name expr)", n.line)
+	}
+	v, err := m.eval(n.list[2], env)
+	if err != nil {
+		return nil, err
+	}
+	env.vars[n.list[1].sym] = v
+	return v, nil
+}
+
+// (let ((name expr) ...) body...) binds names in order, each binding seeing
+// the previous ones.
+func evalLet(m *machine, n *node, env *scope) (Value, error) {
+	if len(n.list) < 3 || !n.list[1].isLst {
+		return nil, fmt.Errorf("line %d: expected (let ((name expr) ...) body)", n.line)
+	}
+	inner := &scope{vars: make(map[string]Value), parent: env}
+	for _, binding := range n.list[1].list {
+		if !binding.isLst || len(binding.list) != 2 || !binding.list[0].isSym {
+			return nil, fmt.Errorf("line %d: invalid let binding", binding.line)
+		}
+		v, err := m.eval(binding.list[1], inner)
+		if err != nil {
+			return nil, err
+		}
+		inner.vars[binding.list[0].sym] = v
+	}
+	return m.evalBody(n.list[2:], inner)
+}
+
+// (lambda (params...) body...) creates a closure over the current scope.
+func evalLambda(m *machine, n *node, env *scope) (Value, error) {
+	if len(n.list) < 3 || !n.list[1].isLst {
+		return nil, fmt.Errorf("line %d: expected (lambda (params) body)", n.line)
+	}
+	params := make([]string, len(n.list[1].list))
+	for i, p := range n.list[1].list {
+		if !p.isSym {
+			return nil, fmt.Errorf("line %d: lambda parameters must be symbols", n.line)
+		}
+		params[i] = p.sym
+	}
+	return &lambda{params: params, body: n.list[2:], env: env}, nil
+}
+
+// (if cond then else)
+func evalIf(m *machine, n *node, env *scope) (Value, error) {
+	if len(n.list) != 4 {
+		return nil, fmt.Errorf("line %d: expected (if cond then else)", n.line)
+	}
+	cond, err := m.eval(n.list[1], env)
+	if err != nil {
+		return nil, err
+	}
+	b, ok := cond.(bool)
+	if !ok {
+		return nil, fmt.Errorf("line %d: if condition must be a boolean, got %s", n.line, typeName(cond))
+	}
+	if b {
+		return m.eval(n.list[2], env)
+	}
+	return m.eval(n.list[3], env)
+}
+
+func evalAnd(m *machine, n *node, env *scope) (Value, error) {
+	return evalLogic(m, n, env, false)
+}
+
+func evalOr(m *machine, n *node, env *scope) (Value, error) {
+	return evalLogic(m, n, env, true)
+}
+
+// evalLogic evaluates operands left to right, stopping at the first one equal
+// to stop.
+func evalLogic(m *machine, n *node, env *scope, stop bool) (Value, error) {
+	for _, operand := range n.list[1:] {
+		v, err := m.eval(operand, env)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("line %d: logical operand must be a boolean, got %s", n.line, typeName(v))
+		}
+		if b == stop {
+			return stop, nil
+		}
+	}
+	return !stop, nil
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case int64:
+		return "integer"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case List:
+		return "list"
+	case Map:
+		return "map"
+	case *lambda, *builtin:
+		return "function"
+	case nil:
+		return "nil"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,168 @@
+// pkg/smartcontracts/script/parser.go
+
+// Package script implements a small deterministic language for custom tally
+// rules. Scripts are s-expressions over integers, booleans, strings, lists
+// and maps; they have no access to I/O, time or randomness, every evaluation
+// step is metered, and integer arithmetic fails on overflow, so the same
+// script over the same inputs yields the same result on every node.
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxSourceBytes bounds the size of a script accepted by Parse.
+const MaxSourceBytes = 16 * 1024
+
+// node is a parsed expression: an atom or a parenthesized list.
+type node struct {
+	atom  Value  // Literal value for number, boolean and string atoms
+	sym   string // Symbol name, when the node is a symbol
+	list  []*node
+	isSym bool
+	isLst bool
+	line  int
+}
+
+// Program is a parsed script, ready to be run any number of times.
+type Program struct {
+	forms []*node
+}
+
+// Parse parses src into a program without running it.
+func Parse(src string) (*Program, error) {
+	if len(src) > MaxSourceBytes {
+		return nil, fmt.Errorf("script is %d bytes, maximum is %d", len(src), MaxSourceBytes)
+	}
+
+	p := &parser{tokens: tokenize(src)}
+	var forms []*node
+	for p.pos < len(p.tokens) {
+		n, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, n)
+	}
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("script is empty")
+	}
+	return &Program{forms: forms}, nil
+}
+
+type token struct {
+	text string
+	line int
+}
+
+func tokenize(src string) []token {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == ';':
+			// Comment until end of line
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{string(c), line})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				j = len(src) - 1
+			}
+			tokens = append(tokens, token{src[i : j+1], line})
+			i = j + 1
+		default:
+			j := i
+			for j < len(src) && !strings.ContainsRune(" \t\r\n();\"", rune(src[j])) {
+				j++
+			}
+			tokens = append(tokens, token{src[i:j], line})
+			i = j
+		}
+	}
+	return tokens
+}
+
+// maxNesting bounds the nesting depth of parenthesized expressions.
+const maxNesting = 64
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) parse(depth int) (*node, error) {
+	if depth > maxNesting {
+		return nil, fmt.Errorf("expressions nested deeper than %d", maxNesting)
+	}
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of script")
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.text {
+	case "(":
+		n := &node{isLst: true, line: tok.line}
+		for {
+			if p.pos >= len(p.tokens) {
+				return nil, fmt.Errorf("line %d: unclosed parenthesis", tok.line)
+			}
+			if p.tokens[p.pos].text == ")" {
+				p.pos++
+				return n, nil
+			}
+			child, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			n.list = append(n.list, child)
+		}
+	case ")":
+		return nil, fmt.Errorf("line %d: unexpected ')'", tok.line)
+	}
+
+	return parseAtom(tok)
+}
+
+func parseAtom(tok token) (*node, error) {
+	text := tok.text
+	switch {
+	case text == "true":
+		return &node{atom: true, line: tok.line}, nil
+	case text == "false":
+		return &node{atom: false, line: tok.line}, nil
+	case strings.HasPrefix(text, "\""):
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid string %s", tok.line, text)
+		}
+		return &node{atom: s, line: tok.line}, nil
+	case text[0] == '-' && len(text) > 1 || text[0] >= '0' && text[0] <= '9':
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid integer %s", tok.line, text)
+		}
+		return &node{atom: i, line: tok.line}, nil
+	}
+	return &node{sym: text, isSym: true, line: tok.line}, nil
+}
@@ -0,0 +1,337 @@
+// pkg/smartcontracts/state.go
+package smartcontracts
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts/script"
+)
+
+type ElectionStatus string
+
+const (
+	ElectionCreated   ElectionStatus = "created"
+	ElectionTallied   ElectionStatus = "tallied"
+	ElectionCertified ElectionStatus = "certified" // Tallied and signed by a quorum of officials
+)
+
+// State is the application state derived by applying the chain's
+// transactions in order.
+type State struct {
+	Elections map[string]*ElectionState
+}
+
+func NewState() *State {
+	return &State{
+		Elections: make(map[string]*ElectionState),
+	}
+}
+
+// ElectionState is the on-chain state of a single election.
+type ElectionState struct {
+	Election       *election.Election           `json:"election"`
+	Status         ElectionStatus               `json:"status"`
+	Ballots        []*election.Ballot           `json:"ballots"`              // Counted ballots, at most one per voter
+	Voters         map[string]int               `json:"-"`                    // Index into Ballots by voter ID
+	Superseded     int                          `json:"superseded,omitempty"` // Number of ballots replaced by a later ballot of the same voter
+	Results        map[string]int               `json:"results,omitempty"`
+	ContestResults map[string]map[string]int    `json:"contest_results,omitempty"` // Per contest ID, for multi-contest elections
+	Outcome        *Outcome                     `json:"outcome,omitempty"`
+	Delegations    map[string]map[string]string `json:"delegations,omitempty"`  // Delegate per delegator ID, per topic: a contest ID, or "" for the whole election
+	Challenged     []*election.BallotAudit      `json:"challenged,omitempty"`   // Ballots opened by their voters instead of being cast, never counted
+	Mixed          [][]*crypto.Ciphertext       `json:"mixed,omitempty"`        // Output of the latest mixing step, one row per ballot
+	MixedBy        []string                     `json:"mixed_by,omitempty"`     // Mix servers that have shuffled the ballots, in order
+	Certificate    *crypto.MultiSignature       `json:"certificate,omitempty"`  // Officials' signatures on the proposed results, see ResultsDigest
+	ReleasedKey    *big.Int                     `json:"released_key,omitempty"` // Private key of a time-locked election, once its beacon round is released
+	Rules          ElectionRules                `json:"-"`                      // Configured from Election.Rules at creation
+
+	TallyScript *script.Program `json:"-"` // Parsed from Election.Rules.Script, if set
+}
+
+// snapshot copies the state that handlers update in place, so the copy can
+// be read while later transactions execute. Ballots, audits and the
+// election itself are never modified once recorded and stay shared.
+func (es *ElectionState) snapshot() *ElectionState {
+	s := *es
+	s.Ballots = append([]*election.Ballot(nil), es.Ballots...)
+	s.Voters = make(map[string]int, len(es.Voters))
+	for voterID, i := range es.Voters {
+		s.Voters[voterID] = i
+	}
+	if es.Delegations != nil {
+		s.Delegations = make(map[string]map[string]string, len(es.Delegations))
+		for topic, delegations := range es.Delegations {
+			s.Delegations[topic] = make(map[string]string, len(delegations))
+			for delegator, delegate := range delegations {
+				s.Delegations[topic][delegator] = delegate
+			}
+		}
+	}
+	s.Challenged = append([]*election.BallotAudit(nil), es.Challenged...)
+	s.MixedBy = append([]string(nil), es.MixedBy...)
+	if es.Certificate != nil {
+		s.Certificate = &crypto.MultiSignature{Signers: append([]byte(nil), es.Certificate.Signers...), Signature: es.Certificate.Signature}
+	}
+	return &s
+}
+
+// HasVoted reports whether a ballot of voterID has been recorded.
+func (es *ElectionState) HasVoted(voterID string) bool {
+	_, ok := es.Voters[voterID]
+	return ok
+}
+
+// RecordBallot stores ballot, replacing an earlier ballot of the same voter
+// in its place, so only the latest ballot of each voter is counted. This
+// does not hide who revoted: both cast_vote transactions stay on chain under
+// the same voter ID and nullifier.
+func (es *ElectionState) RecordBallot(ballot *election.Ballot) {
+	if i, ok := es.Voters[ballot.VoterID]; ok {
+		es.Ballots[i] = ballot
+		es.Superseded++
+		return
+	}
+	es.Voters[ballot.VoterID] = len(es.Ballots)
+	es.Ballots = append(es.Ballots, ballot)
+}
+
+// CastWeight returns the total registry weight of the recorded ballots, which
+// is their number in unweighted elections, plus the weight delegated to their
+// voters.
+func (es *ElectionState) CastWeight() int {
+	cast := es.ResolveDelegations(es.Election.WeightOf).Delegated
+	for _, ballot := range es.Ballots {
+		cast += es.Election.WeightOf(ballot.VoterID)
+	}
+	return cast
+}
+
+// IsChallenged reports whether the ballot with the given tracker has been
+// challenged.
+func (es *ElectionState) IsChallenged(tracker string) bool {
+	for _, audit := range es.Challenged {
+		if audit.Tracker == tracker {
+			return true
+		}
+	}
+	return false
+}
+
+// CastTrackers returns the trackers of the counted ballots, in their order.
+func (es *ElectionState) CastTrackers() []string {
+	trackers := make([]string, 0, len(es.Ballots))
+	for _, ballot := range es.Ballots {
+		if tracker, err := election.BallotTracker(ballot); err == nil {
+			trackers = append(trackers, tracker)
+		}
+	}
+	return trackers
+}
+
+// MixInput returns the rows the next mix server shuffles: the output of the
+// previous step, or the rows of the counted ballots for the first.
+func (es *ElectionState) MixInput() ([][]*crypto.Ciphertext, error) {
+	if len(es.MixedBy) > 0 {
+		return es.Mixed, nil
+	}
+	return es.Election.MixRows(es.Ballots)
+}
+
+// MixPending reports whether the ballots of a mixed election still await a
+// mix server.
+func (es *ElectionState) MixPending() bool {
+	return es.Election.Mix != nil && len(es.Ballots) > 0 && len(es.MixedBy) < len(es.Election.Mix.Servers)
+}
+
+// KeyLocked reports whether the election's key is time-locked and not yet
+// released.
+func (es *ElectionState) KeyLocked() bool {
+	return es.Election.TimeLock != nil && es.ReleasedKey == nil
+}
+
+// TallyCiphertexts returns the ciphertexts whose decryptions the proven
+// results of an election are derived from, and the largest value they may
+// hold: the per-candidate totals of plurality, approval and score ballots,
+// at their voters' effective weights, followed by the write-in cell of
+// every mixed write-in row; the mixed cells of ranked ballots; or the totals
+// of every contest in turn.
+func (es *ElectionState) TallyCiphertexts() ([]*crypto.Ciphertext, int64, error) {
+	e := es.Election
+	if e.IsMultiContest() {
+		var cts []*crypto.Ciphertext
+		var max int64
+		for i := range e.Contests {
+			c := &e.Contests[i]
+			if c.BallotType == election.BallotRanked {
+				return nil, 0, fmt.Errorf("ranked contest %s is decrypted only once mixed", c.ID)
+			}
+			totals, contestMax, err := es.contestState(c).scoreTotals(func(ballot *election.Ballot) *election.ScoreBallot {
+				return election.ContestSections([]*election.Ballot{ballot}, c.ID)[0].Scores
+			})
+			if err != nil {
+				return nil, 0, fmt.Errorf("contest %s: %v", c.ID, err)
+			}
+			cts = append(cts, totals...)
+			if contestMax > max {
+				max = contestMax
+			}
+		}
+		return cts, max, nil
+	}
+
+	switch e.BallotType {
+	case election.BallotPlurality, election.BallotApproval, election.BallotScore:
+		cts, max, err := es.scoreTotals(func(ballot *election.Ballot) *election.ScoreBallot { return ballot.Scores })
+		if err != nil || !e.WriteIn {
+			return cts, max, err
+		}
+		if e.Mix == nil || es.MixPending() {
+			return nil, 0, errors.New("write-ins are decrypted only once mixed")
+		}
+		for _, row := range es.Mixed {
+			cts = append(cts, row[0])
+		}
+		return cts, max, nil
+	case election.BallotRanked:
+		if e.Mix == nil || es.MixPending() {
+			return nil, 0, errors.New("ranked ballots are decrypted only once mixed")
+		}
+		var cells []*crypto.Ciphertext
+		for _, row := range es.Mixed {
+			cells = append(cells, row...)
+		}
+		return cells, 1, nil
+	}
+	return nil, 0, fmt.Errorf("results of %s elections cannot be proven", e.BallotType)
+}
+
+// scoreTotals adds up the score ballots of the counted ballots, as picked by
+// scores, per candidate at their voters' effective weights. It also returns
+// the largest value a total may hold.
+func (es *ElectionState) scoreTotals(scores func(*election.Ballot) *election.ScoreBallot) ([]*crypto.Ciphertext, int64, error) {
+	e := es.Election
+	ballots := make(map[string]*election.ScoreBallot, len(es.Ballots))
+	for _, ballot := range es.Ballots {
+		s := scores(ballot)
+		if s == nil {
+			return nil, 0, fmt.Errorf("ballot of voter %s has no scores", ballot.VoterID)
+		}
+		ballots[ballot.VoterID] = s
+	}
+	totals := e.TallyDelegatedScores(ballots, es.ResolveDelegations(e.WeightOf))
+	return totals, int64(es.CastWeight() * e.MaxCellValue()), nil
+}
+
+// ResultsDigest returns the digest officials sign to certify the tallied
+// results and outcome of the election.
+func (es *ElectionState) ResultsDigest() ([]byte, error) {
+	if es.Status == ElectionCreated {
+		return nil, fmt.Errorf("election %s has not been tallied", es.Election.ID)
+	}
+	data, err := json.Marshal(struct {
+		ElectionID     string                    `json:"election_id"`
+		Results        map[string]int            `json:"results"`
+		ContestResults map[string]map[string]int `json:"contest_results,omitempty"`
+		Outcome        *Outcome                  `json:"outcome"`
+	}{es.Election.ID, es.Results, es.ContestResults, es.Outcome})
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// Certifiers returns the IDs of the officials who signed the results.
+func (es *ElectionState) Certifiers() []string {
+	var ids []string
+	if es.Certificate == nil || es.Election.Certification == nil {
+		return ids
+	}
+	for i, official := range es.Election.Certification.Officials {
+		if es.Certificate.HasSigned(i) {
+			ids = append(ids, official.ID)
+		}
+	}
+	return ids
+}
+
+// ResolveDelegations resolves the whole-election delegations against the
+// recorded ballots, weighing voters with weight.
+func (es *ElectionState) ResolveDelegations(weight func(string) int) *election.DelegationReport {
+	return election.ResolveDelegations(es.Delegations[""], es.HasVoted, weight)
+}
+
+// delegationsFor returns the delegations in effect for a topic: those made
+// for the whole election, overridden by those made for the topic itself.
+func (es *ElectionState) delegationsFor(topic string) map[string]string {
+	if topic == "" {
+		return es.Delegations[""]
+	}
+	delegations := make(map[string]string, len(es.Delegations[""])+len(es.Delegations[topic]))
+	for delegator, delegate := range es.Delegations[""] {
+		delegations[delegator] = delegate
+	}
+	for delegator, delegate := range es.Delegations[topic] {
+		delegations[delegator] = delegate
+	}
+	return delegations
+}
+
+// contestState returns the state of a single contest of a multi-contest
+// election: its view as an election, the ballots whose style includes it and
+// the delegations in effect for it.
+func (es *ElectionState) contestState(c *election.Contest) *ElectionState {
+	cs := &ElectionState{
+		Election:    es.Election.ContestElection(c),
+		Status:      es.Status,
+		Voters:      make(map[string]int),
+		Rules:       es.Rules,
+		TallyScript: es.TallyScript,
+	}
+	for _, ballot := range es.Ballots {
+		if len(election.ContestSections([]*election.Ballot{ballot}, c.ID)) > 0 {
+			cs.RecordBallot(ballot)
+		}
+	}
+	// Ranked rankings are tallied without voter links, so weight cannot move
+	if c.BallotType != election.BallotRanked {
+		delegations := make(map[string]string)
+		for delegator, delegate := range es.delegationsFor(c.ID) {
+			if es.Election.HasContest(delegator, c.ID) {
+				delegations[delegator] = delegate
+			}
+		}
+		if len(delegations) > 0 {
+			cs.Delegations = map[string]map[string]string{"": delegations}
+		}
+	}
+	return cs
+}
+
+// delegationCycle reports whether delegating from delegator to delegate for
+// topic would close a cycle in the delegations in effect for any contest.
+// A whole-election delegation is in effect for every contest where the
+// delegator has not delegated separately.
+func (es *ElectionState) delegationCycle(topic, delegator, delegate string) bool {
+	if election.DelegationCycle(es.delegationsFor(topic), delegator, delegate) {
+		return true
+	}
+	if topic != "" {
+		return false
+	}
+	for _, c := range es.Election.Contests {
+		if _, overridden := es.Delegations[c.ID][delegator]; overridden {
+			continue
+		}
+		if election.DelegationCycle(es.delegationsFor(c.ID), delegator, delegate) {
+			return true
+		}
+	}
+	return false
+}
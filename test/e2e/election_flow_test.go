@@ -2,22 +2,37 @@ package e2e
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/koushamad/election-system/pkg/blockchain"
 	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
 	"github.com/koushamad/election-system/test/utils"
 	"testing"
 	"time"
 )
 
+// castVote creates a proven plurality ballot of voterID for candidate
+func castVote(e *election.Election, voterID, candidate string) (*election.Ballot, error) {
+	for i, c := range e.Candidates {
+		if c.Name == candidate {
+			ballot, _, err := election.NewPluralityBallot(e, voterID, i)
+			return ballot, err
+		}
+	}
+	return nil, fmt.Errorf("candidate '%s' not found", candidate)
+}
+
 func TestFullElectionFlow(t *testing.T) {
 	// Initialize blockchain
 	node := utils.SetupTestNode()
 
 	// Step 1: Create election
-	electionData, adminKeys := utils.CreateTestElection(
+	electionData, electionKeys := utils.CreateTestElection(
 		"Presidential Election 2025",
 		[]string{"Alice", "Bob", "Charlie"},
 	)
+	electionData.StartTime = time.Now().Add(-1 * time.Hour)
+	electionData.EndTime = time.Now().Add(2 * time.Second)
 
 	electionTx, err := utils.CreateElectionTransaction(electionData)
 	if err != nil {
@@ -46,14 +61,11 @@ func TestFullElectionFlow(t *testing.T) {
 
 	// Cast votes
 	for _, voter := range voters {
-		ballot, err := utils.CreateTestVote(electionData, voter.Candidate)
+		ballot, err := castVote(electionData, voter.ID, voter.Candidate)
 		if err != nil {
 			t.Fatalf("Failed to create vote for %s: %v", voter.Candidate, err)
 		}
 
-		// Override voter ID for test
-		ballot.VoterID = voter.ID
-
 		voteTx, err := utils.CreateVoteTransaction(electionData.ID, ballot)
 		if err != nil {
 			t.Fatalf("Failed to create vote transaction: %v", err)
@@ -73,8 +85,7 @@ func TestFullElectionFlow(t *testing.T) {
 
 	// Step 3: Test double voting prevention
 	duplicateVoter := voters[0] // Try to vote again with voter1
-	ballot, _ := utils.CreateTestVote(electionData, duplicateVoter.Candidate)
-	ballot.VoterID = duplicateVoter.ID
+	ballot, _ := castVote(electionData, duplicateVoter.ID, duplicateVoter.Candidate)
 
 	voteTx, _ := utils.CreateVoteTransaction(electionData.ID, ballot)
 	node.TransactionPool = append(node.TransactionPool, voteTx)
@@ -82,12 +93,9 @@ func TestFullElectionFlow(t *testing.T) {
 	// Create another block
 	node.CreateBlock()
 
-	// In a proper implementation, the duplicate vote should be rejected
-	// This test would need to be updated when proper validation is implemented
+	// The contract rejects the duplicate vote, which the tally below confirms
 
-	// Step 4: Tally votes (homomorphically)
-	// In a real implementation, this would use the homomorphic properties
-	// For this test, we'll manually count
+	// Step 4: Count the ballots recorded on the chain
 
 	// Extract votes from blockchain
 	votes := make(map[string]int)
@@ -107,8 +115,7 @@ func TestFullElectionFlow(t *testing.T) {
 					continue
 				}
 
-				// In a real implementation, we would decrypt the vote
-				// For this test, we'll just count the ballots
+				// The ballots stay encrypted, so only count voters
 				votes[voteData.Ballot.VoterID]++
 			}
 		}
@@ -119,39 +126,44 @@ func TestFullElectionFlow(t *testing.T) {
 		t.Errorf("Expected 5 unique voters, got %d", len(votes))
 	}
 
-	// Step 5: Create tally transaction
-	tallyResult := struct {
-		ElectionID string         `json:"election_id"`
-		Results    map[string]int `json:"results"`
-		Timestamp  int64          `json:"timestamp"`
-	}{
-		ElectionID: electionData.ID,
-		Results: map[string]int{
-			"Alice":   3,
-			"Bob":     1,
-			"Charlie": 1,
-		},
-		Timestamp: time.Now().Unix(),
+	// Step 5: Propose the decrypted tally once voting has closed
+	time.Sleep(3 * time.Second)
+	runtime := node.Executor.(*smartcontracts.Runtime)
+	state, ok := runtime.Election(electionData.ID)
+	if !ok {
+		t.Fatalf("Election %s not found in contract state", electionData.ID)
 	}
-
-	tallyJSON, _ := json.Marshal(tallyResult)
-	tallyTx := &blockchain.Transaction{
-		Type:    "tally_votes",
-		Payload: tallyJSON,
+	proposal, err := smartcontracts.NewProposalPayload(state, electionKeys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	tallyTx, err := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	if err != nil {
+		t.Fatalf("Failed to create proposal transaction: %v", err)
 	}
-	tallyTx.Hash = tallyTx.CalculateHash()
 
 	node.TransactionPool = append(node.TransactionPool, tallyTx)
 	node.CreateBlock()
 
+	if receipt, ok := node.Receipt(tallyTx.ID); !ok || receipt.Status != blockchain.ReceiptApplied {
+		t.Fatalf("Expected the proposal to be applied, got %+v", receipt)
+	}
+	state, _ = runtime.Election(electionData.ID)
+	expected := map[string]int{"Alice": 3, "Bob": 1, "Charlie": 1}
+	for name, count := range expected {
+		if state.Results[name] != count {
+			t.Errorf("Expected %d votes for %s, got %v", count, name, state.Results)
+		}
+	}
+
 	// Verify tally block
 	tallyBlock := node.Chain.Blocks[4]
 	if len(tallyBlock.Transactions) != 1 {
-		t.Errorf("Expected 1 tally transaction, got %d", len(tallyBlock.Transactions))
+		t.Errorf("Expected 1 proposal transaction, got %d", len(tallyBlock.Transactions))
 	}
 
 	// Verify final blockchain state
-	if len(node.Chain.Blocks) != 5 { // Genesis + election + votes + duplicate attempt + tally
+	if len(node.Chain.Blocks) != 5 { // Genesis + election + votes + duplicate attempt + proposal
 		t.Errorf("Expected 5 blocks in final chain, got %d", len(node.Chain.Blocks))
 	}
 
@@ -0,0 +1,141 @@
+package integration
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/network"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestBallotAudit(t *testing.T) {
+	e, keys := utils.CreateTestElection("Audited Election", []string{"Alice", "Bob", "Carol"})
+
+	plurality, opening, err := election.NewPluralityBallot(e, "voter-1", 1)
+	if err != nil {
+		t.Fatalf("Failed to create ballot: %v", err)
+	}
+	audit, err := e.AuditBallot(plurality, opening)
+	if err != nil {
+		t.Fatalf("Expected the opening to verify: %v", err)
+	}
+	if !reflect.DeepEqual(audit.Selections, []string{"Bob"}) {
+		t.Errorf("Expected a vote for Bob, got %v", audit.Selections)
+	}
+
+	// A device that lies about the encrypted choice is caught
+	lie := &election.BallotOpening{Values: []int64{1}, Randomness: opening.Randomness}
+	if _, err := e.AuditBallot(plurality, lie); err == nil {
+		t.Error("Expected an opening revealing another choice to fail")
+	}
+
+	ranked := *e
+	ranked.BallotType = election.BallotRanked
+	cells, rankedOpening, err := election.NewRankedBallotWithOpening(keys.PublicKey, e.ID, "voter-1", 3, []int{2, 0})
+	if err != nil {
+		t.Fatalf("Failed to create ranked ballot: %v", err)
+	}
+	audit, err = ranked.AuditBallot(&election.Ballot{VoterID: "voter-1", Type: election.BallotRanked, Ranked: cells}, rankedOpening)
+	if err != nil || !reflect.DeepEqual(audit.Selections, []string{"Carol", "Alice"}) {
+		t.Errorf("Expected the ranking Carol,Alice, got %v (%v)", audit, err)
+	}
+
+	score := *e
+	score.BallotType, score.MaxScore = election.BallotScore, 5
+	scores, scoreOpening, err := election.NewScoreBallotWithOpening(&score, "voter-1", []int{4, 0, 2})
+	if err != nil {
+		t.Fatalf("Failed to create score ballot: %v", err)
+	}
+	scoreBallot := &election.Ballot{VoterID: "voter-1", Type: election.BallotScore, Scores: scores}
+	audit, err = score.AuditBallot(scoreBallot, scoreOpening)
+	if err != nil || !reflect.DeepEqual(audit.Selections, []string{"Alice=4", "Carol=2"}) {
+		t.Errorf("Expected Alice=4,Carol=2, got %v (%v)", audit, err)
+	}
+	tracker, _ := election.BallotTracker(scoreBallot)
+	if audit.Tracker != tracker || len(tracker) != 64 {
+		t.Errorf("Expected the audit to carry the ballot tracker, got %q", audit.Tracker)
+	}
+
+	// A partial opening proves nothing
+	partial := &election.BallotOpening{Values: scoreOpening.Values[:2], Randomness: []*big.Int{scoreOpening.Randomness[0], scoreOpening.Randomness[1]}}
+	if _, err := score.AuditBallot(scoreBallot, partial); err == nil {
+		t.Error("Expected a partial opening to be rejected")
+	}
+}
+
+func TestChallengeBallotContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	server := network.NewServer(node, 0)
+
+	e, _ := utils.CreateApprovalElection("Challenge Election", []string{"Alice", "Bob"}, 1, 1)
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	encrypt := func(voterID string, values []int) (*election.Ballot, *election.BallotOpening) {
+		scores, opening, err := election.NewScoreBallotWithOpening(e, voterID, values)
+		if err != nil {
+			t.Fatalf("Failed to create ballot: %v", err)
+		}
+		return &election.Ballot{VoterID: voterID, Type: election.BallotApproval, Scores: scores}, opening
+	}
+
+	// voter-1 challenges a first ballot, then casts a fresh one
+	challenged, opening := encrypt("voter-1", []int{1, 0})
+	cast, castOpening := encrypt("voter-1", []int{1, 0})
+	forged, _ := encrypt("voter-2", []int{0, 1})
+	challengeTx, _ := blockchain.NewTransaction(blockchain.TxChallengeBallot, smartcontracts.ChallengePayload{ElectionID: e.ID, Ballot: challenged, Opening: opening})
+	forgedTx, _ := blockchain.NewTransaction(blockchain.TxChallengeBallot, smartcontracts.ChallengePayload{ElectionID: e.ID, Ballot: forged, Opening: opening})
+	castTx, _ := utils.CreateVoteTransaction(e.ID, cast)
+	node.TransactionPool = append(node.TransactionPool, challengeTx, forgedTx, castTx)
+	node.CreateBlock()
+	expectReceipt(t, node, challengeTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, forgedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidChallenge)
+	expectReceipt(t, node, castTx, blockchain.ReceiptApplied, "")
+
+	// Challenged ballots are never counted, and cast ballots cannot be opened
+	recastTx, _ := utils.CreateVoteTransaction(e.ID, challenged)
+	lateChallengeTx, _ := blockchain.NewTransaction(blockchain.TxChallengeBallot, smartcontracts.ChallengePayload{ElectionID: e.ID, Ballot: cast, Opening: castOpening})
+	node.TransactionPool = append(node.TransactionPool, recastTx, lateChallengeTx)
+	node.CreateBlock()
+	expectReceipt(t, node, recastTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+	expectReceipt(t, node, lateChallengeTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidChallenge)
+
+	req, _ := http.NewRequest("GET", "/elections/"+e.ID+"/audit", nil)
+	rr := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the audit endpoint to respond, got %d", rr.Code)
+	}
+	var audit network.ElectionAudit
+	if err := json.Unmarshal(rr.Body.Bytes(), &audit); err != nil {
+		t.Fatalf("Failed to parse audit: %v", err)
+	}
+
+	castTracker, _ := election.BallotTracker(cast)
+	challengedTracker, _ := election.BallotTracker(challenged)
+	if !reflect.DeepEqual(audit.Cast, []string{castTracker}) {
+		t.Errorf("Expected the cast tracker %s, got %v", castTracker, audit.Cast)
+	}
+	if len(audit.Challenged) != 1 || audit.Challenged[0].Tracker != challengedTracker {
+		t.Fatalf("Expected the challenged ballot %s, got %+v", challengedTracker, audit.Challenged)
+	}
+
+	// Anyone can check the published opening against the election key
+	checked, err := e.AuditBallot(audit.Challenged[0].Ballot, audit.Challenged[0].Opening)
+	if err != nil || !reflect.DeepEqual(checked.Selections, []string{"Alice"}) {
+		t.Errorf("Expected the challenged ballot to verify as a vote for Alice, got %v (%v)", checked, err)
+	}
+}
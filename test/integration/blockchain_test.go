@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/koushamad/election-system/pkg/blockchain"
 	"github.com/koushamad/election-system/test/utils"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -559,3 +560,266 @@ func TestTransactionValidation(t *testing.T) {
 		t.Log("Warning: Invalid transaction was accepted. Implement proper validation.")
 	}
 }
+
+func TestReplayProtection(t *testing.T) {
+	node1 := utils.SetupTestNode()
+	node2 := utils.SetupTestNode()
+
+	election, _ := utils.CreateTestElection("Replay Election", []string{"Alice", "Bob"})
+	tx, _ := utils.CreateElectionTransaction(election)
+
+	if err := node1.AddTransaction(tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+	block := node1.CreateBlock()
+	if err := node2.AddBlock(block); err != nil {
+		t.Fatalf("Failed to add block to node2: %v", err)
+	}
+
+	// Replaying an included transaction must fail on every node, even though
+	// the pending pools are empty
+	if err := node1.AddTransaction(tx); err == nil {
+		t.Error("Expected replayed transaction to be rejected by node1")
+	}
+	if err := node2.AddTransaction(tx); err == nil {
+		t.Error("Expected replayed transaction to be rejected by node2")
+	}
+
+	// A block that re-includes the transaction must be rejected
+	prev := node1.Chain.Blocks[len(node1.Chain.Blocks)-1]
+	replayBlock := blockchain.NewBlock(prev.Index+1, []*blockchain.Transaction{tx}, prev.Hash, "replayer")
+	if err := node2.AddBlock(replayBlock); err == nil {
+		t.Error("Expected block replaying a transaction to be rejected")
+	}
+
+	// Transactions bound to another network are rejected
+	foreignTx, _ := blockchain.NewChainTransaction("other-network", 0, blockchain.TxCreateElection, election)
+	if err := node1.AddTransaction(foreignTx); err == nil {
+		t.Error("Expected transaction for another chain to be rejected")
+	}
+
+	// A sender cannot reuse a nonce once it has been included
+	sender := mustBLSKeys(t)
+	signedTx, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, 1, blockchain.TxCreateElection, election)
+	signedTx.Sign(sender)
+	if err := node1.AddTransaction(signedTx); err != nil {
+		t.Fatalf("Failed to add signed transaction: %v", err)
+	}
+	node1.CreateBlock()
+	if next := node1.NextNonce(signedTx.Sender()); next != 2 {
+		t.Errorf("Expected next nonce 2 after nonce 1 was included, got %d", next)
+	}
+
+	reusedNonce, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, 1, blockchain.TxCreateElection, election)
+	reusedNonce.Sign(sender)
+	if err := node1.AddTransaction(reusedNonce); err == nil {
+		t.Error("Expected transaction reusing a nonce to be rejected")
+	}
+
+	// Nobody else can use up the sender's nonces
+	forged, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, math.MaxUint64, blockchain.TxCreateElection, election)
+	forged.Sign(mustBLSKeys(t))
+	forged.PublicKey = signedTx.PublicKey
+	forged.Hash = forged.CalculateHash()
+	if err := node1.AddTransaction(forged); err == nil {
+		t.Error("Expected transaction with a forged sender to be rejected")
+	}
+	unsigned, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, math.MaxUint64, blockchain.TxCreateElection, election)
+	unsigned.PublicKey = signedTx.PublicKey
+	unsigned.Hash = unsigned.CalculateHash()
+	if err := node1.AddTransaction(unsigned); err == nil {
+		t.Error("Expected unsigned transaction with a sender to be rejected")
+	}
+	nextNonce, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, 2, blockchain.TxCreateElection, election)
+	nextNonce.Sign(sender)
+	if err := node1.AddTransaction(nextNonce); err != nil {
+		t.Errorf("Expected the sender's next nonce to be accepted, got %v", err)
+	}
+	if next := node1.NextNonce(signedTx.Sender()); next != 3 {
+		t.Errorf("Expected next nonce 3 to follow the pending nonce 2, got %d", next)
+	}
+
+	// The chain index covers blocks appended after the first lookup
+	if !node1.Chain.ContainsTransaction(tx) || node1.Chain.ContainsTransaction(nextNonce) {
+		t.Error("Expected the chain to contain exactly its included transactions")
+	}
+	node1.CreateBlock()
+	if !node1.Chain.ContainsTransaction(nextNonce) {
+		t.Error("Expected a transaction of a later block to be found")
+	}
+
+	// Transactions created in the same instant still get distinct IDs
+	a, _ := blockchain.NewTransaction(blockchain.TxCreateElection, election)
+	b, _ := blockchain.NewTransaction(blockchain.TxCreateElection, election)
+	if a.ID == b.ID || bytes.Equal(a.Hash, b.Hash) {
+		t.Error("Expected transactions created together to have unique IDs and hashes")
+	}
+}
+
+func TestBlockLimits(t *testing.T) {
+	node := utils.SetupTestNode()
+	node.Params.MaxBlockTxs = 3
+
+	election, _ := utils.CreateTestElection("Limits Election", []string{"Alice", "Bob"})
+	var txs []*blockchain.Transaction
+	for i := 0; i < 7; i++ {
+		ballot, _ := utils.CreateTestVote(election, "Alice")
+		voteTx, _ := utils.CreateVoteTransaction(election.ID, ballot)
+		txs = append(txs, voteTx)
+	}
+	node.TransactionPool = append(node.TransactionPool, txs...)
+
+	block := node.CreateBlock()
+	if len(block.Transactions) != 3 {
+		t.Fatalf("Expected 3 transactions in block, got %d", len(block.Transactions))
+	}
+	if len(node.TransactionPool) != 4 {
+		t.Errorf("Expected 4 transactions left in pool, got %d", len(node.TransactionPool))
+	}
+
+	// Selection is deterministic: the block holds the first transactions in
+	// canonical order regardless of pool order
+	sorted := make([]*blockchain.Transaction, len(txs))
+	copy(sorted, txs)
+	blockchain.SortTransactions(sorted, block.PrevHash)
+	for i, tx := range block.Transactions {
+		if !bytes.Equal(tx.Hash, sorted[i].Hash) {
+			t.Errorf("Transaction %d not selected in canonical order", i)
+		}
+	}
+
+	// Peers with the same limits accept the block
+	peer := utils.SetupTestNode()
+	peer.Params = node.Params
+	if err := peer.AddBlock(block); err != nil {
+		t.Fatalf("Peer rejected block within limits: %v", err)
+	}
+
+	// Oversized blocks are rejected
+	prev := peer.Chain.Blocks[len(peer.Chain.Blocks)-1]
+	oversized := blockchain.NewBlock(prev.Index+1, sorted[3:], prev.Hash, "flooder")
+	if err := peer.AddBlock(oversized); err == nil {
+		t.Error("Expected block with too many transactions to be rejected")
+	}
+
+	peer.Params.MaxBlockTxs = 10
+	peer.Params.MaxBlockBytes = sorted[3].Size()
+	tooLarge := blockchain.NewBlock(prev.Index+1, sorted[3:5], prev.Hash, "flooder")
+	if err := peer.AddBlock(tooLarge); err == nil {
+		t.Error("Expected block exceeding the byte limit to be rejected")
+	}
+
+	// Blocks whose transactions are not in canonical order are rejected
+	peer.Params = node.Params
+	next := []*blockchain.Transaction{sorted[3], sorted[4]}
+	blockchain.SortTransactions(next, prev.Hash)
+	unordered := blockchain.NewBlock(prev.Index+1, []*blockchain.Transaction{next[1], next[0]}, prev.Hash, "reorderer")
+	if err := peer.AddBlock(unordered); err == nil {
+		t.Error("Expected block with non-canonical transaction order to be rejected")
+	}
+}
+
+func TestSenderNonceOrder(t *testing.T) {
+	node := utils.SetupTestNode()
+	sender := mustBLSKeys(t)
+
+	// Later nonces are backdated, but must not be ordered before the
+	// sender's earlier ones
+	var txs []*blockchain.Transaction
+	for nonce := uint64(1); nonce <= 3; nonce++ {
+		election, _ := utils.CreateTestElection(fmt.Sprintf("Nonce Election %d", nonce), []string{"Alice", "Bob"})
+		tx, _ := blockchain.NewChainTransaction(blockchain.DefaultChainID, nonce, blockchain.TxCreateElection, election)
+		tx.Timestamp -= int64(nonce) * 1000
+		tx.Sign(sender)
+		txs = append(txs, tx)
+	}
+	node.TransactionPool = append(node.TransactionPool, txs[2], txs[1], txs[0])
+
+	block := node.CreateBlock()
+	if block == nil || len(block.Transactions) != 3 {
+		t.Fatalf("Expected all of the sender's transactions in the block, got %v", block)
+	}
+	for i, tx := range block.Transactions {
+		if tx.Nonce != uint64(i+1) {
+			t.Errorf("Transaction %d has nonce %d, expected nonce order", i, tx.Nonce)
+		}
+	}
+
+	peer := utils.SetupTestNode()
+	if err := peer.AddBlock(block); err != nil {
+		t.Fatalf("Peer rejected block in nonce order: %v", err)
+	}
+}
+
+func TestTransactionReceipts(t *testing.T) {
+	node := utils.SetupTestNode()
+
+	election, _ := utils.CreateTestElection("Receipt Election", []string{"Alice", "Bob"})
+	electionTx, _ := utils.CreateElectionTransaction(election)
+
+	unknownTx, _ := blockchain.NewTransaction("unknown_type", map[string]string{"id": "x"})
+
+	node.TransactionPool = append(node.TransactionPool, electionTx, unknownTx)
+	block := node.CreateBlock()
+
+	if len(block.ReceiptsRoot) == 0 {
+		t.Fatal("Expected block to commit to a receipts root")
+	}
+
+	receipt, ok := node.Receipt(electionTx.ID)
+	if !ok {
+		t.Fatal("Expected receipt for election transaction")
+	}
+	if receipt.Status != blockchain.ReceiptApplied || receipt.ElectionID != election.ID || receipt.BlockIndex != block.Index {
+		t.Errorf("Unexpected receipt for election transaction: %+v", receipt)
+	}
+
+	receipt, ok = node.Receipt(unknownTx.ID)
+	if !ok {
+		t.Fatal("Expected receipt for unknown transaction")
+	}
+	if receipt.Status != blockchain.ReceiptRejected || receipt.ErrorCode != blockchain.ErrCodeUnknownType {
+		t.Errorf("Unexpected receipt for unknown transaction: %+v", receipt)
+	}
+
+	// Peers re-execute the block and accept it when they reach the same root
+	peer := utils.SetupTestNode()
+	if err := peer.AddBlock(block); err != nil {
+		t.Fatalf("Peer rejected block: %v", err)
+	}
+	if _, ok := peer.Receipt(unknownTx.ID); !ok {
+		t.Error("Expected peer to store receipts of applied block")
+	}
+
+	// A block committing to different receipts is rejected
+	other := utils.SetupTestNode()
+	forged := *block
+	forged.ReceiptsRoot = blockchain.MerkleRoot([][]byte{[]byte("forged")})
+	forged.Hash = forged.CalculateHash()
+	if err := other.AddBlock(&forged); err == nil {
+		t.Error("Expected block with mismatched receipts root to be rejected")
+	}
+	if _, ok := other.Receipt(electionTx.ID); ok {
+		t.Error("Expected no receipts to be stored for a rejected block")
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+
+	// Duplicating the odd leaf must not yield the same root
+	if bytes.Equal(blockchain.MerkleRoot([][]byte{a, b, c}), blockchain.MerkleRoot([][]byte{a, b, c, c})) {
+		t.Error("Expected duplicating the last leaf to change the root")
+	}
+
+	// Neither must passing off the inner nodes as leaves
+	left := blockchain.MerkleRoot([][]byte{a, b})
+	right := blockchain.MerkleRoot([][]byte{c})
+	if bytes.Equal(blockchain.MerkleRoot([][]byte{a, b, c}), blockchain.MerkleRoot([][]byte{left, right})) {
+		t.Error("Expected inner nodes and leaves to hash differently")
+	}
+
+	if blockchain.MerkleRoot(nil) != nil {
+		t.Error("Expected no root for no leaves")
+	}
+}
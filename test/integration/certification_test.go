@@ -0,0 +1,352 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/network"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestDecryptionProofs(t *testing.T) {
+	keys := mustKeys(t)
+	ct, _, err := crypto.EncryptValueRandom(keys.PublicKey, 3)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	context := election.DecryptionContext("election-1", 0)
+
+	proof, err := crypto.ProveDecryption(keys.PrivateKey, ct, 3, context)
+	if err != nil {
+		t.Fatalf("Failed to prove decryption: %v", err)
+	}
+	data, _ := json.Marshal(proof)
+	var decoded crypto.DecryptionProof
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode proof: %v", err)
+	}
+	if !crypto.VerifyDecryption(keys.PublicKey, ct, 3, &decoded, context) {
+		t.Fatal("Expected the decryption proof to verify")
+	}
+	if crypto.VerifyDecryption(keys.PublicKey, ct, 4, &decoded, context) {
+		t.Error("Expected the proof not to verify for another value")
+	}
+	if crypto.VerifyDecryption(keys.PublicKey, ct, 3, &decoded, election.DecryptionContext("election-1", 1)) {
+		t.Error("Expected the proof not to verify for another ciphertext slot")
+	}
+
+	// A proof for a false value cannot be made to verify
+	lie, _ := crypto.ProveDecryption(keys.PrivateKey, ct, 4, context)
+	if crypto.VerifyDecryption(keys.PublicKey, ct, 4, lie, context) {
+		t.Error("Expected a proof of a false decryption not to verify")
+	}
+}
+
+func TestResultCertificationContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+	server := network.NewServer(node, 0)
+
+	officials := make([]*crypto.BLSKeyPair, 3)
+	config := &election.CertificationConfig{Quorum: 2}
+	for i := range officials {
+		officials[i] = mustBLSKeys(t)
+		config.Officials = append(config.Officials, election.NewOfficial([]string{"ana", "ben", "cal"}[i], officials[i]))
+	}
+
+	// Unmixed ranked results cannot be proven from the ballots
+	ranked, _ := utils.CreateTestElection("Ranked Election", []string{"Alice", "Bob"})
+	ranked.BallotType = election.BallotRanked
+	ranked.Certification = config
+	rankedTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, ranked)
+
+	e, keys := utils.CreateApprovalElection("Certified Election", []string{"Alice", "Bob"}, 1, 1)
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	e.Certification = config
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, rankedTx, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, rankedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	for i, values := range [][]int{{1, 0}, {0, 1}, {1, 0}} {
+		voterID := []string{"voter-1", "voter-2", "voter-3"}[i]
+		tx, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, e, voterID, values))
+		node.TransactionPool = append(node.TransactionPool, tx)
+	}
+	node.CreateBlock()
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Results are proposed with decryption proofs instead of being tallied
+	es, _ := runtime.Election(e.ID)
+	proposal, err := smartcontracts.NewProposalPayload(es, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	forged := *proposal
+	forged.Values = []int64{1, 2}
+	forgedTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, &forged)
+	tallyTx, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{ElectionID: e.ID, Results: map[string]int{"Alice": 2, "Bob": 1}})
+	node.TransactionPool = append(node.TransactionPool, forgedTx, tallyTx)
+	node.CreateBlock()
+	expectReceipt(t, node, forgedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+	expectReceipt(t, node, tallyTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	proposeTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	node.TransactionPool = append(node.TransactionPool, proposeTx)
+	node.CreateBlock()
+	expectReceipt(t, node, proposeTx, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if es.Status != smartcontracts.ElectionTallied || !reflect.DeepEqual(es.Results, map[string]int{"Alice": 2, "Bob": 1}) {
+		t.Fatalf("Expected proposed results Alice=2 Bob=1, got %s %v", es.Status, es.Results)
+	}
+
+	getResults := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/elections/"+e.ID+"/results", nil)
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := getResults(); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected uncertified results to be withheld, got %d", rr.Code)
+	}
+
+	// Officials sign the results; a wrong key or repeated signature is refused
+	sign := func(official string, index int) *blockchain.Transaction {
+		payload, err := smartcontracts.NewCertificationPayload(es, official, officials[index].PrivateKey)
+		if err != nil {
+			t.Fatalf("Failed to sign results: %v", err)
+		}
+		tx, _ := blockchain.NewTransaction(blockchain.TxCertifyResults, payload)
+		return tx
+	}
+	anaTx, impostorTx, outsiderTx := sign("ana", 0), sign("ben", 2), sign("dan", 0)
+	node.TransactionPool = append(node.TransactionPool, anaTx, impostorTx, outsiderTx)
+	node.CreateBlock()
+	expectReceipt(t, node, anaTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, impostorTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidCertification)
+	expectReceipt(t, node, outsiderTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidCertification)
+
+	repeatTx, calTx := sign("ana", 0), sign("cal", 2)
+	node.TransactionPool = append(node.TransactionPool, repeatTx, calTx)
+	node.CreateBlock()
+	expectReceipt(t, node, repeatTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidCertification)
+	expectReceipt(t, node, calTx, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if es.Status != smartcontracts.ElectionCertified {
+		t.Fatalf("Expected the election to be certified by two of three officials, got %s", es.Status)
+	}
+
+	rr := getResults()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected certified results to be served, got %d", rr.Code)
+	}
+	var results network.ElectionResults
+	if err := json.Unmarshal(rr.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to parse results: %v", err)
+	}
+	if !reflect.DeepEqual(results.Signers, []string{"ana", "cal"}) || !reflect.DeepEqual(results.Outcome.Elected, []string{"Alice"}) {
+		t.Errorf("Expected Alice elected and signed by ana and cal, got %v %v", results.Outcome.Elected, results.Signers)
+	}
+
+	// Anyone can check the signatures against the officials of the election
+	officialKeys, _ := config.Keys()
+	if !results.Certificate.Verify(officialKeys, results.Digest) {
+		t.Error("Expected the certificate to verify over the results digest")
+	}
+}
+
+func TestProvenTallyWithoutCertification(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	// Unmixed ranked results cannot be proven, so they need an explicit
+	// trusted tally
+	ranked, _ := utils.CreateTestElection("Untrusted Ranked", []string{"Alice", "Bob"})
+	ranked.BallotType = election.BallotRanked
+	rankedTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, ranked)
+
+	// Plurality ballots hold one cell per candidate, so their totals can be
+	// proven like those of approval ballots
+	e, keys := utils.CreateTestElection("Proven Election", []string{"Alice", "Bob"})
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, rankedTx, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, rankedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	for _, candidate := range []string{"Bob", "Bob", "Alice"} {
+		ballot, _ := utils.CreateTestVote(e, candidate)
+		tx, _ := utils.CreateVoteTransaction(e.ID, ballot)
+		node.TransactionPool = append(node.TransactionPool, tx)
+	}
+	node.CreateBlock()
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Without the opt-in, tallier-supplied results are refused
+	tallyTx, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{ElectionID: e.ID, Results: map[string]int{"Alice": 3, "Bob": 0}})
+	node.TransactionPool = append(node.TransactionPool, tallyTx)
+	node.CreateBlock()
+	expectReceipt(t, node, tallyTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	es, _ := runtime.Election(e.ID)
+	proposal, err := smartcontracts.NewProposalPayload(es, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	proposeTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	node.TransactionPool = append(node.TransactionPool, proposeTx)
+	node.CreateBlock()
+	expectReceipt(t, node, proposeTx, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if es.Status != smartcontracts.ElectionTallied || !reflect.DeepEqual(es.Results, map[string]int{"Alice": 1, "Bob": 2}) {
+		t.Fatalf("Expected proven results Alice=1 Bob=2, got %s %v", es.Status, es.Results)
+	}
+	if es.Certificate != nil {
+		t.Error("Expected no certificate for an election without officials")
+	}
+}
+
+func TestTrustedTallySettings(t *testing.T) {
+	// Only results that cannot be proven are left to the officials
+	ranked, _ := utils.CreateTestElection("Ranked Election", []string{"Alice", "Bob"})
+	ranked.BallotType = election.BallotRanked
+	if err := ranked.ValidateTally(); err == nil {
+		t.Error("Expected unmixed ranked results to require a trusted tally")
+	}
+	ranked.TrustedTally = true
+	if err := ranked.ValidateTally(); err == nil {
+		t.Error("Expected a trusted tally without officials to be rejected")
+	}
+	utils.TrustTally(t, ranked, 2)
+	if err := ranked.ValidateTally(); err != nil {
+		t.Errorf("Expected a trusted tally signed by officials to be accepted: %v", err)
+	}
+
+	plurality, _ := utils.CreateTestElection("Plurality Election", []string{"Alice", "Bob"})
+	utils.TrustTally(t, plurality, 2)
+	if err := plurality.ValidateTally(); err == nil {
+		t.Error("Expected provable results not to take a trusted tally")
+	}
+
+	// Multi-contest results are proven unless a contest is ranked
+	e, _ := createMultiContestElection()
+	if e.TallyProvable() {
+		t.Error("Expected an unmixed ranked contest to make the results unprovable")
+	}
+	referendum, _ := createReferendum()
+	if !referendum.TallyProvable() {
+		t.Error("Expected question contests to be provable")
+	}
+}
+
+func TestCertifiedMultiContestElection(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys := createReferendum()
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	official := mustBLSKeys(t)
+	e.Certification = &election.CertificationConfig{Officials: []election.Official{election.NewOfficial("ana", official)}, Quorum: 1}
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	for _, voterID := range []string{"voter-1", "voter-2"} {
+		ballot := castContests(t, e, voterID, []string{"amendment", "budget"}, []int{0}, []int{1})
+		tx, _ := utils.CreateVoteTransaction(e.ID, ballot)
+		node.TransactionPool = append(node.TransactionPool, tx)
+	}
+	node.CreateBlock()
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	es, _ := runtime.Election(e.ID)
+	proposeTx := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, proposeTx)
+	node.CreateBlock()
+	expectReceipt(t, node, proposeTx, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if es.ContestResults["budget"]["Roads"] != 2 {
+		t.Fatalf("Expected both votes for roads, got %v", es.ContestResults)
+	}
+	payload, err := smartcontracts.NewCertificationPayload(es, "ana", official.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to sign results: %v", err)
+	}
+	certifyTx, _ := blockchain.NewTransaction(blockchain.TxCertifyResults, payload)
+	node.TransactionPool = append(node.TransactionPool, certifyTx)
+	node.CreateBlock()
+	expectReceipt(t, node, certifyTx, blockchain.ReceiptApplied, "")
+
+	if es, _ := runtime.Election(e.ID); es.Status != smartcontracts.ElectionCertified {
+		t.Errorf("Expected the election to be certified, got %s", es.Status)
+	}
+}
+
+func TestElectionStateEndpoint(t *testing.T) {
+	node := utils.SetupTestNode()
+	server := network.NewServer(node, 0)
+
+	e, keys := utils.CreateTestElection("Served Election", []string{"Alice", "Bob"})
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	official := mustBLSKeys(t)
+	e.Certification = &election.CertificationConfig{Officials: []election.Official{election.NewOfficial("ana", official)}, Quorum: 1}
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+
+	for _, voterID := range []string{"voter-1", "voter-2"} {
+		ballot, _, _ := election.NewPluralityBallot(e, voterID, 1)
+		tx, _ := utils.CreateVoteTransaction(e.ID, ballot)
+		node.TransactionPool = append(node.TransactionPool, tx)
+	}
+	node.CreateBlock()
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Clients rebuild the contract state served by the node to act on it
+	restore := func() *smartcontracts.ElectionState {
+		req, _ := http.NewRequest("GET", "/elections/"+e.ID+"/state", nil)
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected the election state, got %d", rr.Code)
+		}
+		es, err := smartcontracts.NewRuntime().RestoreElection(rr.Body.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to restore election state: %v", err)
+		}
+		return es
+	}
+
+	proposeTx := utils.CreateProposalTransaction(t, restore(), keys)
+	node.TransactionPool = append(node.TransactionPool, proposeTx)
+	node.CreateBlock()
+	expectReceipt(t, node, proposeTx, blockchain.ReceiptApplied, "")
+
+	payload, err := smartcontracts.NewCertificationPayload(restore(), "ana", official.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to sign results: %v", err)
+	}
+	certifyTx, _ := blockchain.NewTransaction(blockchain.TxCertifyResults, payload)
+	node.TransactionPool = append(node.TransactionPool, certifyTx)
+	node.CreateBlock()
+	expectReceipt(t, node, certifyTx, blockchain.ReceiptApplied, "")
+}
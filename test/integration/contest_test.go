@@ -0,0 +1,199 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createMultiContestElection returns a general election with a presidential
+// race on every ballot, a council race in the north and a measure in the
+// south.
+func createMultiContestElection() (*election.Election, *crypto.KeyPair) {
+	e, keys := utils.CreateTestElection("General Election", nil)
+	e.Candidates = nil
+	e.Contests = []election.Contest{
+		{ID: "president", Name: "President", Candidates: []election.Candidate{{ID: "p1", Name: "Alice"}, {ID: "p2", Name: "Bob"}}},
+		{ID: "council", Name: "North Council", BallotType: election.BallotRanked, Seats: 2, Candidates: []election.Candidate{
+			{ID: "c1", Name: "Carol"}, {ID: "c2", Name: "Dave"}, {ID: "c3", Name: "Erin"},
+		}},
+		{ID: "measure", Name: "Measure A", MinSelections: 1, Candidates: []election.Candidate{{ID: "yes", Name: "Yes"}, {ID: "no", Name: "No"}}},
+	}
+	e.Styles = []election.BallotStyle{
+		{ID: "north", Contests: []string{"president", "council"}},
+		{ID: "south", Contests: []string{"president", "measure"}},
+	}
+	e.VoterStyles = map[string]string{"voter-1": "north", "voter-2": "north", "voter-3": "south"}
+	return e, keys
+}
+
+// castContests builds a multi-contest ballot answering contests in order.
+func castContests(t *testing.T, e *election.Election, voterID string, contests []string, choices ...[]int) *election.Ballot {
+	t.Helper()
+
+	ballot := &election.Ballot{VoterID: voterID, Type: election.BallotMultiContest}
+	for i, contestID := range contests {
+		section, err := e.NewContestSection(contestID, voterID, choices[i])
+		if err != nil {
+			t.Fatalf("Failed to encrypt %s for %s: %v", contestID, voterID, err)
+		}
+		ballot.Contests = append(ballot.Contests, *section)
+	}
+	return ballot
+}
+
+func TestMultiContestBallots(t *testing.T) {
+	e, keys := createMultiContestElection()
+	if err := e.ValidateContests(); err != nil {
+		t.Fatalf("Expected valid contests: %v", err)
+	}
+
+	north := castContests(t, e, "voter-1", []string{"president", "council"}, []int{0}, []int{2, 0})
+	south := castContests(t, e, "voter-3", []string{"president", "measure"}, []int{1}, []int{0})
+	if err := e.VerifySections("voter-1", north.Contests); err != nil {
+		t.Fatalf("Expected north ballot to verify: %v", err)
+	}
+	if err := e.VerifySections("voter-3", south.Contests); err != nil {
+		t.Fatalf("Expected south ballot to verify: %v", err)
+	}
+
+	// Sections must follow the voter's ballot style
+	if err := e.VerifySections("voter-3", north.Contests); err == nil {
+		t.Error("Expected a ballot of another style to be rejected")
+	}
+	if err := e.VerifySections("voter-9", north.Contests); err == nil {
+		t.Error("Expected a voter without a ballot style to be rejected")
+	}
+	if err := e.VerifySections("voter-1", north.Contests[:1]); err == nil {
+		t.Error("Expected a ballot missing a contest to be rejected")
+	}
+
+	// Proofs are bound to their contest: a section cannot answer another one
+	swapped := []election.ContestSection{south.Contests[0], south.Contests[0]}
+	swapped[1].ContestID = "measure"
+	if err := e.VerifySections("voter-3", swapped); err == nil {
+		t.Error("Expected a section moved to another contest to be rejected")
+	}
+
+	// A blank presidential vote is allowed, but the measure requires a choice
+	if _, err := e.NewContestSection("president", "voter-2", nil); err != nil {
+		t.Errorf("Expected a blank plurality section to be allowed: %v", err)
+	}
+	if _, err := e.NewContestSection("measure", "voter-3", nil); err == nil {
+		t.Error("Expected a blank section to be rejected when a selection is required")
+	}
+
+	totals, err := e.TallyContest("president", []*election.Ballot{north, south})
+	if err != nil {
+		t.Fatalf("Failed to tally contest: %v", err)
+	}
+	for i, expected := range []int64{1, 1} {
+		count, err := crypto.DecryptValue(keys.PrivateKey, totals[i], 2)
+		if err != nil || count != expected {
+			t.Errorf("Expected %d votes for president candidate %d, got %d (%v)", expected, i, count, err)
+		}
+	}
+	if _, err := e.TallyContest("council", []*election.Ballot{north, south}); err == nil {
+		t.Error("Expected ranked contests to require decrypted rankings")
+	}
+
+	invalid := *e
+	invalid.Styles = []election.BallotStyle{{ID: "north", Contests: []string{"president", "mayor"}}}
+	if err := invalid.ValidateContests(); err == nil {
+		t.Error("Expected a style listing an unknown contest to be rejected")
+	}
+}
+
+func TestMultiContestElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	// The ranked council race is not mixed, so its officials sign the tally
+	e, _ := createMultiContestElection()
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	officials := utils.TrustTally(t, e, 2)
+
+	invalid := *e
+	invalid.ID = "invalid-contests"
+	invalid.VoterStyles = map[string]string{"voter-1": "east"}
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	invalidTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &invalid)
+	node.TransactionPool = append(node.TransactionPool, createTx, invalidTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, invalidTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	ballots := []*election.Ballot{
+		castContests(t, e, "voter-1", []string{"president", "council"}, []int{0}, []int{0, 1}),
+		castContests(t, e, "voter-2", []string{"president", "council"}, []int{0}, []int{1, 2}),
+		castContests(t, e, "voter-3", []string{"president", "measure"}, []int{1}, []int{0}),
+	}
+	var voteTxs []*blockchain.Transaction
+	for _, ballot := range ballots {
+		tx, _ := utils.CreateVoteTransaction(e.ID, ballot)
+		voteTxs = append(voteTxs, tx)
+	}
+	unassigned := castContests(t, e, "voter-1", []string{"president", "council"}, []int{0}, []int{0})
+	unassigned.VoterID = "voter-9"
+	unassignedTx, _ := utils.CreateVoteTransaction(e.ID, unassigned)
+
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, unassignedTx)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, unassignedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	contests := map[string]smartcontracts.ContestTally{
+		"president": {Results: map[string]int{"Alice": 2, "Bob": 1}},
+		"council":   {Rankings: [][]string{{"Carol", "Dave"}, {"Dave", "Erin"}}},
+	}
+	incomplete, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{
+		ElectionID: e.ID,
+		Contests:   contests,
+	})
+	node.TransactionPool = append(node.TransactionPool, incomplete)
+	node.CreateBlock()
+	expectReceipt(t, node, incomplete, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	// Only one southern ballot answered the measure
+	contests["measure"] = smartcontracts.ContestTally{Results: map[string]int{"Yes": 2}}
+	inflated, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{
+		ElectionID: e.ID,
+		Contests:   contests,
+	})
+	node.TransactionPool = append(node.TransactionPool, inflated)
+	node.CreateBlock()
+	expectReceipt(t, node, inflated, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	contests["measure"] = smartcontracts.ContestTally{Results: map[string]int{"Yes": 1, "No": 0}}
+	es, _ := runtime.Election(e.ID)
+	tally := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{Contests: contests}, officials)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if es.Outcome == nil || len(es.Outcome.Contests) != 3 {
+		t.Fatalf("Expected outcomes for three contests, got %+v", es.Outcome)
+	}
+	if elected := es.Outcome.Contests["president"].Elected; len(elected) != 1 || elected[0] != "Alice" {
+		t.Errorf("Expected Alice to win the presidency, got %v", elected)
+	}
+	if council := es.Outcome.Contests["council"]; council.STV == nil || len(council.Elected) != 2 {
+		t.Errorf("Expected two council seats filled by STV, got %+v", council)
+	}
+	if elected := es.Outcome.Contests["measure"].Elected; len(elected) != 1 || elected[0] != "Yes" {
+		t.Errorf("Expected the measure to pass, got %v", elected)
+	}
+}
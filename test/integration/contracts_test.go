@@ -0,0 +1,273 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestContractRuntime(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	// Election that has already closed, so it can be tallied right away
+	closed, keys := utils.CreateTestElection("Closed Election", []string{"Alice", "Bob"})
+	closed.StartTime = time.Now().Add(-2 * time.Hour)
+	closed.EndTime = time.Now().Add(-1 * time.Hour)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, closed)
+	duplicateTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, closed)
+	duplicateTx.Timestamp = createTx.Timestamp + 1 // Ordered after createTx
+	duplicateTx.Hash = duplicateTx.CalculateHash()
+	invalid := *closed
+	invalid.ID = "invalid-election"
+	invalid.Candidates = invalid.Candidates[:1]
+	invalidTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &invalid)
+
+	node.TransactionPool = append(node.TransactionPool, createTx, duplicateTx, invalidTx)
+	node.CreateBlock()
+
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, duplicateTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeElectionExists)
+	expectReceipt(t, node, invalidTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	es, ok := runtime.Election(closed.ID)
+	if !ok {
+		t.Fatal("Expected election to be stored in contract state")
+	}
+	if es.Election.PublicKey == nil || string(es.Election.PublicKey.Marshal()) != string(closed.PublicKey.Marshal()) {
+		t.Error("Election public key was not preserved through the transaction payload")
+	}
+
+	// Votes after the close time and for unknown elections are rejected
+	ballot, _ := utils.CreateTestVote(closed, "Alice")
+	lateVote, _ := utils.CreateVoteTransaction(closed.ID, ballot)
+	unknownVote, _ := utils.CreateVoteTransaction("missing-election", ballot)
+
+	// Plurality results are proven, so results posted without proofs are
+	// refused
+	unprovenTally, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{
+		ElectionID: closed.ID,
+		Results:    map[string]int{"Alice": 1},
+	})
+
+	node.TransactionPool = append(node.TransactionPool, lateVote, unknownVote, unprovenTally)
+	node.CreateBlock()
+
+	expectReceipt(t, node, lateVote, blockchain.ReceiptRejected, smartcontracts.ErrCodeVotingClosed)
+	expectReceipt(t, node, unknownVote, blockchain.ReceiptRejected, smartcontracts.ErrCodeElectionNotFound)
+	expectReceipt(t, node, unprovenTally, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	es, _ = runtime.Election(closed.ID)
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	secondTally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, secondTally)
+	node.CreateBlock()
+	expectReceipt(t, node, secondTally, blockchain.ReceiptRejected, smartcontracts.ErrCodeAlreadyTallied)
+
+	if es, _ := runtime.Election(closed.ID); es.Status != smartcontracts.ElectionTallied {
+		t.Errorf("Expected election to be tallied, got status %s", es.Status)
+	}
+
+	// A peer replaying the chain reaches the same state
+	peer := utils.SetupTestNode()
+	peer.ReplaceChain(node.Chain)
+	if len(peer.Chain.Blocks) != len(node.Chain.Blocks) {
+		t.Fatalf("Peer did not adopt the chain")
+	}
+	expectReceipt(t, peer, secondTally, blockchain.ReceiptRejected, smartcontracts.ErrCodeAlreadyTallied)
+}
+
+func TestContractRuntimeTallyTooEarly(t *testing.T) {
+	node := utils.SetupTestNode()
+
+	open, _ := utils.CreateTestElection("Open Election", []string{"Alice", "Bob"})
+	open.StartTime = time.Now().Add(-1 * time.Hour)
+	open.EndTime = time.Now().Add(1 * time.Hour)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, open)
+	tally, _ := blockchain.NewTransaction(blockchain.TxProposeResults, smartcontracts.ProposalPayload{
+		ElectionID: open.ID,
+	})
+	tally.Timestamp = createTx.Timestamp + 1
+	tally.Hash = tally.CalculateHash()
+
+	node.TransactionPool = append(node.TransactionPool, createTx, tally)
+	node.CreateBlock()
+
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, tally, blockchain.ReceiptRejected, smartcontracts.ErrCodeTallyTooEarly)
+}
+
+func expectReceipt(t *testing.T, node *blockchain.Node, tx *blockchain.Transaction, status blockchain.ReceiptStatus, code string) {
+	t.Helper()
+
+	receipt, ok := node.Receipt(tx.ID)
+	if !ok {
+		t.Fatalf("No receipt for transaction %s (%s)", tx.ID, tx.Type)
+	}
+	if receipt.Status != status || receipt.ErrorCode != code {
+		t.Errorf("Transaction %s (%s): expected %s/%q, got %s/%q (%s)",
+			tx.ID, tx.Type, status, code, receipt.Status, receipt.ErrorCode, receipt.Error)
+	}
+}
+
+func TestContractRuntimePluralityVote(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	open, _ := utils.CreateTestElection("Plurality Election", []string{"Alice", "Bob"})
+	open.StartTime = time.Now().Add(-1 * time.Hour)
+	open.EndTime = time.Now().Add(1 * time.Hour)
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, open)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+
+	ballot, err := utils.CreateTestVote(open, "Bob")
+	if err != nil {
+		t.Fatalf("Failed to create vote: %v", err)
+	}
+	voteTx, _ := utils.CreateVoteTransaction(open.ID, ballot)
+
+	// The proof is bound to the voter, so it cannot be reused under another ID
+	stolen := *ballot
+	stolen.VoterID = "another-voter"
+	stolenTx, _ := utils.CreateVoteTransaction(open.ID, &stolen)
+
+	node.TransactionPool = append(node.TransactionPool, voteTx, stolenTx)
+	node.CreateBlock()
+
+	expectReceipt(t, node, voteTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, stolenTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+	snapshot, _ := runtime.Election(open.ID)
+	if len(snapshot.Ballots) != 1 {
+		t.Errorf("Expected 1 counted ballot, got %d", len(snapshot.Ballots))
+	}
+
+	// Snapshots do not change as later transactions execute
+	next, _ := utils.CreateTestVote(open, "Alice")
+	nextTx, _ := utils.CreateVoteTransaction(open.ID, next)
+	node.TransactionPool = append(node.TransactionPool, nextTx)
+	node.CreateBlock()
+	expectReceipt(t, node, nextTx, blockchain.ReceiptApplied, "")
+	if snapshot.HasVoted(next.VoterID) || len(snapshot.Ballots) != 1 {
+		t.Error("Expected the snapshot not to see a later ballot")
+	}
+}
+
+func TestElectionRuleModules(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	keys := make(map[string]*crypto.KeyPair)
+	newElection := func(name string, rules election.RuleConfig) *election.Election {
+		e, electionKeys := utils.CreateTestElection(name, []string{"Alice", "Bob"})
+		keys[name] = electionKeys
+		e.ID = name
+		e.StartTime = time.Now().Add(-2 * time.Hour)
+		e.EndTime = time.Now().Add(-1 * time.Hour)
+		e.Rules = rules
+		return e
+	}
+
+	runtime.RegisterRuleModule(minTurnout{})
+
+	defaults := newElection("default-rules", election.RuleConfig{})
+	turnoutRules := election.RuleConfig{
+		Module: minTurnoutRule,
+		Params: json.RawMessage(`{"ballots": 2}`),
+	}
+	turnoutElection := newElection("turnout-rules", turnoutRules)
+	turnoutElection.RulesHash = turnoutRules.Hash()
+
+	unknown := newElection("unknown-rules", election.RuleConfig{Module: "quadratic/v1"})
+	badParams := newElection("bad-params", election.RuleConfig{Module: minTurnoutRule, Params: json.RawMessage(`{}`)})
+	mismatched := newElection("mismatched-hash", turnoutRules)
+	mismatched.RulesHash = election.RuleConfig{Module: smartcontracts.RuleLastBallotCount}.Hash()
+
+	var txs []*blockchain.Transaction
+	for _, e := range []*election.Election{defaults, turnoutElection, unknown, badParams, mismatched} {
+		tx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+		txs = append(txs, tx)
+	}
+	node.TransactionPool = append(node.TransactionPool, txs...)
+	node.CreateBlock()
+
+	expectReceipt(t, node, txs[0], blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, txs[1], blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, txs[2], blockchain.ReceiptRejected, smartcontracts.ErrCodeUnknownRules)
+	expectReceipt(t, node, txs[3], blockchain.ReceiptRejected, smartcontracts.ErrCodeUnknownRules)
+	expectReceipt(t, node, txs[4], blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	// Elections without rules get the default module, committed to by hash
+	es, _ := runtime.Election(defaults.ID)
+	if es.Election.Rules.Module != smartcontracts.DefaultRuleModule {
+		t.Errorf("Expected default rule module, got %q", es.Election.Rules.Module)
+	}
+	if !bytes.Equal(es.Election.RulesHash, es.Election.Rules.Hash()) {
+		t.Error("Expected election to commit to its rules")
+	}
+
+	// The commitment does not depend on parameter formatting
+	compact := election.RuleConfig{
+		Module: minTurnoutRule,
+		Params: json.RawMessage(`{"ballots":2}`),
+	}
+	if !bytes.Equal(compact.Hash(), turnoutRules.Hash()) {
+		t.Error("Expected rules hash to ignore parameter formatting")
+	}
+
+	// No ballots were cast, so the module rejects the tally
+	es, _ = runtime.Election(turnoutElection.ID)
+	tally := utils.CreateProposalTransaction(t, es, keys[turnoutElection.ID])
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+}
+
+const minTurnoutRule = "min-turnout/v1"
+
+// minTurnout is a rule module with parameters: tallies need at least the
+// given number of ballots.
+type minTurnout struct {
+	Ballots int `json:"ballots"`
+}
+
+func (minTurnout) ID() string { return minTurnoutRule }
+
+func (minTurnout) Configure(params json.RawMessage) (smartcontracts.ElectionRules, error) {
+	var m minTurnout
+	if err := json.Unmarshal(params, &m); err != nil {
+		return nil, err
+	}
+	if m.Ballots <= 0 {
+		return nil, fmt.Errorf("minimum turnout must be positive")
+	}
+	return m, nil
+}
+
+func (minTurnout) CheckBallot(es *smartcontracts.ElectionState, ballot *election.Ballot) error {
+	if es.HasVoted(ballot.VoterID) {
+		return blockchain.NewExecutionError(smartcontracts.ErrCodeDuplicateVote, "voter %s has already voted", ballot.VoterID)
+	}
+	return nil
+}
+
+func (m minTurnout) CheckTally(es *smartcontracts.ElectionState, results map[string]int) error {
+	if len(es.Ballots) < m.Ballots {
+		return blockchain.NewExecutionError(smartcontracts.ErrCodeInvalidTally, "%d ballots cast, %d required", len(es.Ballots), m.Ballots)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestDelegationResolution(t *testing.T) {
+	delegations := map[string]string{"b": "a", "c": "b", "d": "e", "f": "c"}
+	voted := func(voterID string) bool { return voterID == "a" || voterID == "c" }
+	one := func(string) int { return 1 }
+
+	// c voted directly, so b's weight stops at a and f's at c; d's chain
+	// reaches nobody who voted
+	report := election.ResolveDelegations(delegations, voted, one)
+	if a := report.Delegates["a"]; a == nil || a.Effective != 2 || !reflect.DeepEqual(a.Delegators, []string{"b"}) {
+		t.Errorf("Expected a to carry b's vote, got %+v", a)
+	}
+	if c := report.Delegates["c"]; c == nil || c.Effective != 2 || !reflect.DeepEqual(c.Delegators, []string{"f"}) {
+		t.Errorf("Expected c to carry f's vote, got %+v", c)
+	}
+	if report.Delegated != 2 || report.Lost != 1 || !reflect.DeepEqual(report.Overridden, []string{"c"}) {
+		t.Errorf("Unexpected delegation totals: %+v", report)
+	}
+
+	if !election.DelegationCycle(delegations, "a", "f") {
+		t.Error("Expected a delegation from a to f to close the cycle a-f-c-b-a")
+	}
+	if election.DelegationCycle(delegations, "a", "d") {
+		t.Error("Expected a delegation from a to d to be acyclic")
+	}
+
+	// Delegated weight is applied to the encrypted tally exactly
+	e, keys := createShareholderVote()
+	ballots := make(map[string]*election.ScoreBallot)
+	for voterID, values := range map[string][]int{"fund": {0, 1}, "employee": {1, 0}} {
+		ballots[voterID], _ = election.NewScoreBallot(e, voterID, values)
+	}
+	report = election.ResolveDelegations(map[string]string{"founder": "employee"}, func(voterID string) bool {
+		return ballots[voterID] != nil
+	}, e.WeightOf)
+	totals := e.TallyDelegatedScores(ballots, report)
+	for i, expected := range []int64{60, 100} {
+		weight, err := crypto.DecryptValue(keys.PrivateKey, totals[i], 160)
+		if err != nil || weight != expected {
+			t.Errorf("Expected weight %d for candidate %d, got %d (%v)", expected, i, weight, err)
+		}
+	}
+}
+
+func TestDelegationContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys, secrets := createCredentialElection(t, "Delegated Assembly", "voter-1", "voter-2", "voter-3", "voter-4", "voter-5")
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	// Delegations are signed by the delegator's credential, like ballots
+	signedDelegation := func(delegator, delegate string, secret *big.Int) *blockchain.Transaction {
+		credential, err := e.SignDelegation("", delegator, delegate, secret)
+		if err != nil {
+			t.Fatalf("Failed to sign delegation: %v", err)
+		}
+		tx, _ := blockchain.NewTransaction(blockchain.TxDelegateVote, smartcontracts.DelegationPayload{
+			ElectionID: e.ID,
+			Delegator:  delegator,
+			Delegate:   delegate,
+			Credential: credential,
+		})
+		return tx
+	}
+	delegate := func(delegator, delegate string) *blockchain.Transaction {
+		return signedDelegation(delegator, delegate, secrets[delegator])
+	}
+	vote := func(voterID string, values []int) *blockchain.Transaction {
+		tx, _ := utils.CreateVoteTransaction(e.ID, signedBallot(t, e, voterID, values, secrets[voterID]))
+		return tx
+	}
+
+	// Elections without voter credentials cannot authenticate delegators
+	open, _ := utils.CreateTestElection("Open Assembly", []string{"Alice", "Bob"})
+	open.ID += "-open"
+	open.StartTime, open.EndTime = e.StartTime, e.EndTime
+	openTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, open)
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, openTx, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, openTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	unsignedOpen, _ := blockchain.NewTransaction(blockchain.TxDelegateVote, smartcontracts.DelegationPayload{
+		ElectionID: open.ID,
+		Delegator:  "voter-2",
+		Delegate:   "voter-1",
+	})
+	// voter-1 cannot move voter-2's weight to itself, signed or not
+	forged := signedDelegation("voter-2", "voter-1", secrets["voter-1"])
+	unsigned, _ := blockchain.NewTransaction(blockchain.TxDelegateVote, smartcontracts.DelegationPayload{
+		ElectionID: e.ID,
+		Delegator:  "voter-2",
+		Delegate:   "voter-1",
+	})
+	// A signature does not carry over to another delegate
+	redirected := delegate("voter-2", "voter-5")
+	var payload smartcontracts.DelegationPayload
+	json.Unmarshal(redirected.Payload, &payload)
+	payload.Delegate = "voter-1"
+	redirected, _ = blockchain.NewTransaction(blockchain.TxDelegateVote, payload)
+	node.TransactionPool = append(node.TransactionPool, unsignedOpen, forged, unsigned, redirected)
+	node.CreateBlock()
+	for _, tx := range []*blockchain.Transaction{unsignedOpen, forged, unsigned, redirected} {
+		expectReceipt(t, node, tx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidDelegation)
+	}
+
+	// Transactions of a block are applied in canonical order, so dependent ones
+	// go into separate blocks
+	chain := []*blockchain.Transaction{delegate("voter-2", "voter-1"), delegate("voter-3", "voter-2"), delegate("voter-4", "voter-5")}
+	node.TransactionPool = append(node.TransactionPool, chain...)
+	node.CreateBlock()
+	for _, tx := range chain {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+
+	// voter-3 votes directly, overriding its delegation
+	self, cycle, revoke := delegate("voter-1", "voter-1"), delegate("voter-1", "voter-3"), delegate("voter-4", "")
+	votes := []*blockchain.Transaction{vote("voter-1", []int{1, 0}), vote("voter-3", []int{0, 1})}
+	node.TransactionPool = append(node.TransactionPool, self, cycle, revoke)
+	node.TransactionPool = append(node.TransactionPool, votes...)
+	node.CreateBlock()
+	expectReceipt(t, node, self, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidDelegation)
+	expectReceipt(t, node, cycle, blockchain.ReceiptRejected, smartcontracts.ErrCodeDelegationCycle)
+	expectReceipt(t, node, revoke, blockchain.ReceiptApplied, "")
+	for _, tx := range votes {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+
+	revokeAgain := delegate("voter-4", "")
+	node.TransactionPool = append(node.TransactionPool, revokeAgain)
+	node.CreateBlock()
+	expectReceipt(t, node, revokeAgain, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidDelegation)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	late := delegate("voter-2", "")
+	node.TransactionPool = append(node.TransactionPool, late)
+	node.CreateBlock()
+	expectReceipt(t, node, late, blockchain.ReceiptRejected, smartcontracts.ErrCodeVotingClosed)
+
+	// The proven totals apply the resolved weights to the encrypted ballots
+	es, _ := runtime.Election(e.ID)
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if !reflect.DeepEqual(es.Results, map[string]int{"Alice": 2, "Bob": 1}) {
+		t.Fatalf("Expected voter-1 to cast 2 votes for Alice, got %v", es.Results)
+	}
+
+	delegation := es.Outcome.Delegation
+	if delegation == nil || delegation.Delegates["voter-1"].Effective != 2 || !reflect.DeepEqual(delegation.Overridden, []string{"voter-3"}) {
+		t.Fatalf("Expected voter-1 to carry voter-2's weight and voter-3 to override, got %+v", delegation)
+	}
+	if es.Outcome.Elected[0] != "Alice" {
+		t.Errorf("Expected Alice to win, got %v", es.Outcome.Elected)
+	}
+}
@@ -1,11 +1,10 @@
 package integration
 
 import (
-	"github.com/cloudflare/bn256"
 	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
 	"github.com/koushamad/election-system/pkg/election"
 	"github.com/koushamad/election-system/test/utils"
-	"math/big"
 	"testing"
 	"time"
 )
@@ -14,7 +13,7 @@ func TestElectionCreation(t *testing.T) {
 	node := utils.SetupTestNode()
 
 	// Create test election
-	electionData, keys := utils.CreateTestElection(
+	electionData, _ := utils.CreateTestElection(
 		"Presidential Election 2025",
 		[]string{"Alice", "Bob", "Charlie"},
 	)
@@ -82,7 +81,7 @@ func TestVoteCasting(t *testing.T) {
 		}
 
 		// Verify ballot
-		if !ballot.Validate() {
+		if !ballot.Validate(electionData) {
 			t.Errorf("Ballot for %s failed validation", candidate)
 		}
 
@@ -127,34 +126,40 @@ func TestZeroKnowledgeProofs(t *testing.T) {
 	}
 
 	// Verify valid ballot passes validation
-	if !ballot.Validate() {
+	if !ballot.Validate(electionData) {
 		t.Error("Valid ballot failed verification")
 	}
 
-	// Create invalid proof
-	invalidBallot := &election.Ballot{
-		Ciphertext: ballot.Ciphertext,
-		ZKProof:    make([]byte, 64), // Random invalid proof
-		VoterID:    ballot.VoterID,
-	}
+	// Create invalid proofs
+	invalidScores := *ballot.Scores
+	invalidScores.Proofs = []*crypto.MembershipProof{ballot.Scores.Proofs[1], ballot.Scores.Proofs[0]}
+	invalidBallot := &election.Ballot{VoterID: ballot.VoterID, Scores: &invalidScores}
 
 	// Verify invalid ballot fails validation
-	if invalidBallot.Validate() {
+	if invalidBallot.Validate(electionData) {
 		t.Error("Invalid ballot passed verification")
 	}
 
 	// Test tampering with ciphertext
-	tamperedBallot := &election.Ballot{
-		Ciphertext: []*bn256.G1{
-			new(bn256.G1).ScalarBaseMult(big.NewInt(999)),
-			ballot.Ciphertext[1],
-		},
-		ZKProof: ballot.ZKProof,
-		VoterID: ballot.VoterID,
-	}
+	tamperedScores := *ballot.Scores
+	tamperedScores.Cells = []*crypto.Ciphertext{ballot.Scores.Cells[0], ballot.Scores.Cells[0]}
+	tamperedBallot := &election.Ballot{VoterID: ballot.VoterID, Scores: &tamperedScores}
 
 	// Verify tampered ballot fails validation
-	if tamperedBallot.Validate() {
+	if tamperedBallot.Validate(electionData) {
 		t.Error("Tampered ballot passed verification")
 	}
+
+	// Every cell of a ballot voting for both candidates is 0 or 1, but its
+	// total is not
+	approval := *electionData
+	approval.BallotType = election.BallotApproval
+	both, err := election.NewScoreBallot(&approval, ballot.VoterID, []int{1, 1})
+	if err != nil {
+		t.Fatalf("Failed to create approval cells: %v", err)
+	}
+	both.TotalProof = ballot.Scores.TotalProof
+	if (&election.Ballot{VoterID: ballot.VoterID, Scores: both}).Validate(electionData) {
+		t.Error("Ballot voting for two candidates passed verification")
+	}
 }
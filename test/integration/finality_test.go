@@ -0,0 +1,202 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestAggregateSignatures(t *testing.T) {
+	message := []byte("results")
+	var keys []*bn256.G2
+	var sigs []*bn256.G1
+	for i := 0; i < 4; i++ {
+		key := mustBLSKeys(t)
+		keys = append(keys, key.PublicKey)
+		sigs = append(sigs, crypto.BLSSign(key.PrivateKey, message))
+		if !crypto.VerifyPossession(key.PublicKey, crypto.ProvePossession(key.PrivateKey)) {
+			t.Errorf("Expected the proof of possession of key %d to verify", i)
+		}
+	}
+
+	aggregate := crypto.AggregateSignatures(sigs...)
+	if !crypto.BLSVerifyAggregate(keys, message, aggregate) {
+		t.Fatal("Expected the aggregate signature to verify")
+	}
+	if crypto.BLSVerifyAggregate(keys, message, crypto.AggregateSignatures(sigs[:3]...)) {
+		t.Error("Expected an aggregate missing a signer not to verify")
+	}
+	if crypto.BLSVerifyAggregate(keys, []byte("other results"), aggregate) {
+		t.Error("Expected the aggregate not to verify for another message")
+	}
+
+	// A signer bitmap records who signed
+	multi := crypto.NewMultiSignature(len(keys))
+	for _, i := range []int{0, 2, 3} {
+		if err := multi.Add(i, sigs[i]); err != nil {
+			t.Fatalf("Failed to add signature %d: %v", i, err)
+		}
+	}
+	if err := multi.Add(2, sigs[2]); err == nil {
+		t.Error("Expected a second signature by the same signer to be refused")
+	}
+	if multi.Count() != 3 || multi.HasSigned(1) || !multi.HasSigned(3) {
+		t.Errorf("Expected signers 0, 2 and 3, got bitmap %08b", multi.Signers)
+	}
+
+	data, _ := json.Marshal(multi)
+	var decoded crypto.MultiSignature
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode multi-signature: %v", err)
+	}
+	if !decoded.Verify(keys, message) {
+		t.Error("Expected the decoded multi-signature to verify")
+	}
+
+	// Claiming a signer who did not sign breaks the signature
+	decoded.Signers[0] |= 1 << 1
+	if decoded.Verify(keys, message) {
+		t.Error("Expected a bitmap listing a non-signer not to verify")
+	}
+	if multi.Verify(keys[:3], message) {
+		t.Error("Expected the multi-signature not to verify over another signer set")
+	}
+}
+
+// relayBlock copies block the way it travels between nodes.
+func relayBlock(t *testing.T, block *blockchain.Block) *blockchain.Block {
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Failed to encode block: %v", err)
+	}
+	var copied blockchain.Block
+	if err := json.Unmarshal(data, &copied); err != nil {
+		t.Fatalf("Failed to decode block: %v", err)
+	}
+	return &copied
+}
+
+func TestFinalityCertificates(t *testing.T) {
+	keys := make([]*crypto.BLSKeyPair, 4)
+	params := blockchain.DefaultConsensusParams()
+	for i := range keys {
+		keys[i] = mustBLSKeys(t)
+		params.Validators = append(params.Validators, blockchain.NewValidatorKey(keys[i]))
+	}
+	nodes := make([]*blockchain.Node, len(keys))
+	for i := range nodes {
+		nodes[i] = utils.SetupTestNode()
+		nodes[i].Params = params
+		nodes[i].Signer = crypto.NewLocalSigner(keys[i])
+	}
+
+	e, _ := utils.CreateTestElection("Certified Election", []string{"Alice", "Bob"})
+	tx, _ := utils.CreateElectionTransaction(e)
+	nodes[0].TransactionPool = append(nodes[0].TransactionPool, tx)
+	block := nodes[0].CreateBlock()
+	if nodes[0].IsFinal(block.Index) {
+		t.Fatal("Expected a block committed by one of four validators not to be final")
+	}
+
+	// Every validator commits to the block it accepts and hands the commit
+	// to OnCommit for its peers; two more commits reach the quorum of three
+	var commits []*blockchain.Commit
+	for _, node := range nodes[1:3] {
+		node.OnCommit = func(commit *blockchain.Commit) { commits = append(commits, commit) }
+		if err := node.AddBlock(relayBlock(t, block)); err != nil {
+			t.Fatalf("Failed to add block: %v", err)
+		}
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected a commit from each validator accepting the block, got %d", len(commits))
+	}
+
+	forged := *commits[0]
+	forged.Validator = 3
+	if err := nodes[0].AddCommit(&forged); err == nil {
+		t.Error("Expected a commit attributed to another validator to be refused")
+	}
+	outsider := utils.SetupTestNode()
+	outsider.Params = params
+	outsider.Signer = crypto.NewLocalSigner(mustBLSKeys(t))
+	if err := outsider.AddBlock(relayBlock(t, block)); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	if _, err := outsider.SignCommit(block.Index); err == nil {
+		t.Error("Expected a node outside the validator set to be unable to commit")
+	}
+
+	for _, commit := range commits {
+		if err := nodes[0].AddCommit(commit); err != nil {
+			t.Fatalf("Failed to add commit: %v", err)
+		}
+	}
+	if err := nodes[0].AddCommit(commits[0]); err == nil {
+		t.Error("Expected a repeated commit to be refused")
+	}
+	if !nodes[0].IsFinal(block.Index) {
+		t.Fatal("Expected the block to be final after three of four commits")
+	}
+
+	// The certificate is a single aggregate signature with a signer bitmap
+	certificate := nodes[0].Chain.Blocks[block.Index].Certificate
+	if certificate.Count() != 3 || certificate.HasSigned(3) {
+		t.Errorf("Expected validators 0 to 2 in the certificate, got bitmap %08b", certificate.Signers)
+	}
+
+	// Nodes syncing the chain check certificates, which are not part of the
+	// block hash
+	synced := &blockchain.Chain{Blocks: []*blockchain.Block{nodes[0].Chain.Blocks[0], relayBlock(t, nodes[0].Chain.Blocks[1])}}
+	if !nodes[3].VerifyChain(synced) {
+		t.Error("Expected the certified chain to verify")
+	}
+	synced.Blocks[1].Certificate.Signers[0] |= 1 << 3
+	if nodes[3].VerifyChain(synced) {
+		t.Error("Expected a chain with a tampered certificate to be rejected")
+	}
+
+	// A longer chain is only adopted if it keeps the final block
+	fork := utils.SetupTestNode()
+	fork.Params = params
+	for i := 0; i < 2; i++ {
+		other, _ := utils.CreateTestElection("Fork Election", []string{"Alice", "Bob"})
+		other.ID = fmt.Sprintf("fork-%d", i)
+		forkTx, _ := utils.CreateElectionTransaction(other)
+		fork.TransactionPool = append(fork.TransactionPool, forkTx)
+		fork.CreateBlock()
+	}
+	nodes[0].ReplaceChain(fork.Chain)
+	if len(nodes[0].Chain.Blocks) != 2 || !bytes.Equal(nodes[0].Chain.Blocks[1].Hash, block.Hash) {
+		t.Fatal("Expected a chain reverting the final block to be rejected")
+	}
+
+	extension := utils.SetupTestNode()
+	extension.Params = params
+	if err := extension.AddBlock(relayBlock(t, block)); err != nil {
+		t.Fatalf("Failed to add block: %v", err)
+	}
+	next, _ := utils.CreateTestElection("Next Election", []string{"Alice", "Bob"})
+	next.ID = "next"
+	nextTx, _ := utils.CreateElectionTransaction(next)
+	extension.TransactionPool = append(extension.TransactionPool, nextTx)
+	extension.CreateBlock()
+	nodes[0].ReplaceChain(extension.Chain)
+	if len(nodes[0].Chain.Blocks) != 3 {
+		t.Error("Expected a longer chain extending the final block to be adopted")
+	}
+
+	// Certificates are checked against the current validator set
+	if !nodes[0].IsFinal(block.Index) {
+		t.Fatal("Expected the block to stay final")
+	}
+	nodes[0].Params.Validators = params.Validators[1:]
+	if nodes[0].IsFinal(block.Index) {
+		t.Error("Expected the certificate not to verify against another validator set")
+	}
+}
@@ -0,0 +1,75 @@
+package integration
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/koushamad/election-system/pkg/keystore"
+)
+
+func TestKeystore(t *testing.T) {
+	ks := keystore.NewKeystore(t.TempDir())
+	passphrase := []byte("correct horse battery staple")
+
+	electionKeys := mustKeys(t)
+	stored, err := keystore.EncryptKey(keystore.KindElGamal, electionKeys.PrivateKey, passphrase, keystore.LightScrypt)
+	if err != nil {
+		t.Fatalf("Failed to encrypt key: %v", err)
+	}
+	if err := ks.Store("election-1", stored); err != nil {
+		t.Fatalf("Failed to store key: %v", err)
+	}
+	if err := ks.Store("election-1", stored); !errors.Is(err, keystore.ErrKeyExists) {
+		t.Errorf("Expected a stored key not to be overwritten, got %v", err)
+	}
+	if err := ks.Store("../escape", stored); err == nil {
+		t.Error("Expected a key name outside the keystore to be refused")
+	}
+
+	loaded, err := ks.Load("election-1")
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	keys, err := loaded.ElGamalKeys(passphrase)
+	if err != nil {
+		t.Fatalf("Failed to decrypt key: %v", err)
+	}
+	if keys.PrivateKey.Cmp(electionKeys.PrivateKey) != 0 || keys.PublicKey.String() != electionKeys.PublicKey.String() {
+		t.Error("Expected the decrypted key to be the stored one")
+	}
+	if _, err := loaded.Decrypt([]byte("wrong")); !errors.Is(err, keystore.ErrWrongPassphrase) {
+		t.Errorf("Expected a wrong passphrase to be refused, got %v", err)
+	}
+	if _, err := loaded.BLSKeys(passphrase); err == nil {
+		t.Error("Expected an elgamal key not to load as a BLS key")
+	}
+
+	// The kind and public key are authenticated with the key
+	relabelled := *loaded
+	relabelled.Kind = keystore.KindBLS
+	if _, err := relabelled.Decrypt(passphrase); err == nil {
+		t.Error("Expected a relabelled key not to decrypt")
+	}
+
+	blsKey := mustBLSKeys(t)
+	stored, err = keystore.EncryptKey(keystore.KindBLS, blsKey.PrivateKey, passphrase, keystore.LightScrypt)
+	if err != nil {
+		t.Fatalf("Failed to encrypt BLS key: %v", err)
+	}
+	if err := ks.Store("validator", stored); err != nil {
+		t.Fatalf("Failed to store key: %v", err)
+	}
+	loaded, _ = ks.Load("validator")
+	if decrypted, err := loaded.BLSKeys(passphrase); err != nil || decrypted.PrivateKey.Cmp(blsKey.PrivateKey) != 0 {
+		t.Errorf("Expected the stored BLS key back, got %v", err)
+	}
+
+	names, err := ks.List()
+	if err != nil || !reflect.DeepEqual(names, []string{"election-1", "validator"}) {
+		t.Errorf("Expected keys election-1 and validator, got %v (%v)", names, err)
+	}
+	if _, err := ks.Load("missing"); !errors.Is(err, keystore.ErrKeyNotFound) {
+		t.Errorf("Expected a missing key to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestVerifiableShuffle(t *testing.T) {
+	keys := mustKeys(t)
+	var rows [][]*crypto.Ciphertext
+	for _, values := range [][]int64{{1, 2}, {3, 4}, {5, 6}, {7, 8}} {
+		var row []*crypto.Ciphertext
+		for _, v := range values {
+			ct, _, _ := crypto.EncryptValueRandom(keys.PublicKey, v)
+			row = append(row, ct)
+		}
+		rows = append(rows, row)
+	}
+	context := []byte("mix/test/0")
+
+	out, proof, err := crypto.Shuffle(keys.PublicKey, rows, context)
+	if err != nil {
+		t.Fatalf("Failed to shuffle: %v", err)
+	}
+	if !crypto.VerifyShuffle(keys.PublicKey, rows, out, proof, context) {
+		t.Fatal("Expected the shuffle proof to verify")
+	}
+
+	// The output holds the same rows, re-encrypted
+	var decrypted []string
+	for i, row := range out {
+		if row[0].Equal(rows[i][0]) {
+			t.Error("Expected every ciphertext to be re-encrypted")
+		}
+		a, _ := crypto.DecryptValue(keys.PrivateKey, row[0], 10)
+		b, _ := crypto.DecryptValue(keys.PrivateKey, row[1], 10)
+		decrypted = append(decrypted, fmt.Sprintf("%d%d", a, b))
+	}
+	sort.Strings(decrypted)
+	if expected := []string{"12", "34", "56", "78"}; !reflect.DeepEqual(decrypted, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, decrypted)
+	}
+
+	// The proof survives encoding
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatalf("Failed to encode proof: %v", err)
+	}
+	var decoded crypto.ShuffleProof
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode proof: %v", err)
+	}
+	if !crypto.VerifyShuffle(keys.PublicKey, rows, out, &decoded, context) {
+		t.Error("Expected the decoded proof to verify")
+	}
+
+	// Tampering with the output, or reusing the proof elsewhere, fails
+	tampered := append([][]*crypto.Ciphertext{}, out...)
+	replaced, _, _ := crypto.EncryptValueRandom(keys.PublicKey, 9)
+	tampered[0] = []*crypto.Ciphertext{replaced, out[0][1]}
+	if crypto.VerifyShuffle(keys.PublicKey, rows, tampered, proof, context) {
+		t.Error("Expected a shuffle with a replaced ciphertext to fail verification")
+	}
+	if crypto.VerifyShuffle(keys.PublicKey, rows, out, proof, []byte("mix/test/1")) {
+		t.Error("Expected the proof not to verify for another context")
+	}
+}
+
+func TestWriteInText(t *testing.T) {
+	e, keys := createMayoralElection()
+	text, err := e.EncryptWriteInText("voter-1", "Jane Smith")
+	if err != nil {
+		t.Fatalf("Failed to encrypt write-in: %v", err)
+	}
+	if err := e.CheckWriteInText("voter-1", text); err != nil {
+		t.Fatalf("Expected the write-in text to verify: %v", err)
+	}
+	if err := e.CheckWriteInText("voter-2", text); err == nil {
+		t.Error("Expected a write-in text copied to another voter's ballot to be rejected")
+	}
+
+	// Decryption still works after re-encryption
+	for i, chunk := range text.Chunks {
+		r, _, _ := crypto.EncryptValueRandom(keys.PublicKey, 0)
+		text.Chunks[i] = chunk.Add(r)
+	}
+	name, err := crypto.DecryptText(keys.PrivateKey, text.Chunks)
+	if err != nil || string(name) != "Jane Smith" {
+		t.Errorf("Expected Jane Smith, got %q (%v)", name, err)
+	}
+	if _, err := e.EncryptWriteInText("voter-1", strings.Repeat("x", election.MaxWriteInLength+1)); err == nil {
+		t.Error("Expected an overlong write-in to be rejected")
+	}
+}
+
+// mixBallots submits the shuffle of server and expects its receipt.
+func mixBallots(t *testing.T, node *blockchain.Node, runtime *smartcontracts.Runtime, electionID, server string) {
+	t.Helper()
+
+	es, _ := runtime.Election(electionID)
+	payload, err := smartcontracts.NewMixPayload(es, server)
+	if err != nil {
+		t.Fatalf("Failed to mix as %s: %v", server, err)
+	}
+	tx, _ := blockchain.NewTransaction(blockchain.TxMixBallots, payload)
+	node.TransactionPool = append(node.TransactionPool, tx)
+	node.CreateBlock()
+	expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+}
+
+func TestMixedRankedElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys := utils.CreateTestElection("Mixed Ranked Election", []string{"Alice", "Bob", "Carol"})
+	e.BallotType = election.BallotRanked
+	e.Mix = &election.MixConfig{Servers: []string{"mix-1", "mix-2"}}
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	plurality := *e
+	plurality.ID = e.ID + "-plurality"
+	plurality.BallotType = election.BallotPlurality
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	pluralityTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &plurality)
+	node.TransactionPool = append(node.TransactionPool, createTx, pluralityTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, pluralityTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	var voteTxs []*blockchain.Transaction
+	for voterID, ranking := range map[string][]int{"voter-1": {0, 1}, "voter-2": {1}, "voter-3": {1, 2}} {
+		ranked, err := election.NewRankedBallot(keys.PublicKey, e.ID, voterID, len(e.Candidates), ranking)
+		if err != nil {
+			t.Fatalf("Failed to create ranked ballot: %v", err)
+		}
+		tx, _ := utils.CreateVoteTransaction(e.ID, &election.Ballot{VoterID: voterID, Type: election.BallotRanked, Ranked: ranked})
+		voteTxs = append(voteTxs, tx)
+	}
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Servers take turns, and the tally waits for all of them
+	es, _ := runtime.Election(e.ID)
+	early, err := smartcontracts.NewMixPayload(es, "mix-1")
+	if err != nil {
+		t.Fatalf("Failed to mix: %v", err)
+	}
+	early.Server = "mix-2"
+	outOfTurn, _ := blockchain.NewTransaction(blockchain.TxMixBallots, early)
+	tallyEarly, _ := blockchain.NewTransaction(blockchain.TxProposeResults, smartcontracts.ProposalPayload{ElectionID: e.ID})
+	node.TransactionPool = append(node.TransactionPool, outOfTurn, tallyEarly)
+	node.CreateBlock()
+	expectReceipt(t, node, outOfTurn, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidMix)
+	expectReceipt(t, node, tallyEarly, blockchain.ReceiptRejected, smartcontracts.ErrCodeMixIncomplete)
+
+	mixBallots(t, node, runtime, e.ID, "mix-1")
+
+	// A shuffle that drops a ballot does not verify
+	es, _ = runtime.Election(e.ID)
+	dropped, _ := smartcontracts.NewMixPayload(es, "mix-2")
+	dropped.Output[0] = dropped.Output[1]
+	droppedTx, _ := blockchain.NewTransaction(blockchain.TxMixBallots, dropped)
+	node.TransactionPool = append(node.TransactionPool, droppedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, droppedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidMix)
+
+	mixBallots(t, node, runtime, e.ID, "mix-2")
+
+	es, _ = runtime.Election(e.ID)
+	if !reflect.DeepEqual(es.MixedBy, []string{"mix-1", "mix-2"}) {
+		t.Fatalf("Expected both servers to have mixed, got %v", es.MixedBy)
+	}
+	rankings, err := es.Election.DecryptMixedRankings(keys.PrivateKey, es.Mixed)
+	if err != nil {
+		t.Fatalf("Failed to decrypt mixed rankings: %v", err)
+	}
+	var sorted []string
+	for _, ranking := range rankings {
+		sorted = append(sorted, strings.Join(ranking, ">"))
+	}
+	sort.Strings(sorted)
+	if expected := []string{"Alice>Bob", "Bob", "Bob>Carol"}; !reflect.DeepEqual(sorted, expected) {
+		t.Errorf("Expected rankings %v, got %v", expected, sorted)
+	}
+
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if len(es.Outcome.Elected) != 1 || es.Outcome.Elected[0] != "Bob" {
+		t.Errorf("Expected Bob to be elected, got %v", es.Outcome.Elected)
+	}
+}
+
+func TestMixedWriteInElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys := createMayoralElection()
+	e.Mix = &election.MixConfig{Servers: []string{"mix-1"}}
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	ballot := func(voterID string, index int, name string) *election.Ballot {
+		b := writeInBallot(t, e, voterID, index, "")
+		b.WriteIn = nil
+		text, err := e.EncryptWriteInText(voterID, name)
+		if err != nil {
+			t.Fatalf("Failed to encrypt write-in: %v", err)
+		}
+		b.WriteInText = text
+		return b
+	}
+	var voteTxs []*blockchain.Transaction
+	for _, b := range []*election.Ballot{
+		ballot("voter-1", e.WriteInIndex(), "Jane Smith"),
+		ballot("voter-2", e.WriteInIndex(), "jane  smith"),
+		ballot("voter-3", 0, "Unopened"),
+	} {
+		tx, _ := utils.CreateVoteTransaction(e.ID, b)
+		voteTxs = append(voteTxs, tx)
+	}
+	sealedTx, _ := utils.CreateVoteTransaction(e.ID, writeInBallot(t, e, "voter-4", 1, ""))
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, sealedTx)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, sealedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	mixBallots(t, node, runtime, e.ID, "mix-1")
+
+	es, _ := runtime.Election(e.ID)
+	names, err := es.Election.DecryptMixedWriteIns(keys.PrivateKey, es.Mixed)
+	if err != nil {
+		t.Fatalf("Failed to decrypt mixed write-ins: %v", err)
+	}
+	if expected := map[string]int{"Jane Smith": 1, "jane smith": 1}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected write-ins %v, got %v", expected, names)
+	}
+
+	// The texts of the selected write-ins are proven along with the totals
+	proposal, err := smartcontracts.NewProposalPayload(es, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	if len(proposal.WriteIns) != 2 {
+		t.Fatalf("Expected only the two selected write-ins to be decrypted, got %d", len(proposal.WriteIns))
+	}
+	withheld := *proposal
+	withheld.WriteIns = proposal.WriteIns[1:]
+	renamed := *proposal
+	renamed.WriteIns = append([]*smartcontracts.TextDecryption{{Row: proposal.WriteIns[0].Row, Chunks: proposal.WriteIns[1].Chunks, Proofs: proposal.WriteIns[0].Proofs}}, proposal.WriteIns[1:]...)
+	withheldTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, &withheld)
+	renamedTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, &renamed)
+	node.TransactionPool = append(node.TransactionPool, withheldTx, renamedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, withheldTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+	expectReceipt(t, node, renamedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	tally, _ := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if !reflect.DeepEqual(es.Results, map[string]int{"Alice": 1, "Bob": 0, "Write-in": 2}) {
+		t.Errorf("Expected results Alice=1 Write-in=2, got %v", es.Results)
+	}
+	if groups := es.Outcome.WriteIns; len(groups) != 1 || groups[0].Name != "Jane Smith" || groups[0].Count != 2 {
+		t.Errorf("Expected both write-ins grouped under Jane Smith, got %+v", groups)
+	}
+}
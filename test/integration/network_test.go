@@ -31,7 +31,7 @@ func TestServerChainEndpoint(t *testing.T) {
 
 	// Create response recorder
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(server.handleGetChain)
+	handler := server.Handler()
 
 	// Serve HTTP request
 	handler.ServeHTTP(rr, req)
@@ -84,18 +84,60 @@ func TestServerTransactionEndpoint(t *testing.T) {
 
 	// Create response recorder
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(server.handleTransactions)
+	handler := server.Handler()
 
 	// Serve HTTP request
 	handler.ServeHTTP(rr, req)
 
 	// Check status code
-	if status := rr.Code; status != http.StatusOK {
+	if status := rr.Code; status != http.StatusCreated {
 		t.Errorf("Handler returned wrong status code: got %v want %v",
-			status, http.StatusOK)
+			status, http.StatusCreated)
 	}
 
 	// Verify transaction was added to the chain
 	// Note: In the current implementation, transactions are validated but not stored
 	// This would need to be updated when proper transaction pooling is implemented
 }
+
+func TestServerTransactionLookupEndpoint(t *testing.T) {
+	node := utils.SetupTestNode()
+	server := network.NewServer(node, 0)
+
+	election, _ := utils.CreateTestElection("Test Election", []string{"Alice", "Bob"})
+	tx, _ := utils.CreateElectionTransaction(election)
+	if err := node.AddTransaction(tx); err != nil {
+		t.Fatalf("Failed to add transaction: %v", err)
+	}
+
+	lookup := func(id string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", "/tx/"+id, nil)
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, req)
+
+		var body map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	// Pending transactions have no receipt yet
+	code, body := lookup(tx.ID)
+	if code != http.StatusOK || body["status"] != "pending" {
+		t.Errorf("Expected pending transaction, got %d %v", code, body)
+	}
+
+	node.CreateBlock()
+
+	code, body = lookup(tx.ID)
+	if code != http.StatusOK || body["status"] != string(blockchain.ReceiptApplied) {
+		t.Fatalf("Expected applied transaction, got %d %v", code, body)
+	}
+	receipt, _ := body["receipt"].(map[string]interface{})
+	if receipt["election_id"] != election.ID {
+		t.Errorf("Expected receipt for election %s, got %v", election.ID, receipt)
+	}
+
+	if code, _ := lookup("missing"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown transaction, got %d", code)
+	}
+}
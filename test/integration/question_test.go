@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createReferendum returns an election with a two-thirds referendum needing
+// half of the four voters to turn out, and a three-option poll.
+func createReferendum() (*election.Election, *crypto.KeyPair) {
+	e, keys := utils.CreateTestElection("Referendum", nil)
+	e.Candidates = nil
+	e.Contests = []election.Contest{
+		{
+			ID: "amendment", Name: "Amendment 1", BallotType: election.BallotQuestion,
+			Candidates: election.ReferendumOptions(true),
+			Threshold:  &election.Ratio{Num: 2, Den: 3}, QuorumPercent: 50,
+		},
+		{
+			ID: "budget", Name: "Budget priority", BallotType: election.BallotQuestion,
+			Candidates: []election.Candidate{{ID: "parks", Name: "Parks"}, {ID: "roads", Name: "Roads"}, {ID: "schools", Name: "Schools"}},
+		},
+	}
+	e.Styles = []election.BallotStyle{{ID: "all", Contests: []string{"amendment", "budget"}}}
+	e.VoterStyles = map[string]string{"voter-1": "all", "voter-2": "all", "voter-3": "all", "voter-4": "all"}
+	return e, keys
+}
+
+func TestQuestionEvaluation(t *testing.T) {
+	e, _ := createReferendum()
+	if err := e.ValidateContests(); err != nil {
+		t.Fatalf("Expected valid question contests: %v", err)
+	}
+	amendment, _ := e.Contest("amendment")
+	budget, _ := e.Contest("budget")
+
+	tests := []struct {
+		name    string
+		contest *election.Contest
+		results map[string]int
+		turnout int
+		quorum  bool
+		passed  string
+	}{
+		{"exactly two thirds", amendment, map[string]int{"Yes": 2, "No": 1, "Abstain": 1}, 4, true, "Yes"},
+		{"short of two thirds", amendment, map[string]int{"Yes": 1, "No": 1}, 2, true, ""},
+		{"abstentions count for quorum only", amendment, map[string]int{"Yes": 1, "Abstain": 1}, 2, true, "Yes"},
+		{"below quorum", amendment, map[string]int{"Yes": 1}, 1, false, ""},
+		{"no majority", budget, map[string]int{"Parks": 2, "Roads": 1, "Schools": 1}, 4, true, ""},
+		{"majority", budget, map[string]int{"Parks": 3, "Roads": 1}, 4, true, "Parks"},
+	}
+	for _, tt := range tests {
+		result := e.EvaluateQuestion(tt.contest, tt.results, tt.turnout)
+		if result.Eligible != 4 {
+			t.Errorf("%s: expected 4 eligible voters, got %d", tt.name, result.Eligible)
+		}
+		if result.QuorumMet != tt.quorum || result.Passed != tt.passed {
+			t.Errorf("%s: expected quorum %v and %q to pass, got %+v", tt.name, tt.quorum, tt.passed, result)
+		}
+	}
+
+	invalid := []func(c *election.Contest){
+		func(c *election.Contest) { c.Threshold = &election.Ratio{Num: 1, Den: 3} },
+		func(c *election.Contest) { c.QuorumPercent = 150 },
+		func(c *election.Contest) { c.MaxSelections = 2 },
+		func(c *election.Contest) { c.Candidates = c.Candidates[:1] },
+		func(c *election.Contest) { c.BallotType = election.BallotPlurality },
+	}
+	for i, mutate := range invalid {
+		e, _ := createReferendum()
+		mutate(&e.Contests[0])
+		if err := e.ValidateContests(); err == nil {
+			t.Errorf("Expected invalid question settings %d to be rejected", i)
+		}
+	}
+
+	if _, err := e.NewContestSection("amendment", "voter-1", nil); err == nil {
+		t.Error("Expected a question to require an answer")
+	}
+}
+
+func TestReferendumContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys := createReferendum()
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	// Three of four voters: two yes, one abstention; parks wins the poll
+	var voteTxs []*blockchain.Transaction
+	for voterID, choices := range map[string][2]int{"voter-1": {0, 0}, "voter-2": {0, 0}, "voter-3": {2, 1}} {
+		ballot := castContests(t, e, voterID, []string{"amendment", "budget"}, []int{choices[0]}, []int{choices[1]})
+		tx, _ := utils.CreateVoteTransaction(e.ID, ballot)
+		voteTxs = append(voteTxs, tx)
+	}
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Question answers are proven per option like approval ballots
+	es, _ := runtime.Election(e.ID)
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	expected := map[string]map[string]int{
+		"amendment": {"Yes": 2, "No": 0, "Abstain": 1},
+		"budget":    {"Parks": 2, "Roads": 1, "Schools": 0},
+	}
+	if !reflect.DeepEqual(es.ContestResults, expected) {
+		t.Errorf("Expected results %v, got %v", expected, es.ContestResults)
+	}
+	amendment := es.Outcome.Contests["amendment"]
+	if amendment.Question == nil || amendment.Question.Turnout != 3 || !amendment.Question.QuorumMet {
+		t.Fatalf("Expected the recorded turnout of 3 to meet the quorum, got %+v", amendment.Question)
+	}
+	if len(amendment.Elected) != 1 || amendment.Elected[0] != "Yes" {
+		t.Errorf("Expected the amendment to pass, got %v", amendment.Elected)
+	}
+	if budget := es.Outcome.Contests["budget"]; budget.Question.Passed != "Parks" {
+		t.Errorf("Expected parks to win the poll, got %+v", budget.Question)
+	}
+}
@@ -0,0 +1,300 @@
+package integration
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestRankedBallot(t *testing.T) {
+	electionData, keys := utils.CreateTestElection("Ranked Election", []string{"Alice", "Bob", "Carol"})
+	n := len(electionData.Candidates)
+
+	ballot, err := election.NewRankedBallot(keys.PublicKey, electionData.ID, "voter-1", n, []int{2, 0})
+	if err != nil {
+		t.Fatalf("Failed to create ranked ballot: %v", err)
+	}
+	if !ballot.Verify(keys.PublicKey, electionData.ID, "voter-1", n) {
+		t.Fatal("Expected valid ranked ballot to verify")
+	}
+	if ballot.Verify(keys.PublicKey, electionData.ID, "voter-2", n) {
+		t.Error("Ranked ballot proofs must be bound to the voter")
+	}
+
+	ranking, err := ballot.Decrypt(keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to decrypt ranked ballot: %v", err)
+	}
+	if len(ranking) != 2 || ranking[0] != 2 || ranking[1] != 0 {
+		t.Errorf("Expected ranking [2 0], got %v", ranking)
+	}
+
+	// Swapping two cells changes the plaintext without updating the proofs
+	tampered := *ballot
+	tampered.Cells = [][]*crypto.Ciphertext{ballot.Cells[1], ballot.Cells[0], ballot.Cells[2]}
+	if tampered.Verify(keys.PublicKey, electionData.ID, "voter-1", n) {
+		t.Error("Expected tampered ranked ballot to fail verification")
+	}
+
+	if _, err := election.NewRankedBallot(keys.PublicKey, electionData.ID, "voter-1", n, []int{1, 1}); err == nil {
+		t.Error("Expected a ranking with a repeated candidate to be rejected")
+	}
+}
+
+func TestIRVTabulation(t *testing.T) {
+	candidates := []election.Candidate{{ID: "a", Name: "Alice"}, {ID: "b", Name: "Bob"}, {ID: "c", Name: "Carol"}}
+
+	rankings, err := election.ParseRankings(candidates, [][]string{
+		{"Alice", "Bob"},
+		{"Alice"},
+		{"Bob", "Alice"},
+		{"Bob", "Carol"},
+		{"Carol", "Bob"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to parse rankings: %v", err)
+	}
+
+	report, err := election.TabulateIRV(candidates, rankings, election.DefaultTieBreak, "seed")
+	if err != nil {
+		t.Fatalf("Tabulation failed: %v", err)
+	}
+	if report.Winner != "Bob" {
+		t.Errorf("Expected Bob to win after transfers, got %q", report.Winner)
+	}
+	if len(report.Rounds) != 2 || report.Rounds[0].Eliminated != "Carol" {
+		t.Fatalf("Expected Carol to be eliminated in the first of two rounds, got %+v", report.Rounds)
+	}
+	if report.Rounds[1].Votes["Bob"] != 3 {
+		t.Errorf("Expected Bob to have 3 votes in round 2, got %d", report.Rounds[1].Votes["Bob"])
+	}
+
+	if _, err := election.ParseRankings(candidates, [][]string{{"Alice", "Alice"}}); err == nil {
+		t.Error("Expected repeated candidate in a ranking to be rejected")
+	}
+	if _, err := election.ParseRankings(candidates, [][]string{{"Dave"}}); err == nil {
+		t.Error("Expected unknown candidate in a ranking to be rejected")
+	}
+
+	// A first-round tie between Bob and Carol is resolved by each rule
+	tied := [][]int{{0}, {0}, {1}, {2}}
+	for _, rule := range []election.TieBreak{election.TieBreakPreviousRounds, election.TieBreakLot, election.TieBreakCandidateOrder} {
+		report, err := election.TabulateIRV(candidates, tied, rule, "seed")
+		if err != nil {
+			t.Fatalf("Tabulation with %s failed: %v", rule, err)
+		}
+		first := report.Rounds[0]
+		if len(first.TiedWith) != 1 || first.TieBreak == "" {
+			t.Errorf("%s: expected the tie to be recorded, got %+v", rule, first)
+		}
+		if rule == election.TieBreakCandidateOrder && first.Eliminated != "Carol" {
+			t.Errorf("Expected candidate order to eliminate Carol, got %s", first.Eliminated)
+		}
+
+		again, _ := election.TabulateIRV(candidates, tied, rule, "seed")
+		if again.Rounds[0].Eliminated != first.Eliminated {
+			t.Errorf("%s: tie-break is not deterministic", rule)
+		}
+	}
+}
+
+func TestRankedElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	electionData, keys := utils.CreateTestElection("Ranked Contract", []string{"Alice", "Bob", "Carol"})
+	electionData.BallotType = election.BallotRanked
+	electionData.StartTime = time.Now().Add(-1 * time.Hour)
+	electionData.EndTime = time.Now().Add(2 * time.Second)
+	officials := utils.TrustTally(t, electionData, 2)
+	n := len(electionData.Candidates)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, electionData)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	votes := map[string][]int{"voter-1": {0, 1}, "voter-2": {1}, "voter-3": {2, 1}, "voter-4": {1, 0}}
+	var voteTxs []*blockchain.Transaction
+	for voterID, ranking := range votes {
+		ranked, err := election.NewRankedBallot(keys.PublicKey, electionData.ID, voterID, n, ranking)
+		if err != nil {
+			t.Fatalf("Failed to create ranked ballot: %v", err)
+		}
+		tx, _ := utils.CreateVoteTransaction(electionData.ID, &election.Ballot{VoterID: voterID, Type: election.BallotRanked, Ranked: ranked})
+		voteTxs = append(voteTxs, tx)
+	}
+
+	// Plurality ballots are rejected by ranked elections
+	plurality, _ := utils.CreateTestVote(electionData, "Alice")
+	pluralityTx, _ := utils.CreateVoteTransaction(electionData.ID, plurality)
+
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, pluralityTx)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, pluralityTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	es, _ := runtime.Election(electionData.ID)
+	var rankings [][]string
+	for _, ballot := range es.Ballots {
+		ranking, err := ballot.Ranked.Decrypt(keys.PrivateKey)
+		if err != nil {
+			t.Fatalf("Failed to decrypt ranked ballot: %v", err)
+		}
+		names := make([]string, len(ranking))
+		for i, c := range ranking {
+			names[i] = electionData.Candidates[c].Name
+		}
+		rankings = append(rankings, names)
+	}
+
+	// Unmixed rankings cannot be proven, so a quorum of officials signs them
+	unsigned, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{
+		ElectionID: electionData.ID,
+		Rankings:   rankings,
+	})
+	partial := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{Rankings: rankings}, officials[:1])
+	// The signatures do not carry over to other rankings
+	forged := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{Rankings: rankings}, officials)
+	var payload smartcontracts.TallyPayload
+	json.Unmarshal(forged.Payload, &payload)
+	payload.Rankings = rankings[1:]
+	forged, _ = blockchain.NewTransaction(blockchain.TxTallyVotes, payload)
+	node.TransactionPool = append(node.TransactionPool, unsigned, partial, forged)
+	node.CreateBlock()
+	expectReceipt(t, node, unsigned, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+	expectReceipt(t, node, partial, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+	expectReceipt(t, node, forged, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	tally := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{Rankings: rankings}, officials)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(electionData.ID)
+	if es.Outcome == nil || es.Outcome.IRV == nil {
+		t.Fatal("Expected an IRV report in the election outcome")
+	}
+	if len(es.Outcome.Elected) != 1 || es.Outcome.Elected[0] != "Bob" {
+		t.Errorf("Expected Bob to be elected, got %v", es.Outcome.Elected)
+	}
+	if es.Status != smartcontracts.ElectionCertified || len(es.Certifiers()) != 2 {
+		t.Errorf("Expected the tally to be certified by both officials, got %s %v", es.Status, es.Certifiers())
+	}
+	if es.Results["Alice"] != 1 || es.Results["Bob"] != 2 || es.Results["Carol"] != 1 {
+		t.Errorf("Expected first-round results to be recorded, got %v", es.Results)
+	}
+}
+
+func TestSTVCount(t *testing.T) {
+	candidates := []election.Candidate{
+		{ID: "a", Name: "Alice"}, {ID: "b", Name: "Bob"}, {ID: "c", Name: "Carol"},
+		{ID: "d", Name: "Dave"}, {ID: "e", Name: "Erin"},
+	}
+	var rankings [][]int
+	add := func(count int, ranking ...int) {
+		for i := 0; i < count; i++ {
+			rankings = append(rankings, ranking)
+		}
+	}
+	add(8, 0, 1)
+	add(4, 2)
+	add(4, 3, 2)
+	add(3, 4, 3)
+	add(1, 1)
+
+	report, err := election.CountSTV(candidates, rankings, 2, election.DefaultTieBreak, "seed")
+	if err != nil {
+		t.Fatalf("STV count failed: %v", err)
+	}
+	if report.Quota != 7*election.STVScale {
+		t.Errorf("Expected a Droop quota of 7, got %d", report.Quota)
+	}
+	if len(report.Elected) != 2 || report.Elected[0] != "Alice" || report.Elected[1] != "Dave" {
+		t.Fatalf("Expected Alice and Dave to be elected, got %v", report.Elected)
+	}
+
+	surplus := report.Stages[1]
+	if surplus.Action != election.STVSurplus || surplus.Candidate != "Alice" {
+		t.Fatalf("Expected Alice's surplus to be transferred second, got %+v", surplus)
+	}
+	if surplus.TransferValue != election.STVScale/8 || surplus.Transfers["Bob"] != election.STVScale {
+		t.Errorf("Expected 8 ballots to move to Bob at 1/8, got %+v", surplus)
+	}
+
+	// Observers can replay the log: each stage's totals follow from the previous one
+	for i := 0; i+1 < len(report.Stages); i++ {
+		stage, next := report.Stages[i], report.Stages[i+1]
+		expected := make(map[string]int64, len(stage.Votes))
+		for name, votes := range stage.Votes {
+			expected[name] = votes
+		}
+		switch stage.Action {
+		case election.STVSurplus:
+			expected[stage.Candidate] = report.Quota
+		case election.STVExclude:
+			delete(expected, stage.Candidate)
+		}
+		for name, value := range stage.Transfers {
+			expected[name] += value
+		}
+		for name, votes := range expected {
+			if next.Votes[name] != votes {
+				t.Errorf("Stage %d: expected %s to have %d, got %d", next.Stage, name, votes, next.Votes[name])
+			}
+		}
+		if next.Exhausted != stage.Exhausted+stage.ToExhausted {
+			t.Errorf("Stage %d: exhausted value does not follow from stage %d", next.Stage, stage.Stage)
+		}
+	}
+
+	if _, err := election.CountSTV(candidates, rankings, 6, election.DefaultTieBreak, "seed"); err == nil {
+		t.Error("Expected more seats than candidates to be rejected")
+	}
+}
+
+func TestMultiSeatElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	board, _ := utils.CreateTestElection("Board", []string{"Alice", "Bob", "Carol"})
+	board.ID = "board"
+	board.BallotType = election.BallotRanked
+	board.Seats = 2
+	board.StartTime = time.Now().Add(-2 * time.Hour)
+	board.EndTime = time.Now().Add(-1 * time.Hour)
+	officials := utils.TrustTally(t, board, 1)
+
+	plurality := *board
+	plurality.ID = "plurality-board"
+	plurality.BallotType = election.BallotPlurality
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, board)
+	pluralityTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &plurality)
+	node.TransactionPool = append(node.TransactionPool, createTx, pluralityTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, pluralityTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	es, _ := runtime.Election(board.ID)
+	tally := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{}, officials)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(board.ID)
+	if es.Outcome == nil || es.Outcome.STV == nil || es.Outcome.STV.Seats != 2 {
+		t.Fatalf("Expected an STV report for a two-seat election, got %+v", es.Outcome)
+	}
+}
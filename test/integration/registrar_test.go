@@ -0,0 +1,175 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/registrar"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// issueToken obtains a voting token for voterID from r.
+func issueToken(t *testing.T, r *registrar.Registrar, e *election.Election, voterID, code string) *election.VotingToken {
+	request, err := e.NewTokenRequest()
+	if err != nil {
+		t.Fatalf("Failed to create token request: %v", err)
+	}
+	resp, err := r.Issue(&registrar.IssueRequest{ElectionID: e.ID, VoterID: voterID, Secret: code, Blinded: request.Blinded.Marshal()})
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	blindSig, err := crypto.UnmarshalPoint(resp.Signature)
+	if err != nil {
+		t.Fatalf("Malformed blind signature: %v", err)
+	}
+	token, err := request.Finish(e, blindSig)
+	if err != nil {
+		t.Fatalf("Failed to finish token: %v", err)
+	}
+	return token
+}
+
+// tokenBallot creates an approval ballot cast with token.
+func tokenBallot(t *testing.T, e *election.Election, values []int, token *election.VotingToken) *election.Ballot {
+	ballot := utils.CreateApprovalBallot(t, e, token.VoterID(), values)
+	if err := e.SignBallotWithToken(ballot, token); err != nil {
+		t.Fatalf("Failed to sign ballot: %v", err)
+	}
+	return ballot
+}
+
+func TestBlindSignatures(t *testing.T) {
+	key, err := crypto.GenerateBLSKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	message := []byte("voting-token")
+	blinded, factor, err := crypto.BlindMessage(message)
+	if err != nil {
+		t.Fatalf("Failed to blind message: %v", err)
+	}
+	sig := crypto.Unblind(crypto.BlindSign(key.PrivateKey, blinded), factor)
+	if !crypto.BLSVerify(key.PublicKey, message, sig) {
+		t.Fatal("Expected the unblinded signature to verify")
+	}
+	if sig.String() == crypto.BlindSign(key.PrivateKey, blinded).String() {
+		t.Error("Expected the unblinded signature to differ from the one the signer saw")
+	}
+	if crypto.BLSVerify(key.PublicKey, []byte("other"), sig) {
+		t.Error("Expected the signature not to verify for another message")
+	}
+	other, _ := crypto.GenerateBLSKeys()
+	if crypto.BLSVerify(other.PublicKey, message, sig) {
+		t.Error("Expected the signature not to verify under another key")
+	}
+}
+
+func TestRegistrarIssue(t *testing.T) {
+	key := mustBLSKeys(t)
+	r := registrar.NewRegistrar(key, registrar.AccessCodes{"voter-1": "code-1", "voter-2": "code-2"})
+	e, _ := utils.CreateTestElection("Registrar Election", []string{"Alice", "Bob"})
+	e.RegistrarKey = r.PublicKey()
+
+	token := issueToken(t, r, e, "voter-1", "code-1")
+	if _, err := e.VerifyToken(token.VoterID(), token.Key, token.Signature); err != nil {
+		t.Fatalf("Expected the token to verify: %v", err)
+	}
+
+	request, _ := e.NewTokenRequest()
+	issue := &registrar.IssueRequest{ElectionID: e.ID, VoterID: "voter-1", Secret: "code-1", Blinded: request.Blinded.Marshal()}
+	if _, err := r.Issue(issue); !errors.Is(err, registrar.ErrAlreadyIssued) {
+		t.Errorf("Expected a second token to be refused, got %v", err)
+	}
+	issue.VoterID, issue.Secret = "voter-2", "code-1"
+	if _, err := r.Issue(issue); !errors.Is(err, registrar.ErrUnauthenticated) {
+		t.Errorf("Expected a wrong access code to be refused, got %v", err)
+	}
+
+	post := func(issue *registrar.IssueRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(issue)
+		req, _ := http.NewRequest("POST", "/tokens", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := post(issue); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a wrong access code, got %d", rr.Code)
+	}
+	issue.Secret = "code-2"
+	rr := post(issue)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp registrar.IssueResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	blindSig, _ := crypto.UnmarshalPoint(resp.Signature)
+	if _, err := request.Finish(e, blindSig); err != nil {
+		t.Errorf("Expected the token issued over HTTP to verify: %v", err)
+	}
+	if rr := post(issue); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a second token, got %d", rr.Code)
+	}
+}
+
+func TestRegistrarTokensContract(t *testing.T) {
+	node := utils.SetupTestNode()
+
+	key := mustBLSKeys(t)
+	r := registrar.NewRegistrar(key, registrar.AccessCodes{"voter-1": "code-1"})
+	e, _ := utils.CreateApprovalElection("Anonymous Election", []string{"Alice", "Bob"}, 1, 1)
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(1 * time.Hour)
+	e.RegistrarKey = r.PublicKey()
+
+	both := *e
+	both.ID = "registrar-and-registry"
+	both.VoterCredentials = map[string][]byte{"voter-1": mustKeys(t).PublicKey.Marshal()}
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	bothTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &both)
+	node.TransactionPool = append(node.TransactionPool, createTx, bothTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, bothTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	// A token from another signer, and a ballot without a token, are refused
+	token := issueToken(t, r, e, "voter-1", "code-1")
+	rogue := registrar.NewRegistrar(mustBLSKeys(t), registrar.AccessCodes{"voter-1": "code-1"})
+	forged := issueToken(t, rogue, &election.Election{ID: e.ID, RegistrarKey: rogue.PublicKey()}, "voter-1", "code-1")
+
+	voteTx, _ := utils.CreateVoteTransaction(e.ID, tokenBallot(t, e, []int{1, 0}, token))
+	forgedTx, _ := utils.CreateVoteTransaction(e.ID, tokenBallot(t, e, []int{0, 1}, forged))
+	unsignedTx, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, e, "voter-2", []int{1, 0}))
+	node.TransactionPool = append(node.TransactionPool, voteTx, forgedTx, unsignedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, voteTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, forgedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+	expectReceipt(t, node, unsignedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+}
+
+func mustKeys(t *testing.T) *crypto.KeyPair {
+	key, err := crypto.GenerateKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
+
+func mustBLSKeys(t *testing.T) *crypto.BLSKeyPair {
+	key, err := crypto.GenerateBLSKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return key
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createCredentialElection returns a single-choice election whose voters are
+// registered with credential keys, and the voters' credential secrets.
+func createCredentialElection(t *testing.T, name string, voterIDs ...string) (*election.Election, *crypto.KeyPair, map[string]*big.Int) {
+	e, keys := utils.CreateApprovalElection(name, []string{"Alice", "Bob"}, 1, 1)
+	e.VoterCredentials = make(map[string][]byte)
+	secrets := make(map[string]*big.Int)
+	for _, voterID := range voterIDs {
+		credential := mustKeys(t)
+		e.VoterCredentials[voterID] = credential.PublicKey.Marshal()
+		secrets[voterID] = credential.PrivateKey
+	}
+	return e, keys, secrets
+}
+
+// signedBallot creates an approval ballot of voterID signed with secret.
+func signedBallot(t *testing.T, e *election.Election, voterID string, values []int, secret *big.Int) *election.Ballot {
+	ballot := utils.CreateApprovalBallot(t, e, voterID, values)
+	if err := e.SignBallot(ballot, secret); err != nil {
+		t.Fatalf("Failed to sign ballot: %v", err)
+	}
+	return ballot
+}
+
+func TestBallotCredentials(t *testing.T) {
+	e, _, secrets := createCredentialElection(t, "Board Election", "voter-1", "voter-2")
+	first := signedBallot(t, e, "voter-1", []int{1, 0}, secrets["voter-1"])
+	second := signedBallot(t, e, "voter-1", []int{0, 1}, secrets["voter-1"])
+	for _, ballot := range []*election.Ballot{first, second} {
+		if err := e.VerifyBallotCredential(ballot); err != nil {
+			t.Fatalf("Expected the credential to verify: %v", err)
+		}
+	}
+
+	// Ballots of one credential share a nullifier within, but not across,
+	// elections
+	if string(first.Credential.Nullifier) != string(second.Credential.Nullifier) {
+		t.Error("Expected both ballots of voter-1 to carry the same nullifier")
+	}
+	other, _, _ := createCredentialElection(t, "Other Election")
+	other.ID = e.ID + "-runoff"
+	other.VoterCredentials = e.VoterCredentials
+	if string(signedBallot(t, other, "voter-1", []int{1, 0}, secrets["voter-1"]).Credential.Nullifier) == string(first.Credential.Nullifier) {
+		t.Error("Expected nullifiers to differ between elections")
+	}
+
+	// The credential signs the ballot's contents and identity
+	tampered := *first
+	tampered.Scores = second.Scores
+	if err := e.VerifyBallotCredential(&tampered); err == nil {
+		t.Error("Expected a ballot with swapped contents to fail verification")
+	}
+	if err := e.VerifyBallotCredential(signedBallot(t, e, "voter-2", []int{1, 0}, secrets["voter-1"])); err == nil {
+		t.Error("Expected a ballot signed with another voter's credential to fail verification")
+	}
+}
+
+func TestLastBallotCountsContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys, secrets := createCredentialElection(t, "Coercion Resistant Election", "voter-1", "voter-2")
+	e.Rules = election.RuleConfig{Module: smartcontracts.RuleLastBallotCount}
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	uncredentialed := *e
+	uncredentialed.ID = "uncredentialed"
+	uncredentialed.VoterCredentials = nil
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	uncredentialedTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &uncredentialed)
+	node.TransactionPool = append(node.TransactionPool, createTx, uncredentialedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, uncredentialedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	// voter-1 is coerced into voting for Alice
+	coerced, _ := utils.CreateVoteTransaction(e.ID, signedBallot(t, e, "voter-1", []int{1, 0}, secrets["voter-1"]))
+	honest, _ := utils.CreateVoteTransaction(e.ID, signedBallot(t, e, "voter-2", []int{0, 1}, secrets["voter-2"]))
+	node.TransactionPool = append(node.TransactionPool, coerced, honest)
+	node.CreateBlock()
+	expectReceipt(t, node, coerced, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, honest, blockchain.ReceiptApplied, "")
+
+	// Only the credential holder can supersede a ballot
+	override, _ := utils.CreateVoteTransaction(e.ID, signedBallot(t, e, "voter-1", []int{0, 1}, secrets["voter-1"]))
+	forged, _ := utils.CreateVoteTransaction(e.ID, signedBallot(t, e, "voter-2", []int{1, 0}, secrets["voter-1"]))
+	unsigned, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, e, "voter-2", []int{1, 0}))
+	node.TransactionPool = append(node.TransactionPool, override, forged, unsigned)
+	node.CreateBlock()
+	expectReceipt(t, node, override, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, forged, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+	expectReceipt(t, node, unsigned, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	es, _ := runtime.Election(e.ID)
+	if len(es.Ballots) != 2 || es.Superseded != 1 {
+		t.Fatalf("Expected 2 counted ballots and 1 superseded, got %d and %d", len(es.Ballots), es.Superseded)
+	}
+
+	// Only the latest ballot of voter-1 is in the proven totals
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if !reflect.DeepEqual(es.Results, map[string]int{"Alice": 0, "Bob": 2}) {
+		t.Errorf("Expected results Alice=0 Bob=2, got %v", es.Results)
+	}
+}
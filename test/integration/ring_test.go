@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createRingElection returns a single-choice election over a voter ring of n
+// keys, and the ring secrets.
+func createRingElection(t *testing.T, name string, n int) (*election.Election, []*big.Int) {
+	e, _ := utils.CreateApprovalElection(name, []string{"Alice", "Bob"}, 1, 1)
+	secrets := make([]*big.Int, n)
+	for i := range secrets {
+		key := mustKeys(t)
+		e.VoterRing = append(e.VoterRing, key.PublicKey.Marshal())
+		secrets[i] = key.PrivateKey
+	}
+	return e, secrets
+}
+
+// ringBallot creates an approval ballot signed with the ring secret.
+func ringBallot(t *testing.T, e *election.Election, values []int, secret *big.Int) *election.Ballot {
+	voterID := e.RingVoterID(secret)
+	ballot := utils.CreateApprovalBallot(t, e, voterID, values)
+	if err := e.SignBallotWithRing(ballot, secret); err != nil {
+		t.Fatalf("Failed to sign ballot: %v", err)
+	}
+	return ballot
+}
+
+func TestRingSignatures(t *testing.T) {
+	keys := []*crypto.KeyPair{mustKeys(t), mustKeys(t), mustKeys(t)}
+	ring := []*bn256.G1{keys[0].PublicKey, keys[1].PublicKey, keys[2].PublicKey}
+	base := crypto.HashToPoint("test/ring", []byte("election"))
+	message := []byte("ballot")
+
+	for i, key := range keys {
+		sig, err := crypto.RingSign(ring, i, key.PrivateKey, base, message)
+		if err != nil {
+			t.Fatalf("Failed to sign as member %d: %v", i, err)
+		}
+		image := crypto.Nullifier(key.PrivateKey, base)
+		if !crypto.VerifyRing(ring, base, image, sig, message) {
+			t.Errorf("Expected the signature of member %d to verify", i)
+		}
+		if crypto.VerifyRing(ring, base, image, sig, []byte("other ballot")) {
+			t.Errorf("Expected the signature of member %d not to verify for another message", i)
+		}
+		if crypto.VerifyRing(ring, base, crypto.Nullifier(keys[(i+1)%3].PrivateKey, base), sig, message) {
+			t.Errorf("Expected the signature of member %d not to verify with another key image", i)
+		}
+		if crypto.VerifyRing(ring[:2], base, image, &crypto.RingSignature{C: sig.C, S: sig.S[:2]}, message) {
+			t.Errorf("Expected the signature of member %d not to verify over a smaller ring", i)
+		}
+	}
+
+	if _, err := crypto.RingSign(ring, 0, keys[1].PrivateKey, base, message); err == nil {
+		t.Error("Expected signing with another member's index to fail")
+	}
+}
+
+func TestRingBallotsContract(t *testing.T) {
+	node := utils.SetupTestNode()
+
+	e, secrets := createRingElection(t, "Ring Election", 3)
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(1 * time.Hour)
+
+	// Ring ballots do not reveal their member, so they cannot be weighted
+	weighted := *e
+	weighted.VoterWeights = map[string]int{e.RingVoterID(secrets[0]): 10}
+	if err := weighted.ValidateCredentials(); err == nil {
+		t.Error("Expected a weighted ring election to be rejected")
+	}
+
+	withRegistrar := *e
+	withRegistrar.ID = "ring-and-registrar"
+	withRegistrar.RegistrarKey = mustBLSKeys(t).PublicKey.Marshal()
+	duplicateKeys := *e
+	duplicateKeys.ID = "ring-with-duplicates"
+	duplicateKeys.VoterRing = [][]byte{e.VoterRing[0], e.VoterRing[0]}
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	registrarTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &withRegistrar)
+	duplicateTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &duplicateKeys)
+	node.TransactionPool = append(node.TransactionPool, createTx, registrarTx, duplicateTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, registrarTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+	expectReceipt(t, node, duplicateTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	// An outsider can only sign over a ring that includes their own key
+	outsider := mustKeys(t)
+	if err := e.SignBallotWithRing(&election.Ballot{VoterID: e.RingVoterID(outsider.PrivateKey)}, outsider.PrivateKey); err == nil {
+		t.Error("Expected a key outside the ring to be unable to sign")
+	}
+	widened := *e
+	widened.VoterRing = append(append([][]byte{}, e.VoterRing...), outsider.PublicKey.Marshal())
+	forged := ringBallot(t, &widened, []int{0, 1}, outsider.PrivateKey)
+
+	// A ballot cannot be moved to another voter ID
+	renamed := ringBallot(t, e, []int{0, 1}, secrets[2])
+	renamed.VoterID = e.RingVoterID(secrets[1])
+
+	firstTx, _ := utils.CreateVoteTransaction(e.ID, ringBallot(t, e, []int{1, 0}, secrets[0]))
+	forgedTx, _ := utils.CreateVoteTransaction(e.ID, forged)
+	renamedTx, _ := utils.CreateVoteTransaction(e.ID, renamed)
+	node.TransactionPool = append(node.TransactionPool, firstTx, forgedTx, renamedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, firstTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, forgedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+	expectReceipt(t, node, renamedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	// A second ballot with the same key image is a double vote
+	secondTx, _ := utils.CreateVoteTransaction(e.ID, ringBallot(t, e, []int{0, 1}, secrets[0]))
+	otherTx, _ := utils.CreateVoteTransaction(e.ID, ringBallot(t, e, []int{0, 1}, secrets[1]))
+	node.TransactionPool = append(node.TransactionPool, secondTx, otherTx)
+	node.CreateBlock()
+	expectReceipt(t, node, secondTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeDuplicateVote)
+	expectReceipt(t, node, otherTx, blockchain.ReceiptApplied, "")
+}
@@ -0,0 +1,160 @@
+package integration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestApprovalBallots(t *testing.T) {
+	electionData, keys := utils.CreateApprovalElection("Approval Election", []string{"Alice", "Bob", "Carol"}, 1, 2)
+	if err := electionData.ValidateScoring(); err != nil {
+		t.Fatalf("Expected valid approval settings: %v", err)
+	}
+
+	var ballots []*election.ScoreBallot
+	for i, values := range [][]int{{1, 1, 0}, {0, 1, 0}, {1, 0, 1}} {
+		voterID := string(rune('a' + i))
+		ballot, err := election.NewScoreBallot(electionData, voterID, values)
+		if err != nil {
+			t.Fatalf("Failed to create approval ballot: %v", err)
+		}
+		if !ballot.Verify(electionData, voterID) {
+			t.Fatalf("Expected approval ballot %v to verify", values)
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	if _, err := election.NewScoreBallot(electionData, "d", []int{1, 1, 1}); err == nil {
+		t.Error("Expected a ballot above the selection limit to be rejected")
+	}
+	if _, err := election.NewScoreBallot(electionData, "d", []int{0, 0, 0}); err == nil {
+		t.Error("Expected a ballot below the selection minimum to be rejected")
+	}
+
+	// A ballot made without the selection proof does not verify
+	unbounded := *electionData
+	unbounded.MinSelections, unbounded.MaxSelections = 0, 0
+	all, _ := election.NewScoreBallot(&unbounded, "d", []int{1, 1, 1})
+	if all.Verify(electionData, "d") {
+		t.Error("Expected a ballot without selection proof to fail verification")
+	}
+
+	// Moving a cell to another candidate invalidates the proofs
+	tampered := *ballots[1]
+	tampered.Cells = []*crypto.Ciphertext{ballots[1].Cells[1], ballots[1].Cells[0], ballots[1].Cells[2]}
+	if tampered.Verify(electionData, "b") {
+		t.Error("Expected tampered approval ballot to fail verification")
+	}
+
+	totals := election.TallyScores(ballots, len(electionData.Candidates))
+	for i, expected := range []int64{2, 2, 1} {
+		count, err := crypto.DecryptValue(keys.PrivateKey, totals[i], int64(len(ballots)))
+		if err != nil || count != expected {
+			t.Errorf("Expected %d approvals for candidate %d, got %d (%v)", expected, i, count, err)
+		}
+	}
+}
+
+func TestScoreBallots(t *testing.T) {
+	electionData, keys := utils.CreateTestElection("Score Election", []string{"Alice", "Bob"})
+	electionData.BallotType = election.BallotScore
+	if err := electionData.ValidateScoring(); err == nil {
+		t.Error("Expected a score election without a max score to be rejected")
+	}
+	electionData.MaxScore = 5
+
+	a, err := election.NewScoreBallot(electionData, "a", []int{5, 2})
+	if err != nil {
+		t.Fatalf("Failed to create score ballot: %v", err)
+	}
+	b, _ := election.NewScoreBallot(electionData, "b", []int{0, 4})
+	if !a.Verify(electionData, "a") || !b.Verify(electionData, "b") {
+		t.Fatal("Expected score ballots to verify")
+	}
+	if a.Verify(electionData, "b") {
+		t.Error("Score ballot proofs must be bound to the voter")
+	}
+	if _, err := election.NewScoreBallot(electionData, "c", []int{6, 0}); err == nil {
+		t.Error("Expected a score above the maximum to be rejected")
+	}
+
+	totals := election.TallyScores([]*election.ScoreBallot{a, b}, 2)
+	for i, expected := range []int64{5, 6} {
+		score, err := crypto.DecryptValue(keys.PrivateKey, totals[i], 10)
+		if err != nil || score != expected {
+			t.Errorf("Expected total score %d for candidate %d, got %d (%v)", expected, i, score, err)
+		}
+	}
+}
+
+func TestApprovalElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	electionData, keys := utils.CreateApprovalElection("Approval Contract", []string{"Alice", "Bob", "Carol"}, 0, 0)
+	electionData.StartTime = time.Now().Add(-1 * time.Hour)
+	electionData.EndTime = time.Now().Add(2 * time.Second)
+
+	badScoring := *electionData
+	badScoring.ID = "bad-scoring"
+	badScoring.MaxScore = 3
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, electionData)
+	badTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &badScoring)
+	node.TransactionPool = append(node.TransactionPool, createTx, badTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, badTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	var voteTxs []*blockchain.Transaction
+	for voterID, values := range map[string][]int{"voter-1": {1, 1, 0}, "voter-2": {1, 0, 0}} {
+		tx, _ := utils.CreateVoteTransaction(electionData.ID, utils.CreateApprovalBallot(t, electionData, voterID, values))
+		voteTxs = append(voteTxs, tx)
+	}
+	plurality, _ := utils.CreateTestVote(electionData, "Alice")
+	pluralityTx, _ := utils.CreateVoteTransaction(electionData.ID, plurality)
+
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, pluralityTx)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, pluralityTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// The approvals of each candidate are proven from the ballots, so a
+	// proposal cannot claim more of them
+	es, _ := runtime.Election(electionData.ID)
+	proposal, err := smartcontracts.NewProposalPayload(es, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	inflated := *proposal
+	inflated.Values = []int64{3, 1, 0}
+	inflatedTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, &inflated)
+	node.TransactionPool = append(node.TransactionPool, inflatedTx)
+	node.CreateBlock()
+	expectReceipt(t, node, inflatedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	tally, _ := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(electionData.ID)
+	if !reflect.DeepEqual(es.Results, map[string]int{"Alice": 2, "Bob": 1, "Carol": 0}) {
+		t.Errorf("Expected results Alice=2 Bob=1 Carol=0, got %v", es.Results)
+	}
+	if es.Outcome == nil || len(es.Outcome.Elected) != 1 || es.Outcome.Elected[0] != "Alice" {
+		t.Errorf("Expected Alice to be elected, got %+v", es.Outcome)
+	}
+}
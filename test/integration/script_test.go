@@ -0,0 +1,158 @@
+package integration
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/pkg/smartcontracts/script"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// thresholdScript elects every candidate with at least 30% of the votes,
+// most votes first, and fails if nobody reaches the threshold.
+const thresholdScript = `
+; candidates reaching the threshold, in descending order of votes
+(define total (sum (map (lambda (c) (get votes c 0)) candidates)))
+(define passed
+  (filter (lambda (c) (>= (* 100 (get votes c 0)) (* 30 total))) candidates))
+(if (= (len passed) 0)
+    (error "no candidate reached the threshold")
+    (sort-by (lambda (c) (- (get votes c 0))) passed))
+`
+
+func TestScriptInterpreter(t *testing.T) {
+	program, err := script.Parse(thresholdScript)
+	if err != nil {
+		t.Fatalf("Failed to parse script: %v", err)
+	}
+
+	globals := map[string]script.Value{
+		"votes":      script.Map{"Alice": int64(40), "Bob": int64(25), "Charlie": int64(35)},
+		"candidates": script.List{"Alice", "Bob", "Charlie"},
+	}
+
+	var firstSteps uint64
+	for i := 0; i < 3; i++ {
+		result, steps, err := program.Run(globals, script.DefaultLimits())
+		if err != nil {
+			t.Fatalf("Script failed: %v", err)
+		}
+		elected, _ := result.(script.List)
+		if len(elected) != 2 || elected[0] != "Alice" || elected[1] != "Charlie" {
+			t.Errorf("Expected [Alice Charlie], got %v", result)
+		}
+		if i == 0 {
+			firstSteps = steps
+		} else if steps != firstSteps {
+			t.Errorf("Step count not deterministic: %d vs %d", steps, firstSteps)
+		}
+	}
+
+	// Runs are bounded by the step limit, including allocations
+	loop, _ := script.Parse(`(define f (lambda (n) (f (+ n 1)))) (f 0)`)
+	if _, _, err := loop.Run(nil, script.Limits{MaxSteps: 10000, MaxDepth: 1 << 20}); !errors.Is(err, script.ErrStepLimit) {
+		t.Errorf("Expected step limit error for unbounded recursion, got %v", err)
+	}
+	if _, _, err := loop.Run(nil, script.DefaultLimits()); err == nil || !strings.Contains(err.Error(), "depth") {
+		t.Errorf("Expected depth limit error for deep recursion, got %v", err)
+	}
+	huge, _ := script.Parse(`(range 1000000000)`)
+	if _, _, err := huge.Run(nil, script.DefaultLimits()); !errors.Is(err, script.ErrStepLimit) {
+		t.Errorf("Expected step limit error for huge allocation, got %v", err)
+	}
+
+	// Builtins that walk or copy lists pay per element, so a cheap-looking
+	// loop over a deep comparison still runs out of steps
+	deepEqual, _ := script.Parse(`(define big (range 2000)) (map (lambda (i) (= big big)) (range 2000))`)
+	if _, _, err := deepEqual.Run(nil, script.DefaultLimits()); !errors.Is(err, script.ErrStepLimit) {
+		t.Errorf("Expected step limit error for repeated deep comparisons, got %v", err)
+	}
+	deepContains, _ := script.Parse(`(define big (range 2000)) (map (lambda (i) (contains big -1)) (range 2000))`)
+	if _, _, err := deepContains.Run(nil, script.DefaultLimits()); !errors.Is(err, script.ErrStepLimit) {
+		t.Errorf("Expected step limit error for repeated list searches, got %v", err)
+	}
+
+	// Integer overflow fails instead of wrapping
+	overflow, _ := script.Parse(`(* 9223372036854775807 2)`)
+	if _, _, err := overflow.Run(nil, script.DefaultLimits()); err == nil {
+		t.Error("Expected integer overflow to fail")
+	}
+
+	// Scripts only see what they are given
+	if _, err := script.Parse(`(+ 1`); err == nil {
+		t.Error("Expected syntax error for unclosed expression")
+	}
+	undefined, _ := script.Parse(`(read-file "/etc/passwd")`)
+	if _, _, err := undefined.Run(nil, script.DefaultLimits()); err == nil {
+		t.Error("Expected undefined symbol error")
+	}
+}
+
+func TestTallyScriptOutcome(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	keys := make(map[string]*crypto.KeyPair)
+	newElection := func(id, src string) *election.Election {
+		e, electionKeys := utils.CreateTestElection(id, []string{"Alice", "Bob", "Charlie"})
+		e.ID = id
+		keys[id] = electionKeys
+		e.StartTime = time.Now().Add(-2 * time.Hour)
+		e.EndTime = time.Now().Add(-1 * time.Hour)
+		e.Rules = election.RuleConfig{Script: src}
+		return e
+	}
+
+	scripted := newElection("scripted", thresholdScript)
+	failing := newElection("failing", `(if (< ballots 10) (error "quorum not reached") candidates)`)
+	plurality := newElection("plurality", "")
+	broken := newElection("broken", "(filter")
+
+	var creates []*blockchain.Transaction
+	for _, e := range []*election.Election{scripted, failing, plurality, broken} {
+		tx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+		creates = append(creates, tx)
+	}
+	node.TransactionPool = append(node.TransactionPool, creates...)
+	node.CreateBlock()
+	expectReceipt(t, node, creates[3], blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+
+	// No ballots were counted, so exercise the scripts with zero results
+	tallies := make([]*blockchain.Transaction, 3)
+	for i, id := range []string{scripted.ID, failing.ID, plurality.ID} {
+		es, _ := runtime.Election(id)
+		tallies[i] = utils.CreateProposalTransaction(t, es, keys[id])
+	}
+	node.TransactionPool = append(node.TransactionPool, tallies...)
+	node.CreateBlock()
+
+	// With no votes every candidate passes the 30% threshold of zero
+	expectReceipt(t, node, tallies[0], blockchain.ReceiptApplied, "")
+	es, _ := runtime.Election(scripted.ID)
+	if es.Outcome == nil || len(es.Outcome.Elected) != 3 {
+		t.Errorf("Expected scripted outcome to elect all candidates, got %+v", es.Outcome)
+	}
+	receipt, _ := node.Receipt(tallies[0].ID)
+	if receipt.Cost == 0 {
+		t.Error("Expected script execution to be metered in the receipt")
+	}
+
+	// A failing script rejects the tally, leaving the election open to a
+	// later tally
+	expectReceipt(t, node, tallies[1], blockchain.ReceiptRejected, smartcontracts.ErrCodeScriptFailed)
+	if es, _ := runtime.Election(failing.ID); es.Status == smartcontracts.ElectionTallied {
+		t.Error("Expected election with failing script to remain untallied")
+	}
+
+	expectReceipt(t, node, tallies[2], blockchain.ReceiptApplied, "")
+	es, _ = runtime.Election(plurality.ID)
+	if es.Outcome == nil || len(es.Outcome.Elected) != 0 {
+		t.Errorf("Expected nobody elected without votes, got %+v", es.Outcome)
+	}
+}
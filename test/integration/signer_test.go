@@ -0,0 +1,138 @@
+package integration
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/signer"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestLocalSignerDoubleSign(t *testing.T) {
+	key := mustBLSKeys(t)
+	s := crypto.NewLocalSigner(key)
+
+	sig, err := s.SignCommit("test-chain", 1, []byte("block-a"))
+	if err != nil {
+		t.Fatalf("Failed to sign commit: %v", err)
+	}
+	if !crypto.BLSVerify(key.PublicKey, crypto.CommitMessage("test-chain", 1, []byte("block-a")), sig) {
+		t.Error("Expected the commit signature to verify")
+	}
+	if _, err := s.SignCommit("test-chain", 1, []byte("block-a")); err != nil {
+		t.Errorf("Expected the same block to be signed again, got %v", err)
+	}
+	if _, err := s.SignCommit("test-chain", 1, []byte("block-b")); !errors.Is(err, crypto.ErrDoubleSign) {
+		t.Errorf("Expected a second block at height 1 to be refused, got %v", err)
+	}
+	if _, err := s.SignCommit("other-chain", 1, []byte("block-b")); err != nil {
+		t.Errorf("Expected heights of another chain to be independent, got %v", err)
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key := mustBLSKeys(t)
+	statePath := filepath.Join(t.TempDir(), "signer-state.json")
+	daemon, err := signer.NewDaemon(key, statePath)
+	if err != nil {
+		t.Fatalf("Failed to start signer: %v", err)
+	}
+	server := httptest.NewServer(daemon.Handler())
+	defer server.Close()
+
+	remote, err := signer.NewRemote(server.URL, server.Client())
+	if err != nil {
+		t.Fatalf("Failed to connect to signer: %v", err)
+	}
+	if !bytes.Equal(crypto.MarshalG2(remote.PublicKey()), crypto.MarshalG2(key.PublicKey)) {
+		t.Fatal("Expected the remote signer to report the daemon's key")
+	}
+
+	// A validator signs through the daemon
+	params := blockchain.DefaultConsensusParams()
+	params.Validators = []blockchain.ValidatorKey{blockchain.NewValidatorKey(key)}
+	node := utils.SetupTestNode()
+	node.Params = params
+	node.Signer = remote
+
+	e, _ := utils.CreateTestElection("Remote Signer Election", []string{"Alice", "Bob"})
+	tx, _ := utils.CreateElectionTransaction(e)
+	node.TransactionPool = append(node.TransactionPool, tx)
+	block := node.CreateBlock()
+	if !node.IsFinal(block.Index) {
+		t.Fatal("Expected the block to be final with the remote signer's commit")
+	}
+
+	// A second node misconfigured with the same key cannot commit to a
+	// conflicting block at the same height
+	twin := utils.SetupTestNode()
+	twin.Params = params
+	twin.Signer = remote
+	other, _ := utils.CreateTestElection("Conflicting Election", []string{"Alice", "Bob"})
+	otherTx, _ := utils.CreateElectionTransaction(other)
+	twin.TransactionPool = append(twin.TransactionPool, otherTx)
+	conflicting := twin.CreateBlock()
+	if twin.IsFinal(conflicting.Index) {
+		t.Error("Expected the conflicting block not to be committed")
+	}
+	if _, err := twin.SignCommit(conflicting.Index); !errors.Is(err, crypto.ErrDoubleSign) {
+		t.Errorf("Expected the signer to refuse a double sign, got %v", err)
+	}
+
+	// The record of signed heights survives a restart
+	restarted, err := signer.NewDaemon(key, statePath)
+	if err != nil {
+		t.Fatalf("Failed to restart signer: %v", err)
+	}
+	_, err = restarted.Sign(&signer.SignRequest{ChainID: twin.ChainID, Height: conflicting.Index, Hash: conflicting.Hash})
+	if !errors.Is(err, crypto.ErrDoubleSign) {
+		t.Errorf("Expected the restarted signer to refuse a double sign, got %v", err)
+	}
+}
+
+func TestRemoteSignerUnixSocket(t *testing.T) {
+	daemon, err := signer.NewDaemon(mustBLSKeys(t), filepath.Join(t.TempDir(), "signer-state.json"))
+	if err != nil {
+		t.Fatalf("Failed to start signer: %v", err)
+	}
+	addr := "unix:" + filepath.Join(t.TempDir(), "signer.sock")
+	go daemon.Serve(addr)
+
+	var remote *signer.Remote
+	for i := 0; i < 50 && remote == nil; i++ {
+		remote, err = signer.Dial(addr)
+		if err != nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if remote == nil {
+		t.Fatalf("Failed to connect to signer socket: %v", err)
+	}
+	if _, err := remote.SignCommit("test-chain", 1, []byte("block-a")); err != nil {
+		t.Errorf("Failed to sign over the socket: %v", err)
+	}
+	if info, err := os.Stat(strings.TrimPrefix(addr, "unix:")); err != nil {
+		t.Errorf("Failed to stat signer socket: %v", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the socket to be accessible to its owner only, got %v", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(strings.TrimPrefix(addr, "unix:"))); len(entries) != 1 {
+		t.Errorf("Expected only the socket next to it, got %d entries", len(entries))
+	}
+
+	// Callers are not authenticated, so the signer refuses network listeners
+	if err := daemon.Serve("127.0.0.1:0"); err == nil {
+		t.Error("Expected the signer to refuse a TCP address")
+	}
+	if _, err := signer.Dial("127.0.0.1:9"); err == nil {
+		t.Error("Expected dialing a TCP signer to be refused")
+	}
+}
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/beacon"
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+func TestTimeLockEncryption(t *testing.T) {
+	key := mustBLSKeys(t)
+	b := beacon.NewBeacon(key)
+	past := crypto.BeaconRound(time.Now()) - 10
+	secret := big.NewInt(123456789)
+
+	locked, err := crypto.TimeLockEncrypt(key.PublicKey, past, secret)
+	if err != nil {
+		t.Fatalf("Failed to time-lock secret: %v", err)
+	}
+	data, _ := json.Marshal(locked)
+	var decoded crypto.TimeLockCiphertext
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode time-locked secret: %v", err)
+	}
+
+	round, err := b.Release(past)
+	if err != nil {
+		t.Fatalf("Expected a past round to be released: %v", err)
+	}
+	sig, _ := crypto.UnmarshalPoint(round.Signature)
+	if !crypto.BLSVerify(key.PublicKey, crypto.BeaconMessage(past), sig) {
+		t.Fatal("Expected the beacon signature to verify")
+	}
+	opened, err := crypto.TimeLockDecrypt(&decoded, sig)
+	if err != nil || opened.Cmp(secret) != 0 {
+		t.Errorf("Expected to recover %v, got %v (%v)", secret, opened, err)
+	}
+
+	// Another round's signature does not open the secret
+	other, _ := b.Release(past - 1)
+	otherSig, _ := crypto.UnmarshalPoint(other.Signature)
+	if opened, _ := crypto.TimeLockDecrypt(&decoded, otherSig); opened.Cmp(secret) == 0 {
+		t.Error("Expected another round not to open the secret")
+	}
+
+	// Future rounds are withheld
+	future := crypto.BeaconRound(time.Now()) + 60
+	if _, err := b.Release(future); !errors.Is(err, beacon.ErrNotReleased) {
+		t.Errorf("Expected a future round to be withheld, got %v", err)
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/rounds/%d", future), nil)
+	rr := httptest.NewRecorder()
+	b.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooEarly {
+		t.Errorf("Expected status %d for a future round, got %d", http.StatusTooEarly, rr.Code)
+	}
+}
+
+func TestTimeLockedElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+	beaconKey := mustBLSKeys(t)
+	b := beacon.NewBeacon(beaconKey)
+
+	e, keys := utils.CreateApprovalElection("Time-Locked Election", []string{"Alice", "Bob"}, 1, 1)
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	if err := e.LockKey(keys.PrivateKey, beaconKey.PublicKey); err != nil {
+		t.Fatalf("Failed to time-lock election key: %v", err)
+	}
+
+	// The key must open no earlier than voting closes
+	early := *e
+	early.ID += "-early"
+	early.TimeLock = &election.TimeLockConfig{BeaconKey: e.TimeLock.BeaconKey, Round: e.TimeLock.Round - 60, LockedKey: e.TimeLock.LockedKey}
+	earlyTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &early)
+
+	// The lock must open to the election's own key, or the tally could never
+	// be decrypted
+	wrong := *e
+	wrong.ID += "-wrong"
+	lockedWrong, err := crypto.LockKey(beaconKey.PublicKey, e.TimeLock.Round, mustKeys(t).PrivateKey, e.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to time-lock key: %v", err)
+	}
+	wrong.TimeLock = &election.TimeLockConfig{BeaconKey: e.TimeLock.BeaconKey, Round: e.TimeLock.Round, LockedKey: lockedWrong}
+	wrongKeyTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, &wrong)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, earlyTx, wrongKeyTx, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, earlyTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+	expectReceipt(t, node, wrongKeyTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidElection)
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	voteTx, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, e, "voter-1", []int{1, 0}))
+	node.TransactionPool = append(node.TransactionPool, voteTx)
+	node.CreateBlock()
+	expectReceipt(t, node, voteTx, blockchain.ReceiptApplied, "")
+
+	if _, err := b.Release(e.TimeLock.Round); !errors.Is(err, beacon.ErrNotReleased) {
+		t.Fatalf("Expected the beacon to withhold the round while voting is open, got %v", err)
+	}
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	tallyTx, _ := blockchain.NewTransaction(blockchain.TxProposeResults, smartcontracts.ProposalPayload{ElectionID: e.ID})
+	wrongRound, _ := b.Release(e.TimeLock.Round - 1)
+	wrongTx, _ := blockchain.NewTransaction(blockchain.TxReleaseKey, smartcontracts.KeyReleasePayload{ElectionID: e.ID, Signature: wrongRound.Signature})
+	node.TransactionPool = append(node.TransactionPool, tallyTx, wrongTx)
+	node.CreateBlock()
+	expectReceipt(t, node, tallyTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeKeyLocked)
+	expectReceipt(t, node, wrongTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidKeyRelease)
+
+	round, err := b.Release(e.TimeLock.Round)
+	if err != nil {
+		t.Fatalf("Expected the round to be released once voting closed: %v", err)
+	}
+	releaseTx, _ := blockchain.NewTransaction(blockchain.TxReleaseKey, smartcontracts.KeyReleasePayload{ElectionID: e.ID, Signature: round.Signature})
+	node.TransactionPool = append(node.TransactionPool, releaseTx)
+	node.CreateBlock()
+	expectReceipt(t, node, releaseTx, blockchain.ReceiptApplied, "")
+
+	// Anyone can now decrypt the tally with the released key
+	es, _ := runtime.Election(e.ID)
+	if es.ReleasedKey == nil || es.ReleasedKey.Cmp(keys.PrivateKey) != 0 {
+		t.Fatal("Expected the released key to be the election's private key")
+	}
+	totals, max, _ := es.TallyCiphertexts()
+	if alice, err := crypto.DecryptValue(es.ReleasedKey, totals[0], max); err != nil || alice != 1 {
+		t.Errorf("Expected one vote for Alice, got %d (%v)", alice, err)
+	}
+
+	released := &crypto.KeyPair{PrivateKey: es.ReleasedKey, PublicKey: es.Election.PublicKey}
+	tallyTx = utils.CreateProposalTransaction(t, es, released)
+	node.TransactionPool = append(node.TransactionPool, tallyTx)
+	node.CreateBlock()
+	expectReceipt(t, node, tallyTx, blockchain.ReceiptApplied, "")
+}
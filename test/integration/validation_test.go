@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/bn256"
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// fieldPrime is the prime of the field G1 is defined over.
+var fieldPrime, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+func TestStrictPointDecoding(t *testing.T) {
+	valid := mustKeys(t).PublicKey.Marshal()
+	if _, err := crypto.UnmarshalPoint(valid); err != nil {
+		t.Fatalf("Failed to decode a valid point: %v", err)
+	}
+
+	// The generator (1, y) re-encoded with x + p, which reduces to the same point
+	generator := new(bn256.G1).ScalarBaseMult(big.NewInt(1)).Marshal()
+	nonCanonical := append([]byte(nil), generator...)
+	new(big.Int).Add(big.NewInt(1), fieldPrime).FillBytes(nonCanonical[:32])
+	offCurve := append([]byte(nil), generator...)
+	offCurve[63] ^= 1
+
+	cases := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"identity", make([]byte, 64), crypto.ErrIdentityPoint},
+		{"trailing bytes", append(append([]byte(nil), valid...), 0), crypto.ErrEncodingLength},
+		{"truncated", valid[:63], crypto.ErrEncodingLength},
+		{"non-canonical", nonCanonical, crypto.ErrNonCanonical},
+		{"off the curve", offCurve, crypto.ErrNotOnCurve},
+	}
+	for _, c := range cases {
+		_, err := crypto.UnmarshalPoint(c.data)
+		var decodeErr *crypto.DecodeError
+		if !errors.Is(err, c.err) || !errors.As(err, &decodeErr) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+	}
+
+	key := mustBLSKeys(t)
+	if _, err := crypto.UnmarshalG2(crypto.MarshalG2(key.PublicKey)); err != nil {
+		t.Fatalf("Failed to decode a valid G2 point: %v", err)
+	}
+	if _, err := crypto.UnmarshalG2([]byte{0}); !errors.Is(err, crypto.ErrIdentityPoint) {
+		t.Errorf("Expected the G2 identity to be rejected, got %v", err)
+	}
+	if _, err := crypto.UnmarshalG2(append(crypto.MarshalG2(key.PublicKey), 0)); !errors.Is(err, crypto.ErrEncodingLength) {
+		t.Errorf("Expected trailing bytes after a G2 point to be rejected, got %v", err)
+	}
+}
+
+func TestStrictScalarDecoding(t *testing.T) {
+	keys := mustKeys(t)
+	ct, _, err := crypto.EncryptValueRandom(keys.PublicKey, 1)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	proof, err := crypto.ProveDecryption(keys.PrivateKey, ct, 1, []byte("context"))
+	if err != nil {
+		t.Fatalf("Failed to prove decryption: %v", err)
+	}
+	encoded, _ := json.Marshal(proof)
+	var decoded crypto.DecryptionProof
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode a valid proof: %v", err)
+	}
+
+	// The group order is zero in modular arithmetic, but not its canonical
+	// encoding
+	unreduced := make([]byte, 64)
+	proof.C.FillBytes(unreduced[:32])
+	bn256.Order.FillBytes(unreduced[32:])
+	encoded, _ = json.Marshal(unreduced)
+	if err := json.Unmarshal(encoded, &decoded); !errors.Is(err, crypto.ErrScalarRange) {
+		t.Errorf("Expected an unreduced scalar to be rejected, got %v", err)
+	}
+}
+
+func TestMalformedBallotRejected(t *testing.T) {
+	node := utils.SetupTestNode()
+
+	e, _ := utils.CreateTestElection("Validation Election", []string{"Alice", "Bob"})
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(1 * time.Hour)
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+
+	ballot, _ := utils.CreateTestVote(e, "Alice")
+	cell := ballot.Scores.Cells[0].Marshal()
+	vote := func(cell []byte) *blockchain.Transaction {
+		var raw map[string]interface{}
+		scores, _ := json.Marshal(ballot.Scores)
+		if err := json.Unmarshal(scores, &raw); err != nil {
+			t.Fatalf("Failed to decode ballot: %v", err)
+		}
+		raw["cells"].([]interface{})[0] = cell
+		tx, _ := blockchain.NewTransaction(blockchain.TxCastVote, map[string]interface{}{
+			"election_id": e.ID,
+			"ballot": map[string]interface{}{
+				"voter_id": ballot.VoterID,
+				"scores":   raw,
+			},
+		})
+		tx.Timestamp = createTx.Timestamp + 1
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	identityVote := vote(append(make([]byte, 64), cell[64:]...))
+	paddedVote := vote(append(append([]byte(nil), cell...), 0))
+
+	node.TransactionPool = append(node.TransactionPool, createTx, identityVote, paddedVote)
+	node.CreateBlock()
+
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+	expectReceipt(t, node, identityVote, blockchain.ReceiptRejected, blockchain.ErrCodeInvalidEncoding)
+	expectReceipt(t, node, paddedVote, blockchain.ReceiptRejected, blockchain.ErrCodeInvalidEncoding)
+}
@@ -0,0 +1,149 @@
+package integration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createShareholderVote returns a single-choice board vote weighted by share
+// count.
+func createShareholderVote() (*election.Election, *crypto.KeyPair) {
+	e, keys := utils.CreateApprovalElection("Shareholder Meeting", []string{"Alice", "Bob"}, 1, 1)
+	e.VoterWeights = map[string]int{"fund": 100, "founder": 50, "employee": 10}
+	return e, keys
+}
+
+func TestWeightedBallots(t *testing.T) {
+	e, keys := createShareholderVote()
+	if err := e.ValidateWeights(); err != nil {
+		t.Fatalf("Expected a valid voter registry: %v", err)
+	}
+
+	var ballots []*election.ScoreBallot
+	for voterID, values := range map[string][]int{"fund": {0, 1}, "founder": {1, 0}, "employee": {1, 0}} {
+		ballot, err := election.NewScoreBallot(e, voterID, values)
+		if err != nil {
+			t.Fatalf("Failed to create weighted ballot: %v", err)
+		}
+		if !ballot.Verify(e, voterID) {
+			t.Fatalf("Expected weighted ballot of %s to verify", voterID)
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	// The homomorphic tally reflects share counts
+	totals := election.TallyScores(ballots, len(e.Candidates))
+	for i, expected := range []int64{60, 100} {
+		weight, err := crypto.DecryptValue(keys.PrivateKey, totals[i], 160)
+		if err != nil || weight != expected {
+			t.Errorf("Expected weight %d for candidate %d, got %d (%v)", expected, i, weight, err)
+		}
+	}
+
+	// A ballot encrypted with more weight than the registry grants is rejected
+	inflated := *e
+	inflated.VoterWeights = map[string]int{"employee": 1000}
+	forged, _ := election.NewScoreBallot(&inflated, "employee", []int{1, 0})
+	if forged.Verify(e, "employee") {
+		t.Error("Expected a ballot with inflated weight to fail verification")
+	}
+
+	if _, err := election.NewScoreBallot(e, "outsider", []int{1, 0}); err == nil {
+		t.Error("Expected a voter missing from the registry to be rejected")
+	}
+
+	ranked := *e
+	ranked.BallotType = election.BallotRanked
+	if err := ranked.ValidateWeights(); err == nil {
+		t.Error("Expected weighted ranked ballots to be rejected")
+	}
+	plurality := *e
+	plurality.BallotType = election.BallotPlurality
+	plurality.MinSelections, plurality.MaxSelections = 0, 0
+	if err := plurality.ValidateWeights(); err != nil {
+		t.Errorf("Expected weighted plurality ballots to be accepted: %v", err)
+	}
+
+	// Plurality ballots prove their weight the same way
+	vote, _, err := election.NewPluralityBallot(&plurality, "founder", 0)
+	if err != nil || !vote.Validate(&plurality) {
+		t.Fatalf("Expected weighted plurality ballot to verify: %v", err)
+	}
+	inflatedPlurality := plurality
+	inflatedPlurality.VoterWeights = map[string]int{"employee": 1000}
+	forgedVote, _, _ := election.NewPluralityBallot(&inflatedPlurality, "employee", 0)
+	if forgedVote.Validate(&plurality) {
+		t.Error("Expected a plurality ballot with inflated weight to fail verification")
+	}
+
+	// Total weight is bounded so the largest tally still decrypts quickly
+	heavy := *e
+	heavy.VoterWeights = map[string]int{"fund": 1 << 20, "founder": 1}
+	if err := heavy.ValidateWeights(); err == nil {
+		t.Error("Expected a total weight beyond the decryptable range to be rejected")
+	}
+	max := int64(1<<20) * 100
+	ct, _, _ := crypto.EncryptValueRandom(keys.PublicKey, max-7)
+	if value, err := crypto.DecryptValue(keys.PrivateKey, ct, max); err != nil || value != max-7 {
+		t.Errorf("Expected to decrypt %d, got %d (%v)", max-7, value, err)
+	}
+	if _, err := crypto.DecryptValue(keys.PrivateKey, ct, max-8); err == nil {
+		t.Error("Expected a plaintext above max to be reported")
+	}
+}
+
+func TestWeightedElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	e, keys := createShareholderVote()
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	var voteTxs []*blockchain.Transaction
+	for voterID, values := range map[string][]int{"fund": {0, 1}, "employee": {1, 0}} {
+		tx, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, e, voterID, values))
+		voteTxs = append(voteTxs, tx)
+	}
+	unweighted := *e
+	unweighted.VoterWeights = nil
+	outsiderTx, _ := utils.CreateVoteTransaction(e.ID, utils.CreateApprovalBallot(t, &unweighted, "outsider", []int{1, 0}))
+
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, outsiderTx)
+	node.CreateBlock()
+	for _, tx := range voteTxs {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, outsiderTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	// Results are weights, proven from the weighted cells: 110 shares were
+	// cast
+	es, _ := runtime.Election(e.ID)
+	tally := utils.CreateProposalTransaction(t, es, keys)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if !reflect.DeepEqual(es.Results, map[string]int{"Alice": 10, "Bob": 100}) {
+		t.Errorf("Expected results Alice=10 Bob=100, got %v", es.Results)
+	}
+	if es.CastWeight() != 110 || es.Outcome.Elected[0] != "Bob" {
+		t.Errorf("Expected Bob to win with 110 shares cast, got %d and %v", es.CastWeight(), es.Outcome.Elected)
+	}
+}
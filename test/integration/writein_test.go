@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+	"github.com/koushamad/election-system/test/utils"
+)
+
+// createMayoralElection returns a single-choice election with a write-in
+// option after Alice and Bob.
+func createMayoralElection() (*election.Election, *crypto.KeyPair) {
+	e, keys := utils.CreateApprovalElection("Mayoral Election", []string{"Alice", "Bob"}, 0, 1)
+	e.WriteIn = true
+	e.Candidates = append(e.Candidates, election.WriteInCandidate())
+	return e, keys
+}
+
+// writeInBallot creates a ballot selecting the candidate at index, or
+// nobody when index is negative, with a sealed write-in name.
+func writeInBallot(t *testing.T, e *election.Election, voterID string, index int, name string) *election.Ballot {
+	values := make([]int, len(e.Candidates))
+	if index >= 0 {
+		values[index] = 1
+	}
+	ballot := utils.CreateApprovalBallot(t, e, voterID, values)
+	sealed, err := e.SealWriteIn(voterID, name)
+	if err != nil {
+		t.Fatalf("Failed to seal write-in: %v", err)
+	}
+	ballot.WriteIn = sealed
+	return ballot
+}
+
+func TestWriteIns(t *testing.T) {
+	e, keys := createMayoralElection()
+	if err := e.ValidateWriteIn(); err != nil {
+		t.Fatalf("Expected a valid write-in election: %v", err)
+	}
+	writeIn := e.WriteInIndex()
+
+	ballots := []*election.Ballot{
+		writeInBallot(t, e, "voter-1", writeIn, "  Jane   Smith "),
+		writeInBallot(t, e, "voter-2", writeIn, "jane smith."),
+		writeInBallot(t, e, "voter-3", 0, "Secret Name"), // Not selected, so never opened
+		writeInBallot(t, e, "voter-4", writeIn, "alice"),
+	}
+	for _, ballot := range ballots {
+		if err := e.CheckSealedWriteIn(ballot.WriteIn); err != nil {
+			t.Fatalf("Expected a well-formed sealed write-in: %v", err)
+		}
+	}
+	if len(ballots[0].WriteIn.Ciphertext) != len(writeInBallot(t, e, "voter-5", -1, "").WriteIn.Ciphertext) {
+		t.Error("Expected sealed write-ins to have the same size whatever their name")
+	}
+
+	names, err := e.DecryptWriteIns(keys.PrivateKey, ballots)
+	if err != nil {
+		t.Fatalf("Failed to decrypt write-ins: %v", err)
+	}
+	if expected := map[string]int{"Jane Smith": 1, "jane smith.": 1, "alice": 1}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected write-ins %v, got %v", expected, names)
+	}
+
+	groups := e.GroupWriteIns(names)
+	expected := []election.WriteInGroup{
+		{Name: "Jane Smith", Count: 2, Variants: []string{"jane smith."}},
+		{Name: "alice", Count: 1, Candidate: "Alice"},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected groups %+v, got %+v", expected, groups)
+	}
+
+	// Write-ins are bound to their ballot's voter
+	if _, err := e.OpenWriteIn(keys.PrivateKey, "voter-2", ballots[0].WriteIn); err == nil {
+		t.Error("Expected a write-in moved to another voter's ballot not to open")
+	}
+	if _, err := e.SealWriteIn("voter-1", strings.Repeat("x", election.MaxWriteInLength+1)); err == nil {
+		t.Error("Expected an overlong write-in to be rejected")
+	}
+
+	ranked := *e
+	ranked.BallotType = election.BallotRanked
+	if err := ranked.ValidateWriteIn(); err == nil {
+		t.Error("Expected write-ins on ranked ballots to be rejected")
+	}
+}
+
+func TestWriteInElectionContract(t *testing.T) {
+	node := utils.SetupTestNode()
+	runtime := node.Executor.(*smartcontracts.Runtime)
+
+	// Sealed write-ins are opened ballot by ballot, so the officials sign
+	// the tally instead of proving it
+	e, keys := createMayoralElection()
+	e.StartTime = time.Now().Add(-1 * time.Hour)
+	e.EndTime = time.Now().Add(2 * time.Second)
+	officials := utils.TrustTally(t, e, 1)
+
+	createTx, _ := blockchain.NewTransaction(blockchain.TxCreateElection, e)
+	node.TransactionPool = append(node.TransactionPool, createTx)
+	node.CreateBlock()
+	expectReceipt(t, node, createTx, blockchain.ReceiptApplied, "")
+
+	var voteTxs []*blockchain.Transaction
+	for voterID, name := range map[string]string{"voter-1": "Jane Smith", "voter-2": "JANE SMITH"} {
+		tx, _ := utils.CreateVoteTransaction(e.ID, writeInBallot(t, e, voterID, e.WriteInIndex(), name))
+		voteTxs = append(voteTxs, tx)
+	}
+	aliceTx, _ := utils.CreateVoteTransaction(e.ID, writeInBallot(t, e, "voter-3", 0, ""))
+	unsealed := writeInBallot(t, e, "voter-4", 1, "")
+	unsealed.WriteIn = nil
+	unsealedTx, _ := utils.CreateVoteTransaction(e.ID, unsealed)
+	node.TransactionPool = append(node.TransactionPool, voteTxs...)
+	node.TransactionPool = append(node.TransactionPool, aliceTx, unsealedTx)
+	node.CreateBlock()
+	for _, tx := range append(voteTxs, aliceTx) {
+		expectReceipt(t, node, tx, blockchain.ReceiptApplied, "")
+	}
+	expectReceipt(t, node, unsealedTx, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidBallot)
+
+	time.Sleep(3 * time.Second) // Wait for the voting window to close
+
+	es, _ := runtime.Election(e.ID)
+	names, err := es.Election.DecryptWriteIns(keys.PrivateKey, es.Ballots)
+	if err != nil {
+		t.Fatalf("Failed to decrypt write-ins: %v", err)
+	}
+
+	mismatched, _ := blockchain.NewTransaction(blockchain.TxTallyVotes, smartcontracts.TallyPayload{
+		ElectionID: e.ID,
+		Results:    map[string]int{"Alice": 1, "Bob": 0, "Write-in": 2},
+		WriteIns:   map[string]int{"Jane Smith": 1},
+	})
+	node.TransactionPool = append(node.TransactionPool, mismatched)
+	node.CreateBlock()
+	expectReceipt(t, node, mismatched, blockchain.ReceiptRejected, smartcontracts.ErrCodeInvalidTally)
+
+	tally := utils.CreateTallyTransaction(t, es, smartcontracts.TallyPayload{
+		Results:  map[string]int{"Alice": 1, "Bob": 0, "Write-in": 2},
+		WriteIns: names,
+	}, officials)
+	node.TransactionPool = append(node.TransactionPool, tally)
+	node.CreateBlock()
+	expectReceipt(t, node, tally, blockchain.ReceiptApplied, "")
+
+	es, _ = runtime.Election(e.ID)
+	if groups := es.Outcome.WriteIns; len(groups) != 1 || groups[0].Count != 2 {
+		t.Errorf("Expected both write-ins grouped under one name, got %+v", groups)
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/koushamad/election-system/pkg/blockchain"
+	"github.com/koushamad/election-system/pkg/crypto"
+	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
+)
+
+// CreateApprovalElection creates an approval election allowing between
+// minSelections and maxSelections approvals per ballot
+func CreateApprovalElection(name string, candidates []string, minSelections, maxSelections int) (*election.Election, *crypto.KeyPair) {
+	e, keys := CreateTestElection(name, candidates)
+	e.BallotType = election.BallotApproval
+	e.MinSelections, e.MaxSelections = minSelections, maxSelections
+	return e, keys
+}
+
+// CreateApprovalBallot creates a proven approval ballot of voterID
+func CreateApprovalBallot(t testing.TB, e *election.Election, voterID string, values []int) *election.Ballot {
+	t.Helper()
+
+	scores, err := election.NewScoreBallot(e, voterID, values)
+	if err != nil {
+		t.Fatalf("Failed to create approval ballot: %v", err)
+	}
+	return &election.Ballot{VoterID: voterID, Type: election.BallotApproval, Scores: scores}
+}
+
+// TrustTally gives e a trusted tally signed by n officials, all of whom are
+// needed for the quorum, and returns the officials' keys
+func TrustTally(t testing.TB, e *election.Election, n int) []*crypto.BLSKeyPair {
+	t.Helper()
+
+	officials := make([]*crypto.BLSKeyPair, n)
+	e.Certification = &election.CertificationConfig{Quorum: n}
+	for i := range officials {
+		key, err := crypto.GenerateBLSKeys()
+		if err != nil {
+			t.Fatalf("Failed to generate official key: %v", err)
+		}
+		officials[i] = key
+		e.Certification.Officials = append(e.Certification.Officials, election.NewOfficial(fmt.Sprintf("official-%d", i+1), key))
+	}
+	e.TrustedTally = true
+	return officials
+}
+
+// CreateTallyTransaction creates a tally_votes transaction for es signed by
+// officials, the first officials of the election
+func CreateTallyTransaction(t testing.TB, es *smartcontracts.ElectionState, payload smartcontracts.TallyPayload, officials []*crypto.BLSKeyPair) *blockchain.Transaction {
+	t.Helper()
+
+	payload.ElectionID = es.Election.ID
+	payload.Certificate = crypto.NewMultiSignature(len(es.Election.Certification.Officials))
+	for i, official := range officials {
+		sig, err := smartcontracts.SignTally(es, &payload, official.PrivateKey)
+		if err != nil {
+			t.Fatalf("Failed to sign tally: %v", err)
+		}
+		if err := payload.Certificate.Add(i, sig); err != nil {
+			t.Fatalf("Failed to add tally signature: %v", err)
+		}
+	}
+	tx, err := blockchain.NewTransaction(blockchain.TxTallyVotes, payload)
+	if err != nil {
+		t.Fatalf("Failed to create tally transaction: %v", err)
+	}
+	return tx
+}
+
+// CreateProposalTransaction creates a propose_results transaction decrypting
+// the tally of es with the election's private key
+func CreateProposalTransaction(t testing.TB, es *smartcontracts.ElectionState, keys *crypto.KeyPair) *blockchain.Transaction {
+	t.Helper()
+
+	proposal, err := smartcontracts.NewProposalPayload(es, keys.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to propose results: %v", err)
+	}
+	tx, err := blockchain.NewTransaction(blockchain.TxProposeResults, proposal)
+	if err != nil {
+		t.Fatalf("Failed to create proposal transaction: %v", err)
+	}
+	return tx
+}
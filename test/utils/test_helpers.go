@@ -1,15 +1,13 @@
 package utils
 
 import (
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"github.com/koushamad/election-system/pkg/blockchain"
 	"github.com/koushamad/election-system/pkg/crypto"
 	"github.com/koushamad/election-system/pkg/election"
+	"github.com/koushamad/election-system/pkg/smartcontracts"
 	"time"
-
-	"github.com/cloudflare/bn256"
 )
 
 // SetupTestBlockchain creates a blockchain with genesis block for testing
@@ -17,15 +15,21 @@ func SetupTestBlockchain() *blockchain.Chain {
 	return blockchain.NewChain()
 }
 
-// SetupTestNode creates a node with initialized blockchain for testing
+// SetupTestNode creates a node with initialized blockchain for testing,
+// executing blocks with the election contract runtime like a real node
 func SetupTestNode() *blockchain.Node {
-	return blockchain.NewNode()
+	node := blockchain.NewNode()
+	node.Executor = smartcontracts.NewRuntime()
+	return node
 }
 
 // CreateTestElection creates an election for testing purposes
 func CreateTestElection(name string, candidates []string) (*election.Election, *crypto.KeyPair) {
 	// Generate election keys
-	electionKeys := crypto.GenerateKeys()
+	electionKeys, err := crypto.GenerateKeys()
+	if err != nil {
+		panic(err)
+	}
 
 	// Create candidate objects
 	electionCandidates := make([]election.Candidate, len(candidates))
@@ -54,43 +58,31 @@ func CreateTestElection(name string, candidates []string) (*election.Election, *
 // CreateTestVote creates a test vote for a specific candidate
 func CreateTestVote(electionData *election.Election, candidateName string) (*election.Ballot, error) {
 	// Find candidate index
-	candidateIndex := 0
-	found := false
+	candidateIndex := -1
 	for i, candidate := range electionData.Candidates {
 		if candidate.Name == candidateName {
-			candidateIndex = i + 1 // 1-based index for voting
-			found = true
+			candidateIndex = i
 			break
 		}
 	}
 
-	if !found {
+	if candidateIndex < 0 {
 		return nil, fmt.Errorf("candidate '%s' not found", candidateName)
 	}
 
 	// Generate voter keys
-	voterKeys := crypto.GenerateKeys()
-
-	// Create encrypted vote
-	ciphertext, err := crypto.EncryptVote(electionData.PublicKey, candidateIndex)
+	voterKeys, err := crypto.GenerateKeys()
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate random value for encryption (normally this would be saved)
-	r, _ := rand.Int(rand.Reader, bn256.Order)
-
-	// Generate zero-knowledge proof
-	proof := crypto.GenerateVoteProof(ciphertext, r, candidateIndex)
-
-	// Create ballot
-	ballot := election.NewBallot(
-		ciphertext,
-		proof,
+	// Encrypt the vote with its proof
+	ballot, _, err := election.NewPluralityBallot(
+		electionData,
 		fmt.Sprintf("%x", voterKeys.PublicKey.Marshal()[:8]),
+		candidateIndex,
 	)
-
-	return ballot, nil
+	return ballot, err
 }
 
 // test/utils/test_helpers.go (partial update)
@@ -102,6 +94,7 @@ func CreateElectionTransaction(electionData *election.Election) (*blockchain.Tra
 
 	tx := &blockchain.Transaction{
 		ID:        blockchain.GenerateUUID(),
+		ChainID:   blockchain.DefaultChainID,
 		Type:      blockchain.TxCreateElection,
 		Payload:   electionJSON,
 		Timestamp: time.Now().Unix(),
@@ -127,8 +120,11 @@ func CreateVoteTransaction(electionID string, ballot *election.Ballot) (*blockch
 	}
 
 	tx := &blockchain.Transaction{
-		Type:    blockchain.TxCastVote,
-		Payload: voteJSON,
+		ID:        blockchain.GenerateUUID(),
+		ChainID:   blockchain.DefaultChainID,
+		Type:      blockchain.TxCastVote,
+		Payload:   voteJSON,
+		Timestamp: time.Now().Unix(),
 	}
 
 	tx.Hash = tx.CalculateHash()